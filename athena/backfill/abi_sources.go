@@ -0,0 +1,64 @@
+package backfill
+
+import (
+	"fmt"
+
+	"github.com/BlocSoc-iitr/Athena/athena/backfill/importers"
+	"github.com/BlocSoc-iitr/Athena/athena/types"
+)
+
+// DefaultEtherscanRequestsPerSecond is the rate limit of the free Etherscan API tier.
+const DefaultEtherscanRequestsPerSecond = 5
+
+// AbiSourceConfig holds the settings of the ABI sources. Unset URLs fall back to the network defaults.
+type AbiSourceConfig struct {
+	RPCURL            string
+	BaseURL           string
+	APIKey            string
+	RequestsPerSecond float64
+	Dir               string
+}
+
+// NewAbiSource creates the ABI source of a data source for a network.
+func NewAbiSource(dataSource types.DataSources, network Network, config AbiSourceConfig) (importers.AbiSource, error) {
+	switch dataSource {
+	case types.JSONRPC:
+		if network != Starknet {
+			return nil, fmt.Errorf("ABIs of %s contracts are not available from JSON-RPC", network)
+		}
+		rpcURL := config.RPCURL
+		if rpcURL == "" {
+			defaultRPC, err := Default_rpc(network)
+			if err != nil {
+				return nil, err
+			}
+			rpcURL = defaultRPC
+		}
+		return importers.NewJSONRPCAbiSource(rpcURL), nil
+	case types.Etherscan:
+		baseURL := config.BaseURL
+		if baseURL == "" {
+			defaultURL, err := Etherscan_base_url(network)
+			if err != nil {
+				return nil, err
+			}
+			baseURL = defaultURL
+		}
+		requestsPerSecond := config.RequestsPerSecond
+		if requestsPerSecond == 0 {
+			requestsPerSecond = DefaultEtherscanRequestsPerSecond
+		}
+		return importers.NewEtherscanAbiSource(baseURL, config.APIKey, requestsPerSecond), nil
+	case types.LocalFile:
+		if config.Dir == "" {
+			return nil, fmt.Errorf("a directory is required for local ABI files")
+		}
+		decoderOS := importers.EVMDecoderOS
+		if network == Starknet {
+			decoderOS = importers.StarknetDecoderOS
+		}
+		return importers.NewFileAbiSource(config.Dir, decoderOS), nil
+	default:
+		return nil, fmt.Errorf("data source %s is not supported", dataSource)
+	}
+}
@@ -0,0 +1,130 @@
+package backfill
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BlocSoc-iitr/Athena/athena/backfill/importers"
+)
+
+// BlockFlagUsage describes the values accepted by ResolveBlockFlag, for CLI flag help texts.
+const BlockFlagUsage = "a block number, latest, finalized, safe, pending or an offset such as latest-1000"
+
+// ResolveBlockFlag resolves the value of a CLI block flag: a block number, a block identifier,
+// or a block identifier with a negative offset such as latest-1000.
+func ResolveBlockFlag(ctx context.Context, network Network, rpcURL string, value string) (uint64, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if blockNumber, err := strconv.ParseUint(value, 10, 64); err == nil {
+		return blockNumber, nil
+	}
+
+	identifier, offset := value, uint64(0)
+	if i := strings.Index(value, "-"); i >= 0 {
+		parsed, err := strconv.ParseUint(value[i+1:], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid block offset in %q", value)
+		}
+		identifier, offset = value[:i], parsed
+	}
+
+	blockNumber, err := ResolveBlockIdentifier(ctx, network, rpcURL, BlockIdentifier(identifier))
+	if err != nil {
+		return 0, err
+	}
+	if offset > blockNumber {
+		return 0, fmt.Errorf("%q is before the first block", value)
+	}
+	return blockNumber - offset, nil
+}
+
+// BlockRangeFlags are the values of the CLI flags selecting the blocks of a backfill. Each end of
+// the range is given either as a block or as a date.
+type BlockRangeFlags struct {
+	FromBlock string
+	ToBlock   string
+	FromDate  string
+	ToDate    string
+}
+
+// Complete reports whether both ends of the range are set.
+func (f BlockRangeFlags) Complete() bool {
+	return (f.FromBlock != "" || f.FromDate != "") && (f.ToBlock != "" || f.ToDate != "")
+}
+
+// ResolveBlockRange resolves the block and date flags of a backfill into block numbers.
+func ResolveBlockRange(ctx context.Context, network Network, rpcURL string, flags BlockRangeFlags) (uint64, uint64, error) {
+	if flags.FromBlock != "" && flags.FromDate != "" {
+		return 0, 0, fmt.Errorf("a from block and a from date cannot both be set")
+	}
+	if flags.ToBlock != "" && flags.ToDate != "" {
+		return 0, 0, fmt.Errorf("a to block and a to date cannot both be set")
+	}
+
+	var fromDate, toDate time.Time
+	var err error
+	if flags.FromDate != "" {
+		if fromDate, err = ParseDateFlag(flags.FromDate, false); err != nil {
+			return 0, 0, err
+		}
+	}
+	if flags.ToDate != "" {
+		if toDate, err = ParseDateFlag(flags.ToDate, true); err != nil {
+			return 0, 0, err
+		}
+	}
+	fromBlock, toBlock, err := ResolveDateRange(ctx, network, rpcURL, fromDate, toDate)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if flags.FromBlock != "" {
+		if fromBlock, err = ResolveBlockFlag(ctx, network, rpcURL, flags.FromBlock); err != nil {
+			return 0, 0, fmt.Errorf("invalid from block: %v", err)
+		}
+	}
+	if flags.ToBlock != "" {
+		if toBlock, err = ResolveBlockFlag(ctx, network, rpcURL, flags.ToBlock); err != nil {
+			return 0, 0, fmt.Errorf("invalid to block: %v", err)
+		}
+	}
+	return fromBlock, toBlock, nil
+}
+
+// ResolveBlockIdentifier returns the block number an identifier points to on an RPC endpoint.
+// Starknet has no safe head, so both safe and finalized are the latest block accepted on L1.
+func ResolveBlockIdentifier(ctx context.Context, network Network, rpcURL string, identifier BlockIdentifier) (uint64, error) {
+	switch identifier {
+	case latest:
+		return currentBlockNumber(ctx, network, rpcURL)
+	case earliest:
+		return 0, nil
+	case pending:
+		blockNumber, err := currentBlockNumber(ctx, network, rpcURL)
+		if err != nil {
+			return 0, err
+		}
+		return blockNumber + 1, nil
+	case safe, finalized:
+		return finalizedBlockNumber(ctx, network, rpcURL, identifier)
+	default:
+		return 0, fmt.Errorf("block identifier %q not supported", identifier)
+	}
+}
+
+func finalizedBlockNumber(ctx context.Context, network Network, rpcURL string, identifier BlockIdentifier) (uint64, error) {
+	switch network {
+	case Starknet:
+		head, err := currentBlockNumber(ctx, network, rpcURL)
+		if err != nil {
+			return 0, err
+		}
+		return importers.GetLatestAcceptedOnL1Block(ctx, rpcURL, head)
+	case Ethereum, ZkSyncEra:
+		return importers.GetEthBlockNumberByTag(ctx, rpcURL, string(identifier))
+	default:
+		return 0, fmt.Errorf("Network not supported")
+	}
+}
@@ -0,0 +1,64 @@
+package backfill
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveBlockFlag(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string        `json:"method"`
+			Params []interface{} `json:"params"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		switch req.Method {
+		case "eth_blockNumber":
+			w.Write([]byte(`{"jsonrpc": "2.0", "id": 1, "result": "0x7d0"}`))
+		case "eth_getBlockByNumber":
+			if req.Params[0] == "safe" {
+				w.Write([]byte(`{"jsonrpc": "2.0", "id": 1, "result": {"number": "0x7b2"}}`))
+				return
+			}
+			w.Write([]byte(`{"jsonrpc": "2.0", "id": 1, "result": {"number": "0x794"}}`))
+		default:
+			t.Errorf("unexpected method %s", req.Method)
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	for value, expected := range map[string]uint64{
+		"1234":          1234,
+		"latest":        2000,
+		"Latest-1000":   1000,
+		"pending":       2001,
+		"earliest":      0,
+		"finalized":     1940,
+		"safe":          1970,
+		"finalized-40":  1900,
+		" latest-2000 ": 0,
+	} {
+		blockNumber, err := ResolveBlockFlag(ctx, Ethereum, server.URL, value)
+		assert.NoError(t, err, value)
+		assert.Equal(t, expected, blockNumber, value)
+	}
+
+	for _, value := range []string{"latest-2001", "latest-x", "head", ""} {
+		_, err := ResolveBlockFlag(ctx, Ethereum, server.URL, value)
+		assert.Error(t, err, value)
+	}
+
+	from, to, err := ResolveBlockRange(ctx, Ethereum, server.URL, BlockRangeFlags{FromBlock: "latest-10", ToBlock: "latest"})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1990), from)
+	assert.Equal(t, uint64(2000), to)
+
+	_, _, err = ResolveBlockRange(ctx, Ethereum, server.URL, BlockRangeFlags{FromBlock: "1", FromDate: "2024-01-01", ToBlock: "2"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,118 @@
+package backfill
+
+import (
+	"reflect"
+	"sort"
+
+	"github.com/BlocSoc-iitr/Athena/athena/backfill/importers"
+	"github.com/BlocSoc-iitr/Athena/athena/database/models"
+)
+
+// ExportSchemaVersion is the version of the exported columns recorded in partition manifests. It is
+// incremented whenever the columns of a declared schema change.
+const ExportSchemaVersion = 1
+
+// CSVSchema is the ordered list of columns of a CSV export.
+type CSVSchema []string
+
+// csvSchemas declares the columns of the rows exported for each model, in the order they are
+// written. They list the keys of the rows built by the importers *ToDicts functions.
+var csvSchemas = map[reflect.Type]CSVSchema{
+	reflect.TypeOf(models.Block{}): {
+		"block_number", "block_hash", "timestamp", "parent_hash", "state_root", "sequencer_address",
+		"l1_gas_price_wei", "l1_gas_price_fri", "l1_data_gas_price_wei", "l1_data_gas_price_fri",
+		"l1_da_mode", "starknet_version", "transaction_count", "total_fee",
+	},
+	reflect.TypeOf(models.Transaction{}): {
+		"transaction_hash", "block_number", "transaction_index", "timestamp", "gas_used", "type",
+		"nonce", "signature", "version", "status", "max_fee", "actual_fee", "fee_unit",
+		"execution_resources", "tip", "resource_bounds", "paymaster_data", "account_deployment_data",
+		"contract_address", "selector", "calldata", "class_hash", "revert_error",
+	},
+	reflect.TypeOf(models.DefaultEvent{}): {
+		"block_number", "transaction_index", "event_index", "contract_address", "class_hash",
+		"event_name", "keys", "data", "decoded_params",
+	},
+	reflect.TypeOf(importers.EmittedEvent{}): {
+		"block_number", "transaction_hash", "block_hash", "contract_address", "keys", "data",
+	},
+	reflect.TypeOf(importers.NamedEvent{}): {
+		"block_number", "block_hash", "transaction_hash", "contract_address", "event_name", "keys",
+		"data", "decoded_params",
+	},
+	reflect.TypeOf(models.ERC20Transfer{}): {
+		"block_number", "transaction_hash", "transaction_index", "event_index", "token_address",
+		"from_address", "to_address", "value",
+	},
+	reflect.TypeOf(models.Trace{}): {
+		"block_number", "transaction_hash", "transaction_index", "trace_type", "trace_address",
+		"call_type", "entry_point_type", "contract_address", "caller_address", "class_hash",
+		"selector", "calldata", "result", "gas_used", "error",
+	},
+	reflect.TypeOf(models.StorageDiff{}):      {"block_number", "contract_address", "storage_key", "storage_value"},
+	reflect.TypeOf(models.DeployedContract{}): {"block_number", "contract_address", "class_hash"},
+	reflect.TypeOf(models.DeclaredClass{}):    {"block_number", "class_hash", "compiled_class_hash"},
+	reflect.TypeOf(models.ReplacedClass{}):    {"block_number", "contract_address", "class_hash"},
+	reflect.TypeOf(models.NonceUpdate{}):      {"block_number", "contract_address", "nonce"},
+	reflect.TypeOf(models.EVMBlock{}): {
+		"block_number", "block_hash", "parent_hash", "timestamp", "miner", "gas_limit", "gas_used",
+		"base_fee_per_gas", "transaction_count",
+	},
+	reflect.TypeOf(models.EVMTransaction{}): {
+		"transaction_hash", "block_number", "transaction_index", "type", "from_address", "to_address",
+		"value", "nonce", "gas", "gas_used", "effective_gas_price", "status", "selector",
+		"contract_address",
+	},
+	reflect.TypeOf(models.EVMLog{}): {
+		"block_number", "transaction_hash", "transaction_index", "log_index", "contract_address",
+		"topics", "data", "event_name", "decoded_params",
+	},
+	reflect.TypeOf(models.ZkSyncBlock{}): {
+		"block_number", "block_hash", "parent_hash", "timestamp", "miner", "gas_limit", "gas_used",
+		"base_fee_per_gas", "transaction_count", "l1_batch_number",
+	},
+	reflect.TypeOf(models.ZkSyncTransaction{}): {
+		"transaction_hash", "block_number", "transaction_index", "type", "from_address", "to_address",
+		"value", "nonce", "gas", "gas_used", "effective_gas_price", "status", "selector",
+		"contract_address", "l1_batch_number", "l1_batch_tx_index",
+	},
+	reflect.TypeOf(models.ZkSyncLog{}): {
+		"block_number", "transaction_hash", "transaction_index", "log_index", "contract_address",
+		"topics", "data", "event_name", "decoded_params",
+	},
+	reflect.TypeOf(models.ZkSyncL2ToL1Log{}): {
+		"block_number", "log_index", "transaction_hash", "transaction_index", "l1_batch_number",
+		"shard_id", "is_service", "sender", "key", "value",
+	},
+}
+
+// CSVSchemaOf returns the declared CSV schema of the rows of model, or nil if none is declared.
+func CSVSchemaOf(model interface{}) CSVSchema {
+	if model == nil {
+		return nil
+	}
+	return csvSchemas[reflect.Indirect(reflect.ValueOf(model)).Type()]
+}
+
+// csvSchemaOfRow is the schema of rows without a declared schema: the columns of the row in
+// alphabetical order.
+func csvSchemaOfRow(row map[string]interface{}) CSVSchema {
+	schema := make(CSVSchema, 0, len(row))
+	for column := range row {
+		schema = append(schema, column)
+	}
+	sort.Strings(schema)
+	return schema
+}
+
+func (s CSVSchema) equal(other CSVSchema) bool {
+	if len(s) != len(other) {
+		return false
+	}
+	for i := range s {
+		if s[i] != other[i] {
+			return false
+		}
+	}
+	return true
+}
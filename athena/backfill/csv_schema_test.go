@@ -0,0 +1,155 @@
+package backfill
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/BlocSoc-iitr/Athena/athena/backfill/importers"
+	"github.com/BlocSoc-iitr/Athena/athena/database/models"
+	"github.com/BlocSoc-iitr/Athena/athena/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readCSV(t *testing.T, fileName string) [][]string {
+	file, err := os.Open(fileName)
+	require.NoError(t, err)
+	defer file.Close()
+	records, err := csv.NewReader(file).ReadAll()
+	require.NoError(t, err)
+	return records
+}
+
+func TestCSVSchemasMatchRows(t *testing.T) {
+	stateDiffs := importers.StateDiffsToDicts(importers.StateDiffRows{
+		StorageDiffs:      []models.StorageDiff{{}},
+		DeployedContracts: []models.DeployedContract{{}},
+		DeclaredClasses:   []models.DeclaredClass{{}},
+		ReplacedClasses:   []models.ReplacedClass{{}},
+		NonceUpdates:      []models.NonceUpdate{{}},
+	})
+	rows := []struct {
+		model interface{}
+		dicts []map[string]interface{}
+	}{
+		{models.Block{}, importers.BlocksToDicts([]models.Block{{}})},
+		{models.Transaction{}, importers.TransactionsToDicts([]models.Transaction{{}})},
+		{models.DefaultEvent{}, importers.EventsToDicts(testEvents(1))},
+		{importers.EmittedEvent{}, importers.EmittedEventsToDicts([]importers.EmittedEvent{{}})},
+		{importers.NamedEvent{}, importers.NamedEventsToDicts([]importers.NamedEvent{{}})},
+		{models.ERC20Transfer{}, importers.TransfersToDicts([]models.ERC20Transfer{{}})},
+		{models.Trace{}, importers.TracesToDicts([]models.Trace{{}})},
+		{models.StorageDiff{}, stateDiffs["storage_diffs"]},
+		{models.DeployedContract{}, stateDiffs["deployed_contracts"]},
+		{models.DeclaredClass{}, stateDiffs["declared_classes"]},
+		{models.ReplacedClass{}, stateDiffs["replaced_classes"]},
+		{models.NonceUpdate{}, stateDiffs["nonce_updates"]},
+		{models.EVMBlock{}, importers.EVMBlocksToDicts([]models.EVMBlock{{}})},
+		{models.EVMTransaction{}, importers.EVMTransactionsToDicts([]models.EVMTransaction{{}})},
+		{models.EVMLog{}, importers.EVMLogsToDicts([]models.EVMLog{{}})},
+		{models.ZkSyncBlock{}, importers.ZkSyncBlocksToDicts([]models.ZkSyncBlock{{}})},
+		{models.ZkSyncTransaction{}, importers.ZkSyncTransactionsToDicts([]models.ZkSyncTransaction{{}})},
+		{models.ZkSyncLog{}, importers.ZkSyncLogsToDicts([]models.ZkSyncLog{{}})},
+		{models.ZkSyncL2ToL1Log{}, importers.ZkSyncL2ToL1LogsToDicts([]models.ZkSyncL2ToL1Log{{}})},
+	}
+	assert.Len(t, rows, len(csvSchemas))
+	for _, row := range rows {
+		schema := CSVSchemaOf(row.model)
+		require.NotNil(t, schema, "%T", row.model)
+		require.Len(t, row.dicts, 1)
+		assert.ElementsMatch(t, csvSchemaOfRow(row.dicts[0]), schema, "%T", row.model)
+	}
+}
+
+func TestCSVEncodeValue(t *testing.T) {
+	exporter := &FileResourceExporter{}
+	u256, _ := new(big.Int).SetString("340282366920938463463374607431768211457", 10)
+	values := []struct {
+		value    interface{}
+		expected string
+	}{
+		{nil, ""},
+		{uint64(18446744073709551615), "18446744073709551615"},
+		{int32(-7), "-7"},
+		{true, "true"},
+		{1e21, "1000000000000000000000"},
+		{0.25, "0.25"},
+		{u256, "340282366920938463463374607431768211457"},
+		{*big.NewInt(5), "5"},
+		{(*big.Int)(nil), ""},
+		{time.Date(2024, 3, 1, 12, 0, 0, 0, time.FixedZone("CET", 3600)), "2024-03-01T11:00:00Z"},
+		{sql.NullString{String: "Transfer", Valid: true}, "Transfer"},
+		{sql.NullInt64{}, ""},
+		{sql.NullFloat64{Float64: 1.5, Valid: true}, "1.5"},
+		{[]byte{0xab, 0x01}, "ab01"},
+		{[]string{"0x1", "0x2"}, `["0x1","0x2"]`},
+		{[]interface{}{uint64(1), "a,b", u256}, `[1,"a,b",340282366920938463463374607431768211457]`},
+		{map[string]interface{}{"to": "0x1"}, `{"to":"0x1"}`},
+		{types.Events, "6"},
+	}
+	for _, v := range values {
+		encoded, err := exporter.CSVEncodeValue(v.value)
+		require.NoError(t, err)
+		assert.Equal(t, v.expected, encoded, "%#v", v.value)
+	}
+}
+
+func TestFileResourceExporter(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "events.csv")
+	events := testEvents(3)
+	events[0].EventName = sql.NullString{String: `Transfer, "v2"`, Valid: true}
+
+	exporter, err := NewResourceExporter(fileName, models.DefaultEvent{}, ExportOptions{})
+	require.NoError(t, err)
+	require.NoError(t, exporter.Write(importers.EventsToDicts(events[:2])))
+	require.NoError(t, exporter.Close())
+
+	exporter, err = NewResourceExporter(fileName, models.DefaultEvent{}, ExportOptions{Append: true})
+	require.NoError(t, err)
+	require.NoError(t, exporter.Write(importers.EventsToDicts(events[2:])))
+	require.NoError(t, exporter.Close())
+
+	records := readCSV(t, fileName)
+	require.Len(t, records, 4)
+	assert.Equal(t, []string(CSVSchemaOf(models.DefaultEvent{})), records[0])
+	assert.Equal(t, []string{"690000", "0", "0", "0x49d3", "", `Transfer, "v2"`, `["0x99","0x1"]`, "[]", `{"value":"1000000000000000000000"}`}, records[1])
+	assert.Equal(t, "690002", records[3][0])
+
+	// Without append, the file is overwritten.
+	exporter, err = NewResourceExporter(fileName, models.DefaultEvent{}, ExportOptions{})
+	require.NoError(t, err)
+	require.NoError(t, exporter.Write(importers.EventsToDicts(events[:1])))
+	require.NoError(t, exporter.Close())
+	assert.Len(t, readCSV(t, fileName), 2)
+}
+
+func TestFileResourceExporterErrors(t *testing.T) {
+	dir := t.TempDir()
+	fileName := filepath.Join(dir, "blocks.csv")
+
+	exporter, err := NewFileResourceExporter(fileName, CSVSchema{"block_number", "block_hash"}, false)
+	require.NoError(t, err)
+	err = exporter.Write([]map[string]interface{}{{"block_number": 1, "miner": "0x1"}})
+	assert.ErrorContains(t, err, "column miner is not in the schema")
+	require.NoError(t, exporter.Write([]map[string]interface{}{{"block_number": 1}}))
+	require.NoError(t, exporter.Close())
+	assert.Equal(t, [][]string{{"block_number", "block_hash"}, {"1", ""}}, readCSV(t, fileName))
+
+	_, err = NewFileResourceExporter(fileName, CSVSchema{"block_hash", "block_number"}, true)
+	assert.ErrorContains(t, err, "does not match the exported columns")
+
+	// Without a schema, appending continues with the header of the file.
+	exporter, err = NewFileResourceExporter(fileName, nil, true)
+	require.NoError(t, err)
+	require.NoError(t, exporter.Write([]map[string]interface{}{{"block_hash": "0x2", "block_number": 2}}))
+	require.NoError(t, exporter.Close())
+	assert.Equal(t, []string{"2", "0x2"}, readCSV(t, fileName)[2])
+
+	_, err = NewFileResourceExporter(filepath.Join(dir, "blocks.txt"), nil, false)
+	assert.Error(t, err)
+}
@@ -0,0 +1,149 @@
+package backfill
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/BlocSoc-iitr/Athena/athena/backfill/pipeline"
+	"github.com/BlocSoc-iitr/Athena/athena/database/models"
+	"github.com/BlocSoc-iitr/Athena/athena/types"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DefaultDBBatchSize is the number of rows per multi-row insert of a DBResourceExporter.
+const DefaultDBBatchSize = 500
+
+// Natural keys the DB exporter upserts rows on. They are primary keys or unique indexes of the models.
+var (
+	blockKey    = []clause.Column{{Name: "block_number"}}
+	txKey       = []clause.Column{{Name: "transaction_hash"}}
+	positionKey = []clause.Column{{Name: "block_number"}, {Name: "transaction_index"}, {Name: "event_index"}}
+	storageKey  = []clause.Column{{Name: "block_number"}, {Name: "contract_address"}, {Name: "storage_key"}}
+	contractKey = []clause.Column{{Name: "block_number"}, {Name: "contract_address"}}
+	classKey    = []clause.Column{{Name: "block_number"}, {Name: "class_hash"}}
+	l2ToL1Key   = []clause.Column{{Name: "block_number"}, {Name: "log_index"}}
+)
+
+// DBResourceExporter exports backfilled rows to the database through the GORM models. Each chunk of
+// blocks is written in one transaction with batched multi-row inserts, and the BackfilledRange of
+// the chunk is recorded in the same transaction, so a range is only marked as backfilled once all
+// its rows are stored. Rows are upserted on their natural keys, so a chunk can be exported again.
+type DBResourceExporter struct {
+	AbstractResourceExporter
+	db         *gorm.DB
+	backfillID string
+	dataType   types.BackfillDataType
+	network    types.SupportedNetwork
+	batchSize  int
+	filterData map[string]interface{}
+}
+
+func NewDBResourceExporter(db *gorm.DB, backfillID string, dataType types.BackfillDataType, network types.SupportedNetwork, batchSize int) *DBResourceExporter {
+	if batchSize <= 0 {
+		batchSize = DefaultDBBatchSize
+	}
+	exporter := &DBResourceExporter{
+		AbstractResourceExporter: AbstractResourceExporter{
+			exportMode: DBModels,
+		},
+		db:         db,
+		backfillID: backfillID,
+		dataType:   dataType,
+		network:    network,
+		batchSize:  batchSize,
+	}
+	exporter.Init()
+	return exporter
+}
+
+// SetFilterData sets the filters stored with the BackfilledRanges of the exporter.
+func (e *DBResourceExporter) SetFilterData(filterData map[string]interface{}) {
+	e.filterData = filterData
+}
+
+// Name implements pipeline.Sink.
+func (e *DBResourceExporter) Name() string {
+	return "db_export"
+}
+
+// Consume writes the rows of a chunk and its BackfilledRange in one transaction. It implements
+// pipeline.Sink.
+func (e *DBResourceExporter) Consume(ctx context.Context, record *pipeline.Record) error {
+	err := e.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		tables := []struct {
+			kind  pipeline.Kind
+			value interface{}
+			key   []clause.Column
+		}{
+			{pipeline.Blocks, record.Blocks, blockKey},
+			{pipeline.Transactions, record.Transactions, txKey},
+			{pipeline.Events, record.Events, positionKey},
+			{pipeline.Transfers, record.Transfers, positionKey},
+			{pipeline.StorageDiffs, record.StateDiffs.StorageDiffs, storageKey},
+			{pipeline.DeployedContracts, record.StateDiffs.DeployedContracts, contractKey},
+			{pipeline.DeclaredClasses, record.StateDiffs.DeclaredClasses, classKey},
+			{pipeline.ReplacedClasses, record.StateDiffs.ReplacedClasses, contractKey},
+			{pipeline.NonceUpdates, record.StateDiffs.NonceUpdates, contractKey},
+			{pipeline.EVMBlocks, record.EVMBlocks, blockKey},
+			{pipeline.EVMTransactions, record.EVMTransactions, txKey},
+			{pipeline.EVMLogs, record.EVMLogs, positionKey},
+			{pipeline.ZkSyncBlocks, record.ZkSyncBlocks, blockKey},
+			{pipeline.ZkSyncTransactions, record.ZkSyncTransactions, txKey},
+			{pipeline.ZkSyncLogs, record.ZkSyncLogs, positionKey},
+			{pipeline.ZkSyncL2ToL1Logs, record.ZkSyncL2ToL1Logs, l2ToL1Key},
+		}
+		for _, table := range tables {
+			if record.Len(table.kind) == 0 {
+				continue
+			}
+			err := tx.Clauses(clause.OnConflict{Columns: table.key, UpdateAll: true}).CreateInBatches(table.value, e.batchSize).Error
+			if err != nil {
+				return fmt.Errorf("failed to write %s to database: %w", table.kind, err)
+			}
+		}
+
+		// Traces are keyed by their JSON trace address, which cannot be indexed, so the traces of
+		// the chunk are replaced instead of upserted.
+		if len(record.Traces) > 0 {
+			err := tx.Where("block_number BETWEEN ? AND ?", record.FromBlock, record.ToBlock).Delete(&models.Trace{}).Error
+			if err != nil {
+				return fmt.Errorf("failed to delete traces from database: %w", err)
+			}
+			if err := tx.CreateInBatches(record.Traces, e.batchSize).Error; err != nil {
+				return fmt.Errorf("failed to write traces to database: %w", err)
+			}
+		}
+
+		metadata := make(map[string]interface{})
+		for _, kind := range pipeline.Kinds {
+			if rows := record.Len(kind); rows > 0 {
+				metadata[string(kind)] = rows
+			}
+		}
+		backfilledRange := models.BackfilledRange{
+			BackfillID:   e.backfillID,
+			DataType:     e.dataType,
+			Network:      e.network,
+			StartBlock:   int(record.FromBlock),
+			EndBlock:     int(record.ToBlock),
+			FilterData:   e.filterData,
+			MetadataDict: metadata,
+		}
+		if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&backfilledRange).Error; err != nil {
+			return fmt.Errorf("failed to record backfilled range: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to export blocks %d to %d: %w", record.FromBlock, record.ToBlock, err)
+	}
+	e.resourcesSaved += record.Rows()
+	return nil
+}
+
+// Close prints the number of exported rows. It implements pipeline.Sink.
+func (e *DBResourceExporter) Close() error {
+	e.AbstractResourceExporter.Close()
+	return nil
+}
@@ -0,0 +1,245 @@
+package backfill
+
+import (
+	"context"
+	"testing"
+
+	"github.com/BlocSoc-iitr/Athena/athena/backfill/importers"
+	"github.com/BlocSoc-iitr/Athena/athena/backfill/pipeline"
+	"github.com/BlocSoc-iitr/Athena/athena/database/models"
+	"github.com/BlocSoc-iitr/Athena/athena/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newExportDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Discard})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.BackfilledRange{}, &models.Block{}, &models.Transaction{},
+		&models.DefaultEvent{}, &models.ERC20Transfer{}, &models.Trace{}))
+	return db
+}
+
+func exportRecord(fromBlock uint64, toBlock uint64, value string) *pipeline.Record {
+	record := &pipeline.Record{FromBlock: fromBlock, ToBlock: toBlock}
+	for number := fromBlock; number <= toBlock; number++ {
+		block := models.Block{StarknetVersion: value}
+		block.BlockNumber = number
+		record.Blocks = append(record.Blocks, block)
+
+		event := models.DefaultEvent{Keys: []string{"0x1"}, Data: []string{value}}
+		event.BlockNumber, event.TransactionIndex, event.EventIndex = number, 0, 0
+		record.Events = append(record.Events, event)
+
+		transfer := models.ERC20Transfer{}
+		transfer.BlockNumber, transfer.Value = number, value
+		record.Transfers = append(record.Transfers, transfer)
+
+		trace := models.Trace{TraceType: value}
+		trace.BlockNumber = number
+		record.Traces = append(record.Traces, trace)
+	}
+	return record
+}
+
+func TestDBResourceExporter(t *testing.T) {
+	db := newExportDB(t)
+	exporter := NewDBResourceExporter(db, "backfill-1", types.Events, types.StarkNet, 2)
+	ctx := context.Background()
+
+	require.NoError(t, exporter.Consume(ctx, exportRecord(10, 14, "first")))
+	require.NoError(t, exporter.Consume(ctx, exportRecord(15, 16, "first")))
+	// Exporting a chunk again updates its rows instead of duplicating them.
+	require.NoError(t, exporter.Consume(ctx, exportRecord(10, 14, "second")))
+	require.NoError(t, exporter.Close())
+
+	var blocks, events, transfers, traces int64
+	db.Model(&models.Block{}).Count(&blocks)
+	db.Model(&models.DefaultEvent{}).Count(&events)
+	db.Model(&models.ERC20Transfer{}).Count(&transfers)
+	db.Model(&models.Trace{}).Count(&traces)
+	assert.Equal(t, []int64{7, 7, 7, 7}, []int64{blocks, events, transfers, traces})
+
+	var event models.DefaultEvent
+	require.NoError(t, db.Where("block_number = ?", 12).First(&event).Error)
+	assert.Equal(t, []string{"second"}, event.Data)
+	var trace models.Trace
+	require.NoError(t, db.Where("block_number = ?", 16).First(&trace).Error)
+	assert.Equal(t, "first", trace.TraceType)
+
+	var ranges []models.BackfilledRange
+	require.NoError(t, db.Order("start_block").Find(&ranges).Error)
+	require.Len(t, ranges, 2)
+	assert.Equal(t, 10, ranges[0].StartBlock)
+	assert.Equal(t, 14, ranges[0].EndBlock)
+	assert.Equal(t, types.Events, ranges[0].DataType)
+	assert.EqualValues(t, 5, ranges[0].MetadataDict["events"])
+}
+
+func TestDBResourceExporterRollback(t *testing.T) {
+	db := newExportDB(t)
+	exporter := NewDBResourceExporter(db, "backfill-1", types.Events, types.StarkNet, 0)
+	record := exportRecord(10, 11, "first")
+	require.NoError(t, db.Migrator().DropTable(&models.Trace{}))
+
+	err := exporter.Consume(context.Background(), record)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to export blocks 10 to 11")
+
+	var blocks, ranges int64
+	db.Model(&models.Block{}).Count(&blocks)
+	db.Model(&models.BackfilledRange{}).Count(&ranges)
+	assert.Zero(t, blocks)
+	assert.Zero(t, ranges)
+}
+
+func TestDBResourceExporterStateDiffs(t *testing.T) {
+	db := newExportDB(t)
+	require.NoError(t, db.AutoMigrate(&models.StorageDiff{}, &models.DeployedContract{}, &models.DeclaredClass{},
+		&models.ReplacedClass{}, &models.NonceUpdate{}))
+	exporter := NewDBResourceExporter(db, "state-diffs", types.StateDiffs, types.StarkNet, 0)
+	record := func(value string) *pipeline.Record {
+		return &pipeline.Record{FromBlock: 1, ToBlock: 2, StateDiffs: importers.StateDiffRows{
+			StorageDiffs: []models.StorageDiff{
+				{BlockNumber: 1, ContractAddress: "0xa", StorageKey: "0x1", StorageValue: value},
+				{BlockNumber: 2, ContractAddress: "0xa", StorageKey: "0x1", StorageValue: value},
+			},
+			DeployedContracts: []models.DeployedContract{{BlockNumber: 1, ContractAddress: "0xa", ClassHash: "0xc1"}},
+			DeclaredClasses:   []models.DeclaredClass{{BlockNumber: 1, ClassHash: "0xc1"}},
+			ReplacedClasses:   []models.ReplacedClass{{BlockNumber: 2, ContractAddress: "0xa", ClassHash: "0xc2"}},
+			NonceUpdates:      []models.NonceUpdate{{BlockNumber: 2, ContractAddress: "0xa", Nonce: value}},
+		}}
+	}
+
+	ctx := context.Background()
+	require.NoError(t, exporter.Consume(ctx, record("0x5")))
+	// Exporting the state diffs again updates their rows instead of duplicating them.
+	require.NoError(t, exporter.Consume(ctx, record("0x6")))
+	require.NoError(t, exporter.Close())
+
+	var diffs []models.StorageDiff
+	require.NoError(t, db.Order("block_number").Find(&diffs).Error)
+	require.Len(t, diffs, 2)
+	assert.Equal(t, "0x6", diffs[0].StorageValue)
+	for _, model := range []interface{}{&models.DeployedContract{}, &models.DeclaredClass{}, &models.ReplacedClass{}, &models.NonceUpdate{}} {
+		var count int64
+		require.NoError(t, db.Model(model).Count(&count).Error)
+		assert.Equal(t, int64(1), count, "%T", model)
+	}
+
+	var ranges []models.BackfilledRange
+	require.NoError(t, db.Find(&ranges).Error)
+	require.Len(t, ranges, 1)
+	assert.Equal(t, types.StateDiffs, ranges[0].DataType)
+	assert.EqualValues(t, 2, ranges[0].MetadataDict["storage_diffs"])
+}
+
+func TestDBResourceExporterEVM(t *testing.T) {
+	db := newExportDB(t)
+	require.NoError(t, db.AutoMigrate(&models.EVMBlock{}, &models.EVMTransaction{}, &models.EVMLog{}))
+	exporter := NewDBResourceExporter(db, "ethereum", types.FullBlocks, types.Ethereum, 0)
+	record := func(value string) *pipeline.Record {
+		record := &pipeline.Record{FromBlock: 1, ToBlock: 1}
+		block := models.EVMBlock{ExtraData: value}
+		block.BlockNumber = 1
+		transaction := models.EVMTransaction{Value: value}
+		transaction.TransactionHash, transaction.BlockNumber = "0xt1", 1
+		evmLog := models.EVMLog{Data: value, Topics: []string{"0xddf2"}}
+		evmLog.BlockNumber = 1
+		record.EVMBlocks = []models.EVMBlock{block}
+		record.EVMTransactions = []models.EVMTransaction{transaction}
+		record.EVMLogs = []models.EVMLog{evmLog}
+		return record
+	}
+
+	ctx := context.Background()
+	require.NoError(t, exporter.Consume(ctx, record("0x1")))
+	// Exporting the blocks again updates their rows instead of failing on the primary keys.
+	require.NoError(t, exporter.Consume(ctx, record("0x2")))
+	require.NoError(t, exporter.Close())
+
+	var evmLogs []models.EVMLog
+	require.NoError(t, db.Find(&evmLogs).Error)
+	require.Len(t, evmLogs, 1)
+	assert.Equal(t, "0x2", evmLogs[0].Data)
+	var transaction models.EVMTransaction
+	require.NoError(t, db.First(&transaction).Error)
+	assert.Equal(t, "0x2", transaction.Value)
+
+	var ranges []models.BackfilledRange
+	require.NoError(t, db.Find(&ranges).Error)
+	require.Len(t, ranges, 1)
+	assert.Equal(t, types.Ethereum, ranges[0].Network)
+	assert.EqualValues(t, 1, ranges[0].MetadataDict["evm_logs"])
+}
+
+func TestDBResourceExporterZkSync(t *testing.T) {
+	db := newExportDB(t)
+	require.NoError(t, db.AutoMigrate(&models.ZkSyncBlock{}, &models.ZkSyncTransaction{}, &models.ZkSyncLog{}, &models.ZkSyncL2ToL1Log{}))
+	exporter := NewDBResourceExporter(db, "zksync", types.FullBlocks, types.ZkSyncEra, 0)
+	record := func(value string) *pipeline.Record {
+		record := &pipeline.Record{FromBlock: 1, ToBlock: 1}
+		block := models.ZkSyncBlock{}
+		block.BlockNumber, block.ExtraData = 1, value
+		transaction := models.ZkSyncTransaction{}
+		transaction.TransactionHash, transaction.BlockNumber, transaction.Value = "0xt1", 1, value
+		zkLog := models.ZkSyncLog{}
+		zkLog.BlockNumber, zkLog.Data, zkLog.Topics = 1, value, []string{"0xddf2"}
+		record.ZkSyncBlocks = []models.ZkSyncBlock{block}
+		record.ZkSyncTransactions = []models.ZkSyncTransaction{transaction}
+		record.ZkSyncLogs = []models.ZkSyncLog{zkLog}
+		record.ZkSyncL2ToL1Logs = []models.ZkSyncL2ToL1Log{{BlockNumber: 1, LogIndex: 2, Value: value}}
+		return record
+	}
+
+	ctx := context.Background()
+	require.NoError(t, exporter.Consume(ctx, record("0x1")))
+	// Exporting the blocks again updates their rows instead of failing on the primary keys.
+	require.NoError(t, exporter.Consume(ctx, record("0x2")))
+	require.NoError(t, exporter.Close())
+
+	var l2ToL1Logs []models.ZkSyncL2ToL1Log
+	require.NoError(t, db.Find(&l2ToL1Logs).Error)
+	require.Len(t, l2ToL1Logs, 1)
+	assert.Equal(t, "0x2", l2ToL1Logs[0].Value)
+	var logs int64
+	require.NoError(t, db.Model(&models.ZkSyncLog{}).Count(&logs).Error)
+	assert.Equal(t, int64(1), logs)
+
+	var ranges []models.BackfilledRange
+	require.NoError(t, db.Find(&ranges).Error)
+	require.Len(t, ranges, 1)
+	assert.Equal(t, types.ZkSyncEra, ranges[0].Network)
+	assert.EqualValues(t, 1, ranges[0].MetadataDict["zksync_l2_to_l1_logs"])
+}
+
+func TestNewSinkForBackfillDBModels(t *testing.T) {
+	db := newExportDB(t)
+	sink, kinds, err := NewSinkForBackfill(Transfers, map[string]interface{}{
+		"export_mode": DBModels,
+		"db":          db,
+		"backfill_id": "transfers",
+		"filter_data": map[string]interface{}{"tokens": []string{"0x1"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []pipeline.Kind{pipeline.Transfers}, kinds)
+	require.NoError(t, sink.Consume(context.Background(), exportRecord(1, 2, "5")))
+	require.NoError(t, sink.Close())
+
+	var ranges []models.BackfilledRange
+	require.NoError(t, db.Find(&ranges).Error)
+	require.Len(t, ranges, 1)
+	assert.Equal(t, types.Transfers, ranges[0].DataType)
+	assert.Equal(t, map[string]interface{}{"tokens": []interface{}{"0x1"}}, ranges[0].FilterData)
+
+	_, kinds, err = NewSinkForBackfill(StateDiffs, map[string]interface{}{"export_mode": DBModels, "db": db})
+	require.NoError(t, err)
+	assert.Len(t, kinds, 5)
+	_, _, err = NewSinkForBackfill(BackfillDataType("balances"), map[string]interface{}{"export_mode": DBModels, "db": db})
+	assert.Error(t, err)
+	_, _, err = NewSinkForBackfill(Transfers, map[string]interface{}{"export_mode": DBModels})
+	assert.ErrorIs(t, err, BackfillError)
+}
@@ -1,22 +1,30 @@
 package backfill
 
 import (
+	"database/sql/driver"
 	"encoding/csv"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math/big"
 	"os"
-
+	"reflect"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
+
+	"gorm.io/datatypes"
 )
 
-// ExportMode defines the mode of export: db_models or csv.
+// ExportMode defines the mode of export: db_models, csv, parquet or jsonl.
 type ExportMode string
 
 const (
 	DBModels ExportMode = "db_models"
 	CSV      ExportMode = "csv"
+	Parquet  ExportMode = "parquet"
+	JSONL    ExportMode = "jsonl"
 )
 
 // BackfillError is a custom error type for backfill-related errors.
@@ -32,10 +40,60 @@ const (
 	Transfers    BackfillDataType = "transfers"
 	Events       BackfillDataType = "events"
 	Traces       BackfillDataType = "traces"
+	StateDiffs   BackfillDataType = "state_diffs"
 )
 
 //here the value stored inside the enum becomes the string
 
+// StateDiffFiles maps each state diff table to the exporter file argument it is written to.
+var StateDiffFiles = map[string]string{
+	"storage_diffs":      "storage_diff_file",
+	"deployed_contracts": "deployed_contract_file",
+	"declared_classes":   "declared_class_file",
+	"replaced_classes":   "replaced_class_file",
+	"nonce_updates":      "nonce_update_file",
+}
+
+// ResourceExporter exports rows to a file. FileResourceExporter writes CSV files,
+// ParquetResourceExporter Parquet files and JSONLResourceExporter JSON Lines files.
+type ResourceExporter interface {
+	Write(resources []map[string]interface{}) error
+	// Close flushes buffered rows and the file trailer. The file is incomplete if it fails.
+	Close() error
+}
+
+// ExportOptions configure the exporters created by NewResourceExporter.
+type ExportOptions struct {
+	// Append appends to an existing CSV or JSON Lines file. Parquet files cannot be appended to.
+	Append bool
+	// Compression is the Parquet compression codec: snappy (the default), zstd or none.
+	Compression string
+	// RowGroupSize is the maximum number of rows of a Parquet row group.
+	RowGroupSize int64
+}
+
+// NewResourceExporter creates the exporter of a file from its extension: .csv, .parquet, .jsonl or
+// .jsonl.gz. model is the database model of the exported rows, such as models.Block. It selects the
+// declared CSV schema and types the Parquet columns.
+func NewResourceExporter(fileName string, model interface{}, options ExportOptions) (ResourceExporter, error) {
+	var exporter ResourceExporter
+	var err error
+	switch {
+	case strings.HasSuffix(fileName, ".csv"):
+		exporter, err = NewFileResourceExporter(fileName, CSVSchemaOf(model), options.Append)
+	case strings.HasSuffix(fileName, ".parquet"):
+		exporter, err = NewParquetResourceExporter(fileName, model, options)
+	case strings.HasSuffix(fileName, ".jsonl"), strings.HasSuffix(fileName, ".jsonl.gz"):
+		exporter, err = NewJSONLResourceExporter(fileName, options.Append)
+	default:
+		return nil, errors.New("export file name must be a .csv, .parquet, .jsonl or .jsonl.gz file")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return exporter, nil
+}
+
 // AbstractResourceExporter is the base class for resource exporters.
 type AbstractResourceExporter struct {
 	exportMode     ExportMode
@@ -63,142 +121,217 @@ func (e *AbstractResourceExporter) EncodeDataclassAsDict(dataclass map[string]in
 	return dataclass
 }
 
-// FileResourceExporter is used to export resources to a CSV file.
+// FileResourceExporter is used to export resources to a CSV file. Columns are written in the order
+// of the schema of the exporter, and values are quoted as needed by encoding/csv.
 type FileResourceExporter struct {
 	AbstractResourceExporter
 	fileName     string
+	schema       CSVSchema
 	writeHeaders bool
-	csvSeparator string
 	fileHandle   *os.File
 	writer       *csv.Writer
 }
 
-func NewFileResourceExporter(fileName string, append bool) (*FileResourceExporter, error) { //constructor function or init funct
+// NewFileResourceExporter creates a CSV exporter writing the columns of schema. Without a schema,
+// the columns of the first row are written in alphabetical order. When appending to a file that
+// has a header, the header must match the schema, or becomes the schema if there is none.
+func NewFileResourceExporter(fileName string, schema CSVSchema, append bool) (*FileResourceExporter, error) {
 	if !strings.HasSuffix(fileName, ".csv") {
 		return nil, errors.New("export file name must be a .csv file")
 	}
 
-	// Determine file mode
-	// var mode string
-	// if append {
-	// 	mode = "a"
-	// } else {
-	// 	mode = "w"
-	// }
-
-	// Open file
-	fileHandle, err := os.OpenFile(fileName, os.O_CREATE|os.O_RDWR, 0644)
+	flags := os.O_CREATE | os.O_RDWR | os.O_TRUNC
+	if append {
+		flags = os.O_CREATE | os.O_RDWR | os.O_APPEND
+	}
+	fileHandle, err := os.OpenFile(fileName, flags, 0644)
 	if err != nil {
 		return nil, err
 	}
 
-	// Initialize writer
-	writer := csv.NewWriter(fileHandle)
-
 	exporter := &FileResourceExporter{
+		AbstractResourceExporter: AbstractResourceExporter{
+			exportMode: CSV,
+		},
 		fileName:     fileName,
-		writeHeaders: false,
-		csvSeparator: "|", //unless specified explicitly this will remain the default value same as in python where there is no way to explicitly define this
+		schema:       schema,
+		writeHeaders: true,
 		fileHandle:   fileHandle,
-		writer:       writer,
+		writer:       csv.NewWriter(fileHandle),
 	}
 	exporter.Init()
 
-	// Check if file is empty to determine if headers should be written
-	fileInfo, err := fileHandle.Stat() //has variuos methods defined on it like name , size , mode permissions and modification time etc
+	fileInfo, err := fileHandle.Stat()
 	if err != nil {
+		fileHandle.Close()
 		return nil, err
 	}
-	if fileInfo.Size() == 0 {
-		exporter.writeHeaders = true
+	if fileInfo.Size() > 0 {
+		header, err := csv.NewReader(fileHandle).Read()
+		if err != nil {
+			fileHandle.Close()
+			return nil, fmt.Errorf("failed to read the header of %s: %w", fileName, err)
+		}
+		if schema != nil && !schema.equal(header) {
+			fileHandle.Close()
+			return nil, fmt.Errorf("header of %s does not match the exported columns: found %v, expected %v", fileName, header, []string(schema))
+		}
+		exporter.schema = header
+		exporter.writeHeaders = false
 	}
 
 	return exporter, nil
 }
 
-func (e *FileResourceExporter) CSVEncodeValue(val interface{}) (string, error) { //[]interface{} holds value of any type just like a list in python -->  this is only in a slice mde by using []
+// CSVEncodeValue encodes a value as a CSV field. Lists, maps and structs are written as JSON,
+// floats without exponent and times in RFC 3339 format.
+func (e *FileResourceExporter) CSVEncodeValue(val interface{}) (string, error) {
 	switch v := val.(type) {
 	case nil:
 		return "", nil
 	case string:
 		return v, nil
-	case int, float64:
-		return fmt.Sprint(v), nil
-	case []interface{}:
-		encodedList := make([]string, len(v))
-		for i, item := range v {
-			encodedItem, err := e.CSVEncodeValue(item)
-			if err != nil {
-				return "", err
-			}
-			encodedList[i] = encodedItem
+	case *big.Int:
+		if v == nil {
+			return "", nil
 		}
-		return "[" + strings.Join(encodedList, ",") + "]", nil
+		return v.String(), nil
+	case big.Int:
+		return v.String(), nil
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano), nil
+	case datatypes.JSON:
+		return string(v), nil
+	case json.RawMessage:
+		return string(v), nil
 	case []byte:
 		return fmt.Sprintf("%x", v), nil
-	case map[string]interface{}: //doesnt matter if key is string as not used in implementation
-		//interface{} can store any value in it
-		encodedMap, err := json.Marshal(v) //This function call converts the map v into a JSON-encoded byte slice. The json.Marshal function serializes Go values into JSON format
+	case driver.Valuer:
+		// sql.NullString, sql.NullInt64 and the other nullable columns.
+		value, err := v.Value()
+		if err != nil {
+			return "", err
+		}
+		return e.CSVEncodeValue(value)
+	case []interface{}, []string, map[string]interface{}:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return string(encoded), nil
+	}
+
+	value := reflect.ValueOf(val)
+	switch value.Kind() {
+	case reflect.Bool:
+		return strconv.FormatBool(value.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(value.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(value.Uint(), 10), nil
+	case reflect.Float32:
+		return strconv.FormatFloat(value.Float(), 'f', -1, 32), nil
+	case reflect.Float64:
+		return strconv.FormatFloat(value.Float(), 'f', -1, 64), nil
+	case reflect.String:
+		return value.String(), nil
+	case reflect.Pointer:
+		if value.IsNil() {
+			return "", nil
+		}
+		return e.CSVEncodeValue(value.Elem().Interface())
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.Struct:
+		encoded, err := json.Marshal(val)
 		if err != nil {
 			return "", err
 		}
-		return string(encodedMap), nil
+		return string(encoded), nil
 	default:
-		return "", fmt.Errorf("cannot encode %v to CSV", v)
+		return "", fmt.Errorf("cannot encode %v to CSV", val)
 	}
 }
 
-func (e *FileResourceExporter) EncodeDataclass(dataclass map[string]interface{}) ([]string, string, error) {
+// EncodeDataclass encodes a row in the column order of the schema. Missing columns are left empty,
+// columns that are not in the schema are an error.
+func (e *FileResourceExporter) EncodeDataclass(dataclass map[string]interface{}) ([]string, error) {
 	encodedDict := e.EncodeDataclassAsDict(dataclass)
-	csvEncoded := make([]string, len(encodedDict))
-	headers := make([]string, 0, len(encodedDict))
-
-	i := 0
-	for key, val := range encodedDict {
-		headers = append(headers, key)
+	record := make([]string, len(e.schema))
+	found := 0
+	for i, column := range e.schema {
+		val, ok := encodedDict[column]
+		if !ok {
+			continue
+		}
+		found++
 		encodedVal, err := e.CSVEncodeValue(val)
 		if err != nil {
-			return nil, "", err
+			return nil, fmt.Errorf("cannot encode column %s: %w", column, err)
 		}
-		csvEncoded[i] = encodedVal
-		i++
+		record[i] = encodedVal
 	}
-
-	if e.exportMode == CSV {
-		return headers, strings.Join(csvEncoded, e.csvSeparator), nil
+	if found < len(encodedDict) {
+		for column := range encodedDict {
+			if !slices.Contains(e.schema, column) {
+				return nil, fmt.Errorf("column %s is not in the schema of %s", column, e.fileName)
+			}
+		}
 	}
-
-	return nil, "", fmt.Errorf("export mode %s is not implemented", e.exportMode)
+	return record, nil
 }
 
 func (e *FileResourceExporter) Write(resources []map[string]interface{}) error {
 	for _, resource := range resources {
-		headers, csvRow, err := e.EncodeDataclass(resource)
-		if err != nil {
-			return err
+		if e.schema == nil {
+			e.schema = csvSchemaOfRow(resource)
 		}
 		if e.writeHeaders {
-			e.writer.Write(headers)
+			if err := e.writer.Write(e.schema); err != nil {
+				return err
+			}
 			e.writeHeaders = false
 		}
-		e.writer.Write(strings.Split(csvRow, e.csvSeparator))
+		record, err := e.EncodeDataclass(resource)
+		if err != nil {
+			return err
+		}
+		if err := e.writer.Write(record); err != nil {
+			return err
+		}
+	}
+	e.writer.Flush()
+	if err := e.writer.Error(); err != nil {
+		return fmt.Errorf("failed to write rows to %s: %w", e.fileName, err)
 	}
 	e.resourcesSaved += len(resources)
+	return nil
+}
+
+// Close flushes and closes the file.
+func (e *FileResourceExporter) Close() error {
+	defer e.AbstractResourceExporter.Close()
+
 	e.writer.Flush()
+	err := e.writer.Error()
+	if closeErr := e.fileHandle.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to close %s: %w", e.fileName, err)
+	}
 	return nil
 }
 
 // Backfill logic
-func GetFileExportersForBackfill(backfillType BackfillDataType, kwargs map[string]interface{}) (map[string]*FileResourceExporter, error) {
+func GetFileExportersForBackfill(backfillType BackfillDataType, kwargs map[string]interface{}) (map[string]ResourceExporter, error) {
 	switch backfillType {
 	case FullBlocks:
 		if blockFile, ok1 := kwargs["block_file"]; ok1 {
 			if txFile, ok2 := kwargs["transaction_file"]; ok2 {
 				if eventFile, ok3 := kwargs["event_file"]; ok3 {
-					return map[string]*FileResourceExporter{
-						"blocks":       blockFile.(*FileResourceExporter),
-						"transactions": txFile.(*FileResourceExporter),
-						"events":       eventFile.(*FileResourceExporter),
+					return map[string]ResourceExporter{
+						"blocks":       blockFile.(ResourceExporter),
+						"transactions": txFile.(ResourceExporter),
+						"events":       eventFile.(ResourceExporter),
 					}, nil
 				}
 			}
@@ -206,50 +339,62 @@ func GetFileExportersForBackfill(backfillType BackfillDataType, kwargs map[strin
 		return nil, BackfillError
 	case Blocks:
 		if blockFile, ok := kwargs["block_file"]; ok {
-			return map[string]*FileResourceExporter{
-				"blocks": blockFile.(*FileResourceExporter),
+			return map[string]ResourceExporter{
+				"blocks": blockFile.(ResourceExporter),
 			}, nil
 		}
 		return nil, BackfillError
 	case Events:
 		if eventFile, ok := kwargs["event_file"]; ok {
-			return map[string]*FileResourceExporter{
-				"events": eventFile.(*FileResourceExporter),
+			return map[string]ResourceExporter{
+				"events": eventFile.(ResourceExporter),
 			}, nil
 		}
 		return nil, BackfillError
 	case Transactions:
 		if txFile, ok1 := kwargs["transaction_file"]; ok1 {
 			if blockFile, ok2 := kwargs["block_file"]; ok2 {
-				return map[string]*FileResourceExporter{
-					"blocks":       blockFile.(*FileResourceExporter),
-					"transactions": txFile.(*FileResourceExporter),
+				return map[string]ResourceExporter{
+					"blocks":       blockFile.(ResourceExporter),
+					"transactions": txFile.(ResourceExporter),
 				}, nil
 			}
 		}
 		return nil, BackfillError
 	case Transfers:
 		if transferFile, ok := kwargs["transfer_file"]; ok {
-			return map[string]*FileResourceExporter{
-				"transfers": transferFile.(*FileResourceExporter),
+			return map[string]ResourceExporter{
+				"transfers": transferFile.(ResourceExporter),
 			}, nil
 		}
 		return nil, BackfillError
 	case Traces:
 		if traceFile, ok := kwargs["trace_file"]; ok {
-			return map[string]*FileResourceExporter{
-				"traces": traceFile.(*FileResourceExporter),
+			return map[string]ResourceExporter{
+				"traces": traceFile.(ResourceExporter),
 			}, nil
 		}
 		return nil, BackfillError
+	case StateDiffs:
+		// Each state diff table is optional, tables without a file are not exported.
+		exporters := make(map[string]ResourceExporter)
+		for table, fileKey := range StateDiffFiles {
+			if file, ok := kwargs[fileKey]; ok {
+				exporters[table] = file.(ResourceExporter)
+			}
+		}
+		if len(exporters) == 0 {
+			return nil, BackfillError
+		}
+		return exporters, nil
 	default:
-		return nil, fmt.Errorf("backfill type %s cannot be exported to CSV", backfillType)
+		return nil, fmt.Errorf("backfill type %s cannot be exported to files", backfillType)
 	}
 }
 
 func main() {
 	// Example usage
-	exporter, err := NewFileResourceExporter("output.csv", true)
+	exporter, err := NewFileResourceExporter("output.csv", CSVSchema{"block_number", "tx_count"}, true)
 	if err != nil {
 		fmt.Println(err)
 		return
@@ -264,5 +409,7 @@ func main() {
 	if err != nil {
 		fmt.Println(err)
 	}
-	exporter.Close()
+	if err := exporter.Close(); err != nil {
+		fmt.Println(err)
+	}
 }
@@ -1,88 +1,80 @@
 package backfill
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"strings"
 
 	"github.com/BlocSoc-iitr/Athena/athena/backfill/importers"
-	"github.com/NethermindEth/juno/core/felt"
-	"github.com/NethermindEth/starknet.go/rpc"
-	"github.com/NethermindEth/starknet.go/utils"
 )
 
-func FilterEventsByContractAddress(providername string, contractAddress string, FromBlockNumber uint64, ToBlockNumber uint64, filename string) {
-	provider, err := rpc.NewProvider(providername)
-	if err != nil {
-		log.Fatalf("Error creating provider: %v", err)
-	}
-
-	blockId := rpc.BlockID{Number: &FromBlockNumber}
-	blockIdTill := rpc.BlockID{Number: &ToBlockNumber}
-
-	contract, err := utils.HexToFelt(contractAddress)
-
-	filter := rpc.EventFilter{
-		FromBlock: blockId,
-		ToBlock:   blockIdTill,
-		Address:   contract,
+// ParseKeyFilter parses a key filter flag into the key positions of starknet_getEvents.
+// Positions are separated by commas and their alternative values by "|"; an empty position
+// or "*" matches any value. "0xsel,*,0xa|0xb" matches events with selector 0xsel whose third
+// key is 0xa or 0xb.
+func ParseKeyFilter(value string) ([][]string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
 	}
 
-	resultPage := rpc.ResultPageRequest{
-		ChunkSize: 100, // Fetch 100 events per request
-	}
-
-	// Fetch all events
-	events, err := importers.FetchEvents(provider, filter, resultPage)
-	if err != nil {
-		log.Fatalf("Error fetching events: %v", err)
+	var keys [][]string
+	for _, position := range strings.Split(value, ",") {
+		position = strings.TrimSpace(position)
+		if position == "" || position == "*" {
+			keys = append(keys, []string{})
+			continue
+		}
+		var values []string
+		for _, key := range strings.Split(position, "|") {
+			key = strings.TrimSpace(key)
+			if !strings.HasPrefix(key, "0x") {
+				return nil, fmt.Errorf("invalid key %q, keys are hex felts", key)
+			}
+			values = append(values, key)
+		}
+		keys = append(keys, values)
 	}
 
-	// Export filtered events to CSV
-	err = importers.ExportEventsToCSV(events, filename)
-	if err != nil {
-		log.Fatalf("Error exporting events to CSV: %v", err)
+	// Trailing wildcards do not restrict anything.
+	for len(keys) > 0 && len(keys[len(keys)-1]) == 0 {
+		keys = keys[:len(keys)-1]
 	}
-
+	return keys, nil
 }
 
-func FilterEventsByHexKeyString(providername string, hexKeyString []string, FromBlockNumber uint64, ToBlockNumber uint64, filename string) {
-	provider, err := rpc.NewProvider(providername)
+// FilterEvents exports the events passing a filter to a CSV file.
+func FilterEvents(ctx context.Context, rpcURL string, filter importers.EventFilter, filename string) error {
+	events, err := importers.GetFilteredEvents(ctx, rpcURL, filter)
 	if err != nil {
-		log.Fatalf("Error creating provider: %v", err)
+		return fmt.Errorf("error fetching events: %v", err)
 	}
-
-	blockId := rpc.BlockID{Number: &FromBlockNumber}
-	blockIdTill := rpc.BlockID{Number: &ToBlockNumber}
-
-	var events_Key []*felt.Felt
-	for i := 0; i < len(hexKeyString); i++ {
-		hexKey, err := utils.HexToFelt(hexKeyString[i])
-		if err != nil {
-			log.Fatalf("Error converting hex to felt: %v", err)
-		}
-		events_Key = append(events_Key, hexKey)
+	if err := importers.ExportEmittedEventsToCSV(events, filename); err != nil {
+		return fmt.Errorf("error exporting events to CSV: %v", err)
 	}
-	var final_events [][]*felt.Felt
-	final_events = append(final_events, events_Key)
+	return nil
+}
 
-	filter := rpc.EventFilter{
-		FromBlock: blockId,
-		ToBlock:   blockIdTill,
-		Keys:      final_events,
+func FilterEventsByContractAddress(providername string, contractAddresses []string, FromBlockNumber uint64, ToBlockNumber uint64, filename string) {
+	filter := importers.EventFilter{
+		Addresses: contractAddresses,
+		FromBlock: FromBlockNumber,
+		ToBlock:   ToBlockNumber,
 	}
-
-	resultPage := rpc.ResultPageRequest{
-		ChunkSize: 100, // Fetch 100 events per request
+	if err := FilterEvents(context.Background(), providername, filter, filename); err != nil {
+		log.Fatalf("%v", err)
 	}
+}
 
-	// Fetch all events
-	events, err := importers.FetchEvents(provider, filter, resultPage)
-	if err != nil {
-		log.Fatalf("Error fetching events: %v", err)
+// FilterEventsByHexKeyString filters events on their keys, keys[i] holding the accepted values of key i.
+func FilterEventsByHexKeyString(providername string, keys [][]string, FromBlockNumber uint64, ToBlockNumber uint64, filename string) {
+	filter := importers.EventFilter{
+		Keys:      keys,
+		FromBlock: FromBlockNumber,
+		ToBlock:   ToBlockNumber,
 	}
-
-	// Export filtered events to CSV
-	err = importers.ExportEventsToCSV(events, filename)
-	if err != nil {
-		log.Fatalf("Error exporting events to CSV: %v", err)
+	if err := FilterEvents(context.Background(), providername, filter, filename); err != nil {
+		log.Fatalf("%v", err)
 	}
 }
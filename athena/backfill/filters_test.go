@@ -0,0 +1,24 @@
+package backfill
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseKeyFilter(t *testing.T) {
+	keys, err := ParseKeyFilter("0x99, *,0x1|0x2,,")
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"0x99"}, {}, {"0x1", "0x2"}}, keys)
+
+	keys, err = ParseKeyFilter("*,0x5")
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{}, {"0x5"}}, keys)
+
+	keys, err = ParseKeyFilter("")
+	assert.NoError(t, err)
+	assert.Nil(t, keys)
+
+	_, err = ParseKeyFilter("Transfer")
+	assert.Error(t, err)
+}
@@ -0,0 +1,206 @@
+package backfill
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BlocSoc-iitr/Athena/athena/backfill/importers"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	DefaultPollInterval  = 10 * time.Second
+	DefaultConfirmations = 0
+)
+
+// FollowConfig configures a follow run that keeps ingesting new blocks after a backfill range completes.
+type FollowConfig struct {
+	Network       Network
+	RPCURL        string
+	WSURL         string // optional websocket endpoint used for starknet_subscribeNewHeads
+	StartBlock    uint64 // first block that has not been ingested yet
+	Confirmations uint64 // blocks to stay behind the chain head
+	PollInterval  time.Duration
+}
+
+// FollowBlocks tails the chain head and calls ingest for every new range of confirmed blocks.
+// New heads are received over websocket when WSURL supports starknet_subscribeNewHeads,
+// otherwise the RPC endpoint is polled. The loop exits cleanly once the killer fires
+// or the context is cancelled, after the range being ingested has completed.
+// Confirmations only delay ingestion: ingest must still verify each range against reorgs, as
+// StarknetBackfill.Import does.
+func FollowBlocks(ctx context.Context, cfg FollowConfig, killer *GraceFullkiller, ingest func(fromBlock uint64, toBlock uint64) error) error {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = DefaultPollInterval
+	}
+
+	heads := make(chan uint64, 16)
+	followCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if cfg.WSURL != "" && cfg.Network == Starknet {
+		if err := subscribeNewHeads(followCtx, cfg, heads); err != nil {
+			log.Printf("Websocket subscription unavailable, falling back to polling: %v", err)
+			go pollHeads(followCtx, cfg, heads)
+		}
+	} else {
+		go pollHeads(followCtx, cfg, heads)
+	}
+
+	nextBlock := cfg.StartBlock
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		if killer != nil && killer.KillNow() {
+			log.Printf("Stopping follow mode at block %d", nextBlock)
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			// Wake up periodically so the killer is checked even without new heads.
+		case head := <-heads:
+			if head < cfg.Confirmations {
+				continue
+			}
+			confirmed := head - cfg.Confirmations
+			if confirmed < nextBlock {
+				continue
+			}
+			if err := ingest(nextBlock, confirmed); err != nil {
+				return fmt.Errorf("error ingesting blocks %d to %d: %w", nextBlock, confirmed, err)
+			}
+			nextBlock = confirmed + 1
+		}
+	}
+}
+
+// pollHeads sends the current head to heads every poll interval until ctx is cancelled.
+func pollHeads(ctx context.Context, cfg FollowConfig, heads chan<- uint64) {
+	ticker := time.NewTicker(cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		head, err := currentBlockNumber(ctx, cfg.Network, cfg.RPCURL)
+		if err != nil {
+			log.Printf("Error polling chain head: %v", err)
+		} else {
+			select {
+			case heads <- head:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// currentBlockNumber queries the head of the chain from a specific RPC endpoint.
+func currentBlockNumber(ctx context.Context, network Network, rpcURL string) (uint64, error) {
+	switch network {
+	case Starknet:
+		resp, err := importers.MakeRPCCall(ctx, rpcURL, "starknet_blockNumber", []interface{}{})
+		if err != nil {
+			return 0, err
+		}
+		var blockNumber uint64
+		if err := json.Unmarshal(resp.Result, &blockNumber); err != nil {
+			return 0, fmt.Errorf("error decoding block number: %v", err)
+		}
+		return blockNumber, nil
+	case Ethereum, ZkSyncEra:
+		resp, err := importers.MakeRPCCall(ctx, rpcURL, "eth_blockNumber", []interface{}{})
+		if err != nil {
+			return 0, err
+		}
+		var resultHex string
+		if err := json.Unmarshal(resp.Result, &resultHex); err != nil {
+			return 0, fmt.Errorf("error decoding block number: %v", err)
+		}
+		return strconv.ParseUint(strings.TrimPrefix(resultHex, "0x"), 16, 64)
+	default:
+		return 0, fmt.Errorf("Network not supported")
+	}
+}
+
+// subscribeNewHeads opens a starknet_subscribeNewHeads subscription and forwards head block numbers.
+// If the subscription drops later on, heads are polled from the RPC endpoint instead.
+func subscribeNewHeads(ctx context.Context, cfg FollowConfig, heads chan<- uint64) error {
+	dialer := websocket.Dialer{HandshakeTimeout: 10 * time.Second}
+	conn, _, err := dialer.DialContext(ctx, cfg.WSURL, nil)
+	if err != nil {
+		return err
+	}
+
+	request := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "starknet_subscribeNewHeads",
+		"params":  map[string]interface{}{},
+	}
+	if err := conn.WriteJSON(request); err != nil {
+		conn.Close()
+		return err
+	}
+
+	var response struct {
+		Result json.RawMessage `json:"result"`
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	if err := conn.ReadJSON(&response); err != nil {
+		conn.Close()
+		return err
+	}
+	if response.Error != nil {
+		conn.Close()
+		return fmt.Errorf("rpc error: %s", response.Error.Message)
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	go func() {
+		for {
+			var notification struct {
+				Params struct {
+					Result struct {
+						BlockNumber uint64 `json:"block_number"`
+					} `json:"result"`
+				} `json:"params"`
+			}
+			if err := conn.ReadJSON(&notification); err != nil {
+				if ctx.Err() == nil {
+					log.Printf("Websocket subscription closed, falling back to polling: %v", err)
+					pollHeads(ctx, cfg, heads)
+				}
+				return
+			}
+			select {
+			case heads <- notification.Params.Result.BlockNumber:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return nil
+}
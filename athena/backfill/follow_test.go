@@ -0,0 +1,62 @@
+package backfill
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFollowBlocksRespectsConfirmations(t *testing.T) {
+	var head atomic.Uint64
+	head.Store(105)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"jsonrpc": "2.0",
+			"id":      1,
+			"result":  head.Load(),
+		})
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	type blockRange struct{ from, to uint64 }
+	var ranges []blockRange
+	cfg := FollowConfig{
+		Network:       Starknet,
+		RPCURL:        server.URL,
+		StartBlock:    101,
+		Confirmations: 2,
+		PollInterval:  10 * time.Millisecond,
+	}
+	err := FollowBlocks(ctx, cfg, nil, func(from uint64, to uint64) error {
+		ranges = append(ranges, blockRange{from, to})
+		if len(ranges) == 1 {
+			head.Store(108)
+		} else {
+			cancel()
+		}
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []blockRange{{101, 103}, {104, 106}}, ranges)
+}
+
+func TestFollowBlocksStopsOnKiller(t *testing.T) {
+	killer := &GraceFullkiller{killNow: true}
+	cfg := FollowConfig{Network: Starknet, RPCURL: "http://127.0.0.1:0", PollInterval: time.Hour}
+
+	err := FollowBlocks(context.Background(), cfg, killer, func(uint64, uint64) error {
+		t.Fatal("ingest should not be called after the killer fired")
+		return nil
+	})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,195 @@
+package importers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/BlocSoc-iitr/Athena/athena/database/models"
+	"github.com/BlocSoc-iitr/Athena/athena_abi"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// AbiPopulator fetches the classes declared on chain and stores their ABIs in the contract_abis table,
+// so that events of newly declared classes can be decoded without fetching their ABI by hand.
+// ABIs that cannot be parsed are stored in the quarantined_abis table instead of failing the backfill.
+type AbiPopulator struct {
+	url      string
+	db       *gorm.DB
+	registry *AbiRegistry
+}
+
+// NewAbiPopulator creates a populator. If db is nil, parsed ABIs are only added to the registry.
+func NewAbiPopulator(url string, db *gorm.DB, registry *AbiRegistry) *AbiPopulator {
+	return &AbiPopulator{
+		url:      url,
+		db:       db,
+		registry: registry,
+	}
+}
+
+// PopulateFromStateDiffs stores the ABI of every class declared in the state diffs.
+func (p *AbiPopulator) PopulateFromStateDiffs(ctx context.Context, rows StateDiffRows) (int, error) {
+	return p.PopulateClasses(ctx, rows.DeclaredClasses)
+}
+
+// PopulateFromBlock stores the ABI of every class declared by a successful DECLARE transaction of the block.
+func (p *AbiPopulator) PopulateFromBlock(ctx context.Context, block BlockWithReceipts) (int, error) {
+	return p.PopulateClasses(ctx, DeclaredClassesFromBlock(block))
+}
+
+// PopulateClasses stores the ABI of every declared class and returns the number of new ABIs.
+func (p *AbiPopulator) PopulateClasses(ctx context.Context, classes []models.DeclaredClass) (int, error) {
+	stored := 0
+	for _, class := range classes {
+		ok, err := p.PopulateClass(ctx, class.ClassHash, class.BlockNumber)
+		if err != nil {
+			return stored, err
+		}
+		if ok {
+			stored++
+		}
+	}
+	return stored, nil
+}
+
+// DeclaredClassesFromBlock returns the classes declared by the successful DECLARE transactions of
+// a block. Their compiled class hashes are not part of the block and are left null.
+func DeclaredClassesFromBlock(block BlockWithReceipts) []models.DeclaredClass {
+	var classes []models.DeclaredClass
+	for _, tx := range block.Transactions {
+		if tx.Transaction.Type != string(models.Declare) || tx.Receipt.ExecutionStatus == "REVERTED" {
+			continue
+		}
+		classes = append(classes, models.DeclaredClass{BlockNumber: block.BlockNumber, ClassHash: tx.Transaction.ClassHash})
+	}
+	return classes
+}
+
+// PopulateClass fetches a class and stores its ABI. It reports whether a new ABI was stored;
+// classes already known to the registry or already quarantined are skipped.
+// Only RPC and database failures are returned as errors, malformed ABIs are quarantined.
+func (p *AbiPopulator) PopulateClass(ctx context.Context, classHash string, blockNumber uint64) (bool, error) {
+	key := normalizeHex(classHash)
+
+	known, err := p.known(key)
+	if err != nil || known {
+		return false, err
+	}
+
+	rawAbi, err := FetchClassAbi(ctx, p.url, classHash, blockNumber)
+	if err != nil {
+		return false, err
+	}
+
+	abiJSON, abi, parseErr := parseClassAbi(rawAbi, key)
+	if parseErr != nil {
+		logrus.WithFields(logrus.Fields{
+			"class_hash":   key,
+			"block_number": blockNumber,
+		}).Warnf("quarantining malformed ABI: %v", parseErr)
+		return false, p.quarantine(key, blockNumber, rawAbi, parseErr)
+	}
+
+	if p.db != nil {
+		contractABI := models.ContractABI{
+			AbiName:   key,
+			AbiJson:   abiJSON,
+			Priority:  0,
+			DecoderOS: StarknetDecoderOS,
+		}
+		if err := p.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&contractABI).Error; err != nil {
+			return false, fmt.Errorf("failed to store ABI for class %s: %w", key, err)
+		}
+	}
+	p.registry.Add(key, abi)
+	return true, nil
+}
+
+func (p *AbiPopulator) known(classHash string) (bool, error) {
+	if abi, err := p.registry.Get(classHash); err == nil && abi != nil {
+		return true, nil
+	}
+	if p.db == nil {
+		return false, nil
+	}
+
+	var count int64
+	if err := p.db.Model(&models.QuarantinedABI{}).Where("class_hash = ?", classHash).Count(&count).Error; err != nil {
+		return false, fmt.Errorf("failed to look up quarantined ABI for class %s: %w", classHash, err)
+	}
+	return count > 0, nil
+}
+
+func (p *AbiPopulator) quarantine(classHash string, blockNumber uint64, rawAbi json.RawMessage, parseErr error) error {
+	if p.db == nil {
+		return nil
+	}
+	quarantined := models.QuarantinedABI{
+		ClassHash:   classHash,
+		BlockNumber: blockNumber,
+		AbiJson:     string(rawAbi),
+		DecoderOS:   StarknetDecoderOS,
+		Error:       parseErr.Error(),
+	}
+	if err := p.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&quarantined).Error; err != nil {
+		return fmt.Errorf("failed to quarantine ABI for class %s: %w", classHash, err)
+	}
+	return nil
+}
+
+// FetchClassAbi returns the raw ABI of a class with starknet_getClass at the given block.
+// Sierra classes return the ABI as a JSON encoded string, Cairo 0 classes as a JSON array.
+func FetchClassAbi(ctx context.Context, url string, classHash string, blockNumber uint64) (json.RawMessage, error) {
+	return fetchClassAbi(ctx, url, classHash, map[string]interface{}{"block_number": int(blockNumber)})
+}
+
+func fetchClassAbi(ctx context.Context, url string, classHash string, blockID interface{}) (json.RawMessage, error) {
+	params := map[string]interface{}{
+		"block_id":   blockID,
+		"class_hash": classHash,
+	}
+	resp, err := MakeRPCCall(ctx, url, "starknet_getClass", params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get class %s: %v", classHash, err)
+	}
+
+	var class struct {
+		Abi json.RawMessage `json:"abi"`
+	}
+	if err := json.Unmarshal(resp.Result, &class); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal class %s: %v", classHash, err)
+	}
+
+	var encoded string
+	if err := json.Unmarshal(class.Abi, &encoded); err == nil {
+		return json.RawMessage(encoded), nil
+	}
+	return class.Abi, nil
+}
+
+// parseClassAbi parses a raw class ABI. athena_abi panics on some malformed entries,
+// so panics are recovered and reported as parse errors.
+func parseClassAbi(rawAbi json.RawMessage, classHash string) (abiJSON []map[string]interface{}, abi *athena_abi.StarknetABI, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			abiJSON, abi, err = nil, nil, fmt.Errorf("failed to parse ABI: %v", r)
+		}
+	}()
+
+	if err := json.Unmarshal(rawAbi, &abiJSON); err != nil {
+		return nil, nil, fmt.Errorf("failed to unmarshal ABI: %w", err)
+	}
+
+	classHashBytes := make([]byte, 32)
+	if value, err := hexToBigInt(classHash); err == nil {
+		value.FillBytes(classHashBytes)
+	}
+	abi, err = athena_abi.StarknetAbiFromJSON(abiJSON, classHash, classHashBytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	return abiJSON, abi, nil
+}
@@ -0,0 +1,63 @@
+package importers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAbiPopulatorStoresDeclaredClasses(t *testing.T) {
+	erc20Abi, err := os.ReadFile("../../../athena_abi/abis/v2/erc20_compiled.json")
+	assert.NoError(t, err)
+	encodedAbi, err := json.Marshal(string(erc20Abi))
+	assert.NoError(t, err)
+
+	classes := map[string]string{
+		// Sierra classes return the ABI as a string, Cairo 0 classes as an array.
+		"0xa1": `{"sierra_program": [], "abi": ` + string(encodedAbi) + `}`,
+		"0xa2": `{"abi": [{"type": "function", "name": 5}]}`,
+	}
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string `json:"method"`
+			Params struct {
+				ClassHash string `json:"class_hash"`
+			} `json:"params"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		assert.Equal(t, "starknet_getClass", req.Method)
+		requests++
+		w.Write([]byte(`{"jsonrpc": "2.0", "id": 1, "result": ` + classes[req.Params.ClassHash] + `}`))
+	}))
+	defer server.Close()
+
+	var update StateUpdate
+	assert.NoError(t, json.Unmarshal([]byte(`{"state_diff": {
+		"declared_classes": [{"class_hash": "0xa1", "compiled_class_hash": "0xc1"}],
+		"deprecated_declared_classes": ["0xa2"]
+	}}`), &update))
+
+	registry := NewAbiRegistry(nil)
+	populator := NewAbiPopulator(server.URL, nil, registry)
+
+	stored, err := populator.PopulateFromStateDiffs(context.Background(), StateDiffFromUpdate(update))
+	assert.NoError(t, err, "malformed ABIs must not fail the backfill")
+	assert.Equal(t, 1, stored)
+
+	abi, err := registry.Get("0x00a1")
+	assert.NoError(t, err)
+	assert.NotNil(t, abi)
+	assert.Contains(t, abi.Events, "Transfer")
+
+	// Known classes are not fetched again.
+	ok, err := populator.PopulateClass(context.Background(), "0xa1", 2)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, 2, requests)
+}
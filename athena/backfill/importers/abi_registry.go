@@ -0,0 +1,372 @@
+package importers
+
+import (
+	"container/list"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/BlocSoc-iitr/Athena/athena/database/models"
+	"github.com/BlocSoc-iitr/Athena/athena/database/readers"
+	"github.com/BlocSoc-iitr/Athena/athena_abi"
+	"gorm.io/gorm"
+)
+
+// StarknetDecoderOS is the decoder_os tag of ABIs that are decoded with athena_abi.
+const StarknetDecoderOS = "cairo"
+
+// AbiRegistry resolves parsed Starknet ABIs by class hash from the contract_abis store.
+// ABIs are keyed by the normalized class hash, and parsed ABIs are cached in memory.
+type AbiRegistry struct {
+	db    *gorm.DB
+	mu    sync.Mutex
+	cache map[string]*athena_abi.StarknetABI
+
+	// fileAbis are the ABIs of the local ABI file cache, read once when there is no database.
+	fileOnce sync.Once
+	fileAbis map[string]models.ContractABI
+}
+
+// NewAbiRegistry creates a registry backed by the database, or by the local ABI file cache if db is nil.
+func NewAbiRegistry(db *gorm.DB) *AbiRegistry {
+	return &AbiRegistry{
+		db:    db,
+		cache: make(map[string]*athena_abi.StarknetABI),
+	}
+}
+
+// Get returns the parsed ABI for a class hash. It returns nil without an error if no ABI is stored for the class.
+// Missing ABIs are not cached, so ABIs stored later in the same run are found by the next lookup.
+func (r *AbiRegistry) Get(classHash string) (*athena_abi.StarknetABI, error) {
+	key := normalizeHex(classHash)
+
+	r.mu.Lock()
+	abi, ok := r.cache[key]
+	r.mu.Unlock()
+	if ok {
+		return abi, nil
+	}
+
+	contractABI, found, err := r.load(key)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, nil
+	}
+	classHashBytes := make([]byte, 32)
+	if value, err := hexToBigInt(key); err == nil {
+		value.FillBytes(classHashBytes)
+	}
+	abi, err = athena_abi.StarknetAbiFromJSON(contractABI.AbiJson, contractABI.AbiName, classHashBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ABI for class %s: %w", key, err)
+	}
+
+	r.mu.Lock()
+	r.cache[key] = abi
+	r.mu.Unlock()
+	return abi, nil
+}
+
+// Add caches a parsed ABI for a class hash.
+func (r *AbiRegistry) Add(classHash string, abi *athena_abi.StarknetABI) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cache[normalizeHex(classHash)] = abi
+}
+
+// EventPaths returns the events of the ABI of a class with their selectors, see ParseAbiEventPaths.
+// It returns nil without an error if no ABI is stored for the class.
+func (r *AbiRegistry) EventPaths(classHash string) ([]AbiEventPath, error) {
+	contractABI, found, err := r.load(normalizeHex(classHash))
+	if err != nil || !found {
+		return nil, err
+	}
+	paths, err := ParseAbiEventPaths(contractABI.AbiJson)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse events of class %s: %w", classHash, err)
+	}
+	if paths == nil {
+		paths = []AbiEventPath{}
+	}
+	return paths, nil
+}
+
+func (r *AbiRegistry) load(classHash string) (models.ContractABI, bool, error) {
+	if r.db == nil {
+		r.fileOnce.Do(func() {
+			r.fileAbis = make(map[string]models.ContractABI)
+			for _, abi := range readers.GetAbis(nil, nil, StarknetDecoderOS) {
+				r.fileAbis[normalizeHex(abi.AbiName)] = abi
+			}
+		})
+		abi, ok := r.fileAbis[classHash]
+		return abi, ok, nil
+	}
+
+	var abis []models.ContractABI
+	err := r.db.Where("abi_name = ? AND decoder_os = ?", classHash, StarknetDecoderOS).Limit(1).Find(&abis).Error
+	if err != nil {
+		return models.ContractABI{}, false, fmt.Errorf("failed to load ABI for class %s: %w", classHash, err)
+	}
+	if len(abis) == 0 {
+		return models.ContractABI{}, false, nil
+	}
+	return abis[0], true, nil
+}
+
+// contractNotFoundCode is the Starknet JSON-RPC error code of CONTRACT_NOT_FOUND.
+const contractNotFoundCode = 20
+
+// ErrContractNotFound is returned when a contract is not deployed at the requested block.
+var ErrContractNotFound = errors.New("contract not found")
+
+// ClassHashResolver looks up the class hash of a contract at a given block.
+type ClassHashResolver interface {
+	ClassHashAt(ctx context.Context, contractAddress string, blockNumber uint64) (string, error)
+}
+
+// RPCClassHashResolver resolves class hashes with starknet_getClassHashAt and caches the class periods
+// of contracts, so that contracts upgraded within the imported range resolve to the class of each block.
+// A miss resolves the classes of the classPeriodSpan blocks from the missed block by binary search, see
+// SearchClassHistory. The periods of at most maxCachedContracts contracts are kept, the least recently
+// used contracts are evicted first.
+type RPCClassHashResolver struct {
+	url       string
+	mu        sync.Mutex
+	contracts map[string]*list.Element
+	recent    *list.List
+}
+
+// classPeriodSpan is the number of blocks whose classes RPCClassHashResolver resolves on a miss.
+const classPeriodSpan = 1000
+
+// maxCachedContracts bounds the contracts whose class periods RPCClassHashResolver keeps.
+const maxCachedContracts = 10000
+
+// maxCachedPeriods bounds the class periods RPCClassHashResolver keeps per contract; the oldest are dropped.
+const maxCachedPeriods = 64
+
+// blockNotFoundCode is the Starknet JSON-RPC error code of BLOCK_NOT_FOUND.
+const blockNotFoundCode = 24
+
+// cachedPeriod is a block range over which a contract had one class, empty if it was not deployed.
+type cachedPeriod struct {
+	fromBlock uint64
+	toBlock   uint64
+	classHash string
+}
+
+type cachedContract struct {
+	address string
+	periods []cachedPeriod
+}
+
+func NewRPCClassHashResolver(url string) *RPCClassHashResolver {
+	return &RPCClassHashResolver{
+		url:       url,
+		contracts: make(map[string]*list.Element),
+		recent:    list.New(),
+	}
+}
+
+func (r *RPCClassHashResolver) ClassHashAt(ctx context.Context, contractAddress string, blockNumber uint64) (string, error) {
+	key := normalizeHex(contractAddress)
+	if classHash, ok := r.cached(key, blockNumber); ok {
+		return classHashOrNotFound(classHash)
+	}
+
+	classAt := func(blockNumber uint64) (string, error) {
+		classHash, err := GetClassHashAt(ctx, r.url, contractAddress, blockNumber)
+		if errors.Is(err, ErrContractNotFound) {
+			return "", nil
+		}
+		return classHash, err
+	}
+	// The span is cut to the missed block when it goes past the head of the chain.
+	toBlock := blockNumber + classPeriodSpan - 1
+	var rpcErr *rpcError
+	if _, err := classAt(toBlock); errors.As(err, &rpcErr) && rpcErr.Code == blockNotFoundCode {
+		toBlock = blockNumber
+	} else if err != nil {
+		return "", err
+	}
+	startClass, changes, err := bisectChanges(blockNumber, toBlock, classAt)
+	if err != nil {
+		return "", err
+	}
+
+	periods := []cachedPeriod{{fromBlock: blockNumber, toBlock: toBlock, classHash: startClass}}
+	for _, change := range changes {
+		periods[len(periods)-1].toBlock = change.blockNumber - 1
+		periods = append(periods, cachedPeriod{fromBlock: change.blockNumber, toBlock: toBlock, classHash: change.classHash})
+	}
+	r.store(key, periods)
+	return classHashOrNotFound(startClass)
+}
+
+func classHashOrNotFound(classHash string) (string, error) {
+	if classHash == "" {
+		return "", ErrContractNotFound
+	}
+	return classHash, nil
+}
+
+func (r *RPCClassHashResolver) cached(contractAddress string, blockNumber uint64) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	element, ok := r.contracts[contractAddress]
+	if !ok {
+		return "", false
+	}
+	r.recent.MoveToFront(element)
+	for _, period := range element.Value.(*cachedContract).periods {
+		if period.fromBlock <= blockNumber && blockNumber <= period.toBlock {
+			return period.classHash, true
+		}
+	}
+	return "", false
+}
+
+// store adds the periods of a contract, merging them with the adjacent periods of the same class.
+func (r *RPCClassHashResolver) store(contractAddress string, periods []cachedPeriod) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	element, ok := r.contracts[contractAddress]
+	if !ok {
+		element = r.recent.PushFront(&cachedContract{address: contractAddress})
+		r.contracts[contractAddress] = element
+		if r.recent.Len() > maxCachedContracts {
+			oldest := r.recent.Back()
+			r.recent.Remove(oldest)
+			delete(r.contracts, oldest.Value.(*cachedContract).address)
+		}
+	}
+	r.recent.MoveToFront(element)
+
+	contract := element.Value.(*cachedContract)
+	all := append(contract.periods, periods...)
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].fromBlock < all[j].fromBlock
+	})
+	var merged []cachedPeriod
+	for _, period := range all {
+		if n := len(merged); n > 0 && merged[n-1].classHash == period.classHash && period.fromBlock <= merged[n-1].toBlock+1 {
+			merged[n-1].toBlock = max(merged[n-1].toBlock, period.toBlock)
+			continue
+		}
+		merged = append(merged, period)
+	}
+	if len(merged) > maxCachedPeriods {
+		merged = merged[len(merged)-maxCachedPeriods:]
+	}
+	contract.periods = merged
+}
+
+// GetClassHashAt returns the class hash of a contract at a block with starknet_getClassHashAt.
+// It returns ErrContractNotFound if the contract is not deployed at that block.
+func GetClassHashAt(ctx context.Context, url string, contractAddress string, blockNumber uint64) (string, error) {
+	params := map[string]interface{}{
+		"block_id": map[string]interface{}{
+			"block_number": int(blockNumber),
+		},
+		"contract_address": contractAddress,
+	}
+	resp, err := MakeRPCCall(ctx, url, "starknet_getClassHashAt", params)
+	if err != nil {
+		var rpcErr *rpcError
+		if errors.As(err, &rpcErr) && rpcErr.Code == contractNotFoundCode {
+			return "", ErrContractNotFound
+		}
+		return "", fmt.Errorf("failed to get class hash of %s at block %d: %w", contractAddress, blockNumber, err)
+	}
+
+	var classHash string
+	if err := json.Unmarshal(resp.Result, &classHash); err != nil {
+		return "", fmt.Errorf("failed to unmarshal class hash of %s: %v", contractAddress, err)
+	}
+	return classHash, nil
+}
+
+// EventDecoder fills the class hash, event name and decoded parameters of imported events.
+type EventDecoder struct {
+	registry *AbiRegistry
+	resolver ClassHashResolver
+}
+
+func NewEventDecoder(registry *AbiRegistry, resolver ClassHashResolver) *EventDecoder {
+	return &EventDecoder{
+		registry: registry,
+		resolver: resolver,
+	}
+}
+
+// Decode resolves the ABI of the emitting contract and decodes the event in place.
+// Events whose class has no stored ABI, or that do not match any ABI event, are left undecoded.
+func (d *EventDecoder) Decode(ctx context.Context, event *models.DefaultEvent) error {
+	if len(event.Keys) == 0 {
+		return nil
+	}
+
+	classHash, err := d.resolver.ClassHashAt(ctx, event.ContractAddress, event.BlockNumber)
+	if err != nil {
+		return err
+	}
+	event.ClassHash = nullString(classHash)
+
+	abi, err := d.registry.Get(classHash)
+	if err != nil || abi == nil {
+		return err
+	}
+
+	abiEvent, ok := findAbiEvent(abi, event.Keys[0])
+	if !ok {
+		return nil
+	}
+
+	keys, err := hexStringsToBigInts(event.Keys)
+	if err != nil {
+		return err
+	}
+	data, err := hexStringsToBigInts(event.Data)
+	if err != nil {
+		return err
+	}
+
+	decoded, err := abiEvent.Decode(data, keys)
+	if err != nil {
+		return fmt.Errorf("failed to decode event %s of %s: %w", abiEvent.Name(), event.ContractAddress, err)
+	}
+	event.EventName = nullString(decoded.Name())
+	event.DecodedParams = decoded.Data()
+	return nil
+}
+
+// findAbiEvent finds the ABI event whose selector matches the first event key.
+func findAbiEvent(abi *athena_abi.StarknetABI, selector string) (athena_abi.AbiEvent, bool) {
+	normalized := normalizeHex(selector)
+	for _, abiEvent := range abi.Events {
+		if normalizeHex(hex.EncodeToString(abiEvent.Signature())) == normalized {
+			return abiEvent, true
+		}
+	}
+	return athena_abi.AbiEvent{}, false
+}
+
+func hexStringsToBigInts(values []string) ([]*big.Int, error) {
+	result := make([]*big.Int, len(values))
+	for i, value := range values {
+		parsed, err := hexToBigInt(value)
+		if err != nil {
+			return nil, err
+		}
+		result[i] = parsed
+	}
+	return result, nil
+}
@@ -0,0 +1,149 @@
+package importers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/BlocSoc-iitr/Athena/athena/database/models"
+	"github.com/BlocSoc-iitr/Athena/athena_abi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+type staticClassHashResolver string
+
+func (r staticClassHashResolver) ClassHashAt(context.Context, string, uint64) (string, error) {
+	return string(r), nil
+}
+
+func loadTestAbi(t *testing.T, path string) *athena_abi.StarknetABI {
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	var abiJSON []map[string]interface{}
+	assert.NoError(t, json.Unmarshal(data, &abiJSON))
+	abi, err := athena_abi.StarknetAbiFromJSON(abiJSON, "erc20", nil)
+	assert.NoError(t, err)
+	return abi
+}
+
+func TestAbiRegistryDoesNotCacheMissingAbis(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: gormlogger.Discard})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.ContractABI{}))
+	registry := NewAbiRegistry(db)
+
+	abi, err := registry.Get("0xc1a55")
+	assert.NoError(t, err)
+	assert.Nil(t, abi)
+
+	data, err := os.ReadFile("../../../athena_abi/abis/v2/erc20_compiled.json")
+	require.NoError(t, err)
+	var abiJSON []map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &abiJSON))
+	require.NoError(t, db.Create(&models.ContractABI{AbiName: "0xc1a55", AbiJson: abiJSON, DecoderOS: StarknetDecoderOS}).Error)
+
+	abi, err = registry.Get("0x0c1a55")
+	assert.NoError(t, err)
+	assert.NotNil(t, abi)
+}
+
+func TestRPCClassHashResolverCachesClassPeriods(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Params struct {
+				BlockID struct {
+					BlockNumber uint64 `json:"block_number"`
+				} `json:"block_id"`
+			} `json:"params"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		calls++
+		classHash := "0xc1"
+		if req.Params.BlockID.BlockNumber >= 100 {
+			classHash = "0xc2"
+		}
+		fmt.Fprintf(w, `{"jsonrpc": "2.0", "id": 1, "result": %q}`, classHash)
+	}))
+	defer server.Close()
+
+	resolver := NewRPCClassHashResolver(server.URL)
+	for _, block := range []uint64{99, 100, 99, 800} {
+		classHash, err := resolver.ClassHashAt(context.Background(), "0xa", block)
+		require.NoError(t, err)
+		if block < 100 {
+			assert.Equal(t, "0xc1", classHash)
+		} else {
+			assert.Equal(t, "0xc2", classHash)
+		}
+	}
+	// The first miss resolves the periods of the following blocks by binary search.
+	assert.LessOrEqual(t, calls, 12)
+	resolved := calls
+	_, err := resolver.ClassHashAt(context.Background(), "0xa", 1098)
+	require.NoError(t, err)
+	assert.Equal(t, resolved, calls)
+	_, err = resolver.ClassHashAt(context.Background(), "0xa", 1099)
+	require.NoError(t, err)
+	assert.Greater(t, calls, resolved)
+}
+
+func TestEventDecoderDecodesTransfer(t *testing.T) {
+	registry := NewAbiRegistry(nil)
+	registry.Add("0x0c1a55", loadTestAbi(t, "../../../athena_abi/abis/v2/erc20_compiled.json"))
+	decoder := NewEventDecoder(registry, staticClassHashResolver("0xc1a55"))
+
+	event := models.DefaultEvent{
+		AbstractEvent: models.AbstractEvent{BlockNumber: 10, ContractAddress: "0x7"},
+		Keys:          []string{selectorFromName("Transfer")},
+		Data:          []string{"0x1", "0x2", "0x64", "0x0"},
+	}
+	assert.NoError(t, decoder.Decode(context.Background(), &event))
+
+	assert.Equal(t, "0xc1a55", event.ClassHash.String)
+	assert.Equal(t, "Transfer", event.EventName.String)
+	assert.Equal(t, big.NewInt(100), event.DecodedParams["value"])
+	assert.Contains(t, event.DecodedParams, "from")
+	assert.Contains(t, event.DecodedParams, "to")
+}
+
+func TestEventDecoderLeavesUnknownEventsUndecoded(t *testing.T) {
+	registry := NewAbiRegistry(nil)
+	registry.Add("0xc1a55", loadTestAbi(t, "../../../athena_abi/abis/v2/erc20_compiled.json"))
+	decoder := NewEventDecoder(registry, staticClassHashResolver("0xc1a55"))
+
+	event := models.DefaultEvent{Keys: []string{"0x1234"}, Data: []string{}}
+	assert.NoError(t, decoder.Decode(context.Background(), &event))
+	assert.False(t, event.EventName.Valid)
+	assert.Nil(t, event.DecodedParams)
+}
+
+func TestEventsFromBlock(t *testing.T) {
+	var block BlockWithReceipts
+	assert.NoError(t, json.Unmarshal([]byte(`{
+		"block_number": 5,
+		"transactions": [
+			{"transaction": {}, "receipt": {"events": [{"from_address": "0xa", "keys": ["0x1"], "data": []}]}},
+			{"transaction": {}, "receipt": {"events": [
+				{"from_address": "0xb", "keys": ["0x2"], "data": ["0x3"]},
+				{"from_address": "0xc", "keys": ["0x4"], "data": []}
+			]}}
+		]
+	}`), &block))
+
+	events := EventsFromBlock(block)
+	assert.Len(t, events, 3)
+	assert.Equal(t, 1, events[2].TransactionIndex)
+	assert.Equal(t, 1, events[2].EventIndex)
+	assert.Equal(t, "0xc", events[2].ContractAddress)
+	assert.Equal(t, uint64(5), events[2].BlockNumber)
+}
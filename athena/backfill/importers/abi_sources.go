@@ -0,0 +1,250 @@
+package importers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BlocSoc-iitr/Athena/athena/database/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// EVMDecoderOS is the decoder_os tag of Solidity ABIs.
+const EVMDecoderOS = "evm"
+
+// ErrAbiNotFound is returned by an AbiSource that has no ABI for the requested contract or class.
+var ErrAbiNotFound = errors.New("abi not found")
+
+// AbiSource fetches the JSON ABI of a contract address or a Starknet class hash.
+type AbiSource interface {
+	FetchAbi(ctx context.Context, identifier string) ([]map[string]interface{}, error)
+	// DecoderOS is the decoder_os tag the fetched ABIs are stored with.
+	DecoderOS() string
+}
+
+// JSONRPCAbiSource fetches Starknet class ABIs with starknet_getClass at the latest block.
+type JSONRPCAbiSource struct {
+	url string
+}
+
+func NewJSONRPCAbiSource(url string) *JSONRPCAbiSource {
+	return &JSONRPCAbiSource{url: url}
+}
+
+func (s *JSONRPCAbiSource) FetchAbi(ctx context.Context, classHash string) ([]map[string]interface{}, error) {
+	rawAbi, err := fetchClassAbi(ctx, s.url, classHash, "latest")
+	if err != nil {
+		return nil, err
+	}
+	var abiJSON []map[string]interface{}
+	if err := json.Unmarshal(rawAbi, &abiJSON); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal ABI of class %s: %w", classHash, err)
+	}
+	return abiJSON, nil
+}
+
+func (s *JSONRPCAbiSource) DecoderOS() string {
+	return StarknetDecoderOS
+}
+
+// EtherscanAbiSource fetches verified contract ABIs from an Etherscan compatible API,
+// such as Etherscan, Blockscout or a local stand-in serving the same endpoints.
+type EtherscanAbiSource struct {
+	baseURL string
+	apiKey  string
+	client  *http.Client
+	limiter *RateLimiter
+}
+
+// NewEtherscanAbiSource creates a source for the API at baseURL. Requests are spaced to stay under
+// requestsPerSecond; a value of 0 disables rate limiting.
+func NewEtherscanAbiSource(baseURL string, apiKey string, requestsPerSecond float64) *EtherscanAbiSource {
+	return &EtherscanAbiSource{
+		baseURL: baseURL,
+		apiKey:  apiKey,
+		client:  &http.Client{Timeout: 30 * time.Second},
+		limiter: NewRateLimiter(requestsPerSecond),
+	}
+}
+
+type etherscanResponse struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	Result  string `json:"result"`
+}
+
+func (s *EtherscanAbiSource) FetchAbi(ctx context.Context, address string) ([]map[string]interface{}, error) {
+	query := url.Values{}
+	query.Set("module", "contract")
+	query.Set("action", "getabi")
+	query.Set("address", address)
+	if s.apiKey != "" {
+		query.Set("apikey", s.apiKey)
+	}
+
+	if err := s.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.baseURL+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch ABI of %s: %v", address, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ABI response of %s: %v", address, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch ABI of %s: %s", address, resp.Status)
+	}
+
+	var response etherscanResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to decode ABI response of %s: %v", address, err)
+	}
+	if response.Status != "1" {
+		if strings.Contains(strings.ToLower(response.Result), "not verified") {
+			return nil, fmt.Errorf("%w: %s", ErrAbiNotFound, response.Result)
+		}
+		return nil, fmt.Errorf("failed to fetch ABI of %s: %s: %s", address, response.Message, response.Result)
+	}
+
+	var abiJSON []map[string]interface{}
+	if err := json.Unmarshal([]byte(response.Result), &abiJSON); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal ABI of %s: %w", address, err)
+	}
+	return abiJSON, nil
+}
+
+func (s *EtherscanAbiSource) DecoderOS() string {
+	return EVMDecoderOS
+}
+
+// FileAbiSource reads ABIs from a directory holding one <identifier>.json file per contract or class.
+type FileAbiSource struct {
+	dir       string
+	decoderOS string
+}
+
+func NewFileAbiSource(dir string, decoderOS string) *FileAbiSource {
+	return &FileAbiSource{dir: dir, decoderOS: decoderOS}
+}
+
+func (s *FileAbiSource) FetchAbi(_ context.Context, identifier string) ([]map[string]interface{}, error) {
+	for _, name := range []string{identifier, normalizeHex(identifier)} {
+		data, err := os.ReadFile(filepath.Join(s.dir, name+".json"))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ABI of %s: %v", identifier, err)
+		}
+		var abiJSON []map[string]interface{}
+		if err := json.Unmarshal(data, &abiJSON); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal ABI of %s: %w", identifier, err)
+		}
+		return abiJSON, nil
+	}
+	return nil, fmt.Errorf("%w: no file for %s in %s", ErrAbiNotFound, identifier, s.dir)
+}
+
+func (s *FileAbiSource) DecoderOS() string {
+	return s.decoderOS
+}
+
+// CachedAbiSource stores the ABIs fetched from a source in the contract_abis table, keyed by the
+// normalized identifier, and serves later requests from it.
+type CachedAbiSource struct {
+	source AbiSource
+	db     *gorm.DB
+}
+
+func NewCachedAbiSource(source AbiSource, db *gorm.DB) *CachedAbiSource {
+	return &CachedAbiSource{source: source, db: db}
+}
+
+func (s *CachedAbiSource) FetchAbi(ctx context.Context, identifier string) ([]map[string]interface{}, error) {
+	key := normalizeHex(identifier)
+
+	var cached []models.ContractABI
+	err := s.db.Where("abi_name = ? AND decoder_os = ?", key, s.source.DecoderOS()).Limit(1).Find(&cached).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to load cached ABI of %s: %w", key, err)
+	}
+	if len(cached) > 0 {
+		return cached[0].AbiJson, nil
+	}
+
+	abiJSON, err := s.source.FetchAbi(ctx, identifier)
+	if err != nil {
+		return nil, err
+	}
+	contractABI := models.ContractABI{
+		AbiName:   key,
+		AbiJson:   abiJSON,
+		DecoderOS: s.source.DecoderOS(),
+	}
+	if err := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&contractABI).Error; err != nil {
+		return nil, fmt.Errorf("failed to cache ABI of %s: %w", key, err)
+	}
+	return abiJSON, nil
+}
+
+func (s *CachedAbiSource) DecoderOS() string {
+	return s.source.DecoderOS()
+}
+
+// RateLimiter spaces calls to Wait so that at most a given number of them return per second.
+type RateLimiter struct {
+	interval time.Duration
+	mu       sync.Mutex
+	next     time.Time
+}
+
+// NewRateLimiter creates a limiter; a rate of 0 or less never waits.
+func NewRateLimiter(requestsPerSecond float64) *RateLimiter {
+	if requestsPerSecond <= 0 {
+		return &RateLimiter{}
+	}
+	return &RateLimiter{interval: time.Duration(float64(time.Second) / requestsPerSecond)}
+}
+
+// Wait blocks until the next request may be sent or the context is done.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	if l == nil || l.interval == 0 {
+		return nil
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	at := l.next
+	if at.Before(now) {
+		at = now
+	}
+	l.next = at.Add(l.interval)
+	l.mu.Unlock()
+
+	timer := time.NewTimer(time.Until(at))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
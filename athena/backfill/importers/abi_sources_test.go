@@ -0,0 +1,75 @@
+package importers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEtherscanAbiSource(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		assert.Equal(t, "contract", query.Get("module"))
+		assert.Equal(t, "getabi", query.Get("action"))
+		assert.Equal(t, "secret", query.Get("apikey"))
+		switch query.Get("address") {
+		case "0xverified":
+			w.Write([]byte(`{"status": "1", "message": "OK", "result": "[{\"type\": \"function\", \"name\": \"transfer\"}]"}`))
+		default:
+			w.Write([]byte(`{"status": "0", "message": "NOTOK", "result": "Contract source code not verified"}`))
+		}
+	}))
+	defer server.Close()
+
+	source := NewEtherscanAbiSource(server.URL, "secret", 0)
+	abi, err := source.FetchAbi(context.Background(), "0xverified")
+	assert.NoError(t, err)
+	assert.Equal(t, "transfer", abi[0]["name"])
+	assert.Equal(t, EVMDecoderOS, source.DecoderOS())
+
+	_, err = source.FetchAbi(context.Background(), "0xunverified")
+	assert.ErrorIs(t, err, ErrAbiNotFound)
+}
+
+func TestFileAbiSource(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "0xabc.json"), []byte(`[{"type": "event", "name": "Transfer"}]`), 0644))
+
+	source := NewFileAbiSource(dir, StarknetDecoderOS)
+	abi, err := source.FetchAbi(context.Background(), "0x0abc")
+	assert.NoError(t, err)
+	assert.Equal(t, "Transfer", abi[0]["name"])
+
+	_, err = source.FetchAbi(context.Background(), "0xdef")
+	assert.ErrorIs(t, err, ErrAbiNotFound)
+}
+
+func TestJSONRPCAbiSource(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"jsonrpc": "2.0", "id": 1, "result": {"abi": "[{\"type\": \"function\", \"name\": \"balance_of\"}]"}}`))
+	}))
+	defer server.Close()
+
+	abi, err := NewJSONRPCAbiSource(server.URL).FetchAbi(context.Background(), "0x1")
+	assert.NoError(t, err)
+	assert.Equal(t, "balance_of", abi[0]["name"])
+}
+
+func TestRateLimiter(t *testing.T) {
+	limiter := NewRateLimiter(50)
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		assert.NoError(t, limiter.Wait(context.Background()))
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 55*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Error(t, limiter.Wait(ctx))
+}
@@ -0,0 +1,453 @@
+package importers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/BlocSoc-iitr/Athena/athena/database/models"
+	"github.com/BlocSoc-iitr/Athena/athena/types"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ClassHistoryStore is an index of the classes of each contract over time, built from the
+// deployed_contracts and replaced_classes of state updates. The index only knows about the
+// blocks whose state diffs were applied to it, which it reports with IndexedRanges.
+type ClassHistoryStore interface {
+	// History returns the class periods of a contract ordered by block. It is empty if the contract is not indexed.
+	History(contractAddress string) ([]models.ContractClassHistory, error)
+	// IndexedRanges returns the block ranges whose state diffs were applied, ordered and merged.
+	IndexedRanges() ([]IndexedRange, error)
+	// Apply records the deployments and class replacements of the state diffs of a block range.
+	// Ranges may be applied in any order.
+	Apply(rows StateDiffRows, fromBlock uint64, toBlock uint64) error
+}
+
+// IndexedRange is an inclusive block range covered by a class history index.
+type IndexedRange struct {
+	FromBlock uint64
+	ToBlock   uint64
+}
+
+// classHistoryBackfillID is the backfill_id of the BackfilledRanges of the class history index.
+const classHistoryBackfillID = "contract_class_history"
+
+// mergeIndexedRanges orders ranges and merges the ones that overlap or touch.
+func mergeIndexedRanges(ranges []IndexedRange) []IndexedRange {
+	sorted := append([]IndexedRange{}, ranges...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].FromBlock < sorted[j].FromBlock
+	})
+	var merged []IndexedRange
+	for _, r := range sorted {
+		if n := len(merged); n > 0 && r.FromBlock <= merged[n-1].ToBlock+1 {
+			if r.ToBlock > merged[n-1].ToBlock {
+				merged[n-1].ToBlock = r.ToBlock
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// ClassHashResolverFunc adapts a function to the ClassHashResolver interface.
+type ClassHashResolverFunc func(ctx context.Context, contractAddress string, blockNumber uint64) (string, error)
+
+func (f ClassHashResolverFunc) ClassHashAt(ctx context.Context, contractAddress string, blockNumber uint64) (string, error) {
+	return f(ctx, contractAddress, blockNumber)
+}
+
+// classChange is a deployment or class replacement of a contract.
+type classChange struct {
+	blockNumber     uint64
+	contractAddress string
+	classHash       string
+}
+
+// classChanges returns the deployments and replacements of state diffs ordered by block,
+// with the deployments of a block before its replacements.
+func classChanges(rows StateDiffRows) []classChange {
+	var changes []classChange
+	for _, contract := range rows.DeployedContracts {
+		changes = append(changes, classChange{contract.BlockNumber, normalizeHex(contract.ContractAddress), normalizeHex(contract.ClassHash)})
+	}
+	for _, class := range rows.ReplacedClasses {
+		changes = append(changes, classChange{class.BlockNumber, normalizeHex(class.ContractAddress), normalizeHex(class.ClassHash)})
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].blockNumber < changes[j].blockNumber
+	})
+	return changes
+}
+
+// MemoryClassHistoryStore keeps the class history index in memory.
+type MemoryClassHistoryStore struct {
+	mu      sync.Mutex
+	entries map[string][]models.ContractClassHistory
+	ranges  []IndexedRange
+}
+
+func NewMemoryClassHistoryStore() *MemoryClassHistoryStore {
+	return &MemoryClassHistoryStore{entries: make(map[string][]models.ContractClassHistory)}
+}
+
+func (s *MemoryClassHistoryStore) History(contractAddress string) ([]models.ContractClassHistory, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries := s.entries[normalizeHex(contractAddress)]
+	return append([]models.ContractClassHistory{}, entries...), nil
+}
+
+func (s *MemoryClassHistoryStore) IndexedRanges() ([]IndexedRange, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]IndexedRange{}, s.ranges...), nil
+}
+
+func (s *MemoryClassHistoryStore) Apply(rows StateDiffRows, fromBlock uint64, toBlock uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ranges = mergeIndexedRanges(append(s.ranges, IndexedRange{FromBlock: fromBlock, ToBlock: toBlock}))
+
+	for _, change := range classChanges(rows) {
+		entries := s.entries[change.contractAddress]
+		i := sort.Search(len(entries), func(i int) bool {
+			return entries[i].FromBlock >= change.blockNumber
+		})
+		if i < len(entries) && entries[i].FromBlock == change.blockNumber {
+			entries[i].ClassHash = change.classHash
+			continue
+		}
+
+		// A change older than the last period, applied from an earlier range, splits the period holding it.
+		entry := models.ContractClassHistory{
+			ContractAddress: change.contractAddress,
+			FromBlock:       change.blockNumber,
+			ClassHash:       change.classHash,
+		}
+		if i < len(entries) {
+			entry.ToBlock = sql.NullInt64{Int64: int64(entries[i].FromBlock - 1), Valid: true}
+		}
+		if i > 0 {
+			entries[i-1].ToBlock = sql.NullInt64{Int64: int64(change.blockNumber - 1), Valid: true}
+		}
+		entries = append(entries, models.ContractClassHistory{})
+		copy(entries[i+1:], entries[i:])
+		entries[i] = entry
+		s.entries[change.contractAddress] = entries
+	}
+	return nil
+}
+
+// DBClassHistoryStore keeps the class history index in the contract_class_history table.
+type DBClassHistoryStore struct {
+	db *gorm.DB
+}
+
+func NewDBClassHistoryStore(db *gorm.DB) *DBClassHistoryStore {
+	return &DBClassHistoryStore{db: db}
+}
+
+func (s *DBClassHistoryStore) History(contractAddress string) ([]models.ContractClassHistory, error) {
+	var entries []models.ContractClassHistory
+	err := s.db.Where("contract_address = ?", normalizeHex(contractAddress)).Order("from_block").Find(&entries).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to load class history of %s: %w", contractAddress, err)
+	}
+	return entries, nil
+}
+
+// IndexedRanges reads the ranges from the BackfilledRanges written by Apply.
+func (s *DBClassHistoryStore) IndexedRanges() ([]IndexedRange, error) {
+	var backfilledRanges []models.BackfilledRange
+	err := s.db.Where("backfill_id = ? AND data_type = ? AND network = ?", classHistoryBackfillID, types.StateDiffs, types.StarkNet).
+		Find(&backfilledRanges).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the indexed ranges of the class history: %w", err)
+	}
+	ranges := make([]IndexedRange, 0, len(backfilledRanges))
+	for _, r := range backfilledRanges {
+		ranges = append(ranges, IndexedRange{FromBlock: uint64(r.StartBlock), ToBlock: uint64(r.EndBlock)})
+	}
+	return mergeIndexedRanges(ranges), nil
+}
+
+// Apply records the changes and a BackfilledRange for the block range in a single transaction.
+// Applying the same state diffs twice is a no-op.
+func (s *DBClassHistoryStore) Apply(rows StateDiffRows, fromBlock uint64, toBlock uint64) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		backfilledRange := models.BackfilledRange{
+			BackfillID: classHistoryBackfillID,
+			DataType:   types.StateDiffs,
+			Network:    types.StarkNet,
+			StartBlock: int(fromBlock),
+			EndBlock:   int(toBlock),
+		}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&backfilledRange).Error; err != nil {
+			return fmt.Errorf("failed to record the indexed range %d-%d: %w", fromBlock, toBlock, err)
+		}
+		for _, change := range classChanges(rows) {
+			err := tx.Model(&models.ContractClassHistory{}).
+				Where("contract_address = ? AND from_block < ? AND (to_block IS NULL OR to_block >= ?)", change.contractAddress, change.blockNumber, change.blockNumber).
+				Update("to_block", change.blockNumber-1).Error
+			if err != nil {
+				return fmt.Errorf("failed to close class history of %s: %w", change.contractAddress, err)
+			}
+
+			// A change older than the last period, applied from an earlier range, ends where the next period starts.
+			entry := models.ContractClassHistory{
+				ContractAddress: change.contractAddress,
+				FromBlock:       change.blockNumber,
+				ClassHash:       change.classHash,
+			}
+			var nextPeriods []models.ContractClassHistory
+			err = tx.Where("contract_address = ? AND from_block > ?", change.contractAddress, change.blockNumber).
+				Order("from_block").Limit(1).Find(&nextPeriods).Error
+			if err != nil {
+				return fmt.Errorf("failed to load class history of %s: %w", change.contractAddress, err)
+			}
+			if len(nextPeriods) > 0 {
+				entry.ToBlock = sql.NullInt64{Int64: int64(nextPeriods[0].FromBlock - 1), Valid: true}
+			}
+			err = tx.Clauses(clause.OnConflict{DoUpdates: clause.AssignmentColumns([]string{"class_hash"})}).Create(&entry).Error
+			if err != nil {
+				return fmt.Errorf("failed to write class history of %s: %w", change.contractAddress, err)
+			}
+		}
+		return nil
+	})
+}
+
+// DefaultClassHistoryChunkSize is the number of blocks whose state diffs IndexClassHistory applies at once.
+const DefaultClassHistoryChunkSize = 1000
+
+// IndexClassHistory applies the state diffs of a block range to a class history index in chunks of
+// chunkSize blocks, so that only the state diffs of one chunk are held in memory and an interrupted
+// run keeps the chunks applied so far. Chunks already covered by the index are skipped.
+func IndexClassHistory(ctx context.Context, url string, store ClassHistoryStore, fromBlock uint64, toBlock uint64, chunkSize uint64) error {
+	if chunkSize == 0 {
+		chunkSize = DefaultClassHistoryChunkSize
+	}
+	ranges, err := store.IndexedRanges()
+	if err != nil {
+		return err
+	}
+	for start := fromBlock; start <= toBlock; start += chunkSize {
+		end := start + chunkSize - 1
+		if end > toBlock || end < start {
+			end = toBlock
+		}
+		indexed := false
+		for _, r := range ranges {
+			indexed = indexed || (r.FromBlock <= start && r.ToBlock >= end)
+		}
+		if !indexed {
+			rows, err := GetStateDiffs(ctx, url, start, end)
+			if err != nil {
+				return err
+			}
+			if err := store.Apply(rows, start, end); err != nil {
+				return err
+			}
+		}
+		if end == toBlock {
+			break
+		}
+	}
+	return nil
+}
+
+// ImplementationHistory returns the class periods of a contract within a block range. It answers the
+// parts of the range covered by the index from the index, and searches the other parts with a binary
+// search over starknet_getClassHashAt with the resolver. The resolver also gives the class at the start
+// of an indexed part when the index has no change of the contract since the start of its indexed range.
+// The first period starts at fromBlock or at the deployment, and the last one is left open.
+func ImplementationHistory(ctx context.Context, store ClassHistoryStore, resolver ClassHashResolver, contractAddress string, fromBlock uint64, toBlock uint64) ([]models.ContractClassHistory, error) {
+	var entries []models.ContractClassHistory
+	var ranges []IndexedRange
+	if store != nil {
+		var err error
+		if entries, err = store.History(contractAddress); err != nil {
+			return nil, err
+		}
+		if ranges, err = store.IndexedRanges(); err != nil {
+			return nil, err
+		}
+	}
+
+	classAt := func(blockNumber uint64) (string, error) {
+		if resolver == nil {
+			return "", fmt.Errorf("the class of %s at block %d is not indexed and there is no resolver", contractAddress, blockNumber)
+		}
+		classHash, err := resolver.ClassHashAt(ctx, contractAddress, blockNumber)
+		if errors.Is(err, ErrContractNotFound) {
+			return "", nil
+		}
+		if err != nil {
+			return "", err
+		}
+		return normalizeHex(classHash), nil
+	}
+
+	var startClass string
+	var changes []classChange
+	current := func() string {
+		if n := len(changes); n > 0 {
+			return changes[n-1].classHash
+		}
+		return startClass
+	}
+	// addPart appends the class at the start of a part and the changes within it.
+	addPart := func(partFrom uint64, partClass string, partChanges []classChange) {
+		if partFrom == fromBlock {
+			startClass = partClass
+		} else if partClass != current() {
+			changes = append(changes, classChange{blockNumber: partFrom, classHash: partClass})
+		}
+		for _, change := range partChanges {
+			if change.classHash != current() {
+				changes = append(changes, change)
+			}
+		}
+	}
+	searchPart := func(partFrom uint64, partTo uint64) error {
+		if resolver == nil {
+			return fmt.Errorf("blocks %d-%d of %s are not indexed and there is no resolver", partFrom, partTo, contractAddress)
+		}
+		partClass, partChanges, err := bisectChanges(partFrom, partTo, classAt)
+		if err != nil {
+			return err
+		}
+		addPart(partFrom, partClass, partChanges)
+		return nil
+	}
+
+	next := fromBlock
+	for _, r := range ranges {
+		if r.ToBlock < next || r.FromBlock > toBlock {
+			continue
+		}
+		if r.FromBlock > next {
+			if err := searchPart(next, r.FromBlock-1); err != nil {
+				return nil, err
+			}
+			next = r.FromBlock
+		}
+		partTo := min(r.ToBlock, toBlock)
+
+		// An index starting at genesis knows every deployment.
+		partClass, known := "", r.FromBlock == 0
+		var partChanges []classChange
+		for _, entry := range entries {
+			switch {
+			case entry.FromBlock <= next && (!entry.ToBlock.Valid || uint64(entry.ToBlock.Int64) >= next):
+				// The period holding the start of the part is only known if it began inside the indexed range.
+				partClass, known = normalizeHex(entry.ClassHash), entry.FromBlock >= r.FromBlock
+			case entry.FromBlock > next && entry.FromBlock <= partTo:
+				partChanges = append(partChanges, classChange{blockNumber: entry.FromBlock, classHash: normalizeHex(entry.ClassHash)})
+			}
+		}
+		if !known {
+			var err error
+			if partClass, err = classAt(next); err != nil {
+				return nil, err
+			}
+		}
+		addPart(next, partClass, partChanges)
+		if partTo == toBlock {
+			return periodsFromChanges(contractAddress, fromBlock, startClass, changes), nil
+		}
+		next = partTo + 1
+	}
+	if err := searchPart(next, toBlock); err != nil {
+		return nil, err
+	}
+	return periodsFromChanges(contractAddress, fromBlock, startClass, changes), nil
+}
+
+// SearchClassHistory finds the class periods of a contract within a block range by binary search over
+// the class hash at each block. The first period starts at fromBlock or at the deployment, and the last
+// one is left open. A class that is replaced and later restored between two probed blocks is not detected.
+// The resolver must not cache class hashes per contract.
+func SearchClassHistory(ctx context.Context, resolver ClassHashResolver, contractAddress string, fromBlock uint64, toBlock uint64) ([]models.ContractClassHistory, error) {
+	classAt := func(blockNumber uint64) (string, error) {
+		classHash, err := resolver.ClassHashAt(ctx, contractAddress, blockNumber)
+		if errors.Is(err, ErrContractNotFound) {
+			return "", nil
+		}
+		if err != nil {
+			return "", err
+		}
+		return normalizeHex(classHash), nil
+	}
+
+	startClass, changes, err := bisectChanges(fromBlock, toBlock, classAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return periodsFromChanges(contractAddress, fromBlock, startClass, changes), nil
+}
+
+// periodsFromChanges turns the class at fromBlock and the later changes into class periods.
+// An empty class means the contract has no class, e.g. before it is deployed.
+func periodsFromChanges(contractAddress string, fromBlock uint64, startClass string, changes []classChange) []models.ContractClassHistory {
+	address := normalizeHex(contractAddress)
+	var history []models.ContractClassHistory
+	if startClass != "" {
+		history = append(history, models.ContractClassHistory{ContractAddress: address, FromBlock: fromBlock, ClassHash: startClass})
+	}
+	for _, change := range changes {
+		if n := len(history); n > 0 && !history[n-1].ToBlock.Valid {
+			history[n-1].ToBlock = sql.NullInt64{Int64: int64(change.blockNumber - 1), Valid: true}
+		}
+		if change.classHash == "" {
+			continue
+		}
+		history = append(history, models.ContractClassHistory{ContractAddress: address, FromBlock: change.blockNumber, ClassHash: change.classHash})
+	}
+	return history
+}
+
+// bisectChanges finds the blocks at which valueAt changes within a block range by binary search.
+// It returns the value at fromBlock and the changes in block order.
+func bisectChanges(fromBlock uint64, toBlock uint64, valueAt func(uint64) (string, error)) (string, []classChange, error) {
+	startValue, err := valueAt(fromBlock)
+	if err != nil {
+		return "", nil, err
+	}
+	endValue, err := valueAt(toBlock)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var changes []classChange
+	var search func(lo uint64, loValue string, hi uint64, hiValue string) error
+	search = func(lo uint64, loValue string, hi uint64, hiValue string) error {
+		if loValue == hiValue {
+			return nil
+		}
+		if hi-lo == 1 {
+			changes = append(changes, classChange{blockNumber: hi, classHash: hiValue})
+			return nil
+		}
+		mid := lo + (hi-lo)/2
+		midValue, err := valueAt(mid)
+		if err != nil {
+			return err
+		}
+		if err := search(lo, loValue, mid, midValue); err != nil {
+			return err
+		}
+		return search(mid, midValue, hi, hiValue)
+	}
+	if err := search(fromBlock, startValue, toBlock, endValue); err != nil {
+		return "", nil, err
+	}
+	return startValue, changes, nil
+}
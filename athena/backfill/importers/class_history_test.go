@@ -0,0 +1,215 @@
+package importers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/BlocSoc-iitr/Athena/athena/database/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func TestMemoryClassHistoryStore(t *testing.T) {
+	store := NewMemoryClassHistoryStore()
+	rows := StateDiffRows{
+		DeployedContracts: []models.DeployedContract{{BlockNumber: 10, ContractAddress: "0x0a", ClassHash: "0xc1"}},
+		ReplacedClasses: []models.ReplacedClass{
+			{BlockNumber: 50, ContractAddress: "0xa", ClassHash: "0xc2"},
+			{BlockNumber: 20, ContractAddress: "0xa", ClassHash: "0xc3"},
+		},
+	}
+	assert.NoError(t, store.Apply(rows, 0, 100))
+	assert.NoError(t, store.Apply(rows, 0, 100), "applying the same state diffs twice is a no-op")
+
+	history, err := store.History("0x000a")
+	assert.NoError(t, err)
+	assert.Len(t, history, 3)
+	assert.Equal(t, "0xc1", history[0].ClassHash)
+	assert.Equal(t, int64(19), history[0].ToBlock.Int64)
+	assert.Equal(t, "0xc3", history[1].ClassHash)
+	assert.Equal(t, int64(49), history[1].ToBlock.Int64)
+	assert.False(t, history[2].ToBlock.Valid)
+
+	history, err = ImplementationHistory(context.Background(), store, nil, "0xa", 25, 40)
+	assert.NoError(t, err)
+	assert.Len(t, history, 1)
+	assert.Equal(t, "0xc3", history[0].ClassHash)
+}
+
+func TestClassHistoryStoresApplyRangesOutOfOrder(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: gormlogger.Discard})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.ContractClassHistory{}, &models.BackfilledRange{}))
+
+	stores := map[string]ClassHistoryStore{
+		"memory": NewMemoryClassHistoryStore(),
+		"db":     NewDBClassHistoryStore(db),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			// The replacement at block 50 is applied before the deployment and replacement before it.
+			require.NoError(t, store.Apply(StateDiffRows{ReplacedClasses: []models.ReplacedClass{{BlockNumber: 50, ContractAddress: "0xa", ClassHash: "0xc3"}}}, 41, 60))
+			require.NoError(t, store.Apply(StateDiffRows{
+				DeployedContracts: []models.DeployedContract{{BlockNumber: 10, ContractAddress: "0xa", ClassHash: "0xc1"}},
+				ReplacedClasses:   []models.ReplacedClass{{BlockNumber: 20, ContractAddress: "0xa", ClassHash: "0xc2"}},
+			}, 0, 40))
+
+			history, err := store.History("0xa")
+			require.NoError(t, err)
+			require.Len(t, history, 3)
+			assert.Equal(t, "0xc1", history[0].ClassHash)
+			assert.Equal(t, int64(19), history[0].ToBlock.Int64)
+			assert.Equal(t, "0xc2", history[1].ClassHash)
+			assert.Equal(t, uint64(20), history[1].FromBlock)
+			assert.Equal(t, int64(49), history[1].ToBlock.Int64)
+			assert.Equal(t, "0xc3", history[2].ClassHash)
+			assert.False(t, history[2].ToBlock.Valid)
+		})
+	}
+}
+
+func TestIndexClassHistoryIndexesChunks(t *testing.T) {
+	var mu sync.Mutex
+	var requested []uint64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Params struct {
+				BlockID struct {
+					BlockNumber uint64 `json:"block_number"`
+				} `json:"block_id"`
+			} `json:"params"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		number := req.Params.BlockID.BlockNumber
+		mu.Lock()
+		requested = append(requested, number)
+		mu.Unlock()
+		deployed := "[]"
+		if number == 9 {
+			deployed = `[{"address": "0xa", "class_hash": "0xc1"}]`
+		}
+		fmt.Fprintf(w, `{"jsonrpc": "2.0", "id": 1, "result": {"state_diff": {
+			"storage_diffs": [], "deployed_contracts": %s, "declared_classes": [],
+			"deprecated_declared_classes": [], "replaced_classes": [], "nonces": []
+		}}}`, deployed)
+	}))
+	defer server.Close()
+
+	store := NewMemoryClassHistoryStore()
+	require.NoError(t, store.Apply(StateDiffRows{}, 4, 7))
+	require.NoError(t, IndexClassHistory(context.Background(), server.URL, store, 0, 9, 4))
+
+	// Blocks 4 to 7 are already indexed.
+	assert.ElementsMatch(t, []uint64{0, 1, 2, 3, 8, 9}, requested)
+	ranges, err := store.IndexedRanges()
+	require.NoError(t, err)
+	assert.Equal(t, []IndexedRange{{FromBlock: 0, ToBlock: 9}}, ranges)
+	history, err := store.History("0xa")
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, uint64(9), history[0].FromBlock)
+}
+
+func TestImplementationHistoryFallsBackToBinarySearch(t *testing.T) {
+	calls := 0
+	resolver := ClassHashResolverFunc(func(_ context.Context, _ string, blockNumber uint64) (string, error) {
+		calls++
+		switch {
+		case blockNumber < 1200:
+			return "", ErrContractNotFound
+		case blockNumber < 15000:
+			return "0x0c1", nil
+		default:
+			return "0x0c2", nil
+		}
+	})
+
+	history, err := ImplementationHistory(context.Background(), NewMemoryClassHistoryStore(), resolver, "0xa", 1000, 21000)
+	assert.NoError(t, err)
+	assert.Len(t, history, 2)
+	assert.Equal(t, uint64(1200), history[0].FromBlock)
+	assert.Equal(t, "0xc1", history[0].ClassHash)
+	assert.Equal(t, int64(14999), history[0].ToBlock.Int64)
+	assert.Equal(t, uint64(15000), history[1].FromBlock)
+	assert.False(t, history[1].ToBlock.Valid)
+	assert.Less(t, calls, 100)
+}
+
+func TestImplementationHistorySearchesUnindexedBlocks(t *testing.T) {
+	var probed []uint64
+	resolver := ClassHashResolverFunc(func(_ context.Context, _ string, blockNumber uint64) (string, error) {
+		probed = append(probed, blockNumber)
+		switch {
+		case blockNumber < 500:
+			return "", ErrContractNotFound
+		case blockNumber < 1500:
+			return "0xc1", nil
+		case blockNumber < 2500:
+			return "0xc2", nil
+		default:
+			return "0xc3", nil
+		}
+	})
+
+	// The contract is deployed before the indexed range and upgraded inside and after it.
+	store := NewMemoryClassHistoryStore()
+	rows := StateDiffRows{ReplacedClasses: []models.ReplacedClass{{BlockNumber: 1500, ContractAddress: "0xa", ClassHash: "0xc2"}}}
+	assert.NoError(t, store.Apply(rows, 1000, 2000))
+
+	history, err := ImplementationHistory(context.Background(), store, resolver, "0xa", 0, 3000)
+	assert.NoError(t, err)
+	assert.Len(t, history, 3)
+	assert.Equal(t, uint64(500), history[0].FromBlock)
+	assert.Equal(t, "0xc1", history[0].ClassHash)
+	assert.Equal(t, int64(1499), history[0].ToBlock.Int64)
+	assert.Equal(t, uint64(1500), history[1].FromBlock)
+	assert.Equal(t, int64(2499), history[1].ToBlock.Int64)
+	assert.Equal(t, "0xc3", history[2].ClassHash)
+	assert.False(t, history[2].ToBlock.Valid)
+	for _, blockNumber := range probed {
+		assert.False(t, blockNumber > 1000 && blockNumber <= 2000, "indexed block %d was searched", blockNumber)
+	}
+
+	_, err = ImplementationHistory(context.Background(), store, nil, "0xa", 0, 3000)
+	assert.Error(t, err, "unindexed blocks need a resolver")
+}
+
+func TestDBClassHistoryStoreIndexedRanges(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: gormlogger.Discard})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.ContractClassHistory{}, &models.BackfilledRange{}))
+
+	store := NewDBClassHistoryStore(db)
+	rows := StateDiffRows{DeployedContracts: []models.DeployedContract{{BlockNumber: 10, ContractAddress: "0xa", ClassHash: "0xc1"}}}
+	require.NoError(t, store.Apply(rows, 0, 20))
+	require.NoError(t, store.Apply(rows, 0, 20))
+	require.NoError(t, store.Apply(StateDiffRows{}, 21, 30))
+	require.NoError(t, store.Apply(StateDiffRows{}, 50, 60))
+
+	ranges, err := store.IndexedRanges()
+	require.NoError(t, err)
+	assert.Equal(t, []IndexedRange{{FromBlock: 0, ToBlock: 30}, {FromBlock: 50, ToBlock: 60}}, ranges)
+
+	history, err := ImplementationHistory(context.Background(), store, nil, "0xa", 5, 30)
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, uint64(10), history[0].FromBlock)
+}
+
+func TestGetClassHashAtContractNotFound(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"jsonrpc": "2.0", "id": 1, "error": {"code": 20, "message": "Contract not found"}}`))
+	}))
+	defer server.Close()
+
+	_, err := GetClassHashAt(context.Background(), server.URL, "0xa", 1)
+	assert.ErrorIs(t, err, ErrContractNotFound)
+}
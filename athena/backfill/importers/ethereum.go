@@ -0,0 +1,530 @@
+package importers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/BlocSoc-iitr/Athena/athena/database/models"
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultEthLogsBlockRange is the number of blocks requested per eth_getLogs call before splitting.
+const DefaultEthLogsBlockRange uint64 = 2000
+
+// DefaultEthWorkers is the number of blocks or receipts GetEthBlocks and GetEthFullBlocks fetch concurrently.
+// The Starknet block importers use the same bound.
+const DefaultEthWorkers = 16
+
+type EthTransaction struct {
+	Hash                 string `json:"hash"`
+	BlockNumber          string `json:"blockNumber"`
+	TransactionIndex     string `json:"transactionIndex"`
+	Type                 string `json:"type"`
+	From                 string `json:"from"`
+	To                   string `json:"to"`
+	Value                string `json:"value"`
+	Nonce                string `json:"nonce"`
+	Gas                  string `json:"gas"`
+	GasPrice             string `json:"gasPrice"`
+	MaxFeePerGas         string `json:"maxFeePerGas"`
+	MaxPriorityFeePerGas string `json:"maxPriorityFeePerGas"`
+	Input                string `json:"input"`
+}
+
+type EthBlock struct {
+	Number        string           `json:"number"`
+	Hash          string           `json:"hash"`
+	ParentHash    string           `json:"parentHash"`
+	StateRoot     string           `json:"stateRoot"`
+	Miner         string           `json:"miner"`
+	GasLimit      string           `json:"gasLimit"`
+	GasUsed       string           `json:"gasUsed"`
+	BaseFeePerGas string           `json:"baseFeePerGas"`
+	BlobGasUsed   string           `json:"blobGasUsed"`
+	ExcessBlobGas string           `json:"excessBlobGas"`
+	Size          string           `json:"size"`
+	ExtraData     string           `json:"extraData"`
+	Timestamp     string           `json:"timestamp"`
+	Transactions  []EthTransaction `json:"transactions"`
+}
+
+type EthLog struct {
+	Address          string   `json:"address"`
+	Topics           []string `json:"topics"`
+	Data             string   `json:"data"`
+	BlockNumber      string   `json:"blockNumber"`
+	BlockHash        string   `json:"blockHash"`
+	TransactionHash  string   `json:"transactionHash"`
+	TransactionIndex string   `json:"transactionIndex"`
+	LogIndex         string   `json:"logIndex"`
+	Removed          bool     `json:"removed"`
+}
+
+type EthReceipt struct {
+	TransactionHash   string   `json:"transactionHash"`
+	Status            string   `json:"status"`
+	GasUsed           string   `json:"gasUsed"`
+	CumulativeGasUsed string   `json:"cumulativeGasUsed"`
+	EffectiveGasPrice string   `json:"effectiveGasPrice"`
+	ContractAddress   string   `json:"contractAddress"`
+	Logs              []EthLog `json:"logs"`
+}
+
+// EthFullBlock is a block with its transactions and their receipts, in transaction order.
+type EthFullBlock struct {
+	Block    EthBlock
+	Receipts []EthReceipt
+}
+
+// GetEthBlocks fetches the blocks of a range with their full transactions, ordered by block number.
+func GetEthBlocks(ctx context.Context, url string, fromBlock uint64, toBlock uint64) ([]EthBlock, error) {
+	blocks := make([]EthBlock, toBlock-fromBlock+1)
+	err := forEachBlock(ctx, len(blocks), func(ctx context.Context, i int) error {
+		block, err := getEthBlock(ctx, url, fromBlock+uint64(i))
+		blocks[i] = block
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return blocks, nil
+}
+
+// forEachBlock calls fn for the indexes 0 to n-1 with at most DefaultEthWorkers calls running
+// concurrently. It stops at the first error and returns it.
+func forEachBlock(ctx context.Context, n int, fn func(ctx context.Context, i int) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	errChan := make(chan error, 1)
+	workers := make(chan struct{}, DefaultEthWorkers)
+	for i := 0; i < n; i++ {
+		select {
+		case workers <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-workers }()
+			if err := fn(ctx, i); err != nil {
+				select {
+				case errChan <- err:
+					cancel()
+				default:
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	select {
+	case err := <-errChan:
+		return err
+	default:
+		return ctx.Err()
+	}
+}
+
+func getEthBlock(ctx context.Context, url string, blockNumber uint64) (EthBlock, error) {
+	resp, err := MakeRPCCall(ctx, url, "eth_getBlockByNumber", []interface{}{toHexQuantity(blockNumber), true})
+	if err != nil {
+		return EthBlock{}, fmt.Errorf("failed to get block %d: %v", blockNumber, err)
+	}
+	var block EthBlock
+	if err := json.Unmarshal(resp.Result, &block); err != nil {
+		return EthBlock{}, fmt.Errorf("failed to unmarshal block %d: %v", blockNumber, err)
+	}
+	return block, nil
+}
+
+// GetEthBlockReceipts fetches the receipts of every transaction of a block with eth_getBlockReceipts.
+func GetEthBlockReceipts(ctx context.Context, url string, blockNumber uint64) ([]EthReceipt, error) {
+	resp, err := MakeRPCCall(ctx, url, "eth_getBlockReceipts", []interface{}{toHexQuantity(blockNumber)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get receipts of block %d: %v", blockNumber, err)
+	}
+	var receipts []EthReceipt
+	if err := json.Unmarshal(resp.Result, &receipts); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal receipts of block %d: %v", blockNumber, err)
+	}
+	return receipts, nil
+}
+
+// GetEthFullBlocks fetches the blocks of a range with their transactions and receipts.
+func GetEthFullBlocks(ctx context.Context, url string, fromBlock uint64, toBlock uint64) ([]EthFullBlock, error) {
+	blocks, err := GetEthBlocks(ctx, url, fromBlock, toBlock)
+	if err != nil {
+		return nil, err
+	}
+
+	fullBlocks := make([]EthFullBlock, len(blocks))
+	err = forEachBlock(ctx, len(blocks), func(ctx context.Context, i int) error {
+		receipts, err := GetEthBlockReceipts(ctx, url, fromBlock+uint64(i))
+		fullBlocks[i] = EthFullBlock{Block: blocks[i], Receipts: receipts}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fullBlocks, nil
+}
+
+// EthLogFilter restricts the logs fetched with eth_getLogs to the contracts Addresses and the
+// Topics, requested in chunks of BlockRange blocks, see GetEthLogs. Empty fields do not filter.
+type EthLogFilter struct {
+	Addresses  []string
+	Topics     [][]string
+	BlockRange uint64
+}
+
+// GetEthLogs fetches logs with eth_getLogs in chunks of blockRange blocks. A chunk the node rejects,
+// usually because it has too many results, is split in half until it succeeds or is a single block.
+// addresses and topics are optional filters; topics holds the accepted values of each topic position.
+func GetEthLogs(ctx context.Context, url string, fromBlock uint64, toBlock uint64, addresses []string, topics [][]string, blockRange uint64) ([]EthLog, error) {
+	if blockRange == 0 {
+		blockRange = DefaultEthLogsBlockRange
+	}
+
+	var logs []EthLog
+	for start := fromBlock; start <= toBlock; start += blockRange {
+		end := start + blockRange - 1
+		if end > toBlock {
+			end = toBlock
+		}
+		chunk, err := getEthLogsSplitting(ctx, url, start, end, addresses, topics)
+		if err != nil {
+			return nil, err
+		}
+		logs = append(logs, chunk...)
+	}
+	return logs, nil
+}
+
+func getEthLogsSplitting(ctx context.Context, url string, fromBlock uint64, toBlock uint64, addresses []string, topics [][]string) ([]EthLog, error) {
+	logs, err := getEthLogs(ctx, url, fromBlock, toBlock, addresses, topics)
+	var rpcErr *rpcError
+	if err == nil || fromBlock == toBlock || !errors.As(err, &rpcErr) {
+		return logs, err
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"from_block": fromBlock,
+		"to_block":   toBlock,
+	}).Debugf("splitting eth_getLogs range: %v", err)
+	mid := fromBlock + (toBlock-fromBlock)/2
+	first, err := getEthLogsSplitting(ctx, url, fromBlock, mid, addresses, topics)
+	if err != nil {
+		return nil, err
+	}
+	second, err := getEthLogsSplitting(ctx, url, mid+1, toBlock, addresses, topics)
+	if err != nil {
+		return nil, err
+	}
+	return append(first, second...), nil
+}
+
+func getEthLogs(ctx context.Context, url string, fromBlock uint64, toBlock uint64, addresses []string, topics [][]string) ([]EthLog, error) {
+	filter := map[string]interface{}{
+		"fromBlock": toHexQuantity(fromBlock),
+		"toBlock":   toHexQuantity(toBlock),
+	}
+	if len(addresses) > 0 {
+		filter["address"] = addresses
+	}
+	if len(topics) > 0 {
+		topicFilter := make([]interface{}, len(topics))
+		for i, position := range topics {
+			if len(position) > 0 {
+				topicFilter[i] = position
+			}
+		}
+		filter["topics"] = topicFilter
+	}
+
+	resp, err := MakeRPCCall(ctx, url, "eth_getLogs", []interface{}{filter})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get logs from block %d to %d: %w", fromBlock, toBlock, err)
+	}
+	var logs []EthLog
+	if err := json.Unmarshal(resp.Result, &logs); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal logs from block %d to %d: %v", fromBlock, toBlock, err)
+	}
+	return logs, nil
+}
+
+// EthBlockToModel converts an RPC block into an EVMBlock.
+func EthBlockToModel(block EthBlock) (models.EVMBlock, error) {
+	blockNumber, err := hexToUint64(block.Number)
+	if err != nil {
+		return models.EVMBlock{}, fmt.Errorf("invalid block number %q: %v", block.Number, err)
+	}
+	timestamp, _ := hexToUint64(block.Timestamp)
+	gasLimit, _ := hexToUint64(block.GasLimit)
+	gasUsed, _ := hexToUint64(block.GasUsed)
+	size, _ := hexToUint64(block.Size)
+	baseFeePerGas, err := hexToNullDecimal(block.BaseFeePerGas)
+	if err != nil {
+		return models.EVMBlock{}, fmt.Errorf("invalid base fee of block %d: %v", blockNumber, err)
+	}
+
+	return models.EVMBlock{
+		AbstractBlock: models.AbstractBlock{
+			BlockNumber: blockNumber,
+			BlockHash:   block.Hash,
+			Timestamp:   int64(timestamp),
+		},
+		ParentHash:       block.ParentHash,
+		StateRoot:        block.StateRoot,
+		Miner:            strings.ToLower(block.Miner),
+		GasLimit:         gasLimit,
+		GasUsed:          gasUsed,
+		BaseFeePerGas:    baseFeePerGas,
+		BlobGasUsed:      hexToNullInt64(block.BlobGasUsed),
+		ExcessBlobGas:    hexToNullInt64(block.ExcessBlobGas),
+		Size:             size,
+		ExtraData:        block.ExtraData,
+		TransactionCount: len(block.Transactions),
+	}, nil
+}
+
+// EthTransactionsFromBlock converts the transactions of a block, joined with their receipts, into EVMTransactions.
+func EthTransactionsFromBlock(block EthFullBlock) ([]models.EVMTransaction, error) {
+	receipts := make(map[string]EthReceipt, len(block.Receipts))
+	for _, receipt := range block.Receipts {
+		receipts[strings.ToLower(receipt.TransactionHash)] = receipt
+	}
+	blockNumber, _ := hexToUint64(block.Block.Number)
+	timestamp, _ := hexToUint64(block.Block.Timestamp)
+
+	transactions := make([]models.EVMTransaction, 0, len(block.Block.Transactions))
+	for i, tx := range block.Block.Transactions {
+		receipt := receipts[strings.ToLower(tx.Hash)]
+		txType, _ := hexToUint64(tx.Type)
+		nonce, _ := hexToUint64(tx.Nonce)
+		gas, _ := hexToUint64(tx.Gas)
+		status, _ := hexToUint64(receipt.Status)
+		cumulativeGasUsed, _ := hexToUint64(receipt.CumulativeGasUsed)
+
+		var gasUsed *float64
+		if receipt.GasUsed != "" {
+			value, _ := hexToFloat(receipt.GasUsed)
+			gasUsed = &value
+		}
+		selector := ""
+		if len(tx.Input) >= 10 {
+			selector = strings.ToLower(tx.Input[:10])
+		}
+		value, err := hexToDecimal(tx.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of transaction %s: %v", tx.Hash, err)
+		}
+		gasPrice, err := hexToNullDecimal(tx.GasPrice)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gas price of transaction %s: %v", tx.Hash, err)
+		}
+		maxFeePerGas, err := hexToNullDecimal(tx.MaxFeePerGas)
+		if err != nil {
+			return nil, fmt.Errorf("invalid max fee per gas of transaction %s: %v", tx.Hash, err)
+		}
+		maxPriorityFeePerGas, err := hexToNullDecimal(tx.MaxPriorityFeePerGas)
+		if err != nil {
+			return nil, fmt.Errorf("invalid max priority fee per gas of transaction %s: %v", tx.Hash, err)
+		}
+		effectiveGasPrice, err := hexToNullDecimal(receipt.EffectiveGasPrice)
+		if err != nil {
+			return nil, fmt.Errorf("invalid effective gas price of transaction %s: %v", tx.Hash, err)
+		}
+
+		transactions = append(transactions, models.EVMTransaction{
+			AbstractTransaction: models.AbstractTransaction{
+				TransactionHash:  tx.Hash,
+				BlockNumber:      blockNumber,
+				TransactionIndex: i,
+				Timestamp:        int64(timestamp),
+				GasUsed:          gasUsed,
+			},
+			Type:                 int(txType),
+			FromAddress:          strings.ToLower(tx.From),
+			ToAddress:            nullString(strings.ToLower(tx.To)),
+			Value:                value,
+			Nonce:                nonce,
+			Gas:                  gas,
+			GasPrice:             gasPrice,
+			MaxFeePerGas:         maxFeePerGas,
+			MaxPriorityFeePerGas: maxPriorityFeePerGas,
+			Input:                tx.Input,
+			Selector:             selector,
+			Status:               int(status),
+			CumulativeGasUsed:    cumulativeGasUsed,
+			EffectiveGasPrice:    effectiveGasPrice,
+			ContractAddress:      nullString(strings.ToLower(receipt.ContractAddress)),
+		})
+	}
+	return transactions, nil
+}
+
+// EthLogsFromBlock returns the logs of every receipt of a block.
+func EthLogsFromBlock(block EthFullBlock) []models.EVMLog {
+	var logs []EthLog
+	for _, receipt := range block.Receipts {
+		logs = append(logs, receipt.Logs...)
+	}
+	return EthLogsToModels(logs)
+}
+
+// EthLogsToModels converts RPC logs into EVMLogs.
+func EthLogsToModels(logs []EthLog) []models.EVMLog {
+	result := make([]models.EVMLog, len(logs))
+	for i, ethLog := range logs {
+		blockNumber, _ := hexToUint64(ethLog.BlockNumber)
+		txIndex, _ := hexToUint64(ethLog.TransactionIndex)
+		logIndex, _ := hexToUint64(ethLog.LogIndex)
+		result[i] = models.EVMLog{
+			AbstractEvent: models.AbstractEvent{
+				BlockNumber:      blockNumber,
+				EventIndex:       int(logIndex),
+				TransactionIndex: int(txIndex),
+				ContractAddress:  strings.ToLower(ethLog.Address),
+			},
+			TransactionHash: ethLog.TransactionHash,
+			BlockHash:       ethLog.BlockHash,
+			Topics:          nonNil(ethLog.Topics),
+			Data:            ethLog.Data,
+			Removed:         ethLog.Removed,
+		}
+	}
+	return result
+}
+
+// EVMBlocksToDicts converts blocks into rows for the block_file exporter.
+func EVMBlocksToDicts(blocks []models.EVMBlock) []map[string]interface{} {
+	rows := make([]map[string]interface{}, len(blocks))
+	for i, block := range blocks {
+		rows[i] = map[string]interface{}{
+			"block_number":      int(block.BlockNumber),
+			"block_hash":        block.BlockHash,
+			"parent_hash":       block.ParentHash,
+			"timestamp":         int(block.Timestamp),
+			"miner":             block.Miner,
+			"gas_limit":         int(block.GasLimit),
+			"gas_used":          int(block.GasUsed),
+			"base_fee_per_gas":  nullStringToDict(block.BaseFeePerGas),
+			"transaction_count": block.TransactionCount,
+		}
+	}
+	return rows
+}
+
+// EVMTransactionsToDicts converts transactions into rows for the transaction_file exporter.
+func EVMTransactionsToDicts(transactions []models.EVMTransaction) []map[string]interface{} {
+	rows := make([]map[string]interface{}, len(transactions))
+	for i, tx := range transactions {
+		var gasUsed interface{}
+		if tx.GasUsed != nil {
+			gasUsed = int(*tx.GasUsed)
+		}
+		rows[i] = map[string]interface{}{
+			"transaction_hash":    tx.TransactionHash,
+			"block_number":        int(tx.BlockNumber),
+			"transaction_index":   tx.TransactionIndex,
+			"type":                tx.Type,
+			"from_address":        tx.FromAddress,
+			"to_address":          tx.ToAddress.String,
+			"value":               tx.Value,
+			"nonce":               int(tx.Nonce),
+			"gas":                 int(tx.Gas),
+			"gas_used":            gasUsed,
+			"effective_gas_price": nullStringToDict(tx.EffectiveGasPrice),
+			"status":              tx.Status,
+			"selector":            tx.Selector,
+			"contract_address":    tx.ContractAddress.String,
+		}
+	}
+	return rows
+}
+
+// EVMLogsToDicts converts logs into rows for the event_file exporter.
+func EVMLogsToDicts(logs []models.EVMLog) []map[string]interface{} {
+	rows := make([]map[string]interface{}, len(logs))
+	for i, evmLog := range logs {
+		var decodedParams interface{}
+		if evmLog.DecodedParams != nil {
+			decodedParams = evmLog.DecodedParams
+		}
+		rows[i] = map[string]interface{}{
+			"block_number":      int(evmLog.BlockNumber),
+			"transaction_hash":  evmLog.TransactionHash,
+			"transaction_index": evmLog.TransactionIndex,
+			"log_index":         evmLog.EventIndex,
+			"contract_address":  evmLog.ContractAddress,
+			"topics":            stringsToInterfaces(evmLog.Topics),
+			"data":              evmLog.Data,
+			"event_name":        evmLog.EventName.String,
+			"decoded_params":    decodedParams,
+		}
+	}
+	return rows
+}
+
+func toHexQuantity(value uint64) string {
+	return fmt.Sprintf("0x%x", value)
+}
+
+// hexToDecimal converts a required hex quantity into a decimal string.
+func hexToDecimal(value string) (string, error) {
+	if value == "" {
+		return "", errors.New("missing hex quantity")
+	}
+	result, err := hexToBigInt(value)
+	if err != nil {
+		return "", err
+	}
+	return result.String(), nil
+}
+
+// hexToNullDecimal converts an optional hex quantity into a decimal string, NULL if it is missing.
+func hexToNullDecimal(value string) (sql.NullString, error) {
+	if value == "" {
+		return sql.NullString{}, nil
+	}
+	result, err := hexToDecimal(value)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: result, Valid: true}, nil
+}
+
+func hexToNullInt64(value string) sql.NullInt64 {
+	if value == "" {
+		return sql.NullInt64{}
+	}
+	result, err := hexToUint64(value)
+	if err != nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: int64(result), Valid: true}
+}
+
+func nullStringToDict(value sql.NullString) interface{} {
+	if !value.Valid {
+		return nil
+	}
+	return value.String
+}
+
+func nullInt64ToDict(value sql.NullInt64) interface{} {
+	if !value.Valid {
+		return nil
+	}
+	return int(value.Int64)
+}
@@ -0,0 +1,111 @@
+package importers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/BlocSoc-iitr/Athena/athena/database/models"
+	"github.com/stretchr/testify/assert"
+)
+
+type ethRequest struct {
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+func TestGetEthFullBlocks(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ethRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		var number string
+		json.Unmarshal(req.Params[0], &number)
+		switch req.Method {
+		case "eth_getBlockByNumber":
+			fmt.Fprintf(w, `{"jsonrpc": "2.0", "id": 1, "result": {"number": %q, "hash": "0xb%s", "timestamp": "0x64", "gasUsed": "0x5208", "baseFeePerGas": "0x3b9aca00",
+				"miner": "0xABC", "transactions": [{"hash": "0xt%s", "type": "0x2", "from": "0xF", "to": "0xA", "value": "0xde0b6b3a7640000", "nonce": "0x1", "gas": "0x5208", "input": "0xa9059cbb0000"}]}}`, number, number, number)
+		case "eth_getBlockReceipts":
+			fmt.Fprintf(w, `{"jsonrpc": "2.0", "id": 1, "result": [{"transactionHash": "0xt%s", "status": "0x1", "gasUsed": "0x5208", "effectiveGasPrice": "0x3b9aca00",
+				"logs": [{"address": "0xA", "topics": ["0xddf2"], "data": "0x01", "blockNumber": %q, "transactionHash": "0xt%s", "transactionIndex": "0x0", "logIndex": "0x0"}]}]}`, number, number, number)
+		default:
+			t.Errorf("unexpected method %s", req.Method)
+		}
+	}))
+	defer server.Close()
+
+	blocks, err := GetEthFullBlocks(context.Background(), server.URL, 1, 3)
+	assert.NoError(t, err)
+	assert.Len(t, blocks, 3)
+
+	block, err := EthBlockToModel(blocks[2].Block)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), block.BlockNumber)
+	assert.Equal(t, "1000000000", block.BaseFeePerGas.String)
+	assert.Equal(t, "0xabc", block.Miner)
+
+	transactions, err := EthTransactionsFromBlock(blocks[0])
+	assert.NoError(t, err)
+	assert.Len(t, transactions, 1)
+	assert.Equal(t, "1000000000000000000", transactions[0].Value)
+	assert.False(t, transactions[0].GasPrice.Valid)
+	assert.Equal(t, "1000000000", transactions[0].EffectiveGasPrice.String)
+	assert.Equal(t, 1, transactions[0].Status)
+	assert.Equal(t, float64(21000), *transactions[0].GasUsed)
+	assert.Equal(t, "0xa9059cbb", transactions[0].Selector)
+	assert.False(t, transactions[0].ContractAddress.Valid)
+
+	logs := EthLogsFromBlock(blocks[1])
+	assert.Len(t, logs, 1)
+	assert.Equal(t, uint64(2), logs[0].BlockNumber)
+	assert.Equal(t, "0xa", logs[0].ContractAddress)
+}
+
+func TestEthBlockToModelOptionalAmounts(t *testing.T) {
+	// Blocks before London have no base fee, which is stored as NULL rather than an empty string.
+	block, err := EthBlockToModel(EthBlock{Number: "0x1"})
+	assert.NoError(t, err)
+	assert.False(t, block.BaseFeePerGas.Valid)
+	assert.Nil(t, EVMBlocksToDicts([]models.EVMBlock{block})[0]["base_fee_per_gas"])
+
+	_, err = EthBlockToModel(EthBlock{Number: "0x1", BaseFeePerGas: "0xzz"})
+	assert.ErrorContains(t, err, "invalid base fee of block 1")
+
+	_, err = EthTransactionsFromBlock(EthFullBlock{Block: EthBlock{Transactions: []EthTransaction{{Hash: "0xt1"}}}})
+	assert.ErrorContains(t, err, "invalid value of transaction 0xt1")
+}
+
+func TestGetEthLogsSplitsRejectedRanges(t *testing.T) {
+	var ranges [][2]uint64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ethRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		var filter struct {
+			FromBlock string `json:"fromBlock"`
+			ToBlock   string `json:"toBlock"`
+		}
+		json.Unmarshal(req.Params[0], &filter)
+		from, _ := hexToUint64(filter.FromBlock)
+		to, _ := hexToUint64(filter.ToBlock)
+		ranges = append(ranges, [2]uint64{from, to})
+
+		if to-from >= 25 {
+			w.Write([]byte(`{"jsonrpc": "2.0", "id": 1, "error": {"code": -32005, "message": "query returned more than 10000 results"}}`))
+			return
+		}
+		fmt.Fprintf(w, `{"jsonrpc": "2.0", "id": 1, "result": [{"address": "0xa", "blockNumber": %q, "logIndex": "0x0"}]}`, filter.FromBlock)
+	}))
+	defer server.Close()
+
+	logs, err := GetEthLogs(context.Background(), server.URL, 0, 99, []string{"0xa"}, nil, 100)
+	assert.NoError(t, err)
+	assert.Len(t, logs, 4)
+	assert.Equal(t, [2]uint64{0, 99}, ranges[0])
+
+	models := EthLogsToModels(logs)
+	for i := 1; i < len(models); i++ {
+		assert.Less(t, models[i-1].BlockNumber, models[i].BlockNumber)
+	}
+}
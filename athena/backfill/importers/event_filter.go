@@ -0,0 +1,204 @@
+package importers
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultEventsChunkSize is the number of events requested per starknet_getEvents page.
+const DefaultEventsChunkSize = 100
+
+// EventFilter selects events for starknet_getEvents. Keys holds the accepted values of each key
+// position: the values of a position are alternatives, every position must match, and an empty
+// position matches any value. Events of all Addresses are returned, or of every contract if empty.
+// ChunkSize is the page size of starknet_getEvents, BlockRange and Workers set how the block range
+// is split, see GetEmittedEventsInRanges.
+type EventFilter struct {
+	Addresses  []string
+	Keys       [][]string
+	FromBlock  uint64
+	ToBlock    uint64
+	ChunkSize  int
+	BlockRange uint64
+	Workers    int
+}
+
+// Matches reports whether an event emitted by address with the given keys passes the filter.
+func (f EventFilter) Matches(address string, keys []string) bool {
+	if len(f.Addresses) > 0 {
+		found := false
+		for _, filterAddress := range f.Addresses {
+			if sameHash(filterAddress, address) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	for position, accepted := range f.Keys {
+		if len(accepted) == 0 {
+			continue
+		}
+		if position >= len(keys) {
+			return false
+		}
+		found := false
+		for _, value := range accepted {
+			if sameHash(value, keys[position]) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// GetFilteredEvents fetches the events of every address of the filter concurrently, each split into
+// block ranges by GetEmittedEventsInRanges, and merges them in (block, transaction, event index)
+// order. starknet_getEvents does not return the position of events, so blocks holding events of
+// more than one address are ordered from their receipts.
+func GetFilteredEvents(ctx context.Context, url string, filter EventFilter) ([]EmittedEvent, error) {
+	addresses := filter.Addresses
+	if len(addresses) == 0 {
+		addresses = []string{""}
+	}
+
+	streams := make([][]EmittedEvent, len(addresses))
+	var wg sync.WaitGroup
+	errChan := make(chan error, len(addresses))
+	for i, address := range addresses {
+		wg.Add(1)
+		go func(i int, address string) {
+			defer wg.Done()
+			events, err := GetEmittedEventsInRanges(ctx, url, address, filter)
+			if err != nil {
+				errChan <- err
+				return
+			}
+			streams[i] = events
+		}(i, address)
+	}
+
+	wg.Wait()
+	close(errChan)
+
+	if err, ok := <-errChan; ok {
+		return nil, err
+	}
+	if len(streams) == 1 {
+		return streams[0], nil
+	}
+	return mergeEventStreams(ctx, url, filter, streams)
+}
+
+// mergeEventStreams merges the events of several addresses, each in chain order.
+func mergeEventStreams(ctx context.Context, url string, filter EventFilter, streams [][]EmittedEvent) ([]EmittedEvent, error) {
+	byBlock := make(map[uint64][][]EmittedEvent)
+	for _, stream := range streams {
+		for start := 0; start < len(stream); {
+			end := start
+			for end < len(stream) && stream[end].BlockNumber == stream[start].BlockNumber {
+				end++
+			}
+			blockNumber := stream[start].BlockNumber
+			byBlock[blockNumber] = append(byBlock[blockNumber], stream[start:end])
+			start = end
+		}
+	}
+
+	blockNumbers := make([]uint64, 0, len(byBlock))
+	for blockNumber := range byBlock {
+		blockNumbers = append(blockNumbers, blockNumber)
+	}
+	sort.Slice(blockNumbers, func(i, j int) bool { return blockNumbers[i] < blockNumbers[j] })
+
+	var merged []EmittedEvent
+	for _, blockNumber := range blockNumbers {
+		groups := byBlock[blockNumber]
+		if len(groups) == 1 {
+			merged = append(merged, groups[0]...)
+			continue
+		}
+		events, err := blockEvents(ctx, url, blockNumber, filter)
+		if err != nil {
+			return nil, err
+		}
+		merged = append(merged, events...)
+	}
+	return merged, nil
+}
+
+// blockEvents returns the events of a block that pass the filter, in receipt order.
+func blockEvents(ctx context.Context, url string, blockNumber uint64, filter EventFilter) ([]EmittedEvent, error) {
+	blocks, err := GetBlocksWithReceipts(ctx, url, blockNumber, blockNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []EmittedEvent
+	for _, block := range blocks {
+		blockHash := ""
+		if block.BlockHash != nil {
+			blockHash = *block.BlockHash
+		}
+		for _, tx := range block.Transactions {
+			for _, event := range tx.Receipt.Events {
+				if !filter.Matches(event.FromAddress, event.Keys) {
+					continue
+				}
+				events = append(events, EmittedEvent{
+					FromAddress:     event.FromAddress,
+					Keys:            event.Keys,
+					Data:            event.Data,
+					BlockHash:       blockHash,
+					BlockNumber:     block.BlockNumber,
+					TransactionHash: tx.Receipt.TransactionHash,
+				})
+			}
+		}
+	}
+	return events, nil
+}
+
+// ExportEmittedEventsToCSV exports events to a CSV file with the columns of ExportEventsToCSV.
+func ExportEmittedEventsToCSV(events []EmittedEvent, filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create CSV file: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	header := []string{"Block Number", "Transaction Hash", "Block Hash", "Contract Address", "Keys", "Data"}
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("failed to write header to CSV file: %w", err)
+	}
+	for _, event := range events {
+		record := []string{
+			strconv.FormatUint(event.BlockNumber, 10),
+			event.TransactionHash,
+			event.BlockHash,
+			event.FromAddress,
+			strings.Join(event.Keys, ","),
+			strings.Join(event.Data, ","),
+		}
+		if err := writer.Write(record); err != nil {
+			return fmt.Errorf("failed to write record to CSV file: %w", err)
+		}
+	}
+	return nil
+}
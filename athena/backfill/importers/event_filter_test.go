@@ -0,0 +1,87 @@
+package importers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventFilterMatches(t *testing.T) {
+	filter := EventFilter{
+		Addresses: []string{"0x0a", "0xb"},
+		Keys:      [][]string{{"0x99"}, {}, {"0x1", "0x2"}},
+	}
+	assert.True(t, filter.Matches("0xa", []string{"0x99", "0x5", "0x02"}))
+	assert.True(t, filter.Matches("0xB", []string{"0x099", "0x6", "0x1", "0x7"}))
+	assert.False(t, filter.Matches("0xc", []string{"0x99", "0x5", "0x1"}))
+	assert.False(t, filter.Matches("0xa", []string{"0x98", "0x5", "0x1"}))
+	assert.False(t, filter.Matches("0xa", []string{"0x99", "0x5", "0x3"}))
+	assert.False(t, filter.Matches("0xa", []string{"0x99", "0x5"}))
+
+	assert.True(t, EventFilter{}.Matches("0xc", nil))
+}
+
+func TestGetFilteredEventsMergesAddresses(t *testing.T) {
+	var receiptCalls atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string                 `json:"method"`
+			Params map[string]interface{} `json:"params"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		switch req.Method {
+		case "starknet_getEvents":
+			filter := req.Params["filter"].(map[string]interface{})
+			assert.Equal(t, []interface{}{[]interface{}{"0x99"}, []interface{}{}, []interface{}{"0x1"}}, filter["keys"])
+			switch filter["address"] {
+			case "0xa":
+				w.Write([]byte(`{"jsonrpc": "2.0", "id": 1, "result": {"events": [
+					{"from_address": "0xa", "keys": ["0x99", "0x5", "0x1"], "data": ["0xa1"], "block_number": 10, "transaction_hash": "0xt1"},
+					{"from_address": "0xa", "keys": ["0x99", "0x5", "0x1"], "data": ["0xa2"], "block_number": 12, "transaction_hash": "0xt3"}
+				]}}`))
+			case "0xb":
+				w.Write([]byte(`{"jsonrpc": "2.0", "id": 1, "result": {"events": [
+					{"from_address": "0xb", "keys": ["0x99", "0x6", "0x1"], "data": ["0xb1"], "block_number": 11, "transaction_hash": "0xt2"},
+					{"from_address": "0xb", "keys": ["0x99", "0x6", "0x1"], "data": ["0xb2"], "block_number": 12, "transaction_hash": "0xt3"}
+				]}}`))
+			default:
+				t.Errorf("unexpected address %v", filter["address"])
+			}
+		case "starknet_getBlockWithReceipts":
+			receiptCalls.Add(1)
+			w.Write([]byte(`{"jsonrpc": "2.0", "id": 1, "result": {"block_number": 12, "block_hash": "0xb12", "transactions": [
+				{"transaction": {}, "receipt": {"transaction_hash": "0xt3", "events": [
+					{"from_address": "0xb", "keys": ["0x99", "0x6", "0x1"], "data": ["0xb2"]},
+					{"from_address": "0xc", "keys": ["0x99", "0x6", "0x1"], "data": ["0xc1"]},
+					{"from_address": "0xa", "keys": ["0x99", "0x5", "0x2"], "data": ["0xignored"]},
+					{"from_address": "0xa", "keys": ["0x99", "0x5", "0x1"], "data": ["0xa2"]}
+				]}}
+			]}}`))
+		default:
+			t.Errorf("unexpected method %s", req.Method)
+		}
+	}))
+	defer server.Close()
+
+	filter := EventFilter{
+		Addresses: []string{"0xa", "0xb"},
+		Keys:      [][]string{{"0x99"}, {}, {"0x1"}},
+		FromBlock: 10,
+		ToBlock:   12,
+	}
+	events, err := GetFilteredEvents(context.Background(), server.URL, filter)
+	assert.NoError(t, err)
+
+	var data []string
+	for _, event := range events {
+		data = append(data, event.Data[0])
+	}
+	assert.Equal(t, []string{"0xa1", "0xb1", "0xb2", "0xa2"}, data)
+	assert.Equal(t, "0xb12", events[3].BlockHash)
+	assert.Equal(t, int64(1), receiptCalls.Load())
+}
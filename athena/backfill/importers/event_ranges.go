@@ -0,0 +1,162 @@
+package importers
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultEventsBlockRange is the number of blocks of the sub-ranges GetEmittedEventsInRanges starts with.
+const DefaultEventsBlockRange = 10000
+
+// DefaultEventsWorkers is the number of sub-ranges GetEmittedEventsInRanges fetches concurrently.
+const DefaultEventsWorkers = 8
+
+// maxEventPagesPerRange is the number of pages fetched from a sub-range before its remaining blocks
+// are split into smaller sub-ranges.
+const maxEventPagesPerRange = 10
+
+type eventRange struct {
+	fromBlock uint64
+	toBlock   uint64
+}
+
+// GetEmittedEventsInRanges fetches the events of a contract like GetEmittedEvents, but splits the
+// block range into sub-ranges of filter.BlockRange blocks fetched concurrently by filter.Workers
+// workers, each following its own continuation chain. A sub-range that is still not exhausted after
+// maxEventPagesPerRange pages stops at the last block it reached, and its remaining blocks are split
+// into sub-ranges sized from the event density seen so far. Events are returned in chain order.
+func GetEmittedEventsInRanges(ctx context.Context, url string, address string, filter EventFilter) ([]EmittedEvent, error) {
+	if filter.ChunkSize == 0 {
+		filter.ChunkSize = DefaultEventsChunkSize
+	}
+	if filter.BlockRange == 0 {
+		filter.BlockRange = DefaultEventsBlockRange
+	}
+	if filter.Workers == 0 {
+		filter.Workers = DefaultEventsWorkers
+	}
+	if filter.FromBlock > filter.ToBlock {
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var mu sync.Mutex
+	results := make(map[uint64][]EmittedEvent)
+	var wg sync.WaitGroup
+	errChan := make(chan error, 1)
+	workers := make(chan struct{}, filter.Workers)
+
+	var fetch func(r eventRange)
+	fetch = func(r eventRange) {
+		defer wg.Done()
+		workers <- struct{}{}
+		events, remaining, err := fetchEventRange(ctx, url, address, filter, r)
+		<-workers
+		if err != nil {
+			select {
+			case errChan <- err:
+				cancel()
+			default:
+			}
+			return
+		}
+
+		mu.Lock()
+		results[r.fromBlock] = events
+		mu.Unlock()
+
+		if remaining == nil {
+			return
+		}
+		// The blocks before the stop block held enough events to fill the pages; half that many
+		// blocks per sub-range leaves room for denser blocks ahead.
+		size := (remaining.fromBlock - r.fromBlock) / 2
+		if size == 0 {
+			size = 1
+		}
+		logrus.WithFields(logrus.Fields{
+			"address":    address,
+			"from_block": remaining.fromBlock,
+			"to_block":   remaining.toBlock,
+			"block_size": size,
+		}).Debug("splitting dense starknet_getEvents range")
+		for _, sub := range splitEventRange(*remaining, size, filter.Workers) {
+			wg.Add(1)
+			go fetch(sub)
+		}
+	}
+
+	for _, r := range splitEventRange(eventRange{filter.FromBlock, filter.ToBlock}, filter.BlockRange, 0) {
+		wg.Add(1)
+		go fetch(r)
+	}
+	wg.Wait()
+	close(errChan)
+
+	if err, ok := <-errChan; ok {
+		return nil, err
+	}
+
+	starts := make([]uint64, 0, len(results))
+	for start := range results {
+		starts = append(starts, start)
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i] < starts[j] })
+
+	var events []EmittedEvent
+	for _, start := range starts {
+		events = append(events, results[start]...)
+	}
+	return events, nil
+}
+
+// fetchEventRange follows the continuation chain of a sub-range for up to maxEventPagesPerRange
+// pages. If the chain goes on, the events of the last block reached are dropped and the blocks from
+// that block on are returned as the remaining range, unless the pages hold a single block only.
+func fetchEventRange(ctx context.Context, url string, address string, filter EventFilter, r eventRange) ([]EmittedEvent, *eventRange, error) {
+	var events []EmittedEvent
+	continuationToken := ""
+	for pages := 1; ; pages++ {
+		page, err := getEventsPage(ctx, url, address, filter.Keys, r.fromBlock, r.toBlock, filter.ChunkSize, continuationToken)
+		if err != nil {
+			return nil, nil, err
+		}
+		events = append(events, page.Events...)
+		if page.ContinuationToken == "" {
+			return events, nil, nil
+		}
+		continuationToken = page.ContinuationToken
+
+		if pages < maxEventPagesPerRange || len(events) == 0 {
+			continue
+		}
+		stopBlock := events[len(events)-1].BlockNumber
+		if stopBlock <= r.fromBlock {
+			continue
+		}
+		kept := sort.Search(len(events), func(i int) bool { return events[i].BlockNumber >= stopBlock })
+		return events[:kept], &eventRange{stopBlock, r.toBlock}, nil
+	}
+}
+
+// splitEventRange splits a range into sub-ranges of size blocks. With a positive limit, at most
+// limit sub-ranges are made and the last one holds the rest of the range.
+func splitEventRange(r eventRange, size uint64, limit int) []eventRange {
+	var ranges []eventRange
+	for start := r.fromBlock; start <= r.toBlock; start += size {
+		end := start + size - 1
+		if end > r.toBlock || end < start || (limit > 0 && len(ranges) == limit-1) {
+			end = r.toBlock
+		}
+		ranges = append(ranges, eventRange{start, end})
+		if end == r.toBlock {
+			break
+		}
+	}
+	return ranges
+}
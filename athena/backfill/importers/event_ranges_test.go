@@ -0,0 +1,122 @@
+package importers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type blockID struct {
+	BlockNumber uint64 `json:"block_number"`
+}
+
+// newEventsServer serves starknet_getEvents over a chain of 1000 blocks with an event every 10
+// blocks, and 50 events in each of the blocks 500 to 519.
+func newEventsServer(t *testing.T) (*httptest.Server, func() []eventRange) {
+	var chain []EmittedEvent
+	for block := uint64(0); block < 1000; block++ {
+		count := 0
+		if block%10 == 0 {
+			count = 1
+		}
+		if block >= 500 && block < 520 {
+			count = 50
+		}
+		for i := 0; i < count; i++ {
+			chain = append(chain, EmittedEvent{FromAddress: "0xa", Keys: []string{"0x1"}, Data: []string{fmt.Sprintf("0x%x", i)}, BlockNumber: block})
+		}
+	}
+
+	var mu sync.Mutex
+	var ranges []eventRange
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Params struct {
+				Filter struct {
+					FromBlock         blockID `json:"from_block"`
+					ToBlock           blockID `json:"to_block"`
+					ChunkSize         int     `json:"chunk_size"`
+					ContinuationToken string  `json:"continuation_token"`
+				} `json:"filter"`
+			} `json:"params"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		filter := req.Params.Filter
+		if filter.ContinuationToken == "" {
+			mu.Lock()
+			ranges = append(ranges, eventRange{filter.FromBlock.BlockNumber, filter.ToBlock.BlockNumber})
+			mu.Unlock()
+		}
+
+		var matching []EmittedEvent
+		for _, event := range chain {
+			if event.BlockNumber >= filter.FromBlock.BlockNumber && event.BlockNumber <= filter.ToBlock.BlockNumber {
+				matching = append(matching, event)
+			}
+		}
+		offset, _ := strconv.Atoi(filter.ContinuationToken)
+		page := eventsPage{Events: []EmittedEvent{}}
+		for i := offset; i < len(matching) && i < offset+filter.ChunkSize; i++ {
+			page.Events = append(page.Events, matching[i])
+		}
+		if offset+filter.ChunkSize < len(matching) {
+			page.ContinuationToken = strconv.Itoa(offset + filter.ChunkSize)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": 1, "result": page})
+	}))
+	return server, func() []eventRange {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]eventRange{}, ranges...)
+	}
+}
+
+func TestGetEmittedEventsInRangesMatchesSequentialFetch(t *testing.T) {
+	server, requestedRanges := newEventsServer(t)
+	defer server.Close()
+
+	expected, err := GetEmittedEvents(context.Background(), server.URL, "0xa", nil, 0, 999, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 100-2+20*50, len(expected))
+
+	events, err := GetEmittedEventsInRanges(context.Background(), server.URL, "0xa", EventFilter{
+		FromBlock:  0,
+		ToBlock:    999,
+		ChunkSize:  10,
+		BlockRange: 250,
+		Workers:    3,
+	})
+	assert.NoError(t, err)
+	assert.True(t, assert.ObjectsAreEqual(expected, events), "events differ from the sequential fetch")
+
+	// The dense range 500-749 is split after its first 10 pages reach block 501.
+	ranges := requestedRanges()
+	assert.Contains(t, ranges, eventRange{500, 749})
+	assert.Contains(t, ranges, eventRange{501, 501})
+	for _, r := range ranges[1:] {
+		assert.LessOrEqual(t, r.fromBlock, r.toBlock)
+	}
+}
+
+func TestGetEmittedEventsInRangesReturnsErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"jsonrpc": "2.0", "id": 1, "error": {"code": -32603, "message": "internal error"}}`))
+	}))
+	defer server.Close()
+
+	_, err := GetEmittedEventsInRanges(context.Background(), server.URL, "0xa", EventFilter{FromBlock: 0, ToBlock: 99, BlockRange: 10})
+	assert.ErrorContains(t, err, "internal error")
+}
+
+func TestSplitEventRange(t *testing.T) {
+	assert.Equal(t, []eventRange{{0, 9}, {10, 19}, {20, 25}}, splitEventRange(eventRange{0, 25}, 10, 0))
+	assert.Equal(t, []eventRange{{0, 9}, {10, 25}}, splitEventRange(eventRange{0, 25}, 10, 2))
+	assert.Equal(t, []eventRange{{5, 5}}, splitEventRange(eventRange{5, 5}, 10, 0))
+}
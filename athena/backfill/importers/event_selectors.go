@@ -0,0 +1,362 @@
+package importers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/BlocSoc-iitr/Athena/athena/database/models"
+	"github.com/BlocSoc-iitr/Athena/athena_abi"
+	"github.com/sirupsen/logrus"
+)
+
+// maxEventNesting bounds the depth of event enums walked by ParseAbiEventPaths.
+const maxEventNesting = 8
+
+// AbiEventPath is an event of a contract ABI with the enum variants leading to it from the
+// contract's event enum. A nested variant adds its selector to the keys of emitted events, a flat
+// variant does not, so Selectors are the first keys of every event emitted for the path.
+type AbiEventPath struct {
+	Path      []string
+	TypeName  string
+	Selectors []string
+	Event     athena_abi.AbiEvent
+}
+
+// Name returns the variant path of the event, such as ERC20Event::Transfer.
+func (p AbiEventPath) Name() string {
+	return strings.Join(p.Path, "::")
+}
+
+// MatchesName reports whether the event is named by name: its variant name, its variant path,
+// its full type name or the last segment of its type name.
+func (p AbiEventPath) MatchesName(name string) bool {
+	parts := strings.Split(p.TypeName, "::")
+	return name == p.Path[len(p.Path)-1] || name == p.Name() || name == p.TypeName || name == parts[len(parts)-1]
+}
+
+// MatchesKeys reports whether an event with the given keys was emitted for the path.
+func (p AbiEventPath) MatchesKeys(keys []string) bool {
+	if len(keys) < len(p.Selectors) {
+		return false
+	}
+	for i, selector := range p.Selectors {
+		if !sameHash(selector, keys[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Decode decodes the keys and data of an event emitted for the path.
+func (p AbiEventPath) Decode(keys []string, data []string) (*athena_abi.DecodedEvent, error) {
+	if !p.MatchesKeys(keys) {
+		return nil, fmt.Errorf("event keys do not match %s", p.Name())
+	}
+	keyValues, err := hexStringsToBigInts(keys)
+	if err != nil {
+		return nil, err
+	}
+	dataValues, err := hexStringsToBigInts(data)
+	if err != nil {
+		return nil, err
+	}
+	// AbiEvent.Decode skips the event selector, the last selector of the path.
+	return p.Event.Decode(dataValues, keyValues[len(p.Selectors)-1:])
+}
+
+// ParseAbiEventPaths lists the events of a contract ABI with the selectors of their variant paths.
+// Cairo 1 events are walked from the event enums no other event refers to; legacy events are
+// selected by their name alone.
+func ParseAbiEventPaths(abiJson []map[string]interface{}) ([]AbiEventPath, error) {
+	typeDefs := athena_abi.GroupAbiByType(abiJson)["type_def"]
+	customTypes, err := athena_abi.ParseEnumsAndStructs(typeDefs)
+	if err != nil {
+		sorted, sortErr := athena_abi.TopoSortTypeDefs(typeDefs)
+		if sortErr != nil {
+			return nil, fmt.Errorf("failed to parse ABI types: %w", err)
+		}
+		if customTypes, err = athena_abi.ParseEnumsAndStructs(sorted); err != nil {
+			return nil, fmt.Errorf("failed to parse ABI types: %w", err)
+		}
+	}
+
+	events := make(map[string]map[string]interface{})
+	referenced := make(map[string]bool)
+	var names []string
+	for _, entry := range abiJson {
+		if entry["type"] != "event" {
+			continue
+		}
+		name, _ := entry["name"].(string)
+		events[name] = entry
+		names = append(names, name)
+		for _, variant := range eventVariants(entry) {
+			referenced[variant.typeName] = true
+		}
+	}
+
+	var paths []AbiEventPath
+	var walk func(name string, path []string, selectors []string, depth int) error
+	walk = func(name string, path []string, selectors []string, depth int) error {
+		entry, ok := events[name]
+		if !ok || depth > maxEventNesting {
+			return nil
+		}
+		switch entry["kind"] {
+		case "enum":
+			for _, variant := range eventVariants(entry) {
+				variantPath, variantSelectors := path, selectors
+				if variant.kind != "flat" {
+					variantPath = append(append([]string{}, path...), variant.name)
+					variantSelectors = append(append([]string{}, selectors...), selectorFromName(variant.name))
+				}
+				if err := walk(variant.typeName, variantPath, variantSelectors, depth+1); err != nil {
+					return err
+				}
+			}
+		case "struct":
+			if len(selectors) == 0 {
+				return nil
+			}
+			event, err := athena_abi.ParseAbiEvent(entry, customTypes)
+			if err != nil {
+				return fmt.Errorf("failed to parse event %s: %w", name, err)
+			}
+			if event != nil {
+				paths = append(paths, AbiEventPath{Path: path, TypeName: name, Selectors: selectors, Event: *event})
+			}
+		}
+		return nil
+	}
+
+	for _, name := range names {
+		entry := events[name]
+		switch {
+		case entry["kind"] == "enum" && !referenced[name]:
+			if err := walk(name, nil, nil, 0); err != nil {
+				return nil, err
+			}
+		case entry["kind"] == nil:
+			event, err := athena_abi.ParseAbiEvent(entry, customTypes)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse event %s: %w", name, err)
+			}
+			if event != nil {
+				paths = append(paths, AbiEventPath{Path: []string{name}, TypeName: name, Selectors: []string{selectorFromName(name)}, Event: *event})
+			}
+		}
+	}
+	return paths, nil
+}
+
+// FindAbiEventPaths returns the events of paths named by name, see AbiEventPath.MatchesName.
+func FindAbiEventPaths(paths []AbiEventPath, name string) []AbiEventPath {
+	var matches []AbiEventPath
+	for _, path := range paths {
+		if path.MatchesName(name) {
+			matches = append(matches, path)
+		}
+	}
+	return matches
+}
+
+// EventPathKeys builds the key filter selecting the events of paths. Paths of different lengths
+// share key positions, so the filter may select more events than the paths: check them with
+// AbiEventPath.MatchesKeys.
+func EventPathKeys(paths []AbiEventPath) [][]string {
+	var keys [][]string
+	for _, path := range paths {
+		for i, selector := range path.Selectors {
+			if i == len(keys) {
+				keys = append(keys, nil)
+			}
+			found := false
+			for _, value := range keys[i] {
+				found = found || sameHash(value, selector)
+			}
+			if !found {
+				keys[i] = append(keys[i], selector)
+			}
+		}
+	}
+	// A position only some paths have selectors for has to match any value.
+	for _, path := range paths {
+		for i := len(path.Selectors); i < len(keys); i++ {
+			keys[i] = []string{}
+		}
+	}
+	return keys
+}
+
+type eventVariant struct {
+	name     string
+	typeName string
+	kind     string
+}
+
+func eventVariants(entry map[string]interface{}) []eventVariant {
+	if entry["kind"] != "enum" {
+		return nil
+	}
+	rawVariants, _ := entry["variants"].([]interface{})
+	variants := make([]eventVariant, 0, len(rawVariants))
+	for _, rawVariant := range rawVariants {
+		variant, ok := rawVariant.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _ := variant["name"].(string)
+		typeName, _ := variant["type"].(string)
+		kind, _ := variant["kind"].(string)
+		variants = append(variants, eventVariant{name: name, typeName: typeName, kind: kind})
+	}
+	return variants
+}
+
+// NamedEvent is an event fetched by name and decoded with its ABI event.
+type NamedEvent struct {
+	EmittedEvent
+	EventName     string
+	DecodedParams map[string]interface{}
+}
+
+// ClassPeriodsResolver is a ClassHashResolver that also lists the classes a contract had within a block
+// range, such as ImplementationResolver.
+type ClassPeriodsResolver interface {
+	ClassHashResolver
+	ClassPeriods(ctx context.Context, contractAddress string, fromBlock uint64, toBlock uint64) ([]models.ContractClassHistory, error)
+}
+
+// GetEventsByName fetches the events a contract emits under an event name and decodes them. The key
+// filter is built from the selectors of every event matching the name in the ABIs of the classes of the
+// contract within the block range, or of its class at toBlock if the resolver cannot list them. Each
+// event is decoded with the ABI of the class at its block; events that class cannot decode are skipped
+// with a warning.
+func GetEventsByName(ctx context.Context, url string, registry *AbiRegistry, resolver ClassHashResolver, contractAddress string, eventName string, fromBlock uint64, toBlock uint64, chunkSize int) ([]NamedEvent, error) {
+	var classHashes []string
+	if periodsResolver, ok := resolver.(ClassPeriodsResolver); ok {
+		periods, err := periodsResolver.ClassPeriods(ctx, contractAddress, fromBlock, toBlock)
+		if err != nil {
+			return nil, err
+		}
+		for _, period := range periods {
+			classHashes = append(classHashes, period.ClassHash)
+		}
+	}
+	if len(classHashes) == 0 {
+		classHash, err := resolver.ClassHashAt(ctx, contractAddress, toBlock)
+		if err != nil {
+			return nil, err
+		}
+		classHashes = []string{classHash}
+	}
+
+	// classEvents holds the events matching the name in the ABI of each class, and whether an ABI is stored.
+	type classEvents struct {
+		matches []AbiEventPath
+		found   bool
+	}
+	byClass := make(map[string]classEvents)
+	classMatches := func(classHash string) (classEvents, error) {
+		key := normalizeHex(classHash)
+		if events, ok := byClass[key]; ok {
+			return events, nil
+		}
+		paths, err := registry.EventPaths(key)
+		if err != nil {
+			return classEvents{}, err
+		}
+		events := classEvents{matches: FindAbiEventPaths(paths, eventName), found: paths != nil}
+		byClass[key] = events
+		return events, nil
+	}
+
+	var allMatches []AbiEventPath
+	var missing []string
+	for _, classHash := range classHashes {
+		events, err := classMatches(classHash)
+		if err != nil {
+			return nil, err
+		}
+		if !events.found {
+			missing = append(missing, classHash)
+		}
+		allMatches = append(allMatches, events.matches...)
+	}
+	if len(missing) == len(classHashes) {
+		return nil, fmt.Errorf("no ABI stored for class %s of %s", strings.Join(missing, ", "), contractAddress)
+	}
+	if len(allMatches) == 0 {
+		return nil, fmt.Errorf("event %s not found in the ABI of %s", eventName, contractAddress)
+	}
+
+	events, err := GetFilteredEvents(ctx, url, EventFilter{
+		Addresses: []string{contractAddress},
+		Keys:      EventPathKeys(allMatches),
+		FromBlock: fromBlock,
+		ToBlock:   toBlock,
+		ChunkSize: chunkSize,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var named []NamedEvent
+	skipped := 0
+	for _, event := range events {
+		classHash, err := resolver.ClassHashAt(ctx, contractAddress, event.BlockNumber)
+		if err != nil {
+			return nil, err
+		}
+		class, err := classMatches(classHash)
+		if err != nil {
+			return nil, err
+		}
+		fields := logrus.Fields{
+			"transaction_hash": event.TransactionHash,
+			"block_number":     event.BlockNumber,
+			"class_hash":       classHash,
+		}
+		if !class.found {
+			logrus.WithFields(fields).Warnf("skipping event of %s: no ABI stored for its class", contractAddress)
+			skipped++
+			continue
+		}
+		for _, path := range class.matches {
+			if !path.MatchesKeys(event.Keys) {
+				continue
+			}
+			decoded, err := path.Decode(event.Keys, event.Data)
+			if err != nil {
+				logrus.WithFields(fields).Warnf("skipping event %s of %s: %v", path.Name(), contractAddress, err)
+				skipped++
+				break
+			}
+			named = append(named, NamedEvent{EmittedEvent: event, EventName: path.Name(), DecodedParams: decoded.Data()})
+			break
+		}
+	}
+	if skipped > 0 {
+		logrus.Warnf("skipped %d events of %s that could not be decoded", skipped, contractAddress)
+	}
+	return named, nil
+}
+
+// NamedEventsToDicts converts events fetched by name into rows for a FileResourceExporter.
+func NamedEventsToDicts(events []NamedEvent) []map[string]interface{} {
+	rows := make([]map[string]interface{}, len(events))
+	for i, event := range events {
+		rows[i] = map[string]interface{}{
+			"block_number":     int(event.BlockNumber),
+			"block_hash":       event.BlockHash,
+			"transaction_hash": event.TransactionHash,
+			"contract_address": event.FromAddress,
+			"event_name":       event.EventName,
+			"keys":             stringsToInterfaces(event.Keys),
+			"data":             stringsToInterfaces(event.Data),
+			"decoded_params":   event.DecodedParams,
+		}
+	}
+	return rows
+}
@@ -0,0 +1,202 @@
+package importers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/BlocSoc-iitr/Athena/athena/database/models"
+	"github.com/stretchr/testify/assert"
+)
+
+const componentEventsAbi = `[
+	{"type": "event", "name": "c::Transfer", "kind": "struct", "members": [
+		{"name": "from", "type": "core::integer::u128", "kind": "key"},
+		{"name": "value", "type": "core::integer::u128", "kind": "data"}
+	]},
+	{"type": "event", "name": "comp::Paused", "kind": "struct", "members": [
+		{"name": "account", "type": "core::integer::u128", "kind": "data"}
+	]},
+	{"type": "event", "name": "comp::Transfer", "kind": "struct", "members": [
+		{"name": "amount", "type": "core::integer::u128", "kind": "data"}
+	]},
+	{"type": "event", "name": "comp::Event", "kind": "enum", "variants": [
+		{"name": "Paused", "type": "comp::Paused", "kind": "nested"},
+		{"name": "Transfer", "type": "comp::Transfer", "kind": "nested"}
+	]},
+	{"type": "event", "name": "own::OwnershipTransferred", "kind": "struct", "members": [
+		{"name": "previous_owner", "type": "core::integer::u128", "kind": "key"},
+		{"name": "new_owner", "type": "core::integer::u128", "kind": "key"}
+	]},
+	{"type": "event", "name": "own::Event", "kind": "enum", "variants": [
+		{"name": "OwnershipTransferred", "type": "own::OwnershipTransferred", "kind": "nested"}
+	]},
+	{"type": "event", "name": "c::Event", "kind": "enum", "variants": [
+		{"name": "Transfer", "type": "c::Transfer", "kind": "nested"},
+		{"name": "PausableEvent", "type": "comp::Event", "kind": "nested"},
+		{"name": "OwnableEvent", "type": "own::Event", "kind": "flat"}
+	]}
+]`
+
+func parseComponentEventsAbi(t *testing.T) []AbiEventPath {
+	var abiJSON []map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(componentEventsAbi), &abiJSON))
+	paths, err := ParseAbiEventPaths(abiJSON)
+	assert.NoError(t, err)
+	return paths
+}
+
+func TestParseAbiEventPathsFollowsComponents(t *testing.T) {
+	paths := parseComponentEventsAbi(t)
+
+	selectors := make(map[string][]string)
+	for _, path := range paths {
+		selectors[path.Name()] = path.Selectors
+	}
+	assert.Equal(t, map[string][]string{
+		"Transfer":                {selectorFromName("Transfer")},
+		"PausableEvent::Paused":   {selectorFromName("PausableEvent"), selectorFromName("Paused")},
+		"PausableEvent::Transfer": {selectorFromName("PausableEvent"), selectorFromName("Transfer")},
+		"OwnershipTransferred":    {selectorFromName("OwnershipTransferred")},
+	}, selectors)
+}
+
+func TestFindAbiEventPathsAndKeys(t *testing.T) {
+	paths := parseComponentEventsAbi(t)
+
+	transfers := FindAbiEventPaths(paths, "Transfer")
+	assert.Len(t, transfers, 2)
+	assert.Equal(t, [][]string{
+		{selectorFromName("Transfer"), selectorFromName("PausableEvent")},
+		{},
+	}, EventPathKeys(transfers))
+
+	assert.Len(t, FindAbiEventPaths(paths, "PausableEvent::Transfer"), 1)
+	assert.Len(t, FindAbiEventPaths(paths, "comp::Transfer"), 1)
+	assert.Empty(t, FindAbiEventPaths(paths, "Approval"))
+
+	paused := FindAbiEventPaths(paths, "Paused")
+	assert.Equal(t, [][]string{{selectorFromName("PausableEvent")}, {selectorFromName("Paused")}}, EventPathKeys(paused))
+}
+
+func TestAbiEventPathDecodesNestedKeys(t *testing.T) {
+	paths := parseComponentEventsAbi(t)
+
+	paused := FindAbiEventPaths(paths, "Paused")[0]
+	decoded, err := paused.Decode([]string{selectorFromName("PausableEvent"), selectorFromName("Paused")}, []string{"0x2a"})
+	assert.NoError(t, err)
+	assert.Equal(t, "Paused", decoded.Name())
+	assert.Equal(t, big.NewInt(42), decoded.Data()["account"])
+
+	ownership := FindAbiEventPaths(paths, "OwnershipTransferred")[0]
+	decoded, err = ownership.Decode([]string{selectorFromName("OwnershipTransferred"), "0x1", "0x2"}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(1), decoded.Data()["previous_owner"])
+	assert.Equal(t, big.NewInt(2), decoded.Data()["new_owner"])
+
+	_, err = paused.Decode([]string{selectorFromName("Paused")}, []string{"0x2a"})
+	assert.Error(t, err)
+}
+
+func TestGetEventsByName(t *testing.T) {
+	configDir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configDir)
+	t.Setenv("HOME", configDir)
+	var abiJSON []map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(componentEventsAbi), &abiJSON))
+	stored, err := json.Marshal([]models.ContractABI{{AbiName: "0xc1a55", AbiJson: abiJSON, DecoderOS: StarknetDecoderOS}})
+	assert.NoError(t, err)
+	appDir, err := os.UserConfigDir()
+	assert.NoError(t, err)
+	assert.NoError(t, os.MkdirAll(filepath.Join(appDir, "athena"), 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(appDir, "athena", "contract-abis.json"), stored, 0o644))
+
+	transfer, pausable := selectorFromName("Transfer"), selectorFromName("PausableEvent")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string                 `json:"method"`
+			Params map[string]interface{} `json:"params"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		assert.Equal(t, "starknet_getEvents", req.Method)
+		filter := req.Params["filter"].(map[string]interface{})
+		assert.Equal(t, "0x7", filter["address"])
+		assert.Equal(t, []interface{}{[]interface{}{transfer, pausable}, []interface{}{}}, filter["keys"])
+		events := []EmittedEvent{
+			{FromAddress: "0x7", Keys: []string{transfer, "0x5"}, Data: []string{"0x64"}, BlockNumber: 10, TransactionHash: "0xt1"},
+			{FromAddress: "0x7", Keys: []string{pausable, selectorFromName("Paused")}, Data: []string{"0x1"}, BlockNumber: 11, TransactionHash: "0xt2"},
+			{FromAddress: "0x7", Keys: []string{pausable, transfer}, Data: []string{"0x3"}, BlockNumber: 12, TransactionHash: "0xt3"},
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": 1, "result": map[string]interface{}{"events": events}})
+	}))
+	defer server.Close()
+
+	events, err := GetEventsByName(context.Background(), server.URL, NewAbiRegistry(nil), staticClassHashResolver("0xc1a55"), "0x7", "Transfer", 10, 12, 0)
+	assert.NoError(t, err)
+	assert.Len(t, events, 2)
+	assert.Equal(t, "Transfer", events[0].EventName)
+	assert.Equal(t, map[string]interface{}{"from": big.NewInt(5), "value": big.NewInt(100)}, events[0].DecodedParams)
+	assert.Equal(t, "PausableEvent::Transfer", events[1].EventName)
+	assert.Equal(t, map[string]interface{}{"amount": big.NewInt(3)}, events[1].DecodedParams)
+
+	rows := NamedEventsToDicts(events)
+	assert.Equal(t, 12, rows[1]["block_number"])
+	assert.Equal(t, "0xt3", rows[1]["transaction_hash"])
+
+	_, err = GetEventsByName(context.Background(), server.URL, NewAbiRegistry(nil), staticClassHashResolver("0xc1a55"), "0x7", "Approval", 10, 12, 0)
+	assert.ErrorContains(t, err, "event Approval not found")
+}
+
+func TestGetEventsByNameAcrossUpgrade(t *testing.T) {
+	configDir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configDir)
+	t.Setenv("HOME", configDir)
+	var stored []models.ContractABI
+	for classHash, path := range map[string]string{
+		"0xc1": "../../../athena_abi/abis/v2/erc20_compiled.json",
+		"0xc2": "../../../athena_abi/abis/v2/erc20_key_events.json",
+	} {
+		data, err := os.ReadFile(path)
+		assert.NoError(t, err)
+		var abiJSON []map[string]interface{}
+		assert.NoError(t, json.Unmarshal(data, &abiJSON))
+		stored = append(stored, models.ContractABI{AbiName: classHash, AbiJson: abiJSON, DecoderOS: StarknetDecoderOS})
+	}
+	data, err := json.Marshal(stored)
+	assert.NoError(t, err)
+	appDir, err := os.UserConfigDir()
+	assert.NoError(t, err)
+	assert.NoError(t, os.MkdirAll(filepath.Join(appDir, "athena"), 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(appDir, "athena", "contract-abis.json"), data, 0o644))
+
+	transfer := selectorFromName("Transfer")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The upgrade at block 150 moves from and to into the keys; the event of block 120 is malformed.
+		events := []EmittedEvent{
+			{FromAddress: "0x7", Keys: []string{transfer}, Data: []string{"0x1", "0x2", "0x64", "0x0"}, BlockNumber: 100, TransactionHash: "0xt1"},
+			{FromAddress: "0x7", Keys: []string{transfer}, Data: []string{"0x1"}, BlockNumber: 120, TransactionHash: "0xt2"},
+			{FromAddress: "0x7", Keys: []string{transfer, "0x1", "0x2"}, Data: []string{"0xc8", "0x0"}, BlockNumber: 160, TransactionHash: "0xt3"},
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": 1, "result": map[string]interface{}{"events": events}})
+	}))
+	defer server.Close()
+
+	resolver := NewImplementationResolver(staticClassHashResolver("0xc2"))
+	resolver.SetHistory("0x7", []models.ContractClassHistory{
+		{ContractAddress: "0x7", FromBlock: 0, ToBlock: sql.NullInt64{Int64: 149, Valid: true}, ClassHash: "0xc1"},
+		{ContractAddress: "0x7", FromBlock: 150, ClassHash: "0xc2"},
+	})
+	events, err := GetEventsByName(context.Background(), server.URL, NewAbiRegistry(nil), resolver, "0x7", "Transfer", 100, 200, 0)
+	assert.NoError(t, err)
+	assert.Len(t, events, 2)
+	assert.Equal(t, "0xt1", events[0].TransactionHash)
+	assert.Equal(t, big.NewInt(100), events[0].DecodedParams["value"])
+	assert.Equal(t, "0xt3", events[1].TransactionHash)
+	assert.Equal(t, big.NewInt(200), events[1].DecodedParams["value"])
+}
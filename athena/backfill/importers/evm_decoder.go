@@ -0,0 +1,182 @@
+package importers
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/BlocSoc-iitr/Athena/athena/database/models"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/sirupsen/logrus"
+)
+
+// EVMLogDecoder decodes logs with the Solidity ABIs of their contracts, fetched once per contract.
+type EVMLogDecoder struct {
+	source AbiSource
+	mu     sync.Mutex
+	abis   map[string]*abi.ABI
+}
+
+func NewEVMLogDecoder(source AbiSource) *EVMLogDecoder {
+	return &EVMLogDecoder{source: source, abis: make(map[string]*abi.ABI)}
+}
+
+// contractAbi returns the parsed ABI of a contract, or nil if the source has none.
+func (d *EVMLogDecoder) contractAbi(ctx context.Context, address string) (*abi.ABI, error) {
+	key := strings.ToLower(address)
+	d.mu.Lock()
+	contractAbi, ok := d.abis[key]
+	d.mu.Unlock()
+	if ok {
+		return contractAbi, nil
+	}
+
+	abiJSON, err := d.source.FetchAbi(ctx, address)
+	if err != nil && !errors.Is(err, ErrAbiNotFound) {
+		return nil, err
+	}
+	if err == nil {
+		contractAbi, err = ParseEVMAbi(abiJSON)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse ABI of %s: %v", address, err)
+		}
+	}
+
+	d.mu.Lock()
+	d.abis[key] = contractAbi
+	d.mu.Unlock()
+	return contractAbi, nil
+}
+
+// DecodeLogs fills the event name and decoded parameters of the logs whose contract ABI
+// declares their event. Other logs are left undecoded.
+func (d *EVMLogDecoder) DecodeLogs(ctx context.Context, logs []models.EVMLog) error {
+	for i := range logs {
+		contractAbi, err := d.contractAbi(ctx, logs[i].ContractAddress)
+		if err != nil {
+			return err
+		}
+		if contractAbi == nil {
+			continue
+		}
+		name, params, err := DecodeEVMLog(contractAbi, logs[i].Topics, logs[i].Data)
+		if err != nil {
+			logrus.WithField("contract_address", logs[i].ContractAddress).Debugf("could not decode log: %v", err)
+			continue
+		}
+		logs[i].EventName = sql.NullString{String: name, Valid: true}
+		logs[i].DecodedParams = params
+	}
+	return nil
+}
+
+// ParseEVMAbi parses a Solidity JSON ABI.
+func ParseEVMAbi(abiJSON []map[string]interface{}) (*abi.ABI, error) {
+	data, err := json.Marshal(abiJSON)
+	if err != nil {
+		return nil, err
+	}
+	contractAbi, err := abi.JSON(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return &contractAbi, nil
+}
+
+// DecodeEVMLog decodes a log with the event of the ABI matching its first topic. Indexed
+// parameters of dynamic types only hold the hash of their value, which is returned as is.
+func DecodeEVMLog(contractAbi *abi.ABI, topics []string, data string) (string, map[string]interface{}, error) {
+	if len(topics) == 0 {
+		return "", nil, fmt.Errorf("anonymous logs cannot be decoded")
+	}
+	event, err := contractAbi.EventByID(common.HexToHash(topics[0]))
+	if err != nil {
+		return "", nil, err
+	}
+
+	params := make(map[string]interface{})
+	dataBytes, err := hexutil.Decode(data)
+	if err != nil && data != "" && data != "0x" {
+		return "", nil, fmt.Errorf("invalid log data: %v", err)
+	}
+	if len(dataBytes) > 0 {
+		if err := event.Inputs.UnpackIntoMap(params, dataBytes); err != nil {
+			return "", nil, fmt.Errorf("failed to unpack data of %s: %v", event.Name, err)
+		}
+	}
+
+	var indexed abi.Arguments
+	for _, input := range event.Inputs {
+		if input.Indexed {
+			indexed = append(indexed, input)
+		}
+	}
+	if len(indexed) != len(topics)-1 {
+		return "", nil, fmt.Errorf("%s has %d indexed parameters, the log has %d topics", event.Name, len(indexed), len(topics)-1)
+	}
+	topicHashes := make([]common.Hash, len(indexed))
+	for i := range indexed {
+		topicHashes[i] = common.HexToHash(topics[i+1])
+	}
+	if err := abi.ParseTopicsIntoMap(params, indexed, topicHashes); err != nil {
+		return "", nil, fmt.Errorf("failed to parse topics of %s: %v", event.Name, err)
+	}
+
+	for name, value := range params {
+		params[name] = evmValueToJSON(value)
+	}
+	return event.Name, params, nil
+}
+
+// evmValueToJSON converts decoded ABI values into JSON friendly values: integers wider than
+// 64 bits become decimal strings, addresses and byte arrays become hex strings.
+func evmValueToJSON(value interface{}) interface{} {
+	switch v := value.(type) {
+	case *big.Int:
+		return v.String()
+	case common.Address:
+		return strings.ToLower(v.Hex())
+	case common.Hash:
+		return v.Hex()
+	case []byte:
+		return hexutil.Encode(v)
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			raw := make([]byte, rv.Len())
+			reflect.Copy(reflect.ValueOf(raw), rv)
+			return hexutil.Encode(raw)
+		}
+		fallthrough
+	case reflect.Slice:
+		items := make([]interface{}, rv.Len())
+		for i := range items {
+			items[i] = evmValueToJSON(rv.Index(i).Interface())
+		}
+		return items
+	case reflect.Struct:
+		fields := make(map[string]interface{}, rv.NumField())
+		for i := 0; i < rv.NumField(); i++ {
+			field := rv.Type().Field(i)
+			name := field.Tag.Get("json")
+			if name == "" {
+				name = field.Name
+			}
+			fields[name] = evmValueToJSON(rv.Field(i).Interface())
+		}
+		return fields
+	}
+	return value
+}
@@ -0,0 +1,52 @@
+package importers
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/BlocSoc-iitr/Athena/athena/database/models"
+	"github.com/stretchr/testify/assert"
+)
+
+const erc20TransferAbi = `[{"type": "event", "name": "Transfer", "anonymous": false, "inputs": [
+	{"name": "from", "type": "address", "indexed": true},
+	{"name": "to", "type": "address", "indexed": true},
+	{"name": "value", "type": "uint256", "indexed": false}
+]}]`
+
+func TestEVMLogDecoder(t *testing.T) {
+	dir := t.TempDir()
+	token := "0x5aea5775959fbc2557cc8789bc1bf90a239d9a91"
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, token+".json"), []byte(erc20TransferAbi), 0644))
+
+	logs := []models.EVMLog{
+		{
+			AbstractEvent: models.AbstractEvent{ContractAddress: token},
+			Topics: []string{
+				"0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef",
+				"0x000000000000000000000000000000000000000000000000000000000000800a",
+				"0x000000000000000000000000abcdefabcdefabcdefabcdefabcdefabcdefabcd",
+			},
+			Data: "0x0000000000000000000000000000000000000000000000000de0b6b3a7640000",
+		},
+		{
+			AbstractEvent: models.AbstractEvent{ContractAddress: "0x0000000000000000000000000000000000000001"},
+			Topics:        []string{"0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"},
+		},
+	}
+
+	decoder := NewEVMLogDecoder(NewFileAbiSource(dir, EVMDecoderOS))
+	assert.NoError(t, decoder.DecodeLogs(context.Background(), logs))
+
+	assert.Equal(t, "Transfer", logs[0].EventName.String)
+	assert.Equal(t, map[string]interface{}{
+		"from":  "0x000000000000000000000000000000000000800a",
+		"to":    "0xabcdefabcdefabcdefabcdefabcdefabcdefabcd",
+		"value": "1000000000000000000",
+	}, logs[0].DecodedParams)
+
+	assert.False(t, logs[1].EventName.Valid)
+	assert.Nil(t, logs[1].DecodedParams)
+}
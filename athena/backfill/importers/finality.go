@@ -0,0 +1,95 @@
+package importers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// StarknetAcceptedOnL1 is the status of Starknet blocks whose state update is proven on Ethereum.
+const StarknetAcceptedOnL1 = "ACCEPTED_ON_L1"
+
+// GetStarknetBlockStatus returns the status of a block, such as ACCEPTED_ON_L2 or ACCEPTED_ON_L1.
+func GetStarknetBlockStatus(ctx context.Context, url string, blockNumber uint64) (string, error) {
+	resp, err := MakeRPCCall(ctx, url, "starknet_getBlockWithTxHashes", []interface{}{map[string]interface{}{"block_number": blockNumber}})
+	if err != nil {
+		return "", fmt.Errorf("failed to get block %d: %v", blockNumber, err)
+	}
+	var block struct {
+		Status string `json:"status"`
+	}
+	if err := json.Unmarshal(resp.Result, &block); err != nil {
+		return "", fmt.Errorf("failed to unmarshal block %d: %v", blockNumber, err)
+	}
+	return block.Status, nil
+}
+
+// GetLatestAcceptedOnL1Block returns the latest block at or below latestBlock with ACCEPTED_ON_L1
+// status. Blocks are accepted on L1 in order, so it steps back from latestBlock with doubling strides
+// until it reaches an accepted block, then binary searches the last stride.
+func GetLatestAcceptedOnL1Block(ctx context.Context, url string, latestBlock uint64) (uint64, error) {
+	accepted := func(blockNumber uint64) (bool, error) {
+		status, err := GetStarknetBlockStatus(ctx, url, blockNumber)
+		return status == StarknetAcceptedOnL1, err
+	}
+
+	ok, err := accepted(latestBlock)
+	if err != nil || ok {
+		return latestBlock, err
+	}
+
+	// high is known not to be accepted, low becomes the first accepted block found stepping back.
+	high := latestBlock
+	var low uint64
+	for stride := uint64(1); ; stride *= 2 {
+		candidate := uint64(0)
+		if high > stride {
+			candidate = high - stride
+		}
+		ok, err := accepted(candidate)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			low = candidate
+			break
+		}
+		if candidate == 0 {
+			return 0, fmt.Errorf("no block is accepted on L1 yet")
+		}
+		high = candidate
+	}
+
+	for high-low > 1 {
+		mid := low + (high-low)/2
+		ok, err := accepted(mid)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			low = mid
+		} else {
+			high = mid
+		}
+	}
+	return low, nil
+}
+
+// GetEthBlockNumberByTag returns the number of the block an EVM node resolves a tag such as
+// finalized or safe to.
+func GetEthBlockNumberByTag(ctx context.Context, url string, tag string) (uint64, error) {
+	resp, err := MakeRPCCall(ctx, url, "eth_getBlockByNumber", []interface{}{tag, false})
+	if err != nil {
+		return 0, fmt.Errorf("failed to get %s block: %v", tag, err)
+	}
+	var block *struct {
+		Number string `json:"number"`
+	}
+	if err := json.Unmarshal(resp.Result, &block); err != nil {
+		return 0, fmt.Errorf("failed to unmarshal %s block: %v", tag, err)
+	}
+	if block == nil {
+		return 0, fmt.Errorf("the node has no %s block", tag)
+	}
+	return hexToUint64(block.Number)
+}
@@ -0,0 +1,68 @@
+package importers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func starknetStatusServer(t *testing.T, lastAccepted uint64, calls *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string `json:"method"`
+			Params []struct {
+				BlockNumber uint64 `json:"block_number"`
+			} `json:"params"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Method != "starknet_getBlockWithTxHashes" {
+			t.Errorf("unexpected method %s", req.Method)
+		}
+		*calls++
+		status := "ACCEPTED_ON_L2"
+		if req.Params[0].BlockNumber <= lastAccepted {
+			status = StarknetAcceptedOnL1
+		}
+		fmt.Fprintf(w, `{"jsonrpc": "2.0", "id": 1, "result": {"block_number": %d, "status": %q}}`, req.Params[0].BlockNumber, status)
+	}))
+}
+
+func TestGetLatestAcceptedOnL1Block(t *testing.T) {
+	for _, lastAccepted := range []uint64{0, 500, 998, 999, 1000} {
+		calls := 0
+		server := starknetStatusServer(t, lastAccepted, &calls)
+		blockNumber, err := GetLatestAcceptedOnL1Block(context.Background(), server.URL, 1000)
+		server.Close()
+		assert.NoError(t, err)
+		assert.Equal(t, lastAccepted, blockNumber)
+		assert.LessOrEqual(t, calls, 22)
+	}
+}
+
+func TestGetEthBlockNumberByTag(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ethRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		var tag string
+		json.Unmarshal(req.Params[0], &tag)
+		switch tag {
+		case "finalized":
+			w.Write([]byte(`{"jsonrpc": "2.0", "id": 1, "result": {"number": "0x12c"}}`))
+		default:
+			w.Write([]byte(`{"jsonrpc": "2.0", "id": 1, "result": null}`))
+		}
+	}))
+	defer server.Close()
+
+	blockNumber, err := GetEthBlockNumberByTag(context.Background(), server.URL, "finalized")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(300), blockNumber)
+
+	_, err = GetEthBlockNumberByTag(context.Background(), server.URL, "safe")
+	assert.Error(t, err)
+}
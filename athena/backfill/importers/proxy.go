@@ -0,0 +1,395 @@
+package importers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/BlocSoc-iitr/Athena/athena/database/models"
+)
+
+// ProxyImplementationStorageVar is the storage variable of the OpenZeppelin Cairo 0 proxy holding the implementation class hash.
+const ProxyImplementationStorageVar = "Proxy_implementation_hash"
+
+// proxyImplementationFunctions are the getters of the implementation in known proxy ABIs, in order of preference.
+var proxyImplementationFunctions = []string{"get_implementation", "implementation", "getImplementationHash"}
+
+// ProxyInfo describes how the implementation of a proxy can be read.
+type ProxyInfo struct {
+	// ImplementationFunction is the view function returning the implementation, if the ABI has one.
+	ImplementationFunction string
+	// UpgradeEvent is the name of the event emitted on upgrades, if the ABI has one.
+	UpgradeEvent string
+	// StorageVar is the storage variable holding the implementation for Cairo 0 proxies.
+	StorageVar string
+}
+
+// DetectProxy checks a raw class ABI for known proxy patterns: a class is a proxy if it has an implementation
+// getter. An Upgraded event alone does not make a proxy, OpenZeppelin upgradeable contracts emit it when they
+// replace their own class; it is only used to follow the upgrades of proxies. The __default__ entry point of
+// Cairo 0 proxies only marks the Proxy_implementation_hash storage variable when the ABI also has an
+// implementation getter, since any contract may forward unknown calls; ProxyInfoAt checks the storage
+// variable of the other classes with a __default__ entry point.
+func DetectProxy(abiJSON []map[string]interface{}) (ProxyInfo, bool) {
+	functions := make(map[string]bool)
+	events := make(map[string]bool)
+	collectAbiNames(abiJSON, functions, events)
+
+	var info ProxyInfo
+	for _, name := range proxyImplementationFunctions {
+		if functions[name] {
+			info.ImplementationFunction = name
+			break
+		}
+	}
+	if events["Upgraded"] {
+		info.UpgradeEvent = "Upgraded"
+	}
+	if functions["__default__"] && info.ImplementationFunction != "" {
+		info.StorageVar = ProxyImplementationStorageVar
+	}
+	return info, info.ImplementationFunction != ""
+}
+
+// ProxyInfoAt checks the ABI of the class of a contract for known proxy patterns like DetectProxy. A class
+// with a __default__ entry point and no implementation getter is a proxy if the Proxy_implementation_hash
+// storage variable of the contract is set at the block.
+func ProxyInfoAt(ctx context.Context, url string, contractAddress string, abiJSON []map[string]interface{}, blockNumber uint64) (ProxyInfo, bool, error) {
+	info, ok := DetectProxy(abiJSON)
+	if info.StorageVar != "" {
+		return info, ok, nil
+	}
+	functions := make(map[string]bool)
+	collectAbiNames(abiJSON, functions, make(map[string]bool))
+	if !functions["__default__"] {
+		return info, ok, nil
+	}
+
+	storageInfo := info
+	storageInfo.StorageVar = ProxyImplementationStorageVar
+	storageInfo.ImplementationFunction = ""
+	implementation, err := ImplementationAt(ctx, url, contractAddress, storageInfo, blockNumber)
+	if err != nil {
+		return ProxyInfo{}, false, err
+	}
+	if implementation == "" {
+		return info, ok, nil
+	}
+	return storageInfo, true, nil
+}
+
+// collectAbiNames gathers function and event names, including functions nested in Cairo 1 interfaces.
+// Event names are stripped of their module path.
+func collectAbiNames(items []map[string]interface{}, functions map[string]bool, events map[string]bool) {
+	for _, item := range items {
+		name, _ := item["name"].(string)
+		switch item["type"] {
+		case "function", "l1_handler":
+			functions[name] = true
+		case "event":
+			events[name] = true
+			if i := strings.LastIndex(name, "::"); i >= 0 {
+				events[name[i+2:]] = true
+			}
+		case "interface":
+			nested, _ := item["items"].([]interface{})
+			var nestedItems []map[string]interface{}
+			for _, n := range nested {
+				if m, ok := n.(map[string]interface{}); ok {
+					nestedItems = append(nestedItems, m)
+				}
+			}
+			collectAbiNames(nestedItems, functions, events)
+		}
+	}
+}
+
+// ImplementationAt reads the implementation of a proxy at a block, from its storage variable when it is set
+// and from the implementation getter otherwise. It returns an empty string if the proxy is not deployed.
+func ImplementationAt(ctx context.Context, url string, proxyAddress string, info ProxyInfo, blockNumber uint64) (string, error) {
+	blockID := map[string]interface{}{"block_number": int(blockNumber)}
+
+	if info.StorageVar != "" {
+		params := map[string]interface{}{
+			"contract_address": proxyAddress,
+			"key":              selectorFromName(info.StorageVar),
+			"block_id":         blockID,
+		}
+		resp, err := MakeRPCCall(ctx, url, "starknet_getStorageAt", params)
+		if err != nil {
+			return notFoundAsEmpty(fmt.Errorf("failed to read %s of %s at block %d: %w", info.StorageVar, proxyAddress, blockNumber, err))
+		}
+		var value string
+		if err := json.Unmarshal(resp.Result, &value); err != nil {
+			return "", fmt.Errorf("failed to unmarshal %s of %s: %v", info.StorageVar, proxyAddress, err)
+		}
+		if normalizeHex(value) != "0x0" {
+			return normalizeHex(value), nil
+		}
+	}
+
+	if info.ImplementationFunction == "" {
+		return "", nil
+	}
+	params := map[string]interface{}{
+		"request": map[string]interface{}{
+			"contract_address":     proxyAddress,
+			"entry_point_selector": selectorFromName(info.ImplementationFunction),
+			"calldata":             []string{},
+		},
+		"block_id": blockID,
+	}
+	resp, err := MakeRPCCall(ctx, url, "starknet_call", params)
+	if err != nil {
+		return notFoundAsEmpty(fmt.Errorf("failed to call %s of %s at block %d: %w", info.ImplementationFunction, proxyAddress, blockNumber, err))
+	}
+	var result []string
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		return "", fmt.Errorf("failed to unmarshal %s result of %s: %v", info.ImplementationFunction, proxyAddress, err)
+	}
+	if len(result) == 0 {
+		return "", fmt.Errorf("%s of %s returned no value", info.ImplementationFunction, proxyAddress)
+	}
+	return normalizeHex(result[0]), nil
+}
+
+func notFoundAsEmpty(err error) (string, error) {
+	var rpcErr *rpcError
+	if errors.As(err, &rpcErr) && rpcErr.Code == contractNotFoundCode {
+		return "", nil
+	}
+	return "", err
+}
+
+// ProxyImplementationHistory reconstructs the implementation periods of a proxy within a block range.
+// The implementation at fromBlock is read from the proxy; later upgrades are taken from the Upgraded
+// events when the proxy emits them, and found by binary search over the implementation otherwise.
+// The ClassHash of each period is the implementation.
+func ProxyImplementationHistory(ctx context.Context, url string, proxyAddress string, info ProxyInfo, fromBlock uint64, toBlock uint64) ([]models.ContractClassHistory, error) {
+	implementationAt := func(blockNumber uint64) (string, error) {
+		return ImplementationAt(ctx, url, proxyAddress, info, blockNumber)
+	}
+
+	if info.UpgradeEvent == "" {
+		start, changes, err := bisectChanges(fromBlock, toBlock, implementationAt)
+		if err != nil {
+			return nil, err
+		}
+		return periodsFromChanges(proxyAddress, fromBlock, start, changes), nil
+	}
+
+	start, err := implementationAt(fromBlock)
+	if err != nil {
+		return nil, err
+	}
+	var changes []classChange
+	if fromBlock < toBlock {
+		keys := [][]string{{selectorFromName(info.UpgradeEvent)}}
+		events, err := GetEmittedEvents(ctx, url, proxyAddress, keys, fromBlock+1, toBlock, 1000)
+		if err != nil {
+			return nil, err
+		}
+		for _, event := range events {
+			implementation, ok := upgradedImplementation(event)
+			if !ok {
+				continue
+			}
+			// Only the last upgrade of a block is active after it.
+			if n := len(changes); n > 0 && changes[n-1].blockNumber == event.BlockNumber {
+				changes[n-1].classHash = implementation
+				continue
+			}
+			changes = append(changes, classChange{blockNumber: event.BlockNumber, classHash: implementation})
+		}
+	}
+	return periodsFromChanges(proxyAddress, fromBlock, start, changes), nil
+}
+
+// upgradedImplementation reads the new implementation of an Upgraded event. Cairo 0 proxies and the
+// OpenZeppelin upgradeable component emit it as data, some contracts emit it as a key.
+func upgradedImplementation(event EmittedEvent) (string, bool) {
+	switch {
+	case len(event.Data) > 0:
+		return normalizeHex(event.Data[0]), true
+	case len(event.Keys) > 1:
+		return normalizeHex(event.Keys[1]), true
+	default:
+		return "", false
+	}
+}
+
+// ImplementationLoader builds the periods of the class that was active at each block of a contract, with
+// the periods of a proxy class replaced by the implementations of the proxy.
+type ImplementationLoader struct {
+	url   string
+	store ClassHistoryStore
+	mu    sync.Mutex
+	abis  map[string][]map[string]interface{}
+}
+
+// NewImplementationLoader creates a loader that reads the classes of contracts from the class history
+// index, and searches them over RPC where the index has no coverage. The store may be nil.
+func NewImplementationLoader(url string, store ClassHistoryStore) *ImplementationLoader {
+	return &ImplementationLoader{
+		url:   url,
+		store: store,
+		abis:  make(map[string][]map[string]interface{}),
+	}
+}
+
+// History returns the implementation periods of a contract within a block range. Blocks at which the
+// contract is not deployed, or at which a proxy has no implementation, are not covered by any period.
+func (l *ImplementationLoader) History(ctx context.Context, contractAddress string, fromBlock uint64, toBlock uint64) ([]models.ContractClassHistory, error) {
+	resolver := ClassHashResolverFunc(func(ctx context.Context, contractAddress string, blockNumber uint64) (string, error) {
+		return GetClassHashAt(ctx, l.url, contractAddress, blockNumber)
+	})
+	classes, err := ImplementationHistory(ctx, l.store, resolver, contractAddress, fromBlock, toBlock)
+	if err != nil {
+		return nil, err
+	}
+
+	var history []models.ContractClassHistory
+	for _, period := range classes {
+		abiJSON, err := l.classAbi(ctx, period.ClassHash, period.FromBlock)
+		if err != nil {
+			return nil, err
+		}
+		info, proxy, err := ProxyInfoAt(ctx, l.url, contractAddress, abiJSON, period.FromBlock)
+		if err != nil {
+			return nil, err
+		}
+		if !proxy {
+			history = append(history, period)
+			continue
+		}
+
+		periodTo := toBlock
+		if period.ToBlock.Valid {
+			periodTo = uint64(period.ToBlock.Int64)
+		}
+		implementations, err := ProxyImplementationHistory(ctx, l.url, contractAddress, info, period.FromBlock, periodTo)
+		if err != nil {
+			return nil, err
+		}
+		if n := len(implementations); n > 0 && period.ToBlock.Valid {
+			implementations[n-1].ToBlock = period.ToBlock
+		}
+		history = append(history, implementations...)
+	}
+	return history, nil
+}
+
+// classAbi fetches the raw ABI of a class once.
+func (l *ImplementationLoader) classAbi(ctx context.Context, classHash string, blockNumber uint64) ([]map[string]interface{}, error) {
+	key := normalizeHex(classHash)
+	l.mu.Lock()
+	abiJSON, ok := l.abis[key]
+	l.mu.Unlock()
+	if ok {
+		return abiJSON, nil
+	}
+
+	rawAbi, err := FetchClassAbi(ctx, l.url, classHash, blockNumber)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(rawAbi, &abiJSON); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal ABI of class %s: %v", classHash, err)
+	}
+
+	l.mu.Lock()
+	l.abis[key] = abiJSON
+	l.mu.Unlock()
+	return abiJSON, nil
+}
+
+// ImplementationResolver resolves proxies to the implementation that was active at each block, so that
+// their events are decoded with the implementation ABI. Contracts without implementation periods are
+// resolved with the base resolver.
+type ImplementationResolver struct {
+	base      ClassHashResolver
+	mu        sync.Mutex
+	histories map[string][]models.ContractClassHistory
+
+	loader    *ImplementationLoader
+	fromBlock uint64
+	toBlock   uint64
+}
+
+func NewImplementationResolver(base ClassHashResolver) *ImplementationResolver {
+	return &ImplementationResolver{
+		base:      base,
+		histories: make(map[string][]models.ContractClassHistory),
+	}
+}
+
+// SetHistory sets the implementation periods of a proxy.
+func (r *ImplementationResolver) SetHistory(proxyAddress string, history []models.ContractClassHistory) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.histories[normalizeHex(proxyAddress)] = history
+}
+
+// LoadHistories makes the resolver load the implementation periods of each contract within a block range
+// with the loader the first time the contract is resolved at a block of the range.
+func (r *ImplementationResolver) LoadHistories(loader *ImplementationLoader, fromBlock uint64, toBlock uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.loader = loader
+	r.fromBlock = fromBlock
+	r.toBlock = toBlock
+}
+
+func (r *ImplementationResolver) ClassHashAt(ctx context.Context, contractAddress string, blockNumber uint64) (string, error) {
+	history, err := r.history(ctx, contractAddress, blockNumber)
+	if err != nil {
+		return "", err
+	}
+	for _, period := range history {
+		if period.FromBlock <= blockNumber && (!period.ToBlock.Valid || blockNumber <= uint64(period.ToBlock.Int64)) {
+			return period.ClassHash, nil
+		}
+	}
+	return r.base.ClassHashAt(ctx, contractAddress, blockNumber)
+}
+
+// ClassPeriods returns the implementation periods of a contract that overlap a block range. It is empty
+// if the contract has no periods and the range does not start within the range of LoadHistories.
+func (r *ImplementationResolver) ClassPeriods(ctx context.Context, contractAddress string, fromBlock uint64, toBlock uint64) ([]models.ContractClassHistory, error) {
+	history, err := r.history(ctx, contractAddress, fromBlock)
+	if err != nil {
+		return nil, err
+	}
+	var periods []models.ContractClassHistory
+	for _, period := range history {
+		if period.FromBlock <= toBlock && (!period.ToBlock.Valid || uint64(period.ToBlock.Int64) >= fromBlock) {
+			periods = append(periods, period)
+		}
+	}
+	return periods, nil
+}
+
+// history returns the periods of a contract, loading them first if the block is within the range of LoadHistories.
+func (r *ImplementationResolver) history(ctx context.Context, contractAddress string, blockNumber uint64) ([]models.ContractClassHistory, error) {
+	key := normalizeHex(contractAddress)
+	r.mu.Lock()
+	history, ok := r.histories[key]
+	loader, fromBlock, toBlock := r.loader, r.fromBlock, r.toBlock
+	r.mu.Unlock()
+	if ok || loader == nil || blockNumber < fromBlock || blockNumber > toBlock {
+		return history, nil
+	}
+
+	history, err := loader.History(ctx, contractAddress, fromBlock, toBlock)
+	if err != nil {
+		return nil, err
+	}
+	if history == nil {
+		history = []models.ContractClassHistory{}
+	}
+	r.SetHistory(key, history)
+	return history, nil
+}
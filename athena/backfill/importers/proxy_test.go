@@ -0,0 +1,172 @@
+package importers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/BlocSoc-iitr/Athena/athena/database/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectProxy(t *testing.T) {
+	var cairo0Proxy []map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(`[
+		{"type": "event", "name": "Upgraded", "keys": [], "data": [{"name": "implementation", "type": "felt"}]},
+		{"type": "function", "name": "getImplementationHash", "inputs": [], "outputs": [{"name": "implementation", "type": "felt"}], "stateMutability": "view"},
+		{"type": "function", "name": "__default__", "inputs": [], "outputs": []}
+	]`), &cairo0Proxy))
+	info, ok := DetectProxy(cairo0Proxy)
+	assert.True(t, ok)
+	assert.Equal(t, ProxyInfo{ImplementationFunction: "getImplementationHash", UpgradeEvent: "Upgraded", StorageVar: ProxyImplementationStorageVar}, info)
+
+	var cairo1Proxy []map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(`[
+		{"type": "interface", "name": "IProxy", "items": [{"type": "function", "name": "get_implementation", "inputs": [], "outputs": [{"type": "core::felt252"}], "state_mutability": "view"}]},
+		{"type": "event", "name": "openzeppelin::upgrades::upgradeable::UpgradeableComponent::Upgraded", "kind": "struct", "members": []}
+	]`), &cairo1Proxy))
+	info, ok = DetectProxy(cairo1Proxy)
+	assert.True(t, ok)
+	assert.Equal(t, ProxyInfo{ImplementationFunction: "get_implementation", UpgradeEvent: "Upgraded"}, info)
+
+	data, err := os.ReadFile("../../../athena_abi/abis/v2/erc20_compiled.json")
+	assert.NoError(t, err)
+	var erc20 []map[string]interface{}
+	assert.NoError(t, json.Unmarshal(data, &erc20))
+	_, ok = DetectProxy(erc20)
+	assert.False(t, ok)
+
+	var forwarder []map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(`[{"type": "function", "name": "__default__", "inputs": [], "outputs": []}]`), &forwarder))
+	_, ok = DetectProxy(forwarder)
+	assert.False(t, ok, "__default__ alone does not mark a proxy")
+
+	var upgradeable []map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(`[
+		{"type": "interface", "name": "IUpgradeable", "items": [{"type": "function", "name": "upgrade", "inputs": [{"name": "new_class_hash", "type": "core::starknet::class_hash::ClassHash"}], "outputs": [], "state_mutability": "external"}]},
+		{"type": "event", "name": "openzeppelin::upgrades::upgradeable::UpgradeableComponent::Upgraded", "kind": "struct", "members": [{"name": "class_hash", "type": "core::starknet::class_hash::ClassHash", "kind": "data"}]}
+	]`), &upgradeable))
+	_, ok = DetectProxy(upgradeable)
+	assert.False(t, ok, "contracts replacing their own class are not proxies")
+}
+
+func TestProxyInfoAtReadsImplementationSlot(t *testing.T) {
+	slot := "0x0"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"jsonrpc": "2.0", "id": 1, "result": %q}`, slot)
+	}))
+	defer server.Close()
+
+	var forwarder []map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(`[{"type": "function", "name": "__default__", "inputs": [], "outputs": []}]`), &forwarder))
+	_, ok, err := ProxyInfoAt(context.Background(), server.URL, "0xp", forwarder, 10)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	slot = "0xa1"
+	info, ok, err := ProxyInfoAt(context.Background(), server.URL, "0xp", forwarder, 10)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, ProxyInfo{StorageVar: ProxyImplementationStorageVar}, info)
+}
+
+func TestProxyImplementationHistory(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string `json:"method"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		switch req.Method {
+		case "starknet_getStorageAt":
+			w.Write([]byte(`{"jsonrpc": "2.0", "id": 1, "result": "0x0a1"}`))
+		case "starknet_getEvents":
+			w.Write([]byte(`{"jsonrpc": "2.0", "id": 1, "result": {"events": [
+				{"from_address": "0xp", "keys": ["` + selectorFromName("Upgraded") + `"], "data": ["0xa2"], "block_number": 150},
+				{"from_address": "0xp", "keys": ["` + selectorFromName("Upgraded") + `"], "data": ["0xa3"], "block_number": 180},
+				{"from_address": "0xp", "keys": ["` + selectorFromName("Upgraded") + `"], "data": ["0xa4"], "block_number": 180}
+			]}}`))
+		default:
+			t.Errorf("unexpected method %s", req.Method)
+		}
+	}))
+	defer server.Close()
+
+	info := ProxyInfo{UpgradeEvent: "Upgraded", StorageVar: ProxyImplementationStorageVar}
+	history, err := ProxyImplementationHistory(context.Background(), server.URL, "0xp", info, 100, 200)
+	assert.NoError(t, err)
+	assert.Len(t, history, 3)
+	assert.Equal(t, "0xa1", history[0].ClassHash)
+	assert.Equal(t, int64(149), history[0].ToBlock.Int64)
+	assert.Equal(t, "0xa2", history[1].ClassHash)
+	assert.Equal(t, "0xa4", history[2].ClassHash)
+	assert.Equal(t, uint64(180), history[2].FromBlock)
+
+	resolver := NewImplementationResolver(staticClassHashResolver("0xproxyclass"))
+	resolver.SetHistory("0xp", history)
+	classHash, err := resolver.ClassHashAt(context.Background(), "0xp", 160)
+	assert.NoError(t, err)
+	assert.Equal(t, "0xa2", classHash)
+	classHash, err = resolver.ClassHashAt(context.Background(), "0xp", 50)
+	assert.NoError(t, err)
+	assert.Equal(t, "0xproxyclass", classHash)
+}
+
+func TestImplementationResolverDecodesAcrossUpgrade(t *testing.T) {
+	proxyAbi := `[
+		{"type": "function", "name": "get_implementation", "inputs": [], "outputs": [{"type": "core::felt252"}], "state_mutability": "view"},
+		{"type": "event", "name": "Upgraded", "kind": "struct", "members": [{"name": "implementation", "type": "core::felt252", "kind": "data"}]}
+	]`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string `json:"method"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		switch req.Method {
+		case "starknet_getClassHashAt":
+			w.Write([]byte(`{"jsonrpc": "2.0", "id": 1, "result": "0xproxy"}`))
+		case "starknet_getClass":
+			fmt.Fprintf(w, `{"jsonrpc": "2.0", "id": 1, "result": {"abi": %q}}`, proxyAbi)
+		case "starknet_call":
+			w.Write([]byte(`{"jsonrpc": "2.0", "id": 1, "result": ["0xc1"]}`))
+		case "starknet_getEvents":
+			w.Write([]byte(`{"jsonrpc": "2.0", "id": 1, "result": {"events": [
+				{"from_address": "0xp", "keys": ["` + selectorFromName("Upgraded") + `"], "data": ["0xc2"], "block_number": 150}
+			]}}`))
+		default:
+			t.Errorf("unexpected method %s", req.Method)
+		}
+	}))
+	defer server.Close()
+
+	registry := NewAbiRegistry(nil)
+	registry.Add("0xc1", loadTestAbi(t, "../../../athena_abi/abis/v2/erc20_compiled.json"))
+	registry.Add("0xc2", loadTestAbi(t, "../../../athena_abi/abis/v2/erc20_key_events.json"))
+	resolver := NewImplementationResolver(staticClassHashResolver("0xproxy"))
+	resolver.LoadHistories(NewImplementationLoader(server.URL, nil), 100, 200)
+	decoder := NewEventDecoder(registry, resolver)
+
+	// The implementation emits from and to as data before the upgrade, and as keys after it.
+	before := models.DefaultEvent{
+		AbstractEvent: models.AbstractEvent{BlockNumber: 120, ContractAddress: "0xp"},
+		Keys:          []string{selectorFromName("Transfer")},
+		Data:          []string{"0x1", "0x2", "0x64", "0x0"},
+	}
+	after := models.DefaultEvent{
+		AbstractEvent: models.AbstractEvent{BlockNumber: 160, ContractAddress: "0xp"},
+		Keys:          []string{selectorFromName("Transfer"), "0x1", "0x2"},
+		Data:          []string{"0xc8", "0x0"},
+	}
+	require.NoError(t, decoder.Decode(context.Background(), &before))
+	require.NoError(t, decoder.Decode(context.Background(), &after))
+
+	assert.Equal(t, "0xc1", before.ClassHash.String)
+	assert.Equal(t, big.NewInt(100), before.DecodedParams["value"])
+	assert.Equal(t, "0xc2", after.ClassHash.String)
+	assert.Equal(t, big.NewInt(200), after.DecodedParams["value"])
+}
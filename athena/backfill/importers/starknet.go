@@ -33,12 +33,17 @@ type rpcError struct {
 	Message string `json:"message"`
 }
 
+func (e *rpcError) Error() string {
+	return fmt.Sprintf("rpc error: %s", e.Message)
+}
+
 type L1GasPrice struct {
 	PriceInWei string `json:"price_in_wei"`
 	PriceInFri string `json:"price_in_fri"`
 }
 
 type BlockData struct {
+	BlockNumber      uint64     `json:"block_number"`
 	ParentHash       string     `json:"parent_hash"`
 	Timestamp        int64      `json:"timestamp"`
 	SequencerAddress string     `json:"sequencer_address"`
@@ -50,6 +55,7 @@ type BlockData struct {
 }
 
 type BlockTxHashes struct {
+	BlockNumber      uint64     `json:"block_number"`
 	ParentHash       string     `json:"parent_hash"`
 	Timestamp        int64      `json:"timestamp"`
 	SequencerAddress string     `json:"sequencer_address"`
@@ -127,7 +133,7 @@ func MakeRPCCall(ctx context.Context, url string, method string, params interfac
 
 	// Check for RPC errors
 	if rpcResp.Error != nil {
-		return nil, fmt.Errorf("rpc error: %s", rpcResp.Error.Message)
+		return nil, rpcResp.Error
 	}
 
 	return &rpcResp, nil
@@ -9,7 +9,9 @@ import (
 	"os"
 	"strings"
 
+	"github.com/BlocSoc-iitr/Athena/athena/database/models"
 	"github.com/NethermindEth/starknet.go/rpc"
+	"gorm.io/gorm"
 )
 
 func FetchEvents(provider *rpc.Provider, filter rpc.EventFilter, resultPage rpc.ResultPageRequest) ([]rpc.EventChunk, error) {
@@ -62,7 +64,7 @@ func ExportEventsToCSV(events []rpc.EventChunk, filename string) error {
 	defer writer.Flush()
 
 	// Write the header to the CSV file
-	header := []string{"Block Number", "Transaction Hash", "Event Index", "Data", "Keys"}
+	header := []string{"Block Number", "Transaction Hash", "Block Hash", "Contract Address", "Keys", "Data"}
 	if err := writer.Write(header); err != nil {
 		return fmt.Errorf("failed to write header to CSV file: %w", err)
 	}
@@ -86,9 +88,9 @@ func ExportEventsToCSV(events []rpc.EventChunk, filename string) error {
 
 			record := []string{
 				fmt.Sprintf("%d", d.BlockNumber),
-				fmt.Sprintf("%d", d.TransactionHash),
-				fmt.Sprintf("%d", d.BlockHash),
-				fmt.Sprintf("%d", d.Event.FromAddress),
+				d.TransactionHash.String(),
+				d.BlockHash.String(),
+				d.Event.FromAddress.String(),
 				string(keustore),
 				string(dayastore),
 			}
@@ -102,6 +104,165 @@ func ExportEventsToCSV(events []rpc.EventChunk, filename string) error {
 	return nil
 }
 
+// EventsFromBlock maps every event emitted in a block into models.DefaultEvent rows.
+// EventIndex is the position of the event within its transaction receipt.
+func EventsFromBlock(block BlockWithReceipts) []models.DefaultEvent {
+	var events []models.DefaultEvent
+	for txIndex, tx := range block.Transactions {
+		for eventIndex, event := range tx.Receipt.Events {
+			events = append(events, models.DefaultEvent{
+				AbstractEvent: models.AbstractEvent{
+					BlockNumber:      block.BlockNumber,
+					EventIndex:       eventIndex,
+					TransactionIndex: txIndex,
+					ContractAddress:  event.FromAddress,
+				},
+				Keys: nonNil(event.Keys),
+				Data: nonNil(event.Data),
+			})
+		}
+	}
+	return events
+}
+
+// GetEvents imports all events in a block range. When decoder is set, each event is decoded
+// with the ABI of the emitting contract's class.
+func GetEvents(ctx context.Context, url string, fromBlock uint64, toBlock uint64, decoder *EventDecoder) ([]models.DefaultEvent, error) {
+	blocks, err := GetBlocksWithReceipts(ctx, url, fromBlock, toBlock)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []models.DefaultEvent
+	for _, block := range blocks {
+		events = append(events, EventsFromBlock(block)...)
+	}
+
+	if decoder != nil {
+		for i := range events {
+			if err := decoder.Decode(ctx, &events[i]); err != nil {
+				log.Printf("Error decoding event %d of transaction %d in block %d: %v", events[i].EventIndex, events[i].TransactionIndex, events[i].BlockNumber, err)
+			}
+		}
+	}
+	return events, nil
+}
+
+// EventsToDicts converts events into rows for a FileResourceExporter.
+func EventsToDicts(events []models.DefaultEvent) []map[string]interface{} {
+	rows := make([]map[string]interface{}, len(events))
+	for i, event := range events {
+		rows[i] = map[string]interface{}{
+			"block_number":      int(event.BlockNumber),
+			"transaction_index": event.TransactionIndex,
+			"event_index":       event.EventIndex,
+			"contract_address":  event.ContractAddress,
+			"class_hash":        event.ClassHash.String,
+			"event_name":        event.EventName.String,
+			"keys":              stringsToInterfaces(event.Keys),
+			"data":              stringsToInterfaces(event.Data),
+			"decoded_params":    event.DecodedParams,
+		}
+	}
+	return rows
+}
+
+// WriteEventsToDB inserts events into the events table in batches.
+func WriteEventsToDB(db *gorm.DB, events []models.DefaultEvent, batchSize int) error {
+	if len(events) == 0 {
+		return nil
+	}
+	if err := db.CreateInBatches(events, batchSize).Error; err != nil {
+		return fmt.Errorf("failed to write events to database: %w", err)
+	}
+	return nil
+}
+
+func stringsToInterfaces(values []string) []interface{} {
+	result := make([]interface{}, len(values))
+	for i, value := range values {
+		result[i] = value
+	}
+	return result
+}
+
+// EmittedEvent is an event returned by starknet_getEvents.
+type EmittedEvent struct {
+	FromAddress     string   `json:"from_address"`
+	Keys            []string `json:"keys"`
+	Data            []string `json:"data"`
+	BlockHash       string   `json:"block_hash"`
+	BlockNumber     uint64   `json:"block_number"`
+	TransactionHash string   `json:"transaction_hash"`
+}
+
+// EmittedEventsToDicts converts events returned by starknet_getEvents into rows for a ResourceExporter.
+func EmittedEventsToDicts(events []EmittedEvent) []map[string]interface{} {
+	rows := make([]map[string]interface{}, len(events))
+	for i, event := range events {
+		rows[i] = map[string]interface{}{
+			"block_number":     int(event.BlockNumber),
+			"block_hash":       event.BlockHash,
+			"transaction_hash": event.TransactionHash,
+			"contract_address": event.FromAddress,
+			"keys":             stringsToInterfaces(event.Keys),
+			"data":             stringsToInterfaces(event.Data),
+		}
+	}
+	return rows
+}
+
+type eventsPage struct {
+	Events            []EmittedEvent `json:"events"`
+	ContinuationToken string         `json:"continuation_token"`
+}
+
+// GetEmittedEvents fetches all events of a contract in a block range with starknet_getEvents,
+// following continuation tokens. keys holds the accepted values of each key position,
+// an empty position matches any value.
+func GetEmittedEvents(ctx context.Context, url string, address string, keys [][]string, fromBlock uint64, toBlock uint64, chunkSize int) ([]EmittedEvent, error) {
+	var events []EmittedEvent
+	continuationToken := ""
+	for {
+		page, err := getEventsPage(ctx, url, address, keys, fromBlock, toBlock, chunkSize, continuationToken)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, page.Events...)
+		if page.ContinuationToken == "" {
+			return events, nil
+		}
+		continuationToken = page.ContinuationToken
+	}
+}
+
+func getEventsPage(ctx context.Context, url string, address string, keys [][]string, fromBlock uint64, toBlock uint64, chunkSize int, continuationToken string) (eventsPage, error) {
+	filter := map[string]interface{}{
+		"from_block": map[string]interface{}{"block_number": int(fromBlock)},
+		"to_block":   map[string]interface{}{"block_number": int(toBlock)},
+		"chunk_size": chunkSize,
+	}
+	if address != "" {
+		filter["address"] = address
+	}
+	if len(keys) > 0 {
+		filter["keys"] = keys
+	}
+	if continuationToken != "" {
+		filter["continuation_token"] = continuationToken
+	}
+
+	resp, err := MakeRPCCall(ctx, url, "starknet_getEvents", map[string]interface{}{"filter": filter})
+	if err != nil {
+		return eventsPage{}, fmt.Errorf("failed to get events of %s from block %d to %d: %v", address, fromBlock, toBlock, err)
+	}
+	var page eventsPage
+	if err := json.Unmarshal(resp.Result, &page); err != nil {
+		return eventsPage{}, fmt.Errorf("failed to unmarshal events of %s: %v", address, err)
+	}
+	return page, nil
+}
+
 // example usage remove this after implementing it in filters and in cli
 func main() {
 
@@ -0,0 +1,415 @@
+package importers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/BlocSoc-iitr/Athena/athena/database/models"
+	"github.com/BlocSoc-iitr/Athena/athena/types"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// DefaultMaxReorgDepth is the number of blocks the detector walks back before giving up on finding a fork point.
+const DefaultMaxReorgDepth uint64 = 128
+
+// ReorgEvent describes a chain reorganisation detected while ingesting blocks.
+type ReorgEvent struct {
+	DetectedAtBlock   uint64    `json:"detected_at_block"`
+	ForkPoint         uint64    `json:"fork_point"`
+	Depth             uint64    `json:"depth"`
+	StoredParentHash  string    `json:"stored_parent_hash"`
+	CanonicalParent   string    `json:"canonical_parent_hash"`
+	RolledBackFrom    uint64    `json:"rolled_back_from"`
+	RowsInvalidated   int64     `json:"rows_invalidated"`
+	DetectedTimestamp time.Time `json:"detected_at"`
+}
+
+// Fields returns the event as structured log fields.
+func (e ReorgEvent) Fields() logrus.Fields {
+	return logrus.Fields{
+		"event":                 "chain_reorg",
+		"detected_at_block":     e.DetectedAtBlock,
+		"fork_point":            e.ForkPoint,
+		"depth":                 e.Depth,
+		"stored_parent_hash":    e.StoredParentHash,
+		"canonical_parent_hash": e.CanonicalParent,
+		"rolled_back_from":      e.RolledBackFrom,
+		"rows_invalidated":      e.RowsInvalidated,
+	}
+}
+
+// BlockHashStore keeps the hashes of ingested blocks so parent hash continuity can be verified.
+type BlockHashStore interface {
+	// BlockHash returns the stored hash for a block and whether it was found.
+	BlockHash(blockNumber uint64) (string, bool, error)
+	// SaveBlockHash records the hash of an ingested block.
+	SaveBlockHash(blockNumber uint64, blockHash string) error
+	// Rollback removes every block after forkPoint and returns the number of rows removed.
+	Rollback(forkPoint uint64) (int64, error)
+}
+
+// MemoryBlockHashStore is a BlockHashStore kept in memory, used for file exports.
+type MemoryBlockHashStore struct {
+	mu     sync.Mutex
+	hashes map[uint64]string
+}
+
+func NewMemoryBlockHashStore() *MemoryBlockHashStore {
+	return &MemoryBlockHashStore{hashes: make(map[uint64]string)}
+}
+
+func (s *MemoryBlockHashStore) BlockHash(blockNumber uint64) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hash, ok := s.hashes[blockNumber]
+	return hash, ok, nil
+}
+
+func (s *MemoryBlockHashStore) SaveBlockHash(blockNumber uint64, blockHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hashes[blockNumber] = blockHash
+	return nil
+}
+
+func (s *MemoryBlockHashStore) Rollback(forkPoint uint64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var removed int64
+	for blockNumber := range s.hashes {
+		if blockNumber > forkPoint {
+			delete(s.hashes, blockNumber)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// DBBlockHashStore reads block hashes from the blocks table and rolls back the Starknet tables on
+// reorgs. Hashes saved during a run are also kept in memory, so parent hashes can be verified
+// before the blocks are written, or when blocks are not exported at all.
+type DBBlockHashStore struct {
+	db     *gorm.DB
+	memory *MemoryBlockHashStore
+}
+
+func NewDBBlockHashStore(db *gorm.DB) *DBBlockHashStore {
+	return &DBBlockHashStore{db: db, memory: NewMemoryBlockHashStore()}
+}
+
+func (s *DBBlockHashStore) BlockHash(blockNumber uint64) (string, bool, error) {
+	if hash, ok, _ := s.memory.BlockHash(blockNumber); ok {
+		return hash, true, nil
+	}
+	var blocks []models.Block
+	err := s.db.Select("block_number, block_hash").Where("block_number = ?", blockNumber).Limit(1).Find(&blocks).Error
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read block hash for block %d: %w", blockNumber, err)
+	}
+	if len(blocks) == 0 {
+		return "", false, nil
+	}
+	return blocks[0].BlockHash, true, nil
+}
+
+// SaveBlockHash keeps the hash in memory, since block rows are written by the exporters.
+func (s *DBBlockHashStore) SaveBlockHash(blockNumber uint64, blockHash string) error {
+	return s.memory.SaveBlockHash(blockNumber, blockHash)
+}
+
+// blockTables are the tables rolled back by DBBlockHashStore, which hold one row per block,
+// transaction, event or state change.
+var blockTables = []interface{}{
+	&models.Block{},
+	&models.Transaction{},
+	&models.DefaultEvent{},
+	&models.ERC20Transfer{},
+	&models.Trace{},
+	&models.StorageDiff{},
+	&models.DeployedContract{},
+	&models.DeclaredClass{},
+	&models.ReplacedClass{},
+	&models.NonceUpdate{},
+}
+
+// Rollback deletes the rows of every block after forkPoint. Class history periods starting after
+// forkPoint are deleted and periods ending at or after it are reopened, and Starknet backfilled
+// ranges are truncated to forkPoint.
+func (s *DBBlockHashStore) Rollback(forkPoint uint64) (int64, error) {
+	if _, err := s.memory.Rollback(forkPoint); err != nil {
+		return 0, err
+	}
+	var removed int64
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		for _, model := range blockTables {
+			result := tx.Where("block_number > ?", forkPoint).Delete(model)
+			if result.Error != nil {
+				return result.Error
+			}
+			removed += result.RowsAffected
+		}
+
+		result := tx.Where("from_block > ?", forkPoint).Delete(&models.ContractClassHistory{})
+		if result.Error != nil {
+			return result.Error
+		}
+		removed += result.RowsAffected
+		result = tx.Model(&models.ContractClassHistory{}).Where("to_block >= ?", forkPoint).Update("to_block", nil)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		err := tx.Where("network = ? AND start_block > ?", types.StarkNet, forkPoint).Delete(&models.BackfilledRange{}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&models.BackfilledRange{}).
+			Where("network = ? AND end_block > ?", types.StarkNet, forkPoint).
+			Update("end_block", forkPoint).Error
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to roll back blocks after %d: %w", forkPoint, err)
+	}
+	return removed, nil
+}
+
+// ReorgDetector verifies parent hash continuity of ingested blocks and rolls the store back when the chain forks.
+type ReorgDetector struct {
+	url      string
+	store    BlockHashStore
+	maxDepth uint64
+	handlers []func(ReorgEvent)
+}
+
+func NewReorgDetector(url string, store BlockHashStore, maxDepth uint64) *ReorgDetector {
+	if maxDepth == 0 {
+		maxDepth = DefaultMaxReorgDepth
+	}
+	return &ReorgDetector{
+		url:      url,
+		store:    store,
+		maxDepth: maxDepth,
+	}
+}
+
+// OnReorg registers a handler that is called with every reorg event after the rollback has happened.
+func (d *ReorgDetector) OnReorg(handler func(ReorgEvent)) {
+	d.handlers = append(d.handlers, handler)
+}
+
+// Record stores the hash of a block once it has been ingested.
+func (d *ReorgDetector) Record(blockNumber uint64, blockHash string) error {
+	return d.store.SaveBlockHash(blockNumber, blockHash)
+}
+
+// Check compares the parent hash of a new block with the stored hash of the previous block.
+// It returns nil when the chain is continuous. On a mismatch the fork point is located,
+// every stored block after it is rolled back and the emitted reorg event is returned.
+func (d *ReorgDetector) Check(ctx context.Context, blockNumber uint64, parentHash string) (*ReorgEvent, error) {
+	event, err := d.detect(ctx, blockNumber, parentHash)
+	if err != nil || event == nil {
+		return nil, err
+	}
+	if err := d.Rollback(event); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// detect returns the reorg event of a block whose parent hash does not match the stored hash of
+// the previous block, without rolling back the store.
+func (d *ReorgDetector) detect(ctx context.Context, blockNumber uint64, parentHash string) (*ReorgEvent, error) {
+	if blockNumber == 0 {
+		return nil, nil
+	}
+	storedHash, ok, err := d.store.BlockHash(blockNumber - 1)
+	if err != nil {
+		return nil, err
+	}
+	if !ok || sameHash(storedHash, parentHash) {
+		return nil, nil
+	}
+
+	forkPoint, err := d.findForkPoint(ctx, blockNumber-1)
+	if err != nil {
+		return nil, err
+	}
+	return &ReorgEvent{
+		DetectedAtBlock:   blockNumber,
+		ForkPoint:         forkPoint,
+		Depth:             blockNumber - 1 - forkPoint,
+		StoredParentHash:  storedHash,
+		CanonicalParent:   parentHash,
+		RolledBackFrom:    forkPoint + 1,
+		DetectedTimestamp: time.Now().UTC(),
+	}, nil
+}
+
+// Rollback rolls the store back to the fork point of a reorg event, sets the number of rows it
+// invalidated and calls the reorg handlers.
+func (d *ReorgDetector) Rollback(event *ReorgEvent) error {
+	removed, err := d.store.Rollback(event.ForkPoint)
+	if err != nil {
+		return err
+	}
+	event.RowsInvalidated = removed
+	logrus.WithFields(event.Fields()).Warn("chain reorg detected, rolled back to fork point")
+	for _, handler := range d.handlers {
+		handler(*event)
+	}
+	return nil
+}
+
+// findForkPoint walks back from blockNumber until the stored hash matches the canonical chain.
+func (d *ReorgDetector) findForkPoint(ctx context.Context, blockNumber uint64) (uint64, error) {
+	for depth := uint64(0); depth <= d.maxDepth; depth++ {
+		if depth > blockNumber {
+			break
+		}
+		current := blockNumber - depth
+		storedHash, ok, err := d.store.BlockHash(current)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			// Nothing stored this far back, so every stored block above is already invalidated.
+			return current, nil
+		}
+		canonicalHash, err := d.canonicalBlockHash(ctx, current)
+		if err != nil {
+			return 0, err
+		}
+		if sameHash(storedHash, canonicalHash) {
+			return current, nil
+		}
+	}
+	return 0, fmt.Errorf("no fork point found within %d blocks of block %d", d.maxDepth, blockNumber)
+}
+
+func (d *ReorgDetector) canonicalBlockHash(ctx context.Context, blockNumber uint64) (string, error) {
+	params := map[string]interface{}{
+		"block_id": map[string]interface{}{
+			"block_number": int(blockNumber),
+		},
+	}
+	resp, err := MakeRPCCall(ctx, d.url, "starknet_getBlockWithTxHashes", params)
+	if err != nil {
+		return "", fmt.Errorf("failed to get block hash for block %d: %v", blockNumber, err)
+	}
+	var block BlockData
+	if err := json.Unmarshal(resp.Result, &block); err != nil {
+		return "", fmt.Errorf("failed to unmarshal block %d: %v", blockNumber, err)
+	}
+	return block.BlockHash, nil
+}
+
+// BlockHeader is the number, hash and parent hash of a block. BlockHash is empty for pending blocks.
+type BlockHeader struct {
+	BlockNumber uint64
+	BlockHash   string
+	ParentHash  string
+}
+
+// HeaderFromBlockWithReceipts returns the header of a block read with starknet_getBlockWithReceipts.
+func HeaderFromBlockWithReceipts(block BlockWithReceipts) BlockHeader {
+	header := BlockHeader{BlockNumber: block.BlockNumber, ParentHash: block.ParentHash}
+	if block.BlockHash != nil {
+		header.BlockHash = *block.BlockHash
+	}
+	return header
+}
+
+// GetBlockHeaders reads the headers of the blocks fromBlock to toBlock with
+// starknet_getBlockWithTxHashes, in block order.
+func GetBlockHeaders(ctx context.Context, url string, fromBlock uint64, toBlock uint64) ([]BlockHeader, error) {
+	headers := make([]BlockHeader, toBlock-fromBlock+1)
+	err := forEachBlock(ctx, len(headers), func(ctx context.Context, i int) error {
+		blockNumber := fromBlock + uint64(i)
+		params := map[string]interface{}{
+			"block_id": map[string]interface{}{
+				"block_number": int(blockNumber),
+			},
+		}
+		resp, err := MakeRPCCall(ctx, url, "starknet_getBlockWithTxHashes", params)
+		if err != nil {
+			return fmt.Errorf("failed to get block header for block %d: %v", blockNumber, err)
+		}
+		var block BlockData
+		if err := json.Unmarshal(resp.Result, &block); err != nil {
+			return fmt.Errorf("failed to unmarshal block %d: %v", blockNumber, err)
+		}
+		headers[i] = BlockHeader{BlockNumber: blockNumber, BlockHash: block.BlockHash, ParentHash: block.ParentHash}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return headers, nil
+}
+
+// ReorgError is returned when ingested blocks no longer belong to the canonical chain. The store
+// has not been rolled back yet: once no more rows of the blocks after the fork point can be
+// written, ReorgDetector.Rollback must be called with Event, and the blocks from
+// Event.RolledBackFrom on must be ingested again.
+type ReorgError struct {
+	Event ReorgEvent
+}
+
+func (e *ReorgError) Error() string {
+	return fmt.Sprintf("chain reorg detected at block %d, rolled back to block %d", e.Event.DetectedAtBlock, e.Event.ForkPoint)
+}
+
+// Verify checks the parent hashes of consecutive block headers, in block order, and records the
+// hashes of the blocks that continue the stored chain. On a reorg a *ReorgError is returned without
+// rolling back the store, since rows of the blocks verified before may still be on their way to
+// the exporters. Pending blocks are checked but not recorded.
+func (d *ReorgDetector) Verify(ctx context.Context, headers []BlockHeader) error {
+	for _, header := range headers {
+		event, err := d.detect(ctx, header.BlockNumber, header.ParentHash)
+		if err != nil {
+			return err
+		}
+		if event != nil {
+			return &ReorgError{Event: *event}
+		}
+		if header.BlockHash == "" {
+			continue
+		}
+		if err := d.Record(header.BlockNumber, header.BlockHash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sameHash compares two felt hex strings, ignoring case and leading zeros.
+func sameHash(a string, b string) bool {
+	return normalizeHex(a) == normalizeHex(b)
+}
+
+// NormalizeAddress returns the canonical form of a Starknet address: lower case hex without
+// leading zeros, so that addresses can be compared as strings.
+func NormalizeAddress(address string) string {
+	return normalizeHex(address)
+}
+
+func normalizeHex(value string) string {
+	trimmed := value
+	if len(trimmed) >= 2 && (trimmed[:2] == "0x" || trimmed[:2] == "0X") {
+		trimmed = trimmed[2:]
+	}
+	for len(trimmed) > 1 && trimmed[0] == '0' {
+		trimmed = trimmed[1:]
+	}
+	result := []byte(trimmed)
+	for i, c := range result {
+		if c >= 'A' && c <= 'F' {
+			result[i] = c + ('a' - 'A')
+		}
+	}
+	return "0x" + string(result)
+}
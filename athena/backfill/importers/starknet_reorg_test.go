@@ -0,0 +1,178 @@
+package importers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/BlocSoc-iitr/Athena/athena/database/models"
+	"github.com/BlocSoc-iitr/Athena/athena/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// fakeChain serves starknet_getBlockWithReceipts and starknet_getBlockWithTxHashes from an in-memory chain.
+type fakeChain struct {
+	mu     sync.Mutex
+	hashes map[uint64]string
+}
+
+func (c *fakeChain) setHash(blockNumber uint64, hash string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.hashes[blockNumber] = hash
+}
+
+func (c *fakeChain) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Method string `json:"method"`
+		Params struct {
+			BlockID struct {
+				BlockNumber uint64 `json:"block_number"`
+			} `json:"block_id"`
+		} `json:"params"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	number := req.Params.BlockID.BlockNumber
+	block := map[string]interface{}{
+		"block_number": number,
+		"block_hash":   c.hashes[number],
+		"parent_hash":  c.hashes[number-1],
+		"transactions": []interface{}{},
+	}
+	c.mu.Unlock()
+	result, _ := json.Marshal(block)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"result":  json.RawMessage(result),
+	})
+}
+
+func TestReorgDetectorContinuousChain(t *testing.T) {
+	store := NewMemoryBlockHashStore()
+	detector := NewReorgDetector("http://unused", store, 0)
+	assert.NoError(t, detector.Record(10, "0xa"))
+
+	event, err := detector.Check(context.Background(), 11, "0x0A")
+	assert.NoError(t, err)
+	assert.Nil(t, event)
+}
+
+func TestReorgDetectorVerify(t *testing.T) {
+	chain := &fakeChain{hashes: map[uint64]string{}}
+	for i := uint64(0); i <= 20; i++ {
+		chain.setHash(i, fmt.Sprintf("0x%x", 0x100+i))
+	}
+	server := httptest.NewServer(chain)
+	defer server.Close()
+
+	store := NewMemoryBlockHashStore()
+	detector := NewReorgDetector(server.URL, store, 10)
+
+	var events []ReorgEvent
+	detector.OnReorg(func(event ReorgEvent) {
+		events = append(events, event)
+	})
+
+	headers, err := GetBlockHeaders(context.Background(), server.URL, 5, 12)
+	assert.NoError(t, err)
+	assert.Len(t, headers, 8)
+	assert.NoError(t, detector.Verify(context.Background(), headers))
+
+	// Blocks 11 and 12 are replaced by a fork branching off block 10.
+	chain.setHash(11, "0xb11")
+	chain.setHash(12, "0xb12")
+	chain.setHash(13, "0xb13")
+
+	headers, err = GetBlockHeaders(context.Background(), server.URL, 13, 14)
+	assert.NoError(t, err)
+	err = detector.Verify(context.Background(), headers)
+	var reorgErr *ReorgError
+	assert.ErrorAs(t, err, &reorgErr)
+	assert.Equal(t, uint64(11), reorgErr.Event.RolledBackFrom)
+	assert.Empty(t, events, "the store is rolled back by the caller")
+	_, ok, _ := store.BlockHash(12)
+	assert.True(t, ok)
+
+	require.NoError(t, detector.Rollback(&reorgErr.Event))
+	assert.Len(t, events, 1)
+	assert.Equal(t, uint64(13), events[0].DetectedAtBlock)
+	assert.Equal(t, uint64(10), events[0].ForkPoint)
+	assert.Equal(t, uint64(2), events[0].Depth)
+	assert.Equal(t, int64(2), events[0].RowsInvalidated)
+
+	// Ingesting again from the block after the fork point continues the canonical chain.
+	headers, err = GetBlockHeaders(context.Background(), server.URL, 11, 14)
+	assert.NoError(t, err)
+	assert.NoError(t, detector.Verify(context.Background(), headers))
+	hash, ok, _ := store.BlockHash(12)
+	assert.True(t, ok)
+	assert.Equal(t, "0xb12", hash)
+}
+
+func TestDBBlockHashStoreRollback(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: gormlogger.Discard})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.Block{}, &models.Transaction{}, &models.DefaultEvent{}, &models.ERC20Transfer{},
+		&models.Trace{}, &models.StorageDiff{}, &models.DeployedContract{}, &models.DeclaredClass{}, &models.ReplacedClass{},
+		&models.NonceUpdate{}, &models.ContractClassHistory{}, &models.BackfilledRange{}))
+
+	for blockNumber := uint64(9); blockNumber <= 12; blockNumber++ {
+		require.NoError(t, db.Create(&models.StorageDiff{BlockNumber: blockNumber, ContractAddress: "0x1"}).Error)
+		require.NoError(t, db.Create(&models.NonceUpdate{BlockNumber: blockNumber, ContractAddress: "0x1"}).Error)
+	}
+	require.NoError(t, db.Create(&[]models.ContractClassHistory{
+		{ContractAddress: "0x1", FromBlock: 0, ToBlock: sql.NullInt64{Int64: 10, Valid: true}, ClassHash: "0xa"},
+		{ContractAddress: "0x1", FromBlock: 11, ClassHash: "0xb"},
+		{ContractAddress: "0x2", FromBlock: 0, ToBlock: sql.NullInt64{Int64: 5, Valid: true}, ClassHash: "0xc"},
+		{ContractAddress: "0x2", FromBlock: 6, ClassHash: "0xd"},
+	}).Error)
+	require.NoError(t, db.Create(&[]models.BackfilledRange{
+		{BackfillID: "a", DataType: types.Events, Network: types.StarkNet, StartBlock: 0, EndBlock: 12},
+		{BackfillID: "b", DataType: types.Events, Network: types.StarkNet, StartBlock: 11, EndBlock: 12},
+	}).Error)
+
+	store := NewDBBlockHashStore(db)
+	require.NoError(t, store.SaveBlockHash(12, "0xc"))
+	removed, err := store.Rollback(10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), removed)
+
+	_, ok, err := store.BlockHash(12)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	var diffs []models.StorageDiff
+	require.NoError(t, db.Order("block_number").Find(&diffs).Error)
+	assert.Len(t, diffs, 2)
+
+	var history []models.ContractClassHistory
+	require.NoError(t, db.Order("contract_address, from_block").Find(&history).Error)
+	require.Len(t, history, 3)
+	assert.False(t, history[0].ToBlock.Valid)
+	assert.Equal(t, int64(5), history[1].ToBlock.Int64)
+	assert.False(t, history[2].ToBlock.Valid)
+
+	var ranges []models.BackfilledRange
+	require.NoError(t, db.Find(&ranges).Error)
+	require.Len(t, ranges, 1)
+	assert.Equal(t, 10, ranges[0].EndBlock)
+}
+
+func TestSameHash(t *testing.T) {
+	assert.True(t, sameHash("0x00ABc", "0xabc"))
+	assert.False(t, sameHash("0xabc", "0xabd"))
+}
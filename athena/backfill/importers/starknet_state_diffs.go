@@ -0,0 +1,243 @@
+package importers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/BlocSoc-iitr/Athena/athena/database/models"
+)
+
+// StorageEntry is a single storage write. Key and Value are only set directly on a
+// storage diff item in the pre v0.2 schema, which listed every write flat.
+type StorageEntry struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type StorageDiffItem struct {
+	Address        string         `json:"address"`
+	StorageEntries []StorageEntry `json:"storage_entries"`
+	Key            string         `json:"key"`
+	Value          string         `json:"value"`
+}
+
+type DeployedContractItem struct {
+	Address         string `json:"address"`
+	ContractAddress string `json:"contract_address"`
+	ClassHash       string `json:"class_hash"`
+}
+
+type DeclaredClassItem struct {
+	ClassHash         string `json:"class_hash"`
+	CompiledClassHash string `json:"compiled_class_hash"`
+}
+
+type ReplacedClassItem struct {
+	ContractAddress string `json:"contract_address"`
+	ClassHash       string `json:"class_hash"`
+}
+
+type NonceItem struct {
+	ContractAddress string `json:"contract_address"`
+	Nonce           string `json:"nonce"`
+}
+
+// StateDiff is the state_diff of a starknet_getStateUpdate result. Both the v0.13 schema, where
+// Cairo 0 classes are listed in deprecated_declared_classes, and the older schema, which used
+// declared_contract_hashes, are accepted.
+type StateDiff struct {
+	StorageDiffs              []StorageDiffItem      `json:"storage_diffs"`
+	DeployedContracts         []DeployedContractItem `json:"deployed_contracts"`
+	DeclaredClasses           []DeclaredClassItem    `json:"declared_classes"`
+	DeprecatedDeclaredClasses []string               `json:"deprecated_declared_classes"`
+	DeclaredContractHashes    []string               `json:"declared_contract_hashes"`
+	ReplacedClasses           []ReplacedClassItem    `json:"replaced_classes"`
+	Nonces                    []NonceItem            `json:"nonces"`
+}
+
+type StateUpdate struct {
+	BlockNumber uint64    `json:"-"`
+	BlockHash   string    `json:"block_hash"`
+	NewRoot     string    `json:"new_root"`
+	OldRoot     string    `json:"old_root"`
+	StateDiff   StateDiff `json:"state_diff"`
+}
+
+// StateDiffRows holds the rows of every state diff table for one or more blocks.
+type StateDiffRows struct {
+	StorageDiffs      []models.StorageDiff
+	DeployedContracts []models.DeployedContract
+	DeclaredClasses   []models.DeclaredClass
+	ReplacedClasses   []models.ReplacedClass
+	NonceUpdates      []models.NonceUpdate
+}
+
+// GetStateUpdates calls starknet_getStateUpdate for each block of a range, ordered by block number.
+// At most DefaultEthWorkers blocks are fetched concurrently.
+func GetStateUpdates(ctx context.Context, url string, fromBlock uint64, toBlock uint64) ([]StateUpdate, error) {
+	if toBlock < fromBlock {
+		return nil, nil
+	}
+	updates := make([]StateUpdate, toBlock-fromBlock+1)
+	err := forEachBlock(ctx, len(updates), func(ctx context.Context, i int) error {
+		blockNumber := fromBlock + uint64(i)
+		params := map[string]interface{}{
+			"block_id": map[string]interface{}{
+				"block_number": int(blockNumber),
+			},
+		}
+		resp, err := MakeRPCCall(ctx, url, "starknet_getStateUpdate", params)
+		if err != nil {
+			return fmt.Errorf("failed to get state update for block %d: %v", blockNumber, err)
+		}
+
+		if err := json.Unmarshal(resp.Result, &updates[i]); err != nil {
+			return fmt.Errorf("failed to unmarshal state update for block %d: %v", blockNumber, err)
+		}
+		updates[i].BlockNumber = blockNumber
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updates, nil
+}
+
+// GetStateDiffs imports the state diffs of a block range.
+func GetStateDiffs(ctx context.Context, url string, fromBlock uint64, toBlock uint64) (StateDiffRows, error) {
+	updates, err := GetStateUpdates(ctx, url, fromBlock, toBlock)
+	if err != nil {
+		return StateDiffRows{}, err
+	}
+
+	var rows StateDiffRows
+	for _, update := range updates {
+		rows.Append(StateDiffFromUpdate(update))
+	}
+	return rows, nil
+}
+
+// StateDiffFromUpdate converts a state update into rows of the state diff tables.
+func StateDiffFromUpdate(update StateUpdate) StateDiffRows {
+	var rows StateDiffRows
+	diff := update.StateDiff
+	blockNumber := update.BlockNumber
+
+	for _, item := range diff.StorageDiffs {
+		entries := item.StorageEntries
+		if len(entries) == 0 && item.Key != "" {
+			entries = []StorageEntry{{Key: item.Key, Value: item.Value}}
+		}
+		for _, entry := range entries {
+			rows.StorageDiffs = append(rows.StorageDiffs, models.StorageDiff{
+				BlockNumber:     blockNumber,
+				ContractAddress: item.Address,
+				StorageKey:      entry.Key,
+				StorageValue:    entry.Value,
+			})
+		}
+	}
+
+	for _, item := range diff.DeployedContracts {
+		rows.DeployedContracts = append(rows.DeployedContracts, models.DeployedContract{
+			BlockNumber:     blockNumber,
+			ContractAddress: firstNonEmpty(item.Address, item.ContractAddress),
+			ClassHash:       item.ClassHash,
+		})
+	}
+
+	for _, item := range diff.DeclaredClasses {
+		rows.DeclaredClasses = append(rows.DeclaredClasses, models.DeclaredClass{
+			BlockNumber:       blockNumber,
+			ClassHash:         item.ClassHash,
+			CompiledClassHash: nullString(item.CompiledClassHash),
+		})
+	}
+	for _, classHash := range append(diff.DeprecatedDeclaredClasses, diff.DeclaredContractHashes...) {
+		rows.DeclaredClasses = append(rows.DeclaredClasses, models.DeclaredClass{
+			BlockNumber:       blockNumber,
+			ClassHash:         classHash,
+			CompiledClassHash: sql.NullString{},
+		})
+	}
+
+	for _, item := range diff.ReplacedClasses {
+		rows.ReplacedClasses = append(rows.ReplacedClasses, models.ReplacedClass{
+			BlockNumber:     blockNumber,
+			ContractAddress: item.ContractAddress,
+			ClassHash:       item.ClassHash,
+		})
+	}
+
+	for _, item := range diff.Nonces {
+		rows.NonceUpdates = append(rows.NonceUpdates, models.NonceUpdate{
+			BlockNumber:     blockNumber,
+			ContractAddress: item.ContractAddress,
+			Nonce:           item.Nonce,
+		})
+	}
+	return rows
+}
+
+// Append adds the rows of another set of state diffs.
+func (r *StateDiffRows) Append(other StateDiffRows) {
+	r.StorageDiffs = append(r.StorageDiffs, other.StorageDiffs...)
+	r.DeployedContracts = append(r.DeployedContracts, other.DeployedContracts...)
+	r.DeclaredClasses = append(r.DeclaredClasses, other.DeclaredClasses...)
+	r.ReplacedClasses = append(r.ReplacedClasses, other.ReplacedClasses...)
+	r.NonceUpdates = append(r.NonceUpdates, other.NonceUpdates...)
+}
+
+// StateDiffsToDicts converts state diffs into rows for the state diff exporters, keyed by table name.
+func StateDiffsToDicts(rows StateDiffRows) map[string][]map[string]interface{} {
+	dicts := map[string][]map[string]interface{}{
+		"storage_diffs":      make([]map[string]interface{}, 0, len(rows.StorageDiffs)),
+		"deployed_contracts": make([]map[string]interface{}, 0, len(rows.DeployedContracts)),
+		"declared_classes":   make([]map[string]interface{}, 0, len(rows.DeclaredClasses)),
+		"replaced_classes":   make([]map[string]interface{}, 0, len(rows.ReplacedClasses)),
+		"nonce_updates":      make([]map[string]interface{}, 0, len(rows.NonceUpdates)),
+	}
+	for _, diff := range rows.StorageDiffs {
+		dicts["storage_diffs"] = append(dicts["storage_diffs"], map[string]interface{}{
+			"block_number":     int(diff.BlockNumber),
+			"contract_address": diff.ContractAddress,
+			"storage_key":      diff.StorageKey,
+			"storage_value":    diff.StorageValue,
+		})
+	}
+	for _, contract := range rows.DeployedContracts {
+		dicts["deployed_contracts"] = append(dicts["deployed_contracts"], map[string]interface{}{
+			"block_number":     int(contract.BlockNumber),
+			"contract_address": contract.ContractAddress,
+			"class_hash":       contract.ClassHash,
+		})
+	}
+	for _, class := range rows.DeclaredClasses {
+		var compiledClassHash interface{}
+		if class.CompiledClassHash.Valid {
+			compiledClassHash = class.CompiledClassHash.String
+		}
+		dicts["declared_classes"] = append(dicts["declared_classes"], map[string]interface{}{
+			"block_number":        int(class.BlockNumber),
+			"class_hash":          class.ClassHash,
+			"compiled_class_hash": compiledClassHash,
+		})
+	}
+	for _, class := range rows.ReplacedClasses {
+		dicts["replaced_classes"] = append(dicts["replaced_classes"], map[string]interface{}{
+			"block_number":     int(class.BlockNumber),
+			"contract_address": class.ContractAddress,
+			"class_hash":       class.ClassHash,
+		})
+	}
+	for _, nonce := range rows.NonceUpdates {
+		dicts["nonce_updates"] = append(dicts["nonce_updates"], map[string]interface{}{
+			"block_number":     int(nonce.BlockNumber),
+			"contract_address": nonce.ContractAddress,
+			"nonce":            nonce.Nonce,
+		})
+	}
+	return dicts
+}
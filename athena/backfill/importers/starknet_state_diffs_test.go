@@ -0,0 +1,82 @@
+package importers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStateDiffFromUpdate(t *testing.T) {
+	tests := []struct {
+		name   string
+		update string
+	}{
+		{"v0.13 schema", `{"block_hash": "0xb", "state_diff": {
+			"storage_diffs": [{"address": "0xa", "storage_entries": [{"key": "0x1", "value": "0x2"}, {"key": "0x3", "value": "0x4"}]}],
+			"deployed_contracts": [{"address": "0xc", "class_hash": "0xc1"}],
+			"declared_classes": [{"class_hash": "0xd1", "compiled_class_hash": "0xd2"}],
+			"deprecated_declared_classes": ["0xd0"],
+			"replaced_classes": [{"contract_address": "0xa", "class_hash": "0xa2"}],
+			"nonces": [{"contract_address": "0xa", "nonce": "0x5"}]
+		}}`},
+		{"old schema", `{"block_hash": "0xb", "state_diff": {
+			"storage_diffs": [{"address": "0xa", "key": "0x1", "value": "0x2"}, {"address": "0xa", "key": "0x3", "value": "0x4"}],
+			"deployed_contracts": [{"contract_address": "0xc", "class_hash": "0xc1"}],
+			"declared_classes": [{"class_hash": "0xd1", "compiled_class_hash": "0xd2"}],
+			"declared_contract_hashes": ["0xd0"],
+			"replaced_classes": [{"contract_address": "0xa", "class_hash": "0xa2"}],
+			"nonces": [{"contract_address": "0xa", "nonce": "0x5"}]
+		}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var update StateUpdate
+			assert.NoError(t, json.Unmarshal([]byte(tt.update), &update))
+			update.BlockNumber = 9
+
+			rows := StateDiffFromUpdate(update)
+			assert.Len(t, rows.StorageDiffs, 2)
+			assert.Equal(t, "0x3", rows.StorageDiffs[1].StorageKey)
+			assert.Equal(t, "0x4", rows.StorageDiffs[1].StorageValue)
+			assert.Equal(t, "0xc", rows.DeployedContracts[0].ContractAddress)
+			assert.Len(t, rows.DeclaredClasses, 2)
+			assert.Equal(t, "0xd2", rows.DeclaredClasses[0].CompiledClassHash.String)
+			assert.Equal(t, "0xd0", rows.DeclaredClasses[1].ClassHash)
+			assert.False(t, rows.DeclaredClasses[1].CompiledClassHash.Valid)
+			assert.Equal(t, "0xa2", rows.ReplacedClasses[0].ClassHash)
+			assert.Equal(t, "0x5", rows.NonceUpdates[0].Nonce)
+			assert.Equal(t, uint64(9), rows.NonceUpdates[0].BlockNumber)
+		})
+	}
+}
+
+func TestGetStateDiffs(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string `json:"method"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		assert.Equal(t, "starknet_getStateUpdate", req.Method)
+		w.Write([]byte(`{"jsonrpc": "2.0", "id": 1, "result": {"state_diff": {
+			"storage_diffs": [], "deployed_contracts": [], "declared_classes": [],
+			"deprecated_declared_classes": ["0xd0"], "replaced_classes": [], "nonces": [{"contract_address": "0xa", "nonce": "0x1"}]
+		}}}`))
+	}))
+	defer server.Close()
+
+	rows, err := GetStateDiffs(context.Background(), server.URL, 3, 5)
+	assert.NoError(t, err)
+	assert.Len(t, rows.NonceUpdates, 3)
+	assert.Equal(t, uint64(3), rows.NonceUpdates[0].BlockNumber)
+	assert.Equal(t, uint64(5), rows.NonceUpdates[2].BlockNumber)
+
+	dicts := StateDiffsToDicts(rows)
+	assert.Len(t, dicts["declared_classes"], 3)
+	assert.Nil(t, dicts["declared_classes"][0]["compiled_class_hash"])
+	assert.Empty(t, dicts["storage_diffs"])
+}
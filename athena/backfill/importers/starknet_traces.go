@@ -0,0 +1,209 @@
+package importers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/BlocSoc-iitr/Athena/athena/database/models"
+	"github.com/BlocSoc-iitr/Athena/athena/database/writers"
+	"gorm.io/datatypes"
+)
+
+// FunctionInvocation is a single call in a Starknet transaction trace.
+type FunctionInvocation struct {
+	ContractAddress    string                 `json:"contract_address"`
+	EntryPointSelector string                 `json:"entry_point_selector"`
+	Calldata           []string               `json:"calldata"`
+	CallerAddress      string                 `json:"caller_address"`
+	ClassHash          string                 `json:"class_hash"`
+	EntryPointType     string                 `json:"entry_point_type"`
+	CallType           string                 `json:"call_type"`
+	Result             []string               `json:"result"`
+	Calls              []FunctionInvocation   `json:"calls"`
+	ExecutionResources map[string]interface{} `json:"execution_resources"`
+	IsReverted         bool                   `json:"is_reverted"`
+	RevertReason       string                 `json:"revert_reason"`
+}
+
+// TransactionTrace is the trace_root of a transaction. ExecuteInvocation is kept raw,
+// because it is either a function invocation or an object holding the revert reason.
+type TransactionTrace struct {
+	Type                  string              `json:"type"`
+	ValidateInvocation    *FunctionInvocation `json:"validate_invocation"`
+	ExecuteInvocation     json.RawMessage     `json:"execute_invocation"`
+	FeeTransferInvocation *FunctionInvocation `json:"fee_transfer_invocation"`
+	ConstructorInvocation *FunctionInvocation `json:"constructor_invocation"`
+	FunctionInvocation    *FunctionInvocation `json:"function_invocation"`
+}
+
+type BlockTransactionTrace struct {
+	TransactionHash string           `json:"transaction_hash"`
+	TraceRoot       TransactionTrace `json:"trace_root"`
+}
+
+// BlockTraces holds the traces of every transaction in a block, in transaction order.
+type BlockTraces struct {
+	BlockNumber uint64
+	Traces      []BlockTransactionTrace
+}
+
+// GetBlockTraces calls starknet_traceBlockTransactions for each block of a range, ordered by block number.
+// At most DefaultEthWorkers blocks are fetched concurrently.
+func GetBlockTraces(ctx context.Context, url string, fromBlock uint64, toBlock uint64) ([]BlockTraces, error) {
+	if toBlock < fromBlock {
+		return nil, nil
+	}
+	blocks := make([]BlockTraces, toBlock-fromBlock+1)
+	err := forEachBlock(ctx, len(blocks), func(ctx context.Context, i int) error {
+		blockNumber := fromBlock + uint64(i)
+		params := map[string]interface{}{
+			"block_id": map[string]interface{}{
+				"block_number": int(blockNumber),
+			},
+		}
+		resp, err := MakeRPCCall(ctx, url, "starknet_traceBlockTransactions", params)
+		if err != nil {
+			return fmt.Errorf("failed to get traces for block %d: %v", blockNumber, err)
+		}
+
+		var traces []BlockTransactionTrace
+		if err := json.Unmarshal(resp.Result, &traces); err != nil {
+			return fmt.Errorf("failed to unmarshal traces for block %d: %v", blockNumber, err)
+		}
+		blocks[i] = BlockTraces{BlockNumber: blockNumber, Traces: traces}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return blocks, nil
+}
+
+// GetTraces imports the flattened traces of a block range.
+func GetTraces(ctx context.Context, url string, fromBlock uint64, toBlock uint64) ([]models.Trace, error) {
+	blocks, err := GetBlockTraces(ctx, url, fromBlock, toBlock)
+	if err != nil {
+		return nil, err
+	}
+
+	var traces []models.Trace
+	for _, block := range blocks {
+		blockTraces, err := FlattenBlockTraces(block)
+		if err != nil {
+			return nil, err
+		}
+		traces = append(traces, blockTraces...)
+	}
+	return traces, nil
+}
+
+// FlattenBlockTraces flattens the invocation trees of every transaction in a block into trace rows.
+// Each root invocation (validate, execute, fee_transfer, constructor or l1_handler) starts its own tree.
+func FlattenBlockTraces(block BlockTraces) ([]models.Trace, error) {
+	var traces []models.Trace
+	for txIndex, txTrace := range block.Traces {
+		base := models.Trace{
+			AbstractTrace: models.AbstractTrace{
+				BlockNumber:      block.BlockNumber,
+				TransactionHash:  txTrace.TransactionHash,
+				TransactionIndex: txIndex,
+			},
+		}
+		root := txTrace.TraceRoot
+
+		traces = flattenInvocation(traces, base, "validate", root.ValidateInvocation, []int{})
+
+		if len(root.ExecuteInvocation) > 0 && string(root.ExecuteInvocation) != "null" {
+			var execute FunctionInvocation
+			if err := json.Unmarshal(root.ExecuteInvocation, &execute); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal execute invocation of %s: %v", txTrace.TransactionHash, err)
+			}
+			if execute.ContractAddress == "" && execute.RevertReason != "" {
+				// Reverted transactions only carry the revert reason instead of the call tree.
+				reverted := base
+				reverted.TraceType = "execute"
+				reverted.TraceAddress = traceAddressJSON([]int{})
+				reverted.Error = execute.RevertReason
+				traces = append(traces, reverted)
+			} else {
+				traces = flattenInvocation(traces, base, "execute", &execute, []int{})
+			}
+		}
+
+		traces = flattenInvocation(traces, base, "constructor", root.ConstructorInvocation, []int{})
+		traces = flattenInvocation(traces, base, "l1_handler", root.FunctionInvocation, []int{})
+		traces = flattenInvocation(traces, base, "fee_transfer", root.FeeTransferInvocation, []int{})
+	}
+	return traces, nil
+}
+
+// flattenInvocation appends an invocation and all of its nested calls in depth first order.
+func flattenInvocation(traces []models.Trace, base models.Trace, traceType string, invocation *FunctionInvocation, traceAddress []int) []models.Trace {
+	if invocation == nil {
+		return traces
+	}
+
+	trace := base
+	trace.TraceType = traceType
+	trace.TraceAddress = traceAddressJSON(traceAddress)
+	trace.CallType = invocation.CallType
+	trace.EntryPointType = invocation.EntryPointType
+	trace.ContractAddress = invocation.ContractAddress
+	trace.CallerAddress = invocation.CallerAddress
+	trace.ClassHash = invocation.ClassHash
+	trace.Selector = invocation.EntryPointSelector
+	trace.Calldata = nonNil(invocation.Calldata)
+	trace.Result = nonNil(invocation.Result)
+	trace.ExecutionResources = invocation.ExecutionResources
+	trace.GasUsed = invocationGas(invocation.ExecutionResources)
+	if invocation.IsReverted {
+		trace.Error = firstNonEmpty(invocation.RevertReason, "reverted")
+	}
+	traces = append(traces, trace)
+
+	for i := range invocation.Calls {
+		childAddress := append(append([]int{}, traceAddress...), i)
+		traces = flattenInvocation(traces, base, traceType, &invocation.Calls[i], childAddress)
+	}
+	return traces
+}
+
+// invocationGas returns the L2 gas of an invocation when the node reports it, and the Cairo steps otherwise.
+func invocationGas(resources map[string]interface{}) int64 {
+	for _, key := range []string{"l2_gas", "steps"} {
+		if value, ok := resources[key].(float64); ok {
+			return int64(value)
+		}
+	}
+	return 0
+}
+
+func traceAddressJSON(traceAddress []int) datatypes.JSON {
+	return datatypes.JSON(writers.TraceAddressToString(traceAddress))
+}
+
+// TracesToDicts converts traces into rows for the trace_file exporter.
+func TracesToDicts(traces []models.Trace) []map[string]interface{} {
+	rows := make([]map[string]interface{}, len(traces))
+	for i, trace := range traces {
+		rows[i] = map[string]interface{}{
+			"block_number":      int(trace.BlockNumber),
+			"transaction_hash":  trace.TransactionHash,
+			"transaction_index": trace.TransactionIndex,
+			"trace_type":        trace.TraceType,
+			"trace_address":     string(trace.TraceAddress),
+			"call_type":         trace.CallType,
+			"entry_point_type":  trace.EntryPointType,
+			"contract_address":  trace.ContractAddress,
+			"caller_address":    trace.CallerAddress,
+			"class_hash":        trace.ClassHash,
+			"selector":          trace.Selector,
+			"calldata":          stringsToInterfaces(trace.Calldata),
+			"result":            stringsToInterfaces(trace.Result),
+			"gas_used":          int(trace.GasUsed),
+			"error":             trace.Error,
+		}
+	}
+	return rows
+}
@@ -0,0 +1,94 @@
+package importers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testBlockTraces = `[
+	{"transaction_hash": "0xt1", "trace_root": {
+		"type": "INVOKE",
+		"validate_invocation": {"contract_address": "0xa", "entry_point_selector": "0x1", "calldata": [], "caller_address": "0x0", "entry_point_type": "EXTERNAL", "call_type": "CALL", "result": [], "calls": [], "execution_resources": {"steps": 50}},
+		"execute_invocation": {"contract_address": "0xa", "entry_point_selector": "0x2", "calldata": ["0x1"], "caller_address": "0x0", "entry_point_type": "EXTERNAL", "call_type": "CALL", "result": ["0x1"], "execution_resources": {"steps": 300, "l2_gas": 1200},
+			"calls": [
+				{"contract_address": "0xb", "entry_point_selector": "0x3", "calldata": [], "caller_address": "0xa", "entry_point_type": "EXTERNAL", "call_type": "CALL", "result": [], "execution_resources": {"steps": 100},
+					"calls": [{"contract_address": "0xc", "entry_point_selector": "0x4", "calldata": [], "caller_address": "0xb", "entry_point_type": "EXTERNAL", "call_type": "DELEGATE", "result": [], "calls": [], "execution_resources": {"steps": 20}}]},
+				{"contract_address": "0xd", "entry_point_selector": "0x5", "calldata": [], "caller_address": "0xa", "entry_point_type": "EXTERNAL", "call_type": "CALL", "result": [], "calls": [], "execution_resources": {"steps": 30}, "is_reverted": true}
+			]},
+		"fee_transfer_invocation": {"contract_address": "0xfee", "entry_point_selector": "0x6", "calldata": [], "caller_address": "0xa", "entry_point_type": "EXTERNAL", "call_type": "CALL", "result": [], "calls": [], "execution_resources": {"steps": 10}}
+	}},
+	{"transaction_hash": "0xt2", "trace_root": {
+		"type": "INVOKE",
+		"execute_invocation": {"revert_reason": "Error in the called contract"}
+	}},
+	{"transaction_hash": "0xt3", "trace_root": {
+		"type": "L1_HANDLER",
+		"function_invocation": {"contract_address": "0xe", "entry_point_selector": "0x7", "calldata": [], "caller_address": "0x0", "entry_point_type": "L1_HANDLER", "call_type": "CALL", "result": [], "calls": [], "execution_resources": {"steps": 5}}
+	}}
+]`
+
+func TestFlattenBlockTraces(t *testing.T) {
+	var txTraces []BlockTransactionTrace
+	assert.NoError(t, json.Unmarshal([]byte(testBlockTraces), &txTraces))
+
+	traces, err := FlattenBlockTraces(BlockTraces{BlockNumber: 7, Traces: txTraces})
+	assert.NoError(t, err)
+	assert.Len(t, traces, 8)
+
+	expected := []struct {
+		traceType    string
+		traceAddress string
+		contract     string
+	}{
+		{"validate", "[]", "0xa"},
+		{"execute", "[]", "0xa"},
+		{"execute", "[0]", "0xb"},
+		{"execute", "[0,0]", "0xc"},
+		{"execute", "[1]", "0xd"},
+		{"fee_transfer", "[]", "0xfee"},
+		{"execute", "[]", ""},
+		{"l1_handler", "[]", "0xe"},
+	}
+	for i, e := range expected {
+		assert.Equal(t, e.traceType, traces[i].TraceType, "trace %d", i)
+		assert.Equal(t, e.traceAddress, string(traces[i].TraceAddress), "trace %d", i)
+		assert.Equal(t, e.contract, traces[i].ContractAddress, "trace %d", i)
+		assert.Equal(t, uint64(7), traces[i].BlockNumber)
+	}
+
+	assert.Equal(t, int64(1200), traces[1].GasUsed)
+	assert.Equal(t, int64(100), traces[2].GasUsed)
+	assert.Equal(t, "DELEGATE", traces[3].CallType)
+	assert.Equal(t, "reverted", traces[4].Error)
+	assert.Equal(t, "0xt2", traces[6].TransactionHash)
+	assert.Equal(t, 1, traces[6].TransactionIndex)
+	assert.Equal(t, "Error in the called contract", traces[6].Error)
+	assert.Equal(t, "L1_HANDLER", traces[7].EntryPointType)
+}
+
+func TestGetTraces(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string `json:"method"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		assert.Equal(t, "starknet_traceBlockTransactions", req.Method)
+		w.Write([]byte(`{"jsonrpc": "2.0", "id": 1, "result": ` + testBlockTraces + `}`))
+	}))
+	defer server.Close()
+
+	traces, err := GetTraces(context.Background(), server.URL, 1, 2)
+	assert.NoError(t, err)
+	assert.Len(t, traces, 16)
+	assert.Equal(t, uint64(1), traces[0].BlockNumber)
+	assert.Equal(t, uint64(2), traces[15].BlockNumber)
+
+	rows := TracesToDicts(traces)
+	assert.Equal(t, "[0,0]", rows[3]["trace_address"])
+	assert.Equal(t, []interface{}{"0x1"}, rows[1]["calldata"])
+}
@@ -0,0 +1,506 @@
+package importers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/BlocSoc-iitr/Athena/athena/database/models"
+	"github.com/BlocSoc-iitr/Athena/athena_abi"
+)
+
+// queryVersionOffset is added to the version of transactions that were sent for simulation or fee estimation.
+var queryVersionOffset = new(big.Int).Lsh(big.NewInt(1), 128)
+
+type ResourceBound struct {
+	MaxAmount       string `json:"max_amount"`
+	MaxPricePerUnit string `json:"max_price_per_unit"`
+}
+
+// StarknetTransaction holds the union of all fields of every Starknet transaction type and version.
+type StarknetTransaction struct {
+	TransactionHash           string                   `json:"transaction_hash"`
+	Type                      string                   `json:"type"`
+	Version                   string                   `json:"version"`
+	Nonce                     string                   `json:"nonce"`
+	MaxFee                    string                   `json:"max_fee"`
+	Signature                 []string                 `json:"signature"`
+	Calldata                  []string                 `json:"calldata"`
+	SenderAddress             string                   `json:"sender_address"`
+	ContractAddress           string                   `json:"contract_address"`
+	EntryPointSelector        string                   `json:"entry_point_selector"`
+	ClassHash                 string                   `json:"class_hash"`
+	CompiledClassHash         string                   `json:"compiled_class_hash"`
+	ContractAddressSalt       string                   `json:"contract_address_salt"`
+	ConstructorCalldata       []string                 `json:"constructor_calldata"`
+	ResourceBounds            map[string]ResourceBound `json:"resource_bounds"`
+	Tip                       string                   `json:"tip"`
+	PaymasterData             []string                 `json:"paymaster_data"`
+	AccountDeploymentData     []string                 `json:"account_deployment_data"`
+	NonceDataAvailabilityMode string                   `json:"nonce_data_availability_mode"`
+	FeeDataAvailabilityMode   string                   `json:"fee_data_availability_mode"`
+}
+
+type StarknetEvent struct {
+	FromAddress string   `json:"from_address"`
+	Keys        []string `json:"keys"`
+	Data        []string `json:"data"`
+}
+
+type StarknetReceipt struct {
+	Type            string `json:"type"`
+	TransactionHash string `json:"transaction_hash"`
+	ActualFee       struct {
+		Amount string `json:"amount"`
+		Unit   string `json:"unit"`
+	} `json:"actual_fee"`
+	ExecutionStatus    string                 `json:"execution_status"`
+	FinalityStatus     string                 `json:"finality_status"`
+	RevertReason       string                 `json:"revert_reason"`
+	ContractAddress    string                 `json:"contract_address"`
+	Events             []StarknetEvent        `json:"events"`
+	ExecutionResources map[string]interface{} `json:"execution_resources"`
+}
+
+// BlockWithReceipts is the result of starknet_getBlockWithReceipts with every transaction field decoded.
+type BlockWithReceipts struct {
+	BlockNumber      uint64     `json:"block_number"`
+	BlockHash        *string    `json:"block_hash"` // nil for pending blocks
+	ParentHash       string     `json:"parent_hash"`
+	NewRoot          string     `json:"new_root"`
+	Status           string     `json:"status"`
+	Timestamp        int64      `json:"timestamp"`
+	SequencerAddress string     `json:"sequencer_address"`
+	L1GasPrice       L1GasPrice `json:"l1_gas_price"`
+	L1DataGasPrice   L1GasPrice `json:"l1_data_gas_price"`
+	L1DAMode         string     `json:"l1_da_mode"`
+	StarknetVersion  string     `json:"starknet_version"`
+	Transactions     []struct {
+		Transaction StarknetTransaction `json:"transaction"`
+		Receipt     StarknetReceipt     `json:"receipt"`
+	} `json:"transactions"`
+}
+
+// GetBlocksWithReceipts fetches blocks with full transactions and receipts, ordered by block number.
+// At most DefaultEthWorkers blocks are fetched concurrently.
+func GetBlocksWithReceipts(ctx context.Context, url string, fromBlock uint64, toBlock uint64) ([]BlockWithReceipts, error) {
+	if toBlock < fromBlock {
+		return nil, nil
+	}
+	blocks := make([]BlockWithReceipts, toBlock-fromBlock+1)
+	err := forEachBlock(ctx, len(blocks), func(ctx context.Context, i int) error {
+		blockNumber := fromBlock + uint64(i)
+		params := map[string]interface{}{
+			"block_id": map[string]interface{}{
+				"block_number": int(blockNumber),
+			},
+		}
+		resp, err := MakeRPCCall(ctx, url, "starknet_getBlockWithReceipts", params)
+		if err != nil {
+			return fmt.Errorf("failed to get block with receipts for block %d: %v", blockNumber, err)
+		}
+
+		if err := json.Unmarshal(resp.Result, &blocks[i]); err != nil {
+			return fmt.Errorf("failed to unmarshal block with receipts for block %d: %v", blockNumber, err)
+		}
+		if blocks[i].BlockNumber == 0 {
+			blocks[i].BlockNumber = blockNumber
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return blocks, nil
+}
+
+// GetTransactions fetches a block range and maps every transaction into a models.Transaction row.
+func GetTransactions(ctx context.Context, url string, fromBlock uint64, toBlock uint64) ([]models.Transaction, error) {
+	blocks, err := GetBlocksWithReceipts(ctx, url, fromBlock, toBlock)
+	if err != nil {
+		return nil, err
+	}
+
+	var transactions []models.Transaction
+	for _, block := range blocks {
+		blockTransactions, err := TransactionsFromBlock(block)
+		if err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, blockTransactions...)
+	}
+	return transactions, nil
+}
+
+// BlockFromBlockWithReceipts maps a block header into a models.Block row.
+func BlockFromBlockWithReceipts(block BlockWithReceipts) (models.Block, error) {
+	if block.BlockHash == nil {
+		return models.Block{}, fmt.Errorf("block %d is pending and has no block hash", block.BlockNumber)
+	}
+
+	totalFee := 0.0
+	for _, tx := range block.Transactions {
+		fee, err := hexToFloat(tx.Receipt.ActualFee.Amount)
+		if err != nil {
+			return models.Block{}, fmt.Errorf("invalid actual fee in block %d: %v", block.BlockNumber, err)
+		}
+		totalFee += fee
+	}
+
+	l1GasPriceWei, err := hexToFloat(block.L1GasPrice.PriceInWei)
+	if err != nil {
+		return models.Block{}, err
+	}
+	l1GasPriceFri, err := hexToFloat(block.L1GasPrice.PriceInFri)
+	if err != nil {
+		return models.Block{}, err
+	}
+
+	result := models.Block{
+		AbstractBlock: models.AbstractBlock{
+			BlockNumber: block.BlockNumber,
+			BlockHash:   *block.BlockHash,
+			Timestamp:   block.Timestamp,
+		},
+		ParentHash:             block.ParentHash,
+		StateRoot:              block.NewRoot,
+		SequencerAddress:       block.SequencerAddress,
+		L1GasPriceWei:          l1GasPriceWei,
+		L1GasPriceFri:          l1GasPriceFri,
+		L1DataAvailabilityMode: models.BlockDataAvailabilityMode(block.L1DAMode),
+		StarknetVersion:        block.StarknetVersion,
+		TransactionCount:       len(block.Transactions),
+		TotalFee:               totalFee,
+	}
+	if result.L1DataAvailabilityMode == "" {
+		result.L1DataAvailabilityMode = models.Calldata
+	}
+	if block.L1DataGasPrice.PriceInWei != "" {
+		value, err := hexToFloat(block.L1DataGasPrice.PriceInWei)
+		if err != nil {
+			return models.Block{}, err
+		}
+		result.L1DataGasPriceWei = sql.NullFloat64{Float64: value, Valid: true}
+	}
+	if block.L1DataGasPrice.PriceInFri != "" {
+		value, err := hexToFloat(block.L1DataGasPrice.PriceInFri)
+		if err != nil {
+			return models.Block{}, err
+		}
+		result.L1DataGasPriceFri = sql.NullFloat64{Float64: value, Valid: true}
+	}
+	return result, nil
+}
+
+// TransactionsFromBlock maps every transaction and receipt of a block into models.Transaction rows.
+func TransactionsFromBlock(block BlockWithReceipts) ([]models.Transaction, error) {
+	transactions := make([]models.Transaction, 0, len(block.Transactions))
+	for index, tx := range block.Transactions {
+		transaction, err := TransactionFromRPC(tx.Transaction, tx.Receipt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to import transaction %s in block %d: %w", tx.Transaction.TransactionHash, block.BlockNumber, err)
+		}
+		transaction.BlockNumber = block.BlockNumber
+		transaction.TransactionIndex = index
+		transaction.Timestamp = block.Timestamp
+		transactions = append(transactions, transaction)
+	}
+	return transactions, nil
+}
+
+// BlocksToDicts converts blocks into rows for the block_file exporter.
+func BlocksToDicts(blocks []models.Block) []map[string]interface{} {
+	rows := make([]map[string]interface{}, len(blocks))
+	for i, block := range blocks {
+		rows[i] = map[string]interface{}{
+			"block_number":          int(block.BlockNumber),
+			"block_hash":            block.BlockHash,
+			"timestamp":             int(block.Timestamp),
+			"parent_hash":           block.ParentHash,
+			"state_root":            block.StateRoot,
+			"sequencer_address":     block.SequencerAddress,
+			"l1_gas_price_wei":      block.L1GasPriceWei,
+			"l1_gas_price_fri":      block.L1GasPriceFri,
+			"l1_data_gas_price_wei": nullFloat64ToDict(block.L1DataGasPriceWei),
+			"l1_data_gas_price_fri": nullFloat64ToDict(block.L1DataGasPriceFri),
+			"l1_da_mode":            string(block.L1DataAvailabilityMode),
+			"starknet_version":      block.StarknetVersion,
+			"transaction_count":     block.TransactionCount,
+			"total_fee":             block.TotalFee,
+		}
+	}
+	return rows
+}
+
+// TransactionsToDicts converts transactions into rows for the transaction_file exporter.
+func TransactionsToDicts(transactions []models.Transaction) []map[string]interface{} {
+	rows := make([]map[string]interface{}, len(transactions))
+	for i, tx := range transactions {
+		resourceBounds := make(map[string]interface{}, len(tx.ResourceBounds))
+		for resource, bound := range tx.ResourceBounds {
+			resourceBounds[resource] = bound
+		}
+		var gasUsed interface{}
+		if tx.GasUsed != nil {
+			gasUsed = *tx.GasUsed
+		}
+		rows[i] = map[string]interface{}{
+			"transaction_hash":        tx.TransactionHash,
+			"block_number":            int(tx.BlockNumber),
+			"transaction_index":       tx.TransactionIndex,
+			"timestamp":               int(tx.Timestamp),
+			"gas_used":                gasUsed,
+			"type":                    string(tx.Type),
+			"nonce":                   tx.Nonce,
+			"signature":               stringsToInterfaces(tx.Signature),
+			"version":                 tx.Version,
+			"status":                  string(tx.Status),
+			"max_fee":                 tx.MaxFee,
+			"actual_fee":              tx.ActualFee,
+			"fee_unit":                string(tx.FeeUnit),
+			"execution_resources":     tx.ExecutionResources,
+			"tip":                     tx.Tip,
+			"resource_bounds":         resourceBounds,
+			"paymaster_data":          stringsToInterfaces(tx.PaymasterData),
+			"account_deployment_data": stringsToInterfaces(tx.AccountDeploymentData),
+			"contract_address":        tx.ContractAddress.String,
+			"selector":                tx.Selector,
+			"calldata":                stringsToInterfaces(tx.Calldata),
+			"class_hash":              tx.ClassHash.String,
+			"revert_error":            tx.RevertError.String,
+		}
+	}
+	return rows
+}
+
+// TransactionFromRPC maps a single transaction and its receipt into a models.Transaction.
+// It supports INVOKE v0/v1/v3, DECLARE v0-v3, DEPLOY, DEPLOY_ACCOUNT v1/v3 and L1_HANDLER.
+func TransactionFromRPC(tx StarknetTransaction, receipt StarknetReceipt) (models.Transaction, error) {
+	txType := tx.Type
+	if txType == "" {
+		txType = receipt.Type
+	}
+
+	version, err := transactionVersion(tx.Version)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+
+	result := models.Transaction{
+		AbstractTransaction: models.AbstractTransaction{
+			TransactionHash: tx.TransactionHash,
+		},
+		Type:                  models.StarknetTxType(txType),
+		Version:               version,
+		Signature:             nonNil(tx.Signature),
+		Status:                transactionStatus(receipt),
+		FeeUnit:               models.StarknetFeeUnit(strings.ToUpper(receipt.ActualFee.Unit)),
+		ExecutionResources:    receipt.ExecutionResources,
+		PaymasterData:         tx.PaymasterData,
+		AccountDeploymentData: tx.AccountDeploymentData,
+		Calldata:              []string{},
+	}
+	if result.TransactionHash == "" {
+		result.TransactionHash = receipt.TransactionHash
+	}
+	if result.ExecutionResources == nil {
+		result.ExecutionResources = map[string]interface{}{}
+	}
+	if result.FeeUnit == "" {
+		// Receipts before v3 transactions did not report a unit, and all fees were paid in wei.
+		result.FeeUnit = models.Wei
+	}
+
+	if tx.Nonce != "" {
+		nonce, err := hexToUint64(tx.Nonce)
+		if err != nil {
+			return models.Transaction{}, fmt.Errorf("invalid nonce: %v", err)
+		}
+		result.Nonce = int(nonce)
+	}
+	if tx.MaxFee != "" {
+		if result.MaxFee, err = hexToFloat(tx.MaxFee); err != nil {
+			return models.Transaction{}, fmt.Errorf("invalid max fee: %v", err)
+		}
+	}
+	if receipt.ActualFee.Amount != "" {
+		if result.ActualFee, err = hexToFloat(receipt.ActualFee.Amount); err != nil {
+			return models.Transaction{}, fmt.Errorf("invalid actual fee: %v", err)
+		}
+	}
+	if tx.Tip != "" {
+		if result.Tip, err = hexToFloat(tx.Tip); err != nil {
+			return models.Transaction{}, fmt.Errorf("invalid tip: %v", err)
+		}
+	}
+	if len(tx.ResourceBounds) > 0 {
+		if result.ResourceBounds, err = resourceBoundsToMap(tx.ResourceBounds); err != nil {
+			return models.Transaction{}, err
+		}
+	}
+	if receipt.RevertReason != "" {
+		result.RevertError = sql.NullString{String: truncate(receipt.RevertReason, 500), Valid: true}
+	}
+
+	switch result.Type {
+	case models.Invoke:
+		result.Calldata = nonNil(tx.Calldata)
+		if version == 0 {
+			result.ContractAddress = nullString(tx.ContractAddress)
+			result.Selector = tx.EntryPointSelector
+		} else {
+			result.ContractAddress = nullString(tx.SenderAddress)
+			result.Selector = selectorFromName("__execute__")
+		}
+	case models.Declare:
+		result.ContractAddress = nullString(tx.SenderAddress)
+		result.ClassHash = nullString(tx.ClassHash)
+		result.Selector = selectorFromName("__validate_declare__")
+	case models.Deploy:
+		result.ContractAddress = nullString(firstNonEmpty(receipt.ContractAddress, tx.ContractAddress))
+		result.ClassHash = nullString(tx.ClassHash)
+		result.Calldata = nonNil(tx.ConstructorCalldata)
+		result.Selector = selectorFromName("constructor")
+	case models.DeployAccount:
+		result.ContractAddress = nullString(firstNonEmpty(receipt.ContractAddress, tx.ContractAddress))
+		result.ClassHash = nullString(tx.ClassHash)
+		result.Calldata = nonNil(tx.ConstructorCalldata)
+		result.Selector = selectorFromName("constructor")
+	case models.L1Handler:
+		result.ContractAddress = nullString(tx.ContractAddress)
+		result.Selector = tx.EntryPointSelector
+		result.Calldata = nonNil(tx.Calldata)
+	default:
+		return models.Transaction{}, fmt.Errorf("unsupported transaction type %q", txType)
+	}
+
+	return result, nil
+}
+
+// transactionStatus derives the transaction status from the execution and finality status of its receipt.
+func transactionStatus(receipt StarknetReceipt) models.TransactionStatus {
+	if receipt.ExecutionStatus == "REVERTED" {
+		return models.Reverted
+	}
+	switch receipt.FinalityStatus {
+	case "ACCEPTED_ON_L1":
+		return models.AcceptedOnL1
+	case "ACCEPTED_ON_L2":
+		return models.AcceptedOnL2
+	case "REJECTED":
+		return models.Rejected
+	case "":
+		return models.NotReceived
+	default:
+		return models.Received
+	}
+}
+
+// transactionVersion parses a transaction version, stripping the query version offset.
+func transactionVersion(version string) (int, error) {
+	if version == "" {
+		return 0, nil
+	}
+	value, ok := new(big.Int).SetString(strings.TrimPrefix(version, "0x"), 16)
+	if !ok {
+		return 0, fmt.Errorf("invalid transaction version %q", version)
+	}
+	if value.Cmp(queryVersionOffset) >= 0 {
+		value.Sub(value, queryVersionOffset)
+	}
+	return int(value.Int64()), nil
+}
+
+// resourceBoundsToMap flattens the resource bounds of a V3 transaction. The maximum prices are
+// u128, so both bounds are stored as decimal strings.
+func resourceBoundsToMap(bounds map[string]ResourceBound) (map[string]string, error) {
+	result := make(map[string]string, len(bounds)*2)
+	for resource, bound := range bounds {
+		maxAmount, err := hexToBigInt(bound.MaxAmount)
+		if err != nil {
+			return nil, fmt.Errorf("invalid max amount for %s: %v", resource, err)
+		}
+		maxPrice, err := hexToBigInt(bound.MaxPricePerUnit)
+		if err != nil {
+			return nil, fmt.Errorf("invalid max price per unit for %s: %v", resource, err)
+		}
+		result[strings.ToLower(resource)+"_max_amount"] = maxAmount.String()
+		result[strings.ToLower(resource)+"_max_price_per_unit"] = maxPrice.String()
+	}
+	return result, nil
+}
+
+// selectorFromName computes the entry point selector of a function name.
+func selectorFromName(name string) string {
+	return "0x" + strings.TrimLeft(hex.EncodeToString(athena_abi.StarknetKeccak([]byte(name))), "0")
+}
+
+func hexToBigInt(value string) (*big.Int, error) {
+	trimmed := strings.TrimPrefix(strings.TrimPrefix(value, "0x"), "0X")
+	if trimmed == "" {
+		return big.NewInt(0), nil
+	}
+	result, ok := new(big.Int).SetString(trimmed, 16)
+	if !ok {
+		return nil, fmt.Errorf("invalid hex value %q", value)
+	}
+	return result, nil
+}
+
+func hexToUint64(value string) (uint64, error) {
+	result, err := hexToBigInt(value)
+	if err != nil {
+		return 0, err
+	}
+	if !result.IsUint64() {
+		return 0, fmt.Errorf("hex value %q overflows uint64", value)
+	}
+	return result.Uint64(), nil
+}
+
+func hexToFloat(value string) (float64, error) {
+	result, err := hexToBigInt(value)
+	if err != nil {
+		return 0, err
+	}
+	f, _ := new(big.Float).SetInt(result).Float64()
+	return f, nil
+}
+
+func nullString(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
+
+func nullFloat64ToDict(value sql.NullFloat64) interface{} {
+	if !value.Valid {
+		return nil
+	}
+	return value.Float64
+}
+
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+func truncate(value string, length int) string {
+	if len(value) <= length {
+		return value
+	}
+	return value[:length]
+}
@@ -0,0 +1,205 @@
+package importers
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/BlocSoc-iitr/Athena/athena/database/models"
+	"github.com/stretchr/testify/assert"
+)
+
+const blockWithReceiptsJSON = `{
+	"block_number": 650000,
+	"block_hash": "0xb10c",
+	"parent_hash": "0xb10b",
+	"new_root": "0x5",
+	"timestamp": 1716000000,
+	"sequencer_address": "0x1176a1bd84444c89232ec27754698e5d2e7e1a7f1539f12027f28b23ec9f3d8",
+	"l1_gas_price": {"price_in_wei": "0x3b9aca00", "price_in_fri": "0x2540be400"},
+	"l1_data_gas_price": {"price_in_wei": "0x1", "price_in_fri": "0x2"},
+	"l1_da_mode": "BLOB",
+	"starknet_version": "0.13.1",
+	"transactions": [
+		{
+			"transaction": {
+				"transaction_hash": "0x111",
+				"type": "INVOKE",
+				"version": "0x3",
+				"nonce": "0x7",
+				"sender_address": "0xabc",
+				"calldata": ["0x1", "0x2"],
+				"signature": ["0xs1", "0xs2"],
+				"resource_bounds": {
+					"l1_gas": {"max_amount": "0x100", "max_price_per_unit": "0x200"},
+					"l2_gas": {"max_amount": "0x0", "max_price_per_unit": "0x100000000000000000"}
+				},
+				"tip": "0x0",
+				"paymaster_data": [],
+				"account_deployment_data": []
+			},
+			"receipt": {
+				"type": "INVOKE",
+				"transaction_hash": "0x111",
+				"actual_fee": {"amount": "0x10", "unit": "FRI"},
+				"execution_status": "SUCCEEDED",
+				"finality_status": "ACCEPTED_ON_L2",
+				"events": [],
+				"execution_resources": {"steps": 100}
+			}
+		},
+		{
+			"transaction": {
+				"transaction_hash": "0x222",
+				"type": "INVOKE",
+				"version": "0x0",
+				"max_fee": "0x20",
+				"contract_address": "0xdef",
+				"entry_point_selector": "0x99",
+				"calldata": ["0x3"],
+				"signature": []
+			},
+			"receipt": {
+				"type": "INVOKE",
+				"transaction_hash": "0x222",
+				"actual_fee": {"amount": "0x5", "unit": "WEI"},
+				"execution_status": "REVERTED",
+				"finality_status": "ACCEPTED_ON_L1",
+				"revert_reason": "Error in the called contract",
+				"events": [],
+				"execution_resources": {"steps": 10}
+			}
+		},
+		{
+			"transaction": {
+				"transaction_hash": "0x333",
+				"type": "DECLARE",
+				"version": "0x2",
+				"nonce": "0x1",
+				"max_fee": "0x30",
+				"sender_address": "0xabc",
+				"class_hash": "0xc1a55",
+				"compiled_class_hash": "0xc0",
+				"signature": ["0xs"]
+			},
+			"receipt": {
+				"type": "DECLARE",
+				"transaction_hash": "0x333",
+				"actual_fee": {"amount": "0x1", "unit": "WEI"},
+				"execution_status": "SUCCEEDED",
+				"finality_status": "ACCEPTED_ON_L1",
+				"events": [],
+				"execution_resources": {}
+			}
+		},
+		{
+			"transaction": {
+				"transaction_hash": "0x444",
+				"type": "DEPLOY_ACCOUNT",
+				"version": "0x100000000000000000000000000000001",
+				"nonce": "0x0",
+				"max_fee": "0x40",
+				"class_hash": "0xacc",
+				"contract_address_salt": "0x5a17",
+				"constructor_calldata": ["0xpub"],
+				"signature": ["0xs"]
+			},
+			"receipt": {
+				"type": "DEPLOY_ACCOUNT",
+				"transaction_hash": "0x444",
+				"actual_fee": {"amount": "0x2", "unit": "WEI"},
+				"execution_status": "SUCCEEDED",
+				"finality_status": "ACCEPTED_ON_L1",
+				"contract_address": "0xnew",
+				"events": [],
+				"execution_resources": {}
+			}
+		},
+		{
+			"transaction": {
+				"transaction_hash": "0x555",
+				"type": "L1_HANDLER",
+				"version": "0x0",
+				"nonce": "0x9",
+				"contract_address": "0xbridge",
+				"entry_point_selector": "0x2d757788a8d8d6f21d1cd40bce38a8222d70654214e96ff95d8086e684fbee5",
+				"calldata": ["0xl1sender", "0x1"]
+			},
+			"receipt": {
+				"type": "L1_HANDLER",
+				"transaction_hash": "0x555",
+				"actual_fee": {"amount": "0x0", "unit": "WEI"},
+				"execution_status": "SUCCEEDED",
+				"finality_status": "ACCEPTED_ON_L1",
+				"events": [],
+				"execution_resources": {}
+			}
+		}
+	]
+}`
+
+func TestTransactionsFromBlock(t *testing.T) {
+	var block BlockWithReceipts
+	assert.NoError(t, json.Unmarshal([]byte(blockWithReceiptsJSON), &block))
+
+	transactions, err := TransactionsFromBlock(block)
+	assert.NoError(t, err)
+	assert.Len(t, transactions, 5)
+
+	invokeV3 := transactions[0]
+	assert.Equal(t, models.Invoke, invokeV3.Type)
+	assert.Equal(t, 3, invokeV3.Version)
+	assert.Equal(t, 7, invokeV3.Nonce)
+	assert.Equal(t, "0xabc", invokeV3.ContractAddress.String)
+	assert.Equal(t, "0x15d40a3d6ca2ac30f4031e42be28da9b056fef9bb7357ac5e85627ee876e5ad", invokeV3.Selector)
+	assert.Equal(t, models.Fri, invokeV3.FeeUnit)
+	assert.Equal(t, models.AcceptedOnL2, invokeV3.Status)
+	assert.Equal(t, "256", invokeV3.ResourceBounds["l1_gas_max_amount"])
+	assert.Equal(t, "512", invokeV3.ResourceBounds["l1_gas_max_price_per_unit"])
+	assert.Equal(t, "295147905179352825856", invokeV3.ResourceBounds["l2_gas_max_price_per_unit"])
+	assert.Equal(t, uint64(650000), invokeV3.BlockNumber)
+	assert.Equal(t, int64(1716000000), invokeV3.Timestamp)
+
+	invokeV0 := transactions[1]
+	assert.Equal(t, 0, invokeV0.Version)
+	assert.Equal(t, "0xdef", invokeV0.ContractAddress.String)
+	assert.Equal(t, "0x99", invokeV0.Selector)
+	assert.Equal(t, models.Reverted, invokeV0.Status)
+	assert.Equal(t, "Error in the called contract", invokeV0.RevertError.String)
+	assert.Equal(t, float64(0x20), invokeV0.MaxFee)
+	assert.Equal(t, 1, invokeV0.TransactionIndex)
+
+	declare := transactions[2]
+	assert.Equal(t, models.Declare, declare.Type)
+	assert.Equal(t, 2, declare.Version)
+	assert.Equal(t, "0xc1a55", declare.ClassHash.String)
+	assert.Equal(t, models.AcceptedOnL1, declare.Status)
+
+	deployAccount := transactions[3]
+	assert.Equal(t, 1, deployAccount.Version)
+	assert.Equal(t, "0xnew", deployAccount.ContractAddress.String)
+	assert.Equal(t, []string{"0xpub"}, deployAccount.Calldata)
+
+	l1Handler := transactions[4]
+	assert.Equal(t, models.L1Handler, l1Handler.Type)
+	assert.Equal(t, 9, l1Handler.Nonce)
+	assert.Equal(t, "0xbridge", l1Handler.ContractAddress.String)
+	assert.Equal(t, []string{}, l1Handler.Signature)
+}
+
+func TestBlockFromBlockWithReceipts(t *testing.T) {
+	var block BlockWithReceipts
+	assert.NoError(t, json.Unmarshal([]byte(blockWithReceiptsJSON), &block))
+
+	result, err := BlockFromBlockWithReceipts(block)
+	assert.NoError(t, err)
+	assert.Equal(t, "0xb10c", result.BlockHash)
+	assert.Equal(t, 5, result.TransactionCount)
+	assert.Equal(t, float64(0x10+0x5+0x1+0x2), result.TotalFee)
+	assert.Equal(t, models.Blob, result.L1DataAvailabilityMode)
+	assert.True(t, result.L1DataGasPriceFri.Valid)
+}
+
+func TestTransactionFromRPCRejectsUnknownType(t *testing.T) {
+	_, err := TransactionFromRPC(StarknetTransaction{Type: "UNKNOWN"}, StarknetReceipt{})
+	assert.Error(t, err)
+}
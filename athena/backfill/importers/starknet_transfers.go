@@ -0,0 +1,211 @@
+package importers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/BlocSoc-iitr/Athena/athena/database/models"
+)
+
+// TransferSelector is the event selector of Transfer, sn_keccak("Transfer").
+var TransferSelector = selectorFromName("Transfer")
+
+// ParseTransferEvent extracts sender, recipient and amount from an ERC20 Transfer event.
+//
+// Two layouts are recognized:
+//   - Cairo 1 (OpenZeppelin components): keys = [selector, from, to], data = [value]
+//   - Cairo 0 and early Cairo 1 contracts: keys = [selector], data = [from, to, value]
+//
+// The value is either a single felt or a u256 split into low and high felts.
+// Cairo 1 ERC721 Transfer events, which carry the token id as keys, are rejected. Cairo 0 ERC721
+// Transfer events carry the token id as a u256 in data and cannot be told apart from ERC20 ones;
+// SharesERC721Layout reports them, and TransfersFromBlock checks their emitting contract.
+func ParseTransferEvent(keys []string, data []string) (string, string, *big.Int, bool) {
+	if len(keys) == 0 || !sameHash(keys[0], TransferSelector) {
+		return "", "", nil, false
+	}
+
+	var from, to string
+	var valueFelts []string
+	switch {
+	case len(keys) == 3 && (len(data) == 1 || len(data) == 2):
+		from, to = keys[1], keys[2]
+		valueFelts = data
+	case len(keys) == 1 && (len(data) == 3 || len(data) == 4):
+		from, to = data[0], data[1]
+		valueFelts = data[2:]
+	default:
+		return "", "", nil, false
+	}
+
+	value, err := hexToBigInt(valueFelts[0])
+	if err != nil {
+		return "", "", nil, false
+	}
+	if len(valueFelts) == 2 {
+		high, err := hexToBigInt(valueFelts[1])
+		if err != nil {
+			return "", "", nil, false
+		}
+		value.Add(value, high.Lsh(high, 128))
+	}
+	return from, to, value, true
+}
+
+// SharesERC721Layout reports whether a Transfer event has the Cairo 0 layout that ERC20 transfers
+// with a u256 value share with ERC721 transfers: keys = [selector], data = [from, to, low, high].
+func SharesERC721Layout(keys []string, data []string) bool {
+	return len(keys) == 1 && len(data) == 4
+}
+
+// TokenChecker tells ERC20 contracts apart from the ERC721 contracts that emit Transfer events of
+// the same layout.
+type TokenChecker interface {
+	IsERC20(ctx context.Context, tokenAddress string, blockNumber uint64) (bool, error)
+}
+
+// RPCTokenChecker treats a token as an ERC20 if it answers a decimals call, which ERC721 contracts
+// do not implement. Results are cached per token. Only a missing contract or entry point and a failing
+// call mean that the token is not an ERC20; other errors, such as rate limits, are returned and not cached.
+type RPCTokenChecker struct {
+	url   string
+	mu    sync.Mutex
+	cache map[string]bool
+}
+
+// notERC20Codes are the Starknet JSON-RPC error codes of a decimals call to a contract that is not an
+// ERC20: CONTRACT_NOT_FOUND, ENTRYPOINT_NOT_FOUND of older nodes and CONTRACT_ERROR.
+var notERC20Codes = map[int]bool{contractNotFoundCode: true, 21: true, 40: true}
+
+func NewRPCTokenChecker(url string) *RPCTokenChecker {
+	return &RPCTokenChecker{
+		url:   url,
+		cache: make(map[string]bool),
+	}
+}
+
+func (c *RPCTokenChecker) IsERC20(ctx context.Context, tokenAddress string, blockNumber uint64) (bool, error) {
+	key := normalizeHex(tokenAddress)
+
+	c.mu.Lock()
+	isERC20, ok := c.cache[key]
+	c.mu.Unlock()
+	if ok {
+		return isERC20, nil
+	}
+
+	params := map[string]interface{}{
+		"request": map[string]interface{}{
+			"contract_address":     tokenAddress,
+			"entry_point_selector": selectorFromName("decimals"),
+			"calldata":             []string{},
+		},
+		"block_id": map[string]interface{}{"block_number": int(blockNumber)},
+	}
+	_, err := MakeRPCCall(ctx, c.url, "starknet_call", params)
+	var rpcErr *rpcError
+	switch {
+	case err == nil:
+		isERC20 = true
+	case errors.As(err, &rpcErr) && notERC20Codes[rpcErr.Code]:
+		isERC20 = false
+	default:
+		return false, fmt.Errorf("failed to call decimals of %s at block %d: %v", tokenAddress, blockNumber, err)
+	}
+
+	c.mu.Lock()
+	c.cache[key] = isERC20
+	c.mu.Unlock()
+	return isERC20, nil
+}
+
+// TransfersFromBlock extracts every ERC20 transfer emitted in a block. When tokens is not empty,
+// only transfers of those token contracts are returned. Events with the Cairo 0 layout shared with
+// ERC721 are kept only if the checker reports their contract as an ERC20, and dropped if it is nil.
+func TransfersFromBlock(ctx context.Context, block BlockWithReceipts, checker TokenChecker, tokens ...string) ([]models.ERC20Transfer, error) {
+	tokenSet := make(map[string]bool, len(tokens))
+	for _, token := range tokens {
+		tokenSet[normalizeHex(token)] = true
+	}
+
+	var transfers []models.ERC20Transfer
+	for txIndex, tx := range block.Transactions {
+		if tx.Receipt.ExecutionStatus == "REVERTED" {
+			continue
+		}
+		for eventIndex, event := range tx.Receipt.Events {
+			if len(tokenSet) > 0 && !tokenSet[normalizeHex(event.FromAddress)] {
+				continue
+			}
+			from, to, value, ok := ParseTransferEvent(event.Keys, event.Data)
+			if !ok {
+				continue
+			}
+			if SharesERC721Layout(event.Keys, event.Data) {
+				if checker == nil {
+					continue
+				}
+				isERC20, err := checker.IsERC20(ctx, event.FromAddress, block.BlockNumber)
+				if err != nil {
+					return nil, err
+				}
+				if !isERC20 {
+					continue
+				}
+			}
+			transfers = append(transfers, models.ERC20Transfer{
+				AbstractERC20Transfer: models.AbstractERC20Transfer{
+					BlockNumber:      block.BlockNumber,
+					TransactionHash:  firstNonEmpty(tx.Receipt.TransactionHash, tx.Transaction.TransactionHash),
+					TransactionIndex: txIndex,
+					EventIndex:       eventIndex,
+					TokenAddress:     event.FromAddress,
+					FromAddress:      from,
+					ToAddress:        to,
+					Value:            value.String(),
+				},
+			})
+		}
+	}
+	return transfers, nil
+}
+
+// GetTransfers imports the ERC20 transfers of a block range, optionally restricted to some token contracts.
+func GetTransfers(ctx context.Context, url string, fromBlock uint64, toBlock uint64, tokens ...string) ([]models.ERC20Transfer, error) {
+	blocks, err := GetBlocksWithReceipts(ctx, url, fromBlock, toBlock)
+	if err != nil {
+		return nil, err
+	}
+
+	checker := NewRPCTokenChecker(url)
+	var transfers []models.ERC20Transfer
+	for _, block := range blocks {
+		blockTransfers, err := TransfersFromBlock(ctx, block, checker, tokens...)
+		if err != nil {
+			return nil, err
+		}
+		transfers = append(transfers, blockTransfers...)
+	}
+	return transfers, nil
+}
+
+// TransfersToDicts converts transfers into rows for the transfer_file exporter.
+func TransfersToDicts(transfers []models.ERC20Transfer) []map[string]interface{} {
+	rows := make([]map[string]interface{}, len(transfers))
+	for i, transfer := range transfers {
+		rows[i] = map[string]interface{}{
+			"block_number":      int(transfer.BlockNumber),
+			"transaction_hash":  transfer.TransactionHash,
+			"transaction_index": transfer.TransactionIndex,
+			"event_index":       transfer.EventIndex,
+			"token_address":     transfer.TokenAddress,
+			"from_address":      transfer.FromAddress,
+			"to_address":        transfer.ToAddress,
+			"value":             transfer.Value,
+		}
+	}
+	return rows
+}
@@ -0,0 +1,131 @@
+package importers
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTransferEvent(t *testing.T) {
+	maxU128 := "0xffffffffffffffffffffffffffffffff"
+	u256, _ := new(big.Int).SetString("340282366920938463463374607431768211457", 10) // 2**128 + 1
+
+	tests := []struct {
+		name  string
+		keys  []string
+		data  []string
+		from  string
+		to    string
+		value *big.Int
+		ok    bool
+	}{
+		{"cairo 1 keyed u256", []string{TransferSelector, "0xa", "0xb"}, []string{"0x1", "0x1"}, "0xa", "0xb", u256, true},
+		{"cairo 1 keyed felt", []string{TransferSelector, "0xa", "0xb"}, []string{"0x64"}, "0xa", "0xb", big.NewInt(100), true},
+		{"cairo 0 u256", []string{TransferSelector}, []string{"0xa", "0xb", maxU128, "0x0"}, "0xa", "0xb", new(big.Int).SetBytes([]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}), true},
+		{"cairo 0 felt", []string{TransferSelector}, []string{"0xa", "0xb", "0x5"}, "0xa", "0xb", big.NewInt(5), true},
+		{"cairo 0 erc721 layout", []string{TransferSelector}, []string{"0xa", "0xb", "0x7", "0x0"}, "0xa", "0xb", big.NewInt(7), true},
+		{"erc721 token id in keys", []string{TransferSelector, "0xa", "0xb", "0x1", "0x0"}, []string{}, "", "", nil, false},
+		{"other event", []string{"0x1234"}, []string{"0xa", "0xb", "0x5"}, "", "", nil, false},
+		{"no keys", []string{}, []string{"0xa", "0xb", "0x5"}, "", "", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, to, value, ok := ParseTransferEvent(tt.keys, tt.data)
+			assert.Equal(t, tt.ok, ok)
+			if tt.ok {
+				assert.Equal(t, tt.from, from)
+				assert.Equal(t, tt.to, to)
+				assert.Equal(t, 0, tt.value.Cmp(value), "expected %s, got %s", tt.value, value)
+			}
+			assert.Equal(t, tt.name == "cairo 0 u256" || tt.name == "cairo 0 erc721 layout", SharesERC721Layout(tt.keys, tt.data))
+		})
+	}
+}
+
+func TestTransfersFromBlock(t *testing.T) {
+	var block BlockWithReceipts
+	assert.NoError(t, json.Unmarshal([]byte(`{
+		"block_number": 42,
+		"transactions": [
+			{"transaction": {"transaction_hash": "0xt1"}, "receipt": {"transaction_hash": "0xt1", "execution_status": "SUCCEEDED", "events": [
+				{"from_address": "0x49d36570d4e46f48e99674bd3fcc84644ddd6b96f7c741b1562b82f9e004dc7", "keys": ["`+TransferSelector+`"], "data": ["0xa", "0xb", "0x10", "0x0"]},
+				{"from_address": "0xusdc", "keys": ["`+TransferSelector+`", "0xa", "0xb"], "data": ["0x20", "0x0"]},
+				{"from_address": "0xnft", "keys": ["`+TransferSelector+`"], "data": ["0xa", "0xb", "0x7", "0x0"]},
+				{"from_address": "0xnft", "keys": ["`+TransferSelector+`"], "data": ["0xb", "0xc", "0x7", "0x0"]}
+			]}},
+			{"transaction": {"transaction_hash": "0xt2"}, "receipt": {"transaction_hash": "0xt2", "execution_status": "REVERTED", "events": [
+				{"from_address": "0xusdc", "keys": ["`+TransferSelector+`", "0xa", "0xb"], "data": ["0x30", "0x0"]}
+			]}}
+		]
+	}`), &block))
+
+	// ERC721 contracts do not implement decimals.
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Params struct {
+				Request struct {
+					ContractAddress string `json:"contract_address"`
+				} `json:"request"`
+			} `json:"params"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		calls++
+		if req.Params.Request.ContractAddress == "0xnft" {
+			w.Write([]byte(`{"jsonrpc": "2.0", "id": 1, "error": {"code": 40, "message": "Contract error"}}`))
+			return
+		}
+		w.Write([]byte(`{"jsonrpc": "2.0", "id": 1, "result": ["0x12"]}`))
+	}))
+	defer server.Close()
+	checker := NewRPCTokenChecker(server.URL)
+
+	transfers, err := TransfersFromBlock(context.Background(), block, checker)
+	assert.NoError(t, err)
+	assert.Len(t, transfers, 2)
+	assert.Equal(t, "16", transfers[0].Value)
+	assert.Equal(t, "0xt1", transfers[1].TransactionHash)
+	assert.Equal(t, 1, transfers[1].EventIndex)
+	assert.Equal(t, "32", transfers[1].Value)
+
+	assert.Equal(t, 2, calls)
+
+	filtered, err := TransfersFromBlock(context.Background(), block, checker, "0x049d36570d4e46f48e99674bd3fcc84644ddd6b96f7c741b1562b82f9e004dc7")
+	assert.NoError(t, err)
+	assert.Len(t, filtered, 1)
+	assert.Equal(t, uint64(42), filtered[0].BlockNumber)
+	assert.Equal(t, 2, calls)
+
+	// Without a checker, transfers that may be ERC721 are dropped.
+	transfers, err = TransfersFromBlock(context.Background(), block, nil)
+	assert.NoError(t, err)
+	assert.Len(t, transfers, 1)
+	assert.Equal(t, "32", transfers[0].Value)
+}
+
+func TestRPCTokenCheckerDoesNotCacheTransientErrors(t *testing.T) {
+	limited := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if limited {
+			w.Write([]byte(`{"jsonrpc": "2.0", "id": 1, "error": {"code": -32005, "message": "Rate limit exceeded"}}`))
+			return
+		}
+		w.Write([]byte(`{"jsonrpc": "2.0", "id": 1, "result": ["0x12"]}`))
+	}))
+	defer server.Close()
+	checker := NewRPCTokenChecker(server.URL)
+
+	_, err := checker.IsERC20(context.Background(), "0xtoken", 1)
+	assert.Error(t, err)
+
+	limited = false
+	isERC20, err := checker.IsERC20(context.Background(), "0xtoken", 1)
+	assert.NoError(t, err)
+	assert.True(t, isERC20)
+}
@@ -0,0 +1,289 @@
+package importers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/BlocSoc-iitr/Athena/athena/database/models"
+)
+
+// zkSync Era serves the Ethereum JSON-RPC API, with blocks, transactions and receipts extended with
+// the L1 batch that committed them, and zks_ methods for batches and L2 to L1 messages.
+
+type ZkSyncTransaction struct {
+	EthTransaction
+	L1BatchNumber  string `json:"l1BatchNumber"`
+	L1BatchTxIndex string `json:"l1BatchTxIndex"`
+}
+
+type ZkSyncBlock struct {
+	EthBlock
+	L1BatchNumber    string              `json:"l1BatchNumber"`
+	L1BatchTimestamp string              `json:"l1BatchTimestamp"`
+	Transactions     []ZkSyncTransaction `json:"transactions"`
+}
+
+type ZkSyncL2ToL1Log struct {
+	BlockNumber         string `json:"blockNumber"`
+	L1BatchNumber       string `json:"l1BatchNumber"`
+	TransactionHash     string `json:"transactionHash"`
+	TransactionIndex    string `json:"transactionIndex"`
+	TransactionLogIndex string `json:"transactionLogIndex"`
+	LogIndex            string `json:"logIndex"`
+	ShardID             string `json:"shardId"`
+	IsService           bool   `json:"isService"`
+	Sender              string `json:"sender"`
+	Key                 string `json:"key"`
+	Value               string `json:"value"`
+}
+
+type ZkSyncReceipt struct {
+	EthReceipt
+	L1BatchNumber string            `json:"l1BatchNumber"`
+	L2ToL1Logs    []ZkSyncL2ToL1Log `json:"l2ToL1Logs"`
+}
+
+// ZkSyncFullBlock is a block with its transactions and their receipts, in transaction order.
+type ZkSyncFullBlock struct {
+	Block    ZkSyncBlock
+	Receipts []ZkSyncReceipt
+}
+
+// GetZkSyncL1BatchNumber returns the number of the latest L1 batch with zks_L1BatchNumber.
+func GetZkSyncL1BatchNumber(ctx context.Context, url string) (uint64, error) {
+	resp, err := MakeRPCCall(ctx, url, "zks_L1BatchNumber", []interface{}{})
+	if err != nil {
+		return 0, fmt.Errorf("failed to get L1 batch number: %v", err)
+	}
+	var batchNumber string
+	if err := json.Unmarshal(resp.Result, &batchNumber); err != nil {
+		return 0, fmt.Errorf("failed to unmarshal L1 batch number: %v", err)
+	}
+	return hexToUint64(batchNumber)
+}
+
+// GetZkSyncL1BatchBlockRange returns the first and last L2 blocks of an L1 batch with zks_getL1BatchBlockRange.
+func GetZkSyncL1BatchBlockRange(ctx context.Context, url string, batchNumber uint64) (uint64, uint64, error) {
+	resp, err := MakeRPCCall(ctx, url, "zks_getL1BatchBlockRange", []interface{}{batchNumber})
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get block range of L1 batch %d: %v", batchNumber, err)
+	}
+	var blockRange []string
+	if err := json.Unmarshal(resp.Result, &blockRange); err != nil {
+		return 0, 0, fmt.Errorf("failed to unmarshal block range of L1 batch %d: %v", batchNumber, err)
+	}
+	if len(blockRange) != 2 {
+		return 0, 0, fmt.Errorf("L1 batch %d not found", batchNumber)
+	}
+	fromBlock, err := hexToUint64(blockRange[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid block range of L1 batch %d: %v", batchNumber, err)
+	}
+	toBlock, err := hexToUint64(blockRange[1])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid block range of L1 batch %d: %v", batchNumber, err)
+	}
+	return fromBlock, toBlock, nil
+}
+
+// GetZkSyncBlocks fetches the blocks of a range with their full transactions, ordered by block number.
+func GetZkSyncBlocks(ctx context.Context, url string, fromBlock uint64, toBlock uint64) ([]ZkSyncBlock, error) {
+	blocks := make([]ZkSyncBlock, toBlock-fromBlock+1)
+	err := forEachBlock(ctx, len(blocks), func(ctx context.Context, i int) error {
+		block, err := getZkSyncBlock(ctx, url, fromBlock+uint64(i))
+		blocks[i] = block
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return blocks, nil
+}
+
+func getZkSyncBlock(ctx context.Context, url string, blockNumber uint64) (ZkSyncBlock, error) {
+	resp, err := MakeRPCCall(ctx, url, "eth_getBlockByNumber", []interface{}{toHexQuantity(blockNumber), true})
+	if err != nil {
+		return ZkSyncBlock{}, fmt.Errorf("failed to get block %d: %v", blockNumber, err)
+	}
+	var block ZkSyncBlock
+	if err := json.Unmarshal(resp.Result, &block); err != nil {
+		return ZkSyncBlock{}, fmt.Errorf("failed to unmarshal block %d: %v", blockNumber, err)
+	}
+	return block, nil
+}
+
+// GetZkSyncBlockReceipts fetches the receipts of every transaction of a block, with their L2 to L1 logs.
+func GetZkSyncBlockReceipts(ctx context.Context, url string, blockNumber uint64) ([]ZkSyncReceipt, error) {
+	resp, err := MakeRPCCall(ctx, url, "eth_getBlockReceipts", []interface{}{toHexQuantity(blockNumber)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get receipts of block %d: %v", blockNumber, err)
+	}
+	var receipts []ZkSyncReceipt
+	if err := json.Unmarshal(resp.Result, &receipts); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal receipts of block %d: %v", blockNumber, err)
+	}
+	return receipts, nil
+}
+
+// GetZkSyncFullBlocks fetches the blocks of a range with their transactions and receipts.
+func GetZkSyncFullBlocks(ctx context.Context, url string, fromBlock uint64, toBlock uint64) ([]ZkSyncFullBlock, error) {
+	blocks, err := GetZkSyncBlocks(ctx, url, fromBlock, toBlock)
+	if err != nil {
+		return nil, err
+	}
+
+	fullBlocks := make([]ZkSyncFullBlock, len(blocks))
+	err = forEachBlock(ctx, len(blocks), func(ctx context.Context, i int) error {
+		receipts, err := GetZkSyncBlockReceipts(ctx, url, fromBlock+uint64(i))
+		fullBlocks[i] = ZkSyncFullBlock{Block: blocks[i], Receipts: receipts}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fullBlocks, nil
+}
+
+// ethFullBlock strips the zkSync extensions of a block so the Ethereum conversions can be reused.
+func (b ZkSyncFullBlock) ethFullBlock() EthFullBlock {
+	block := b.Block.EthBlock
+	block.Transactions = make([]EthTransaction, len(b.Block.Transactions))
+	for i, tx := range b.Block.Transactions {
+		block.Transactions[i] = tx.EthTransaction
+	}
+	receipts := make([]EthReceipt, len(b.Receipts))
+	for i, receipt := range b.Receipts {
+		receipts[i] = receipt.EthReceipt
+	}
+	return EthFullBlock{Block: block, Receipts: receipts}
+}
+
+// ZkSyncBlockToModel converts an RPC block into a ZkSyncBlock.
+func ZkSyncBlockToModel(block ZkSyncBlock) (models.ZkSyncBlock, error) {
+	evmBlock, err := EthBlockToModel(block.EthBlock)
+	if err != nil {
+		return models.ZkSyncBlock{}, err
+	}
+	evmBlock.TransactionCount = len(block.Transactions)
+	return models.ZkSyncBlock{
+		EVMBlock:         evmBlock,
+		L1BatchNumber:    hexToNullInt64(block.L1BatchNumber),
+		L1BatchTimestamp: hexToNullInt64(block.L1BatchTimestamp),
+	}, nil
+}
+
+// ZkSyncTransactionsFromBlock converts the transactions of a block, joined with their receipts, into ZkSyncTransactions.
+func ZkSyncTransactionsFromBlock(block ZkSyncFullBlock) ([]models.ZkSyncTransaction, error) {
+	evmTransactions, err := EthTransactionsFromBlock(block.ethFullBlock())
+	if err != nil {
+		return nil, err
+	}
+	transactions := make([]models.ZkSyncTransaction, len(evmTransactions))
+	for i, tx := range evmTransactions {
+		transactions[i] = models.ZkSyncTransaction{
+			EVMTransaction: tx,
+			L1BatchNumber:  hexToNullInt64(block.Block.Transactions[i].L1BatchNumber),
+			L1BatchTxIndex: hexToNullInt64(block.Block.Transactions[i].L1BatchTxIndex),
+		}
+	}
+	return transactions, nil
+}
+
+// ZkSyncLogsFromBlock returns the logs of every receipt of a block.
+func ZkSyncLogsFromBlock(block ZkSyncFullBlock) []models.ZkSyncLog {
+	return ZkSyncLogsFromEVMLogs(EthLogsFromBlock(block.ethFullBlock()))
+}
+
+func ZkSyncLogsFromEVMLogs(logs []models.EVMLog) []models.ZkSyncLog {
+	result := make([]models.ZkSyncLog, len(logs))
+	for i, evmLog := range logs {
+		result[i] = models.ZkSyncLog{EVMLog: evmLog}
+	}
+	return result
+}
+
+// ZkSyncL2ToL1LogsFromBlock returns the L2 to L1 logs of every receipt of a block.
+func ZkSyncL2ToL1LogsFromBlock(block ZkSyncFullBlock) []models.ZkSyncL2ToL1Log {
+	var result []models.ZkSyncL2ToL1Log
+	for _, receipt := range block.Receipts {
+		for _, zkLog := range receipt.L2ToL1Logs {
+			blockNumber, _ := hexToUint64(zkLog.BlockNumber)
+			txIndex, _ := hexToUint64(zkLog.TransactionIndex)
+			txLogIndex, _ := hexToUint64(zkLog.TransactionLogIndex)
+			logIndex, _ := hexToUint64(zkLog.LogIndex)
+			shardID, _ := hexToUint64(zkLog.ShardID)
+			result = append(result, models.ZkSyncL2ToL1Log{
+				BlockNumber:         blockNumber,
+				LogIndex:            int(logIndex),
+				TransactionHash:     zkLog.TransactionHash,
+				TransactionIndex:    int(txIndex),
+				TransactionLogIndex: int(txLogIndex),
+				L1BatchNumber:       hexToNullInt64(zkLog.L1BatchNumber),
+				ShardID:             int(shardID),
+				IsService:           zkLog.IsService,
+				Sender:              strings.ToLower(zkLog.Sender),
+				Key:                 zkLog.Key,
+				Value:               zkLog.Value,
+			})
+		}
+	}
+	return result
+}
+
+// ZkSyncBlocksToDicts converts blocks into rows for the block_file exporter.
+func ZkSyncBlocksToDicts(blocks []models.ZkSyncBlock) []map[string]interface{} {
+	evmBlocks := make([]models.EVMBlock, len(blocks))
+	for i, block := range blocks {
+		evmBlocks[i] = block.EVMBlock
+	}
+	rows := EVMBlocksToDicts(evmBlocks)
+	for i, block := range blocks {
+		rows[i]["l1_batch_number"] = nullInt64ToDict(block.L1BatchNumber)
+	}
+	return rows
+}
+
+// ZkSyncTransactionsToDicts converts transactions into rows for the transaction_file exporter.
+func ZkSyncTransactionsToDicts(transactions []models.ZkSyncTransaction) []map[string]interface{} {
+	evmTransactions := make([]models.EVMTransaction, len(transactions))
+	for i, tx := range transactions {
+		evmTransactions[i] = tx.EVMTransaction
+	}
+	rows := EVMTransactionsToDicts(evmTransactions)
+	for i, tx := range transactions {
+		rows[i]["l1_batch_number"] = nullInt64ToDict(tx.L1BatchNumber)
+		rows[i]["l1_batch_tx_index"] = nullInt64ToDict(tx.L1BatchTxIndex)
+	}
+	return rows
+}
+
+// ZkSyncLogsToDicts converts logs into rows for the event_file exporter.
+func ZkSyncLogsToDicts(logs []models.ZkSyncLog) []map[string]interface{} {
+	evmLogs := make([]models.EVMLog, len(logs))
+	for i, zkLog := range logs {
+		evmLogs[i] = zkLog.EVMLog
+	}
+	return EVMLogsToDicts(evmLogs)
+}
+
+// ZkSyncL2ToL1LogsToDicts converts L2 to L1 logs into rows for the l2_to_l1_log_file exporter.
+func ZkSyncL2ToL1LogsToDicts(logs []models.ZkSyncL2ToL1Log) []map[string]interface{} {
+	rows := make([]map[string]interface{}, len(logs))
+	for i, zkLog := range logs {
+		rows[i] = map[string]interface{}{
+			"block_number":      int(zkLog.BlockNumber),
+			"log_index":         zkLog.LogIndex,
+			"transaction_hash":  zkLog.TransactionHash,
+			"transaction_index": zkLog.TransactionIndex,
+			"l1_batch_number":   nullInt64ToDict(zkLog.L1BatchNumber),
+			"shard_id":          zkLog.ShardID,
+			"is_service":        zkLog.IsService,
+			"sender":            zkLog.Sender,
+			"key":               zkLog.Key,
+			"value":             zkLog.Value,
+		}
+	}
+	return rows
+}
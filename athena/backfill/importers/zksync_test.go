@@ -0,0 +1,103 @@
+package importers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetZkSyncFullBlocks(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ethRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		var number string
+		json.Unmarshal(req.Params[0], &number)
+		switch req.Method {
+		case "eth_getBlockByNumber":
+			batch := `"0x1f4"`
+			if number == "0x3" {
+				batch = "null"
+			}
+			fmt.Fprintf(w, `{"jsonrpc": "2.0", "id": 1, "result": {"number": %q, "hash": "0xb%s", "timestamp": "0x64", "l1BatchNumber": %s, "l1BatchTimestamp": "0x60",
+				"transactions": [{"hash": "0xt%s", "type": "0x71", "from": "0xF", "to": "0xA", "value": "0x0", "l1BatchNumber": %s, "l1BatchTxIndex": "0x7"}]}}`, number, number, batch, number, batch)
+		case "eth_getBlockReceipts":
+			fmt.Fprintf(w, `{"jsonrpc": "2.0", "id": 1, "result": [{"transactionHash": "0xt%s", "status": "0x1", "gasUsed": "0x5208", "l1BatchNumber": "0x1f4",
+				"logs": [{"address": "0xA", "topics": ["0xddf2"], "data": "0x01", "blockNumber": %q, "transactionHash": "0xt%s", "logIndex": "0x0"}],
+				"l2ToL1Logs": [{"blockNumber": %q, "l1BatchNumber": "0x1f4", "transactionHash": "0xt%s", "transactionIndex": "0x0", "logIndex": "0x2", "shardId": "0x0",
+					"isService": true, "sender": "0x000000000000000000000000000000000000800A", "key": "0x01", "value": "0x02"}]}]}`, number, number, number, number, number)
+		default:
+			t.Errorf("unexpected method %s", req.Method)
+		}
+	}))
+	defer server.Close()
+
+	blocks, err := GetZkSyncFullBlocks(context.Background(), server.URL, 1, 3)
+	assert.NoError(t, err)
+	assert.Len(t, blocks, 3)
+
+	block, err := ZkSyncBlockToModel(blocks[0].Block)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), block.BlockNumber)
+	assert.Equal(t, 1, block.TransactionCount)
+	assert.Equal(t, int64(500), block.L1BatchNumber.Int64)
+	unsealed, err := ZkSyncBlockToModel(blocks[2].Block)
+	assert.NoError(t, err)
+	assert.False(t, unsealed.L1BatchNumber.Valid)
+
+	transactions, err := ZkSyncTransactionsFromBlock(blocks[1])
+	assert.NoError(t, err)
+	assert.Len(t, transactions, 1)
+	assert.False(t, transactions[0].EffectiveGasPrice.Valid)
+	assert.Equal(t, 113, transactions[0].Type)
+	assert.Equal(t, 1, transactions[0].Status)
+	assert.Equal(t, int64(7), transactions[0].L1BatchTxIndex.Int64)
+
+	logs := ZkSyncLogsFromBlock(blocks[1])
+	assert.Len(t, logs, 1)
+	assert.Equal(t, "0xa", logs[0].ContractAddress)
+
+	l2ToL1Logs := ZkSyncL2ToL1LogsFromBlock(blocks[1])
+	assert.Len(t, l2ToL1Logs, 1)
+	assert.Equal(t, uint64(2), l2ToL1Logs[0].BlockNumber)
+	assert.Equal(t, 2, l2ToL1Logs[0].LogIndex)
+	assert.True(t, l2ToL1Logs[0].IsService)
+	assert.Equal(t, "0x000000000000000000000000000000000000800a", l2ToL1Logs[0].Sender)
+	assert.Equal(t, 500, ZkSyncL2ToL1LogsToDicts(l2ToL1Logs)[0]["l1_batch_number"])
+}
+
+func TestGetZkSyncL1BatchBlockRange(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ethRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		switch req.Method {
+		case "zks_getL1BatchBlockRange":
+			if string(req.Params[0]) == "500" {
+				w.Write([]byte(`{"jsonrpc": "2.0", "id": 1, "result": ["0x3e8", "0x3f1"]}`))
+				return
+			}
+			w.Write([]byte(`{"jsonrpc": "2.0", "id": 1, "result": null}`))
+		case "zks_L1BatchNumber":
+			w.Write([]byte(`{"jsonrpc": "2.0", "id": 1, "result": "0x1f5"}`))
+		default:
+			t.Errorf("unexpected method %s", req.Method)
+		}
+	}))
+	defer server.Close()
+
+	from, to, err := GetZkSyncL1BatchBlockRange(context.Background(), server.URL, 500)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1000), from)
+	assert.Equal(t, uint64(1009), to)
+
+	_, _, err = GetZkSyncL1BatchBlockRange(context.Background(), server.URL, 501)
+	assert.Error(t, err)
+
+	batchNumber, err := GetZkSyncL1BatchNumber(context.Background(), server.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(501), batchNumber)
+}
@@ -0,0 +1,139 @@
+package backfill
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strings"
+)
+
+// maxSafeJSONInteger is the largest integer a float64 JSON number holds exactly. Larger integers
+// are exported as strings so that JSON readers do not round them.
+const maxSafeJSONInteger = 1<<53 - 1
+
+// JSONLResourceExporter is used to export resources to a JSON Lines file, one JSON object per
+// row. Nested values such as decoded params are kept as nested JSON, and big integers are
+// rendered as decimal strings. Files ending in .jsonl.gz are gzip compressed.
+type JSONLResourceExporter struct {
+	AbstractResourceExporter
+	fileName   string
+	fileHandle *os.File
+	gzipWriter *gzip.Writer
+	writer     *bufio.Writer
+	encoder    *json.Encoder
+}
+
+func NewJSONLResourceExporter(fileName string, append bool) (*JSONLResourceExporter, error) {
+	compressed := strings.HasSuffix(fileName, ".jsonl.gz")
+	if !compressed && !strings.HasSuffix(fileName, ".jsonl") {
+		return nil, errors.New("export file name must be a .jsonl or .jsonl.gz file")
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if append {
+		// Appending to a gzip file adds a gzip member, which readers decompress as one stream.
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+	fileHandle, err := os.OpenFile(fileName, flags, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	exporter := &JSONLResourceExporter{
+		AbstractResourceExporter: AbstractResourceExporter{
+			exportMode: JSONL,
+		},
+		fileName:   fileName,
+		fileHandle: fileHandle,
+	}
+	var output io.Writer = fileHandle
+	if compressed {
+		exporter.gzipWriter = gzip.NewWriter(fileHandle)
+		output = exporter.gzipWriter
+	}
+	exporter.writer = bufio.NewWriter(output)
+	exporter.encoder = json.NewEncoder(exporter.writer)
+	exporter.encoder.SetEscapeHTML(false)
+	exporter.Init()
+	return exporter, nil
+}
+
+// JSONEncodeValue converts a value into one that encoding/json renders without losing precision:
+// big integers become decimal strings and nested maps and slices are converted recursively.
+func JSONEncodeValue(val interface{}) interface{} {
+	switch v := val.(type) {
+	case *big.Int:
+		if v == nil {
+			return nil
+		}
+		return v.String()
+	case big.Int:
+		return v.String()
+	case uint64:
+		if v > maxSafeJSONInteger {
+			return fmt.Sprint(v)
+		}
+		return v
+	case int64:
+		if v > maxSafeJSONInteger || v < -maxSafeJSONInteger {
+			return fmt.Sprint(v)
+		}
+		return v
+	case int:
+		if v > maxSafeJSONInteger || v < -maxSafeJSONInteger {
+			return fmt.Sprint(v)
+		}
+		return v
+	case map[string]interface{}:
+		encoded := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			encoded[key] = JSONEncodeValue(item)
+		}
+		return encoded
+	case []interface{}:
+		encoded := make([]interface{}, len(v))
+		for i, item := range v {
+			encoded[i] = JSONEncodeValue(item)
+		}
+		return encoded
+	case []byte:
+		return fmt.Sprintf("%x", v)
+	default:
+		return v
+	}
+}
+
+func (e *JSONLResourceExporter) Write(resources []map[string]interface{}) error {
+	for _, resource := range resources {
+		if err := e.encoder.Encode(JSONEncodeValue(resource)); err != nil {
+			return fmt.Errorf("failed to write row to %s: %w", e.fileName, err)
+		}
+	}
+	if err := e.writer.Flush(); err != nil {
+		return fmt.Errorf("failed to write rows to %s: %w", e.fileName, err)
+	}
+	e.resourcesSaved += len(resources)
+	return nil
+}
+
+// Close flushes the gzip stream of compressed files and closes the file.
+func (e *JSONLResourceExporter) Close() error {
+	defer e.AbstractResourceExporter.Close()
+
+	var err error
+	if e.gzipWriter != nil {
+		err = e.gzipWriter.Close()
+	}
+	if closeErr := e.fileHandle.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to close %s: %w", e.fileName, err)
+	}
+	return nil
+}
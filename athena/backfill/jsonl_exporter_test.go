@@ -0,0 +1,95 @@
+package backfill
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/BlocSoc-iitr/Athena/athena/backfill/importers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readJSONLines(t *testing.T, fileName string) []map[string]interface{} {
+	file, err := os.Open(fileName)
+	require.NoError(t, err)
+	defer file.Close()
+
+	var reader io.Reader = file
+	if strings.HasSuffix(fileName, ".gz") {
+		gzipReader, err := gzip.NewReader(file)
+		require.NoError(t, err)
+		reader = gzipReader
+	}
+	var rows []map[string]interface{}
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		var row map[string]interface{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &row))
+		rows = append(rows, row)
+	}
+	require.NoError(t, scanner.Err())
+	return rows
+}
+
+func TestJSONEncodeValue(t *testing.T) {
+	u256, _ := new(big.Int).SetString("340282366920938463463374607431768211457", 10)
+	decoded := map[string]interface{}{
+		"amount": u256,
+		"route": []interface{}{
+			map[string]interface{}{"pool": big.NewInt(7), "Swap": map[string]interface{}{"fee": uint64(1) << 60}},
+		},
+		"count": 3,
+	}
+
+	encoded, err := json.Marshal(JSONEncodeValue(decoded))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"amount":"340282366920938463463374607431768211457","count":3,"route":[{"Swap":{"fee":"1152921504606846976"},"pool":"7"}]}`, string(encoded))
+}
+
+func TestJSONLResourceExporter(t *testing.T) {
+	for _, name := range []string{"events.jsonl", "events.jsonl.gz"} {
+		t.Run(name, func(t *testing.T) {
+			fileName := filepath.Join(t.TempDir(), name)
+			events := testEvents(3)
+			events[1].DecodedParams = map[string]interface{}{"value": map[string]interface{}{"low": big.NewInt(1), "high": big.NewInt(0)}}
+
+			exporter, err := NewResourceExporter(fileName, nil, ExportOptions{})
+			require.NoError(t, err)
+			require.NoError(t, exporter.Write(importers.EventsToDicts(events[:2])))
+			require.NoError(t, exporter.Close())
+
+			// Appending keeps the rows written before.
+			exporter, err = NewResourceExporter(fileName, nil, ExportOptions{Append: true})
+			require.NoError(t, err)
+			require.NoError(t, exporter.Write(importers.EventsToDicts(events[2:])))
+			require.NoError(t, exporter.Close())
+
+			rows := readJSONLines(t, fileName)
+			require.Len(t, rows, 3)
+			assert.Equal(t, float64(690002), rows[2]["block_number"])
+			assert.Equal(t, []interface{}{"0x99", "0x1"}, rows[0]["keys"])
+			assert.Equal(t, map[string]interface{}{"low": "1", "high": "0"}, rows[1]["decoded_params"].(map[string]interface{})["value"])
+		})
+	}
+}
+
+func TestJSONLResourceExporterOverwrite(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "blocks.jsonl")
+	for i := 0; i < 2; i++ {
+		exporter, err := NewJSONLResourceExporter(fileName, false)
+		require.NoError(t, err)
+		require.NoError(t, exporter.Write([]map[string]interface{}{{"block_number": i}}))
+		require.NoError(t, exporter.Close())
+	}
+	assert.Equal(t, []map[string]interface{}{{"block_number": float64(1)}}, readJSONLines(t, fileName))
+
+	_, err := NewJSONLResourceExporter(filepath.Join(t.TempDir(), "blocks.json"), false)
+	assert.Error(t, err)
+}
@@ -0,0 +1,438 @@
+package backfill
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/compress"
+	"gorm.io/datatypes"
+)
+
+// DefaultParquetRowGroupSize is the maximum number of rows of a Parquet row group.
+const DefaultParquetRowGroupSize = 100000
+
+// parquetKind is the type of a Parquet column.
+type parquetKind int
+
+const (
+	parquetInt64 parquetKind = iota
+	parquetUint64
+	parquetDouble
+	parquetBoolean
+	parquetString
+	parquetJSON
+	parquetStringList
+)
+
+type parquetColumn struct {
+	name  string
+	kind  parquetKind
+	index int
+}
+
+// ParquetResourceExporter is used to export resources to a Parquet file. Columns are typed with the
+// database model of the rows: block numbers are unsigned integers, hashes and addresses strings,
+// keys, data and calldata lists of strings, and decoded params JSON. Columns that are not in the
+// model are typed from their first non-null value. The schema is fixed by the first Write.
+type ParquetResourceExporter struct {
+	AbstractResourceExporter
+	fileName     string
+	modelColumns map[string]parquetKind
+	compression  compress.Codec
+	rowGroupSize int64
+	fileHandle   *os.File
+	writer       *parquet.Writer
+	columns      []parquetColumn
+}
+
+// NewParquetResourceExporter creates a Parquet exporter for rows of model, a database model such
+// as models.Block. model may be nil, columns are then typed from the exported values only.
+func NewParquetResourceExporter(fileName string, model interface{}, options ExportOptions) (*ParquetResourceExporter, error) {
+	if !strings.HasSuffix(fileName, ".parquet") {
+		return nil, errors.New("export file name must be a .parquet file")
+	}
+
+	compression, err := parquetCompression(options.Compression)
+	if err != nil {
+		return nil, err
+	}
+	rowGroupSize := options.RowGroupSize
+	if rowGroupSize <= 0 {
+		rowGroupSize = DefaultParquetRowGroupSize
+	}
+
+	// Parquet metadata is written at the end of the file, rows cannot be appended to it.
+	if options.Append {
+		if info, err := os.Stat(fileName); err == nil && info.Size() > 0 {
+			return nil, fmt.Errorf("cannot append to parquet file %s", fileName)
+		}
+	}
+	fileHandle, err := os.Create(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	exporter := &ParquetResourceExporter{
+		AbstractResourceExporter: AbstractResourceExporter{
+			exportMode: Parquet,
+		},
+		fileName:     fileName,
+		modelColumns: parquetModelColumns(model),
+		compression:  compression,
+		rowGroupSize: rowGroupSize,
+		fileHandle:   fileHandle,
+	}
+	exporter.Init()
+	return exporter, nil
+}
+
+func parquetCompression(name string) (compress.Codec, error) {
+	switch strings.ToLower(name) {
+	case "", "snappy":
+		return &parquet.Snappy, nil
+	case "zstd":
+		return &parquet.Zstd, nil
+	case "none", "uncompressed":
+		return &parquet.Uncompressed, nil
+	default:
+		return nil, fmt.Errorf("unsupported parquet compression %s, expected snappy, zstd or none", name)
+	}
+}
+
+// parquetModelColumns maps the gorm columns of a model, including the columns of embedded structs,
+// to Parquet column types.
+func parquetModelColumns(model interface{}) map[string]parquetKind {
+	columns := make(map[string]parquetKind)
+	if model == nil {
+		return columns
+	}
+	var walk func(t reflect.Type)
+	walk = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.Anonymous && field.Type.Kind() == reflect.Struct {
+				walk(field.Type)
+				continue
+			}
+			for _, setting := range strings.Split(field.Tag.Get("gorm"), ";") {
+				if name, ok := strings.CutPrefix(setting, "column:"); ok {
+					columns[name] = parquetKindOfType(field.Type)
+				}
+			}
+		}
+	}
+	walk(reflect.Indirect(reflect.ValueOf(model)).Type())
+	return columns
+}
+
+func parquetKindOfType(t reflect.Type) parquetKind {
+	switch t {
+	case reflect.TypeOf(sql.NullString{}):
+		return parquetString
+	case reflect.TypeOf(sql.NullInt64{}):
+		return parquetInt64
+	case reflect.TypeOf(sql.NullFloat64{}):
+		return parquetDouble
+	case reflect.TypeOf(sql.NullBool{}):
+		return parquetBoolean
+	case reflect.TypeOf(datatypes.JSON{}):
+		return parquetJSON
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return parquetKindOfType(t.Elem())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return parquetUint64
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return parquetInt64
+	case reflect.Float32, reflect.Float64:
+		return parquetDouble
+	case reflect.Bool:
+		return parquetBoolean
+	case reflect.String:
+		return parquetString
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.String {
+			return parquetStringList
+		}
+	}
+	return parquetJSON
+}
+
+// parquetKindOfValue types a column that is not in the model from one of its values.
+func parquetKindOfValue(value interface{}) parquetKind {
+	switch v := value.(type) {
+	case int, int8, int16, int32, int64:
+		return parquetInt64
+	case uint, uint8, uint16, uint32, uint64:
+		return parquetUint64
+	case float32, float64:
+		return parquetDouble
+	case bool:
+		return parquetBoolean
+	case string:
+		return parquetString
+	case []string:
+		return parquetStringList
+	case []interface{}:
+		for _, item := range v {
+			if _, ok := item.(string); !ok {
+				return parquetJSON
+			}
+		}
+		return parquetStringList
+	default:
+		return parquetJSON
+	}
+}
+
+func (k parquetKind) node() parquet.Node {
+	switch k {
+	case parquetInt64:
+		return parquet.Optional(parquet.Int(64))
+	case parquetUint64:
+		return parquet.Optional(parquet.Uint(64))
+	case parquetDouble:
+		return parquet.Optional(parquet.Leaf(parquet.DoubleType))
+	case parquetBoolean:
+		return parquet.Optional(parquet.Leaf(parquet.BooleanType))
+	case parquetStringList:
+		return parquet.List(parquet.String())
+	case parquetJSON:
+		return parquet.Optional(parquet.JSON())
+	default:
+		return parquet.Optional(parquet.String())
+	}
+}
+
+// openWriter fixes the schema from the columns of rows and creates the Parquet writer.
+func (e *ParquetResourceExporter) openWriter(rows []map[string]interface{}) {
+	kinds := make(map[string]parquetKind)
+	for _, row := range rows {
+		for name, value := range row {
+			if _, ok := kinds[name]; ok {
+				continue
+			}
+			if kind, ok := e.modelColumns[name]; ok {
+				kinds[name] = kind
+			} else if value != nil {
+				kinds[name] = parquetKindOfValue(value)
+			}
+		}
+	}
+	for _, row := range rows {
+		for name := range row {
+			if _, ok := kinds[name]; !ok {
+				// Columns that are not in the model and null in every row are typed as strings.
+				kinds[name] = parquetString
+			}
+		}
+	}
+	if len(rows) == 0 {
+		for name, kind := range e.modelColumns {
+			kinds[name] = kind
+		}
+	}
+
+	group := make(parquet.Group, len(kinds))
+	for name, kind := range kinds {
+		group[name] = kind.node()
+	}
+	schemaName := strings.TrimSuffix(filepath.Base(e.fileName), ".parquet")
+	schema := parquet.NewSchema(schemaName, group)
+
+	e.columns = make([]parquetColumn, 0, len(kinds))
+	for name, kind := range kinds {
+		path := []string{name}
+		if kind == parquetStringList {
+			path = append(path, "list", "element")
+		}
+		leaf, _ := schema.Lookup(path...)
+		e.columns = append(e.columns, parquetColumn{name: name, kind: kind, index: leaf.ColumnIndex})
+	}
+	sort.Slice(e.columns, func(i, j int) bool { return e.columns[i].index < e.columns[j].index })
+
+	e.writer = parquet.NewWriter(e.fileHandle, schema,
+		parquet.Compression(e.compression),
+		parquet.MaxRowsPerRowGroup(e.rowGroupSize),
+	)
+}
+
+func (e *ParquetResourceExporter) Write(resources []map[string]interface{}) error {
+	if len(resources) == 0 {
+		return nil
+	}
+	if e.writer == nil {
+		e.openWriter(resources)
+	}
+
+	rows := make([]parquet.Row, len(resources))
+	for i, resource := range resources {
+		row, err := e.encodeRow(resource)
+		if err != nil {
+			return err
+		}
+		rows[i] = row
+	}
+	if _, err := e.writer.WriteRows(rows); err != nil {
+		return fmt.Errorf("failed to write rows to %s: %w", e.fileName, err)
+	}
+	e.resourcesSaved += len(resources)
+	return nil
+}
+
+func (e *ParquetResourceExporter) encodeRow(resource map[string]interface{}) (parquet.Row, error) {
+	known := 0
+	row := make(parquet.Row, 0, len(e.columns))
+	for _, column := range e.columns {
+		value, ok := resource[column.name]
+		if ok {
+			known++
+		}
+		if column.kind == parquetStringList {
+			items, err := parquetStrings(value)
+			if err != nil {
+				return nil, fmt.Errorf("cannot encode column %s: %w", column.name, err)
+			}
+			if len(items) == 0 {
+				row = append(row, parquet.Value{}.Level(0, 0, column.index))
+			}
+			for i, item := range items {
+				repetitionLevel := 1
+				if i == 0 {
+					repetitionLevel = 0
+				}
+				row = append(row, parquet.ByteArrayValue([]byte(item)).Level(repetitionLevel, 1, column.index))
+			}
+			continue
+		}
+		if value == nil {
+			row = append(row, parquet.Value{}.Level(0, 0, column.index))
+			continue
+		}
+		encoded, err := column.kind.encode(value)
+		if err != nil {
+			return nil, fmt.Errorf("cannot encode column %s: %w", column.name, err)
+		}
+		row = append(row, encoded.Level(0, 1, column.index))
+	}
+	if known < len(resource) {
+		return nil, fmt.Errorf("column %s is not in the schema of %s", e.unknownColumn(resource), e.fileName)
+	}
+	return row, nil
+}
+
+func (e *ParquetResourceExporter) unknownColumn(resource map[string]interface{}) string {
+	names := make(map[string]bool, len(e.columns))
+	for _, column := range e.columns {
+		names[column.name] = true
+	}
+	for name := range resource {
+		if !names[name] {
+			return name
+		}
+	}
+	return ""
+}
+
+func (k parquetKind) encode(value interface{}) (parquet.Value, error) {
+	switch k {
+	case parquetInt64, parquetUint64:
+		rv := reflect.ValueOf(value)
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if k == parquetUint64 && rv.Int() < 0 {
+				return parquet.Value{}, fmt.Errorf("negative value %d for an unsigned column", rv.Int())
+			}
+			return parquet.Int64Value(rv.Int()), nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if k == parquetInt64 && rv.Uint() > math.MaxInt64 {
+				return parquet.Value{}, fmt.Errorf("value %d overflows a signed column", rv.Uint())
+			}
+			return parquet.Int64Value(int64(rv.Uint())), nil
+		}
+	case parquetDouble:
+		rv := reflect.ValueOf(value)
+		switch rv.Kind() {
+		case reflect.Float32, reflect.Float64:
+			return parquet.DoubleValue(rv.Float()), nil
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return parquet.DoubleValue(float64(rv.Int())), nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return parquet.DoubleValue(float64(rv.Uint())), nil
+		}
+	case parquetBoolean:
+		if v, ok := value.(bool); ok {
+			return parquet.BooleanValue(v), nil
+		}
+	case parquetString:
+		if v, ok := value.(string); ok {
+			return parquet.ByteArrayValue([]byte(v)), nil
+		}
+	case parquetJSON:
+		switch v := value.(type) {
+		case string:
+			return parquet.ByteArrayValue([]byte(v)), nil
+		case []byte:
+			return parquet.ByteArrayValue(v), nil
+		default:
+			encoded, err := json.Marshal(v)
+			if err != nil {
+				return parquet.Value{}, err
+			}
+			return parquet.ByteArrayValue(encoded), nil
+		}
+	}
+	return parquet.Value{}, fmt.Errorf("unexpected value %v of type %T", value, value)
+}
+
+func parquetStrings(value interface{}) ([]string, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case []string:
+		return v, nil
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("unexpected list item %v of type %T", item, item)
+			}
+			items[i] = s
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("unexpected value %v of type %T for a list", value, value)
+	}
+}
+
+// Close writes the buffered row group and the Parquet footer. Files without rows get the schema
+// of the model. The file is not a valid Parquet file if Close fails.
+func (e *ParquetResourceExporter) Close() error {
+	defer e.AbstractResourceExporter.Close()
+
+	var err error
+	if e.writer == nil && len(e.modelColumns) > 0 {
+		e.openWriter(nil)
+	}
+	if e.writer != nil {
+		err = e.writer.Close()
+	}
+	if closeErr := e.fileHandle.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to close %s: %w", e.fileName, err)
+	}
+	return nil
+}
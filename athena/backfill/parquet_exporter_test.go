@@ -0,0 +1,110 @@
+package backfill
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/BlocSoc-iitr/Athena/athena/backfill/importers"
+	"github.com/BlocSoc-iitr/Athena/athena/database/models"
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/format"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testEvents(count int) []models.DefaultEvent {
+	events := make([]models.DefaultEvent, count)
+	for i := range events {
+		events[i] = models.DefaultEvent{
+			Keys:          []string{"0x99", "0x1"},
+			Data:          []string{},
+			EventName:     sql.NullString{String: "Transfer", Valid: true},
+			DecodedParams: map[string]interface{}{"value": "1000000000000000000000"},
+		}
+		events[i].BlockNumber = uint64(690000 + i)
+		events[i].EventIndex = i
+		events[i].ContractAddress = "0x49d3"
+	}
+	return events
+}
+
+func openParquet(t *testing.T, fileName string) *parquet.File {
+	file, err := os.Open(fileName)
+	require.NoError(t, err)
+	t.Cleanup(func() { file.Close() })
+	info, err := file.Stat()
+	require.NoError(t, err)
+	parquetFile, err := parquet.OpenFile(file, info.Size())
+	require.NoError(t, err)
+	return parquetFile
+}
+
+func TestParquetResourceExporter(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "events.parquet")
+	exporter, err := NewResourceExporter(fileName, models.DefaultEvent{}, ExportOptions{Compression: "zstd", RowGroupSize: 2})
+	require.NoError(t, err)
+	require.NoError(t, exporter.Write(importers.EventsToDicts(testEvents(3))))
+	require.NoError(t, exporter.Write(importers.EventsToDicts(testEvents(2))))
+	require.NoError(t, exporter.Close())
+
+	file := openParquet(t, fileName)
+	assert.Equal(t, int64(5), file.NumRows())
+	assert.Len(t, file.RowGroups(), 3)
+	assert.Equal(t, format.Zstd, file.Metadata().RowGroups[0].Columns[0].MetaData.Codec)
+
+	schema := file.Schema()
+	blockNumber, ok := schema.Lookup("block_number")
+	require.True(t, ok)
+	assert.Equal(t, parquet.Int64Type.Kind(), blockNumber.Node.Type().Kind())
+	assert.False(t, blockNumber.Node.Type().LogicalType().Integer.IsSigned)
+	keys, ok := schema.Lookup("keys", "list", "element")
+	require.True(t, ok)
+	assert.Equal(t, 1, keys.MaxRepetitionLevel)
+	decoded, ok := schema.Lookup("decoded_params")
+	require.True(t, ok)
+	assert.NotNil(t, decoded.Node.Type().LogicalType().Json)
+
+	reader := parquet.NewReader(file)
+	row := make(map[string]interface{})
+	require.NoError(t, reader.Read(&row))
+	assert.EqualValues(t, 690000, row["block_number"])
+	assert.Equal(t, "Transfer", row["event_name"])
+	assert.Equal(t, map[string]interface{}{"value": "1000000000000000000000"}, row["decoded_params"])
+	elements := []interface{}{map[string]interface{}{"element": "0x99"}, map[string]interface{}{"element": "0x1"}}
+	assert.Equal(t, map[string]interface{}{"list": elements}, row["keys"])
+	assert.Equal(t, map[string]interface{}{"list": []interface{}{}}, row["data"])
+}
+
+func TestParquetResourceExporterErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	_, err := NewResourceExporter(filepath.Join(dir, "events.json"), nil, ExportOptions{})
+	assert.EqualError(t, err, "export file name must be a .csv, .parquet, .jsonl or .jsonl.gz file")
+	_, err = NewResourceExporter(filepath.Join(dir, "events.parquet"), nil, ExportOptions{Compression: "lz4"})
+	assert.Error(t, err)
+
+	fileName := filepath.Join(dir, "blocks.parquet")
+	exporter, err := NewResourceExporter(fileName, models.Block{}, ExportOptions{})
+	require.NoError(t, err)
+	err = exporter.Write([]map[string]interface{}{{"block_number": -1}})
+	assert.ErrorContains(t, err, "cannot encode column block_number")
+	require.NoError(t, exporter.Write([]map[string]interface{}{{"block_number": 1}}))
+	err = exporter.Write([]map[string]interface{}{{"block_number": 2, "miner": "0x1"}})
+	assert.ErrorContains(t, err, "column miner is not in the schema")
+	require.NoError(t, exporter.Close())
+
+	_, err = NewResourceExporter(fileName, models.Block{}, ExportOptions{Append: true})
+	assert.ErrorContains(t, err, "cannot append to parquet file")
+}
+
+func TestParquetResourceExporterCloseError(t *testing.T) {
+	// Rows are buffered until Close writes them with the footer, so a failed write there must be
+	// reported.
+	exporter, err := NewParquetResourceExporter(filepath.Join(t.TempDir(), "blocks.parquet"), models.Block{}, ExportOptions{})
+	require.NoError(t, err)
+	require.NoError(t, exporter.Write([]map[string]interface{}{{"block_number": 1}}))
+	require.NoError(t, exporter.fileHandle.Close())
+	assert.ErrorContains(t, exporter.Close(), "failed to close")
+}
@@ -0,0 +1,534 @@
+package backfill
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ManifestFileName is the name of the manifest of a partitioned export, in its directory.
+const ManifestFileName = "manifest.json"
+
+// PartitionOptions configure how a PartitionedResourceExporter splits its output.
+type PartitionOptions struct {
+	// Blocks is the number of blocks of a partition. Partitions are aligned on multiples of it, so
+	// that 10000 gives partitions such as 000690000-000699999. 0 does not partition by block.
+	Blocks uint64
+	// MaxBytes rotates a partition at the next block once its file reaches this size. Parquet and
+	// gzip files are buffered, so their partitions may grow past it. 0 does not rotate by size.
+	MaxBytes int64
+	// Store uploads the completed partitions and then the manifest, such as S3PartitionStore. Its
+	// manifest is used to resume when dir has none. nil keeps the partitions local only.
+	Store PartitionStore
+	// RemoveStored removes the local partition files once they are uploaded to Store.
+	RemoveStored bool
+}
+
+// PartitionStore stores the files of a partitioned export outside of its directory.
+type PartitionStore interface {
+	// PutFile stores a local file as name, a partition file or the manifest.
+	PutFile(ctx context.Context, name string, fileName string) error
+	// GetManifest returns the stored manifest, or an error wrapping os.ErrNotExist if there is none.
+	GetManifest(ctx context.Context) (*Manifest, error)
+}
+
+// Partition is a completed partition of a manifest. Partitions without rows have no file.
+type Partition struct {
+	File      string `json:"file,omitempty"`
+	FromBlock uint64 `json:"from_block"`
+	ToBlock   uint64 `json:"to_block"`
+	Rows      int    `json:"rows"`
+	SHA256    string `json:"sha256,omitempty"`
+}
+
+// Manifest lists the completed partitions of a partitioned export, in block order. Loaders read
+// only the partitions it lists, and resumed backfills skip them.
+type Manifest struct {
+	SchemaVersion int         `json:"schema_version"`
+	Columns       []string    `json:"columns,omitempty"`
+	Partitions    []Partition `json:"partitions"`
+}
+
+// ReadManifest reads the manifest of a partitioned export directory.
+func ReadManifest(dir string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ManifestFileName))
+	if err != nil {
+		return nil, err
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest in %s: %w", dir, err)
+	}
+	return &manifest, nil
+}
+
+// NextBlock returns the first block from fromBlock on that is not in a completed partition.
+func (m *Manifest) NextBlock(fromBlock uint64) uint64 {
+	next := fromBlock
+	for _, partition := range m.Partitions {
+		if partition.FromBlock <= next && partition.ToBlock >= next {
+			next = partition.ToBlock + 1
+		}
+	}
+	return next
+}
+
+func (m *Manifest) hasSchema(columns CSVSchema) bool {
+	return m.SchemaVersion == ExportSchemaVersion && columns.equal(m.Columns)
+}
+
+// syncManifest reconciles the manifest of dir with the manifest of store, for a directory that was
+// exported without the store or with another one. Partitions only listed in dir are uploaded from
+// dir before the manifest, so that the stored manifest only lists stored partitions. Partitions
+// only listed in the store are kept. The merged manifest replaces both manifests.
+func syncManifest(ctx context.Context, dir string, local *Manifest, store PartitionStore) (*Manifest, error) {
+	stored, err := store.GetManifest(ctx)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		stored = &Manifest{SchemaVersion: local.SchemaVersion, Columns: local.Columns, Partitions: []Partition{}}
+	case err != nil:
+		return nil, err
+	case !stored.hasSchema(CSVSchema(local.Columns)):
+		return nil, fmt.Errorf("stored manifest of %s has schema version %d with columns %v, expected version %d with columns %v",
+			dir, stored.SchemaVersion, stored.Columns, local.SchemaVersion, local.Columns)
+	}
+
+	storedPartitions := make(map[uint64]bool, len(stored.Partitions))
+	for _, partition := range stored.Partitions {
+		storedPartitions[partition.FromBlock] = true
+	}
+	merged := *stored
+	merged.Partitions = append([]Partition{}, stored.Partitions...)
+	for _, partition := range local.Partitions {
+		if storedPartitions[partition.FromBlock] {
+			continue
+		}
+		if partition.File != "" {
+			if err := store.PutFile(ctx, partition.File, filepath.Join(dir, partition.File)); err != nil {
+				return nil, fmt.Errorf("failed to upload partition %s of %s: %w", partition.File, dir, err)
+			}
+		}
+		merged.Partitions = append(merged.Partitions, partition)
+	}
+	if len(merged.Partitions) == len(stored.Partitions) && len(merged.Partitions) == len(local.Partitions) {
+		return local, nil
+	}
+	sort.Slice(merged.Partitions, func(i, j int) bool {
+		return merged.Partitions[i].FromBlock < merged.Partitions[j].FromBlock
+	})
+	if err := merged.write(ctx, dir, store); err != nil {
+		return nil, fmt.Errorf("failed to write the manifest of %s: %w", dir, err)
+	}
+	return &merged, nil
+}
+
+// write replaces the manifest of dir, and uploads it to store if not nil. The manifest is replaced
+// atomically, after its partitions have been stored, so that it never lists a partial partition.
+func (m *Manifest) write(ctx context.Context, dir string, store PartitionStore) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	tempName := filepath.Join(dir, ManifestFileName+".tmp")
+	if err := os.WriteFile(tempName, data, 0644); err != nil {
+		return err
+	}
+	if store != nil {
+		if err := store.PutFile(ctx, ManifestFileName, tempName); err != nil {
+			return err
+		}
+	}
+	return os.Rename(tempName, filepath.Join(dir, ManifestFileName))
+}
+
+// PartitionDirectory splits an output file name such as events.csv into the directory of its
+// partitions, events, and the extension of the partition files, .csv.
+func PartitionDirectory(fileName string) (string, string, error) {
+	for _, extension := range []string{".csv", ".parquet", ".jsonl.gz", ".jsonl"} {
+		if strings.HasSuffix(fileName, extension) {
+			return strings.TrimSuffix(fileName, extension), extension, nil
+		}
+	}
+	return "", "", errors.New("export file name must be a .csv, .parquet, .jsonl or .jsonl.gz file")
+}
+
+// PartitionBlocksFlagUsage and PartitionBytesFlagUsage describe the CLI flags of PartitionOptions.
+const (
+	PartitionBlocksFlagUsage = "Number of blocks per partition; partitions are written to a directory named after the output file"
+	PartitionBytesFlagUsage  = "Size in bytes at which partitions are rotated; partitions are written to a directory named after the output file"
+)
+
+// Enabled reports whether exports are partitioned, by block or by size.
+func (p PartitionOptions) Enabled() bool {
+	return p.Blocks > 0 || p.MaxBytes > 0
+}
+
+// NewBackfillExporter creates the exporter of fileName for a backfill starting at fromBlock. If
+// partition is enabled, the rows are partitioned into the directory named after fileName by a
+// PartitionedResourceExporter, otherwise fileName is written by NewResourceExporter.
+func NewBackfillExporter(fileName string, model interface{}, fromBlock uint64, options ExportOptions, partition PartitionOptions) (ResourceExporter, error) {
+	if !partition.Enabled() {
+		return NewResourceExporter(fileName, model, options)
+	}
+	dir, extension, err := PartitionDirectory(fileName)
+	if err != nil {
+		return nil, err
+	}
+	return NewPartitionedResourceExporter(dir, extension, model, fromBlock, options, partition)
+}
+
+// ResumeBlock returns the block a backfill into exporters starts at: the earliest block that is not
+// in a completed partition of a PartitionedResourceExporter, or fromBlock if there is none.
+func ResumeBlock(fromBlock uint64, exporters ...ResourceExporter) uint64 {
+	resume, partitioned := uint64(math.MaxUint64), false
+	for _, exporter := range exporters {
+		if partitionedExporter, ok := exporter.(*PartitionedResourceExporter); ok {
+			resume, partitioned = min(resume, partitionedExporter.NextBlock()), true
+		}
+	}
+	if !partitioned {
+		return fromBlock
+	}
+	return resume
+}
+
+// PartitionedResourceExporter exports rows ordered by block number to a directory of partition
+// files, each written by the exporter of its extension. A partition is renamed to its block range
+// and added to the manifest once all its blocks have been written, which Complete reports.
+// Partitions already in the manifest are skipped, so a backfill resumes at NextBlock.
+type PartitionedResourceExporter struct {
+	dir       string
+	extension string
+	model     interface{}
+	options   ExportOptions
+	partition PartitionOptions
+	manifest  *Manifest
+
+	// The partition being written covers fromBlock to at most toBlock. Its file is opened with its
+	// first row, and lastBlock is the block of the last row written to it.
+	fromBlock uint64
+	toBlock   uint64
+	exporter  ResourceExporter
+	tempName  string
+	rows      int
+	lastBlock uint64
+	// completed is the block up to which all rows have been written, valid once isCompleted is set.
+	completed   uint64
+	isCompleted bool
+}
+
+// NewPartitionedResourceExporter creates an exporter of the rows of model from fromBlock on into
+// dir. extension selects the file format as in NewResourceExporter. An existing manifest of dir, or
+// else of the partition store, is continued if it has the same schema, and the export starts after
+// its completed partitions. A manifest of dir is first merged with the manifest of the store, and
+// the partitions the store is missing are uploaded.
+func NewPartitionedResourceExporter(dir string, extension string, model interface{}, fromBlock uint64, options ExportOptions, partition PartitionOptions) (*PartitionedResourceExporter, error) {
+	if _, _, err := PartitionDirectory(extension); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	columns := CSVSchemaOf(model)
+	manifest, err := ReadManifest(dir)
+	localManifest := err == nil
+	if errors.Is(err, os.ErrNotExist) && partition.Store != nil {
+		manifest, err = partition.Store.GetManifest(context.Background())
+	}
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		manifest = &Manifest{SchemaVersion: ExportSchemaVersion, Columns: columns, Partitions: []Partition{}}
+	case err != nil:
+		return nil, err
+	case !manifest.hasSchema(columns):
+		return nil, fmt.Errorf("manifest of %s has schema version %d with columns %v, expected version %d with columns %v",
+			dir, manifest.SchemaVersion, manifest.Columns, ExportSchemaVersion, []string(columns))
+	}
+	if localManifest && partition.Store != nil {
+		if manifest, err = syncManifest(context.Background(), dir, manifest, partition.Store); err != nil {
+			return nil, err
+		}
+	}
+
+	// Partitions are written as new files, they are never appended to.
+	options.Append = false
+	exporter := &PartitionedResourceExporter{
+		dir:       dir,
+		extension: extension,
+		model:     model,
+		options:   options,
+		partition: partition,
+		manifest:  manifest,
+	}
+	exporter.startPartition(manifest.NextBlock(fromBlock))
+	return exporter, nil
+}
+
+// NextBlock returns the first block that is not in a completed partition, where the backfill
+// resumes. Rows of earlier blocks are skipped.
+func (e *PartitionedResourceExporter) NextBlock() uint64 {
+	return e.fromBlock
+}
+
+// Manifest returns the manifest of the completed partitions.
+func (e *PartitionedResourceExporter) Manifest() *Manifest {
+	return e.manifest
+}
+
+func (e *PartitionedResourceExporter) startPartition(fromBlock uint64) {
+	e.fromBlock = fromBlock
+	e.toBlock = math.MaxUint64
+	if e.partition.Blocks > 0 {
+		e.toBlock = (fromBlock/e.partition.Blocks+1)*e.partition.Blocks - 1
+	}
+	e.exporter = nil
+	e.tempName = ""
+	e.rows = 0
+}
+
+// Write exports rows ordered by block number, completing the partitions before the block of each
+// row.
+func (e *PartitionedResourceExporter) Write(resources []map[string]interface{}) error {
+	start := 0
+	for i, resource := range resources {
+		block, err := blockNumberOfRow(resource)
+		if err != nil {
+			return err
+		}
+		if e.rows > 0 && block < e.lastBlock {
+			return fmt.Errorf("rows must be written in block order: block %d after block %d", block, e.lastBlock)
+		}
+		if block < e.fromBlock {
+			// The row is in a completed partition.
+			start = i + 1
+			continue
+		}
+		if block > e.toBlock {
+			if err := e.writeRows(resources[start:i]); err != nil {
+				return err
+			}
+			start = i
+			for e.toBlock < block {
+				if err := e.finishPartition(e.toBlock); err != nil {
+					return err
+				}
+			}
+		} else if e.rows > 0 && block > e.lastBlock && e.fileFull() {
+			if err := e.writeRows(resources[start:i]); err != nil {
+				return err
+			}
+			start = i
+			if err := e.finishPartition(block - 1); err != nil {
+				return err
+			}
+		}
+		e.lastBlock = block
+		e.rows++
+	}
+	return e.writeRows(resources[start:])
+}
+
+// writeRows writes rows of the current partition, whose row count already includes them.
+func (e *PartitionedResourceExporter) writeRows(rows []map[string]interface{}) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	if e.exporter == nil {
+		e.tempName = filepath.Join(e.dir, fmt.Sprintf("%09d.partial%s", e.fromBlock, e.extension))
+		exporter, err := NewResourceExporter(e.tempName, e.model, e.options)
+		if err != nil {
+			return err
+		}
+		e.exporter = exporter
+	}
+	return e.exporter.Write(rows)
+}
+
+func (e *PartitionedResourceExporter) fileFull() bool {
+	if e.partition.MaxBytes <= 0 || e.tempName == "" {
+		return false
+	}
+	info, err := os.Stat(e.tempName)
+	return err == nil && info.Size() >= e.partition.MaxBytes
+}
+
+// Complete reports that all the rows up to toBlock have been written. It completes the partitions
+// ending at or before it.
+func (e *PartitionedResourceExporter) Complete(toBlock uint64) error {
+	if toBlock < e.fromBlock {
+		return nil
+	}
+	if e.rows > 0 && toBlock < e.lastBlock {
+		return fmt.Errorf("cannot complete block %d, rows of block %d have been written", toBlock, e.lastBlock)
+	}
+	e.completed, e.isCompleted = toBlock, true
+	for e.toBlock <= toBlock {
+		if err := e.finishPartition(e.toBlock); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Rollback discards the rows of the blocks from fromBlock on, after a reorg. Completed partitions
+// ending at or after fromBlock are removed from the manifest before their files are deleted, and
+// the partition being written is discarded with its partial file if it holds rows from fromBlock
+// on or follows a removed partition. It returns the block the export must be written again from:
+// fromBlock, or the first block of the discarded partition if it started before fromBlock.
+func (e *PartitionedResourceExporter) Rollback(fromBlock uint64) (uint64, error) {
+	kept := make([]Partition, 0, len(e.manifest.Partitions))
+	var removed []Partition
+	for _, partition := range e.manifest.Partitions {
+		if partition.ToBlock < fromBlock {
+			kept = append(kept, partition)
+		} else {
+			removed = append(removed, partition)
+		}
+	}
+	if len(removed) > 0 {
+		manifest := *e.manifest
+		manifest.Partitions = kept
+		if err := manifest.write(context.Background(), e.dir, e.partition.Store); err != nil {
+			return 0, fmt.Errorf("failed to write the manifest of %s: %w", e.dir, err)
+		}
+		e.manifest = &manifest
+		for _, partition := range removed {
+			if partition.File == "" {
+				continue
+			}
+			// Uploaded partitions may have been removed already, see PartitionOptions.RemoveStored.
+			if err := os.Remove(filepath.Join(e.dir, partition.File)); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return 0, err
+			}
+		}
+	}
+
+	if len(removed) == 0 && (e.rows == 0 || e.lastBlock < fromBlock) {
+		return fromBlock, nil
+	}
+	start := e.fromBlock
+	if len(removed) > 0 {
+		start = removed[0].FromBlock
+	}
+	if e.exporter != nil {
+		e.exporter.Close()
+		os.Remove(e.tempName)
+	}
+	e.isCompleted = false
+	e.startPartition(start)
+	return min(fromBlock, start), nil
+}
+
+// finishPartition closes the current partition at toBlock, records it in the manifest and starts
+// the next one. If it fails, the partition is left out of the manifest and not finished on Close.
+func (e *PartitionedResourceExporter) finishPartition(toBlock uint64) error {
+	err := e.storePartition(toBlock)
+	if err != nil {
+		e.exporter = nil
+		e.isCompleted = false
+		return err
+	}
+	e.startPartition(toBlock + 1)
+	return nil
+}
+
+func (e *PartitionedResourceExporter) storePartition(toBlock uint64) error {
+	ctx := context.Background()
+	store := e.partition.Store
+	partition := Partition{FromBlock: e.fromBlock, ToBlock: toBlock}
+	if e.exporter != nil {
+		if err := e.exporter.Close(); err != nil {
+			return err
+		}
+		partition.File = fmt.Sprintf("%09d-%09d%s", e.fromBlock, toBlock, e.extension)
+		partition.Rows = e.rows
+		fileName := filepath.Join(e.dir, partition.File)
+		if err := os.Rename(e.tempName, fileName); err != nil {
+			return err
+		}
+		checksum, err := fileSHA256(fileName)
+		if err != nil {
+			return err
+		}
+		partition.SHA256 = checksum
+		if store != nil {
+			if err := store.PutFile(ctx, partition.File, fileName); err != nil {
+				return err
+			}
+		}
+	}
+
+	partitions := append(append([]Partition{}, e.manifest.Partitions...), partition)
+	sort.Slice(partitions, func(i, j int) bool {
+		return partitions[i].FromBlock < partitions[j].FromBlock
+	})
+	manifest := *e.manifest
+	manifest.Partitions = partitions
+	if err := manifest.write(ctx, e.dir, store); err != nil {
+		return fmt.Errorf("failed to write the manifest of %s: %w", e.dir, err)
+	}
+	e.manifest = &manifest
+
+	if store != nil && e.partition.RemoveStored && partition.File != "" {
+		if err := os.Remove(filepath.Join(e.dir, partition.File)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close completes the current partition up to the last completed block, so that the last partition
+// of a backfill ends at its last block. If rows past it have been written, the partition is
+// discarded with its partial file and exported again by a resumed backfill. Exporters used outside
+// of a pipeline.FileSink must call Complete before Close.
+func (e *PartitionedResourceExporter) Close() error {
+	if e.isCompleted && e.completed >= e.fromBlock && (e.rows == 0 || e.completed >= e.lastBlock) {
+		if err := e.finishPartition(e.completed); err != nil {
+			return fmt.Errorf("failed to close %s: %w", e.dir, err)
+		}
+		return nil
+	}
+	if e.exporter != nil {
+		e.exporter.Close()
+		os.Remove(e.tempName)
+	}
+	return nil
+}
+
+func fileSHA256(fileName string) (string, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// blockNumberOfRow returns the block_number column of a row.
+func blockNumberOfRow(row map[string]interface{}) (uint64, error) {
+	value := reflect.ValueOf(row["block_number"])
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if value.Int() >= 0 {
+			return uint64(value.Int()), nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return value.Uint(), nil
+	}
+	return 0, fmt.Errorf("row has no valid block_number: %v", row["block_number"])
+}
@@ -0,0 +1,164 @@
+package backfill
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/BlocSoc-iitr/Athena/athena/database/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func blockRows(blocks ...int) []map[string]interface{} {
+	rows := make([]map[string]interface{}, len(blocks))
+	for i, block := range blocks {
+		rows[i] = map[string]interface{}{"block_number": block, "block_hash": "0x1"}
+	}
+	return rows
+}
+
+func TestPartitionedResourceExporter(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "blocks")
+	exporter, err := NewPartitionedResourceExporter(dir, ".csv", models.Block{}, 5, ExportOptions{}, PartitionOptions{Blocks: 10})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(5), exporter.NextBlock())
+
+	require.NoError(t, exporter.Write(blockRows(5, 7, 12)))
+	require.NoError(t, exporter.Complete(20))
+	require.NoError(t, exporter.Write(blockRows(35)))
+	require.NoError(t, exporter.Complete(38))
+	require.NoError(t, exporter.Close())
+
+	manifest, err := ReadManifest(dir)
+	require.NoError(t, err)
+	assert.Equal(t, ExportSchemaVersion, manifest.SchemaVersion)
+	assert.Equal(t, []string(CSVSchemaOf(models.Block{})), manifest.Columns)
+	require.Len(t, manifest.Partitions, 4)
+	expected := []Partition{
+		{File: "000000005-000000009.csv", FromBlock: 5, ToBlock: 9, Rows: 2},
+		{File: "000000010-000000019.csv", FromBlock: 10, ToBlock: 19, Rows: 1},
+		{FromBlock: 20, ToBlock: 29},
+		{File: "000000030-000000038.csv", FromBlock: 30, ToBlock: 38, Rows: 1},
+	}
+	for i, partition := range manifest.Partitions {
+		if partition.File != "" {
+			checksum, err := fileSHA256(filepath.Join(dir, partition.File))
+			require.NoError(t, err)
+			assert.Equal(t, checksum, partition.SHA256)
+			assert.Len(t, readCSV(t, filepath.Join(dir, partition.File)), partition.Rows+1)
+		}
+		partition.SHA256 = ""
+		assert.Equal(t, expected[i], partition)
+	}
+
+	// A resumed export skips the completed partitions, and discards a partition that is not
+	// completed when it is closed.
+	exporter, err = NewPartitionedResourceExporter(dir, ".csv", models.Block{}, 5, ExportOptions{}, PartitionOptions{Blocks: 10})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(39), exporter.NextBlock())
+	require.NoError(t, exporter.Write(blockRows(8, 39)))
+	require.NoError(t, exporter.Close())
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 4)
+	manifest, err = ReadManifest(dir)
+	require.NoError(t, err)
+	assert.Len(t, manifest.Partitions, 4)
+
+	_, err = NewPartitionedResourceExporter(dir, ".csv", models.Transaction{}, 5, ExportOptions{}, PartitionOptions{Blocks: 10})
+	assert.ErrorContains(t, err, "has schema version 1 with columns")
+}
+
+func TestPartitionedResourceExporterRotation(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "events")
+	exporter, err := NewPartitionedResourceExporter(dir, ".jsonl", nil, 1, ExportOptions{}, PartitionOptions{MaxBytes: 1})
+	require.NoError(t, err)
+	require.NoError(t, exporter.Write(blockRows(1, 1)))
+	require.NoError(t, exporter.Write(blockRows(1, 2, 2)))
+	require.NoError(t, exporter.Complete(3))
+	err = exporter.Write(blockRows(1))
+	assert.ErrorContains(t, err, "rows must be written in block order")
+	require.NoError(t, exporter.Close())
+
+	manifest := exporter.Manifest()
+	require.Len(t, manifest.Partitions, 2)
+	assert.Equal(t, Partition{File: "000000001-000000001.jsonl", FromBlock: 1, ToBlock: 1, Rows: 3, SHA256: manifest.Partitions[0].SHA256}, manifest.Partitions[0])
+	assert.Equal(t, Partition{File: "000000002-000000003.jsonl", FromBlock: 2, ToBlock: 3, Rows: 2, SHA256: manifest.Partitions[1].SHA256}, manifest.Partitions[1])
+	assert.Len(t, readJSONLines(t, filepath.Join(dir, manifest.Partitions[0].File)), 3)
+
+	dir, extension, err := PartitionDirectory("out/events.jsonl.gz")
+	require.NoError(t, err)
+	assert.Equal(t, "out/events", dir)
+	assert.Equal(t, ".jsonl.gz", extension)
+}
+
+func TestPartitionedResourceExporterRollback(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "blocks")
+	exporter, err := NewPartitionedResourceExporter(dir, ".csv", models.Block{}, 0, ExportOptions{}, PartitionOptions{Blocks: 10})
+	require.NoError(t, err)
+	require.NoError(t, exporter.Write(blockRows(5, 15, 25)))
+	require.NoError(t, exporter.Complete(25))
+
+	// A fork at block 14 removes the partitions from block 10 on, and the partition being written.
+	restart, err := exporter.Rollback(14)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(10), restart)
+	assert.Equal(t, uint64(10), exporter.NextBlock())
+	manifest, err := ReadManifest(dir)
+	require.NoError(t, err)
+	require.Len(t, manifest.Partitions, 1)
+	assert.Equal(t, uint64(9), manifest.Partitions[0].ToBlock)
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2, "the first partition and the manifest")
+
+	require.NoError(t, exporter.Write(blockRows(12, 16)))
+	require.NoError(t, exporter.Complete(16))
+	// A fork in the partition being written discards it from its first block.
+	restart, err = exporter.Rollback(16)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(10), restart)
+	require.NoError(t, exporter.Write(blockRows(12, 17)))
+	require.NoError(t, exporter.Complete(19))
+	require.NoError(t, exporter.Close())
+
+	manifest, err = ReadManifest(dir)
+	require.NoError(t, err)
+	require.Len(t, manifest.Partitions, 2)
+	rows := readCSV(t, filepath.Join(dir, manifest.Partitions[1].File))
+	require.Len(t, rows, 3)
+	assert.Equal(t, "17", rows[2][0])
+
+	// Rows before the fork are kept.
+	restart, err = exporter.Rollback(20)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(20), restart)
+	manifest, err = ReadManifest(dir)
+	require.NoError(t, err)
+	assert.Len(t, manifest.Partitions, 2)
+}
+
+func TestNewBackfillExporter(t *testing.T) {
+	dir := t.TempDir()
+	exporter, err := NewBackfillExporter(filepath.Join(dir, "blocks.csv"), models.Block{}, 0, ExportOptions{}, PartitionOptions{})
+	require.NoError(t, err)
+	assert.IsType(t, &FileResourceExporter{}, exporter)
+	require.NoError(t, exporter.Close())
+	assert.Equal(t, uint64(3), ResumeBlock(3, exporter))
+
+	blocks, err := NewBackfillExporter(filepath.Join(dir, "blocks.csv"), models.Block{}, 0, ExportOptions{}, PartitionOptions{Blocks: 10})
+	require.NoError(t, err)
+	require.IsType(t, &PartitionedResourceExporter{}, blocks)
+	require.NoError(t, blocks.Write(blockRows(3)))
+	require.NoError(t, blocks.(*PartitionedResourceExporter).Complete(19))
+	require.NoError(t, blocks.Close())
+
+	blocks, err = NewBackfillExporter(filepath.Join(dir, "blocks.csv"), models.Block{}, 0, ExportOptions{}, PartitionOptions{Blocks: 10})
+	require.NoError(t, err)
+	transactions, err := NewBackfillExporter(filepath.Join(dir, "transactions.csv"), models.Transaction{}, 0, ExportOptions{}, PartitionOptions{Blocks: 10})
+	require.NoError(t, err)
+	// The backfill resumes at the first block missing from either export.
+	assert.Equal(t, uint64(20), ResumeBlock(0, blocks))
+	assert.Equal(t, uint64(0), ResumeBlock(0, blocks, transactions))
+}
@@ -0,0 +1,271 @@
+// Package pipeline composes backfills from stages: a Source imports Records, Transforms decode,
+// enrich or filter them, and Sinks export them. Stages run concurrently and are connected by
+// bounded channels, so a slow stage blocks the stages before it instead of buffering the backfill.
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultBufferSize is the number of Records buffered between two stages.
+const DefaultBufferSize = 4
+
+// Source imports Records and sends them to out in block order. It returns when it has sent all
+// its Records, or with the context error once ctx is cancelled.
+type Source interface {
+	Name() string
+	Produce(ctx context.Context, out chan<- *Record) error
+}
+
+// Transform processes a Record. It may modify the Record in place or return a new one; returning
+// nil drops the Record.
+type Transform interface {
+	Name() string
+	Apply(ctx context.Context, record *Record) (*Record, error)
+}
+
+// Sink exports Records. Close is called once after the last Record, also when the pipeline fails.
+type Sink interface {
+	Name() string
+	Consume(ctx context.Context, record *Record) error
+	Close() error
+}
+
+// StageMetrics are the counters of a pipeline stage. Busy is the time spent processing Records and
+// Blocked the time spent waiting for the next stage to accept them, which is the backpressure of
+// the stages after it.
+type StageMetrics struct {
+	Stage   string
+	Records int64
+	Rows    int64
+	Busy    time.Duration
+	Blocked time.Duration
+}
+
+func (m StageMetrics) String() string {
+	return fmt.Sprintf("%s: %d records, %d rows, busy %s, blocked %s", m.Stage, m.Records, m.Rows, m.Busy.Round(time.Millisecond), m.Blocked.Round(time.Millisecond))
+}
+
+type stageCounters struct {
+	name    string
+	records atomic.Int64
+	rows    atomic.Int64
+	busy    atomic.Int64
+	blocked atomic.Int64
+}
+
+func (c *stageCounters) snapshot() StageMetrics {
+	return StageMetrics{
+		Stage:   c.name,
+		Records: c.records.Load(),
+		Rows:    c.rows.Load(),
+		Busy:    time.Duration(c.busy.Load()),
+		Blocked: time.Duration(c.blocked.Load()),
+	}
+}
+
+// Pipeline runs a Source through Transforms into Sinks.
+type Pipeline struct {
+	source     Source
+	transforms []Transform
+	sinks      []Sink
+	bufferSize int
+	counters   []*stageCounters
+}
+
+// New creates a pipeline reading from source, with bufferSize Records buffered between stages.
+func New(source Source, bufferSize int) *Pipeline {
+	if bufferSize <= 0 {
+		bufferSize = DefaultBufferSize
+	}
+	return &Pipeline{
+		source:     source,
+		bufferSize: bufferSize,
+		counters:   []*stageCounters{{name: source.Name()}},
+	}
+}
+
+// Then appends a transform stage. Transforms run in the order they are added, before the sinks.
+func (p *Pipeline) Then(transform Transform) *Pipeline {
+	p.transforms = append(p.transforms, transform)
+	// Transform counters go after the source and the previous transforms, before the sinks.
+	counters := append([]*stageCounters{}, p.counters[:len(p.transforms)]...)
+	counters = append(counters, &stageCounters{name: transform.Name()})
+	p.counters = append(counters, p.counters[len(p.transforms):]...)
+	return p
+}
+
+// To adds a sink. Every sink receives every Record.
+func (p *Pipeline) To(sink Sink) *Pipeline {
+	p.sinks = append(p.sinks, sink)
+	p.counters = append(p.counters, &stageCounters{name: sink.Name()})
+	return p
+}
+
+// Metrics returns the counters of the stages, in pipeline order. It may be called while the
+// pipeline runs.
+func (p *Pipeline) Metrics() []StageMetrics {
+	metrics := make([]StageMetrics, len(p.counters))
+	for i, counters := range p.counters {
+		metrics[i] = counters.snapshot()
+	}
+	return metrics
+}
+
+// Run runs the pipeline until the source is exhausted and every sink consumed its Records. The
+// first stage error cancels the other stages and is returned. A pipeline is run once.
+func (p *Pipeline) Run(ctx context.Context) error {
+	if len(p.sinks) == 0 {
+		return fmt.Errorf("pipeline has no sink")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	errChan := make(chan error, 1)
+	fail := func(stage string, err error) {
+		select {
+		case errChan <- fmt.Errorf("%s: %w", stage, err):
+			cancel()
+		default:
+		}
+	}
+
+	// The source sends into a channel it does not own, so the channel is closed once it returns.
+	sourceOut := make(chan *Record, p.bufferSize)
+	sourceIn := make(chan *Record)
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		defer close(sourceIn)
+		if err := p.source.Produce(ctx, sourceIn); err != nil {
+			fail(p.source.Name(), err)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		defer close(sourceOut)
+		counters := p.counters[0]
+		start := time.Now()
+		for record := range sourceIn {
+			counters.busy.Add(int64(time.Since(start)))
+			counters.records.Add(1)
+			counters.rows.Add(int64(record.Rows()))
+			if !send(ctx, sourceOut, record, counters) {
+				// Drain the source so that it observes the cancellation instead of blocking.
+				drain(sourceIn)
+				return
+			}
+			start = time.Now()
+		}
+	}()
+
+	in := sourceOut
+	for i, transform := range p.transforms {
+		out := make(chan *Record, p.bufferSize)
+		wg.Add(1)
+		go func(transform Transform, counters *stageCounters, in <-chan *Record, out chan<- *Record) {
+			defer wg.Done()
+			defer close(out)
+			for record := range in {
+				start := time.Now()
+				result, err := transform.Apply(ctx, record)
+				counters.busy.Add(int64(time.Since(start)))
+				if err != nil {
+					fail(transform.Name(), err)
+					drain(in)
+					return
+				}
+				if result == nil {
+					continue
+				}
+				counters.records.Add(1)
+				counters.rows.Add(int64(result.Rows()))
+				if !send(ctx, out, result, counters) {
+					drain(in)
+					return
+				}
+			}
+		}(transform, p.counters[1+i], in, out)
+		in = out
+	}
+
+	sinkIns := make([]chan *Record, len(p.sinks))
+	for i, sink := range p.sinks {
+		sinkIns[i] = make(chan *Record, p.bufferSize)
+		wg.Add(1)
+		go func(sink Sink, counters *stageCounters, in <-chan *Record) {
+			defer wg.Done()
+			failed := false
+			for record := range in {
+				if failed {
+					continue
+				}
+				start := time.Now()
+				err := sink.Consume(ctx, record)
+				counters.busy.Add(int64(time.Since(start)))
+				if err != nil {
+					fail(sink.Name(), err)
+					failed = true
+					continue
+				}
+				counters.records.Add(1)
+				counters.rows.Add(int64(record.Rows()))
+			}
+			if err := sink.Close(); err != nil {
+				fail(sink.Name(), err)
+			}
+		}(sink, p.counters[1+len(p.transforms)+i], sinkIns[i])
+	}
+
+	// Fan out to the sinks; the slowest sink sets the pace of the pipeline.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer func() {
+			for _, sinkIn := range sinkIns {
+				close(sinkIn)
+			}
+		}()
+		for record := range in {
+			for _, sinkIn := range sinkIns {
+				select {
+				case sinkIn <- record:
+				case <-ctx.Done():
+					drain(in)
+					return
+				}
+			}
+		}
+	}()
+
+	wg.Wait()
+	close(errChan)
+	if err, ok := <-errChan; ok {
+		return err
+	}
+	return ctx.Err()
+}
+
+// send sends a record downstream, accounting the time it waits as blocked. It returns false if the
+// pipeline was cancelled first.
+func send(ctx context.Context, out chan<- *Record, record *Record, counters *stageCounters) bool {
+	start := time.Now()
+	defer func() { counters.blocked.Add(int64(time.Since(start))) }()
+	select {
+	case out <- record:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func drain(in <-chan *Record) {
+	for range in {
+	}
+}
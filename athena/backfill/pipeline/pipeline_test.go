@@ -0,0 +1,133 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/BlocSoc-iitr/Athena/athena/database/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockFetcher returns one block row per block of the range.
+func blockFetcher(ctx context.Context, fromBlock uint64, toBlock uint64) (*Record, error) {
+	record := &Record{}
+	for number := fromBlock; number <= toBlock; number++ {
+		record.Blocks = append(record.Blocks, models.Block{AbstractBlock: models.AbstractBlock{BlockNumber: number}})
+	}
+	return record, nil
+}
+
+type collectSink struct {
+	mu      sync.Mutex
+	records []*Record
+	closed  bool
+	delay   time.Duration
+	err     error
+}
+
+func (s *collectSink) Name() string {
+	return "collect"
+}
+
+func (s *collectSink) Consume(ctx context.Context, record *Record) error {
+	time.Sleep(s.delay)
+	if s.err != nil {
+		return s.err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = append(s.records, record)
+	return nil
+}
+
+func (s *collectSink) Close() error {
+	s.closed = true
+	return nil
+}
+
+func (s *collectSink) ranges() [][2]uint64 {
+	var ranges [][2]uint64
+	for _, record := range s.records {
+		ranges = append(ranges, [2]uint64{record.FromBlock, record.ToBlock})
+	}
+	return ranges
+}
+
+func TestPipelineRun(t *testing.T) {
+	source := NewBlockRangeSource("blocks", 10, 34, 10, blockFetcher)
+	odd := NewTransform("drop_odd_batches", func(ctx context.Context, record *Record) (*Record, error) {
+		if record.FromBlock == 20 {
+			return nil, nil
+		}
+		return record, nil
+	})
+	first, second := &collectSink{}, &collectSink{}
+	p := New(source, 1).Then(odd).To(first).To(second)
+
+	require.NoError(t, p.Run(context.Background()))
+
+	assert.Equal(t, [][2]uint64{{10, 19}, {30, 34}}, first.ranges())
+	assert.Equal(t, first.ranges(), second.ranges())
+	assert.True(t, first.closed)
+	assert.True(t, second.closed)
+
+	metrics := p.Metrics()
+	require.Len(t, metrics, 4)
+	assert.Equal(t, "blocks", metrics[0].Stage)
+	assert.Equal(t, int64(3), metrics[0].Records)
+	assert.Equal(t, int64(25), metrics[0].Rows)
+	assert.Equal(t, "drop_odd_batches", metrics[1].Stage)
+	assert.Equal(t, int64(2), metrics[1].Records)
+	assert.Equal(t, int64(15), metrics[1].Rows)
+	assert.Equal(t, int64(2), metrics[3].Records)
+}
+
+func TestPipelineBackpressure(t *testing.T) {
+	var mu sync.Mutex
+	fetched := 0
+	source := NewBlockRangeSource("blocks", 0, 99, 1, func(ctx context.Context, fromBlock uint64, toBlock uint64) (*Record, error) {
+		mu.Lock()
+		fetched++
+		mu.Unlock()
+		return blockFetcher(ctx, fromBlock, toBlock)
+	})
+	blocked := make(chan struct{})
+	sink := NewSink("blocked", func(ctx context.Context, record *Record) error {
+		<-blocked
+		return nil
+	})
+	p := New(source, 2).To(sink)
+
+	done := make(chan error)
+	go func() { done <- p.Run(context.Background()) }()
+
+	time.Sleep(100 * time.Millisecond)
+	mu.Lock()
+	// The sink holds one record, the buffers hold two each and the source one more.
+	assert.LessOrEqual(t, fetched, 10)
+	mu.Unlock()
+
+	close(blocked)
+	require.NoError(t, <-done)
+	assert.Equal(t, int64(100), p.Metrics()[1].Records)
+	assert.Greater(t, p.Metrics()[0].Blocked, time.Duration(0))
+}
+
+func TestPipelineError(t *testing.T) {
+	source := NewBlockRangeSource("blocks", 0, 999, 1, blockFetcher)
+	sink := &collectSink{err: errors.New("disk full")}
+	p := New(source, 1).To(sink)
+
+	err := p.Run(context.Background())
+	require.Error(t, err)
+	assert.Equal(t, "collect: disk full", err.Error())
+	assert.True(t, sink.closed)
+	assert.Less(t, p.Metrics()[0].Records, int64(1000))
+
+	err = New(source, 1).Run(context.Background())
+	assert.EqualError(t, err, "pipeline has no sink")
+}
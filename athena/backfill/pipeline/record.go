@@ -0,0 +1,175 @@
+package pipeline
+
+import (
+	"github.com/BlocSoc-iitr/Athena/athena/backfill/importers"
+	"github.com/BlocSoc-iitr/Athena/athena/database/models"
+)
+
+// Kind is a kind of row carried by a Record.
+type Kind string
+
+const (
+	Blocks       Kind = "blocks"
+	Transactions Kind = "transactions"
+	Events       Kind = "events"
+	Transfers    Kind = "transfers"
+	Traces       Kind = "traces"
+	// EmittedEvents are events fetched with starknet_getEvents, see StarknetEventsFetcher. They
+	// carry the block and transaction hash of the events instead of their position.
+	EmittedEvents Kind = "emitted_events"
+)
+
+// The state diff kinds are the rows of the state updates of the blocks of a Record, one kind per
+// state diff table.
+const (
+	StorageDiffs      Kind = "storage_diffs"
+	DeployedContracts Kind = "deployed_contracts"
+	DeclaredClasses   Kind = "declared_classes"
+	ReplacedClasses   Kind = "replaced_classes"
+	NonceUpdates      Kind = "nonce_updates"
+)
+
+// The EVM kinds are the rows imported from Ethereum, see EthereumFetcher, and the zkSync kinds the
+// rows imported from zkSync Era, see ZkSyncFetcher. They are kept apart from the Starknet kinds
+// because they are stored in their own tables.
+const (
+	EVMBlocks       Kind = "evm_blocks"
+	EVMTransactions Kind = "evm_transactions"
+	EVMLogs         Kind = "evm_logs"
+
+	ZkSyncBlocks       Kind = "zksync_blocks"
+	ZkSyncTransactions Kind = "zksync_transactions"
+	ZkSyncLogs         Kind = "zksync_logs"
+	ZkSyncL2ToL1Logs   Kind = "zksync_l2_to_l1_logs"
+)
+
+// Headers are the block headers of a Record, fetched to detect reorgs, and Declarations the
+// classes declared in its blocks, fetched to store their ABIs. They are not exported, so they are
+// not in Kinds.
+const (
+	Headers      Kind = "headers"
+	Declarations Kind = "declarations"
+)
+
+// Kinds lists every kind of row in the order sinks write them.
+var Kinds = []Kind{
+	Blocks, Transactions, Events, Transfers, Traces, EmittedEvents,
+	StorageDiffs, DeployedContracts, DeclaredClasses, ReplacedClasses, NonceUpdates,
+	EVMBlocks, EVMTransactions, EVMLogs,
+	ZkSyncBlocks, ZkSyncTransactions, ZkSyncLogs, ZkSyncL2ToL1Logs,
+}
+
+// Record holds the rows imported for a block range. Sources fill the kinds they import and
+// transforms add, drop or rewrite rows. Every sink receives the same Record, so sinks must not
+// modify it.
+type Record struct {
+	FromBlock     uint64
+	ToBlock       uint64
+	Headers       []importers.BlockHeader
+	Declared      []models.DeclaredClass
+	Blocks        []models.Block
+	Transactions  []models.Transaction
+	Events        []models.DefaultEvent
+	Transfers     []models.ERC20Transfer
+	Traces        []models.Trace
+	EmittedEvents []importers.EmittedEvent
+	StateDiffs    importers.StateDiffRows
+
+	EVMBlocks       []models.EVMBlock
+	EVMTransactions []models.EVMTransaction
+	EVMLogs         []models.EVMLog
+
+	ZkSyncBlocks       []models.ZkSyncBlock
+	ZkSyncTransactions []models.ZkSyncTransaction
+	ZkSyncLogs         []models.ZkSyncLog
+	ZkSyncL2ToL1Logs   []models.ZkSyncL2ToL1Log
+}
+
+// Len returns the number of rows of a kind.
+func (r *Record) Len(kind Kind) int {
+	switch kind {
+	case Blocks:
+		return len(r.Blocks)
+	case Transactions:
+		return len(r.Transactions)
+	case Events:
+		return len(r.Events)
+	case Transfers:
+		return len(r.Transfers)
+	case Traces:
+		return len(r.Traces)
+	case EmittedEvents:
+		return len(r.EmittedEvents)
+	case StorageDiffs:
+		return len(r.StateDiffs.StorageDiffs)
+	case DeployedContracts:
+		return len(r.StateDiffs.DeployedContracts)
+	case DeclaredClasses:
+		return len(r.StateDiffs.DeclaredClasses)
+	case ReplacedClasses:
+		return len(r.StateDiffs.ReplacedClasses)
+	case NonceUpdates:
+		return len(r.StateDiffs.NonceUpdates)
+	case EVMBlocks:
+		return len(r.EVMBlocks)
+	case EVMTransactions:
+		return len(r.EVMTransactions)
+	case EVMLogs:
+		return len(r.EVMLogs)
+	case ZkSyncBlocks:
+		return len(r.ZkSyncBlocks)
+	case ZkSyncTransactions:
+		return len(r.ZkSyncTransactions)
+	case ZkSyncLogs:
+		return len(r.ZkSyncLogs)
+	case ZkSyncL2ToL1Logs:
+		return len(r.ZkSyncL2ToL1Logs)
+	default:
+		return 0
+	}
+}
+
+// Rows returns the number of rows of every kind.
+func (r *Record) Rows() int {
+	rows := 0
+	for _, kind := range Kinds {
+		rows += r.Len(kind)
+	}
+	return rows
+}
+
+// Dicts converts the rows of a kind for a FileResourceExporter.
+func (r *Record) Dicts(kind Kind) []map[string]interface{} {
+	switch kind {
+	case Blocks:
+		return importers.BlocksToDicts(r.Blocks)
+	case Transactions:
+		return importers.TransactionsToDicts(r.Transactions)
+	case Events:
+		return importers.EventsToDicts(r.Events)
+	case Transfers:
+		return importers.TransfersToDicts(r.Transfers)
+	case Traces:
+		return importers.TracesToDicts(r.Traces)
+	case EmittedEvents:
+		return importers.EmittedEventsToDicts(r.EmittedEvents)
+	case StorageDiffs, DeployedContracts, DeclaredClasses, ReplacedClasses, NonceUpdates:
+		return importers.StateDiffsToDicts(r.StateDiffs)[string(kind)]
+	case EVMBlocks:
+		return importers.EVMBlocksToDicts(r.EVMBlocks)
+	case EVMTransactions:
+		return importers.EVMTransactionsToDicts(r.EVMTransactions)
+	case EVMLogs:
+		return importers.EVMLogsToDicts(r.EVMLogs)
+	case ZkSyncBlocks:
+		return importers.ZkSyncBlocksToDicts(r.ZkSyncBlocks)
+	case ZkSyncTransactions:
+		return importers.ZkSyncTransactionsToDicts(r.ZkSyncTransactions)
+	case ZkSyncLogs:
+		return importers.ZkSyncLogsToDicts(r.ZkSyncLogs)
+	case ZkSyncL2ToL1Logs:
+		return importers.ZkSyncL2ToL1LogsToDicts(r.ZkSyncL2ToL1Logs)
+	default:
+		return nil
+	}
+}
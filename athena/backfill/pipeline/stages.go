@@ -0,0 +1,548 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/BlocSoc-iitr/Athena/athena/backfill/importers"
+	"github.com/BlocSoc-iitr/Athena/athena/database/models"
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultBatchSize is the number of blocks per Record of a BlockRangeSource.
+const DefaultBatchSize = 100
+
+// Fetcher imports the rows of a block range into a Record.
+type Fetcher func(ctx context.Context, fromBlock uint64, toBlock uint64) (*Record, error)
+
+// BlockRangeSource imports a block range in batches of blocks, one Record per batch.
+type BlockRangeSource struct {
+	name      string
+	fromBlock uint64
+	toBlock   uint64
+	batchSize uint64
+	fetch     Fetcher
+}
+
+func NewBlockRangeSource(name string, fromBlock uint64, toBlock uint64, batchSize uint64, fetch Fetcher) *BlockRangeSource {
+	if batchSize == 0 {
+		batchSize = DefaultBatchSize
+	}
+	return &BlockRangeSource{
+		name:      name,
+		fromBlock: fromBlock,
+		toBlock:   toBlock,
+		batchSize: batchSize,
+		fetch:     fetch,
+	}
+}
+
+func (s *BlockRangeSource) Name() string {
+	return s.name
+}
+
+func (s *BlockRangeSource) Produce(ctx context.Context, out chan<- *Record) error {
+	for start := s.fromBlock; start <= s.toBlock; start += s.batchSize {
+		end := start + s.batchSize - 1
+		if end > s.toBlock || end < start {
+			end = s.toBlock
+		}
+		record, err := s.fetch(ctx, start, end)
+		if err != nil {
+			return err
+		}
+		record.FromBlock, record.ToBlock = start, end
+		select {
+		case out <- record:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if end == s.toBlock {
+			break
+		}
+	}
+	return nil
+}
+
+// StarknetFetcher imports the given kinds of rows from a Starknet node. Blocks, transactions,
+// events and transfers are all read from one starknet_getBlockWithReceipts call per block, traces
+// from starknet_traceBlockTransactions. Headers are taken from the same blocks, or read with
+// starknet_getBlockWithTxHashes when no other kind needs them. The state diff kinds are read with
+// starknet_getStateUpdate. Declarations are taken from the declared classes of the state diffs when
+// they are fetched, and otherwise from the DECLARE transactions of the blocks. Tokens whose transfers have the layout shared with ERC721
+// are checked once with starknet_call, see importers.TransfersFromBlock.
+func StarknetFetcher(url string, kinds ...Kind) Fetcher {
+	return StarknetFetcherForTokens(url, nil, kinds...)
+}
+
+// StarknetFetcherForTokens is a StarknetFetcher that only imports the transfers of the token contracts,
+// before their layout is checked, so that tokens that are filtered out are never called. It imports
+// every transfer if tokens is empty.
+func StarknetFetcherForTokens(url string, tokens []string, kinds ...Kind) Fetcher {
+	wanted := make(map[Kind]bool, len(kinds))
+	for _, kind := range kinds {
+		wanted[kind] = true
+	}
+
+	stateDiffs := wanted[StorageDiffs] || wanted[DeployedContracts] || wanted[DeclaredClasses] || wanted[ReplacedClasses] || wanted[NonceUpdates]
+	blockDeclarations := wanted[Declarations] && !stateDiffs
+	checker := importers.NewRPCTokenChecker(url)
+
+	return func(ctx context.Context, fromBlock uint64, toBlock uint64) (*Record, error) {
+		record := &Record{}
+		if stateDiffs {
+			rows, err := importers.GetStateDiffs(ctx, url, fromBlock, toBlock)
+			if err != nil {
+				return nil, err
+			}
+			record.StateDiffs = rows
+			if wanted[Declarations] {
+				record.Declared = rows.DeclaredClasses
+			}
+		}
+		if wanted[Blocks] || wanted[Transactions] || wanted[Events] || wanted[Transfers] || blockDeclarations {
+			blocks, err := importers.GetBlocksWithReceipts(ctx, url, fromBlock, toBlock)
+			if err != nil {
+				return nil, err
+			}
+			for _, block := range blocks {
+				if wanted[Headers] {
+					record.Headers = append(record.Headers, importers.HeaderFromBlockWithReceipts(block))
+				}
+				if blockDeclarations {
+					record.Declared = append(record.Declared, importers.DeclaredClassesFromBlock(block)...)
+				}
+				if wanted[Blocks] {
+					row, err := importers.BlockFromBlockWithReceipts(block)
+					if err != nil {
+						return nil, err
+					}
+					record.Blocks = append(record.Blocks, row)
+				}
+				if wanted[Transactions] {
+					rows, err := importers.TransactionsFromBlock(block)
+					if err != nil {
+						return nil, err
+					}
+					record.Transactions = append(record.Transactions, rows...)
+				}
+				if wanted[Events] {
+					record.Events = append(record.Events, importers.EventsFromBlock(block)...)
+				}
+				if wanted[Transfers] {
+					rows, err := importers.TransfersFromBlock(ctx, block, checker, tokens...)
+					if err != nil {
+						return nil, err
+					}
+					record.Transfers = append(record.Transfers, rows...)
+				}
+			}
+		} else if wanted[Headers] {
+			headers, err := importers.GetBlockHeaders(ctx, url, fromBlock, toBlock)
+			if err != nil {
+				return nil, err
+			}
+			record.Headers = headers
+		}
+		if wanted[Traces] {
+			traces, err := importers.GetTraces(ctx, url, fromBlock, toBlock)
+			if err != nil {
+				return nil, err
+			}
+			record.Traces = traces
+		}
+		return record, nil
+	}
+}
+
+// StarknetEventsFetcher imports the events passing filter with starknet_getEvents into the
+// EmittedEvents of Records, see importers.GetFilteredEvents. The blocks of each Record are split into
+// sub-ranges of filter.BlockRange blocks fetched by filter.Workers workers per contract. Headers are
+// read with starknet_getBlockWithTxHashes if kinds include them.
+func StarknetEventsFetcher(url string, filter importers.EventFilter, kinds ...Kind) Fetcher {
+	wanted := make(map[Kind]bool, len(kinds))
+	for _, kind := range kinds {
+		wanted[kind] = true
+	}
+
+	return func(ctx context.Context, fromBlock uint64, toBlock uint64) (*Record, error) {
+		record := &Record{}
+		if wanted[EmittedEvents] {
+			filter := filter
+			filter.FromBlock, filter.ToBlock = fromBlock, toBlock
+			events, err := importers.GetFilteredEvents(ctx, url, filter)
+			if err != nil {
+				return nil, err
+			}
+			record.EmittedEvents = events
+		}
+		if wanted[Headers] {
+			headers, err := importers.GetBlockHeaders(ctx, url, fromBlock, toBlock)
+			if err != nil {
+				return nil, err
+			}
+			record.Headers = headers
+		}
+		return record, nil
+	}
+}
+
+// EthereumFetcher imports the EVM kinds of rows from an Ethereum node. Transactions are read with
+// eth_getBlockByNumber joined with the receipts of eth_getBlockReceipts, together with the blocks
+// and, if kinds include them, the logs of the receipts. Without transactions, blocks are read with
+// eth_getBlockByNumber only and logs with eth_getLogs, restricted by filter.
+func EthereumFetcher(url string, filter importers.EthLogFilter, kinds ...Kind) Fetcher {
+	wanted := make(map[Kind]bool, len(kinds))
+	for _, kind := range kinds {
+		wanted[kind] = true
+	}
+
+	return func(ctx context.Context, fromBlock uint64, toBlock uint64) (*Record, error) {
+		record := &Record{}
+		switch {
+		case wanted[EVMTransactions]:
+			blocks, err := importers.GetEthFullBlocks(ctx, url, fromBlock, toBlock)
+			if err != nil {
+				return nil, err
+			}
+			for _, block := range blocks {
+				if wanted[EVMBlocks] {
+					row, err := importers.EthBlockToModel(block.Block)
+					if err != nil {
+						return nil, err
+					}
+					record.EVMBlocks = append(record.EVMBlocks, row)
+				}
+				rows, err := importers.EthTransactionsFromBlock(block)
+				if err != nil {
+					return nil, err
+				}
+				record.EVMTransactions = append(record.EVMTransactions, rows...)
+				if wanted[EVMLogs] {
+					record.EVMLogs = append(record.EVMLogs, importers.EthLogsFromBlock(block)...)
+				}
+			}
+			return record, nil
+		case wanted[EVMBlocks]:
+			blocks, err := importers.GetEthBlocks(ctx, url, fromBlock, toBlock)
+			if err != nil {
+				return nil, err
+			}
+			for _, block := range blocks {
+				row, err := importers.EthBlockToModel(block)
+				if err != nil {
+					return nil, err
+				}
+				record.EVMBlocks = append(record.EVMBlocks, row)
+			}
+		}
+		if wanted[EVMLogs] {
+			logs, err := importers.GetEthLogs(ctx, url, fromBlock, toBlock, filter.Addresses, filter.Topics, filter.BlockRange)
+			if err != nil {
+				return nil, err
+			}
+			record.EVMLogs = importers.EthLogsToModels(logs)
+		}
+		return record, nil
+	}
+}
+
+// ZkSyncFetcher imports the zkSync kinds of rows from a zkSync Era node, as EthereumFetcher does
+// for Ethereum. L2 to L1 logs are taken from the receipts, so they are only imported with
+// transactions.
+func ZkSyncFetcher(url string, filter importers.EthLogFilter, kinds ...Kind) Fetcher {
+	wanted := make(map[Kind]bool, len(kinds))
+	for _, kind := range kinds {
+		wanted[kind] = true
+	}
+
+	return func(ctx context.Context, fromBlock uint64, toBlock uint64) (*Record, error) {
+		record := &Record{}
+		switch {
+		case wanted[ZkSyncTransactions]:
+			blocks, err := importers.GetZkSyncFullBlocks(ctx, url, fromBlock, toBlock)
+			if err != nil {
+				return nil, err
+			}
+			for _, block := range blocks {
+				if wanted[ZkSyncBlocks] {
+					row, err := importers.ZkSyncBlockToModel(block.Block)
+					if err != nil {
+						return nil, err
+					}
+					record.ZkSyncBlocks = append(record.ZkSyncBlocks, row)
+				}
+				rows, err := importers.ZkSyncTransactionsFromBlock(block)
+				if err != nil {
+					return nil, err
+				}
+				record.ZkSyncTransactions = append(record.ZkSyncTransactions, rows...)
+				if wanted[ZkSyncLogs] {
+					record.ZkSyncLogs = append(record.ZkSyncLogs, importers.ZkSyncLogsFromBlock(block)...)
+				}
+				if wanted[ZkSyncL2ToL1Logs] {
+					record.ZkSyncL2ToL1Logs = append(record.ZkSyncL2ToL1Logs, importers.ZkSyncL2ToL1LogsFromBlock(block)...)
+				}
+			}
+			return record, nil
+		case wanted[ZkSyncBlocks]:
+			blocks, err := importers.GetZkSyncBlocks(ctx, url, fromBlock, toBlock)
+			if err != nil {
+				return nil, err
+			}
+			for _, block := range blocks {
+				row, err := importers.ZkSyncBlockToModel(block)
+				if err != nil {
+					return nil, err
+				}
+				record.ZkSyncBlocks = append(record.ZkSyncBlocks, row)
+			}
+		}
+		if wanted[ZkSyncLogs] {
+			logs, err := importers.GetEthLogs(ctx, url, fromBlock, toBlock, filter.Addresses, filter.Topics, filter.BlockRange)
+			if err != nil {
+				return nil, err
+			}
+			record.ZkSyncLogs = importers.ZkSyncLogsFromEVMLogs(importers.EthLogsToModels(logs))
+		}
+		return record, nil
+	}
+}
+
+type transformFunc struct {
+	name  string
+	apply func(ctx context.Context, record *Record) (*Record, error)
+}
+
+// NewTransform creates a transform stage from a function.
+func NewTransform(name string, apply func(ctx context.Context, record *Record) (*Record, error)) Transform {
+	return &transformFunc{name: name, apply: apply}
+}
+
+func (t *transformFunc) Name() string {
+	return t.name
+}
+
+func (t *transformFunc) Apply(ctx context.Context, record *Record) (*Record, error) {
+	return t.apply(ctx, record)
+}
+
+// PopulateAbis stores the ABIs of the classes declared in the blocks of each Record, so that the
+// events of contracts of these classes can be decoded, also by a later transform of the pipeline.
+func PopulateAbis(populator *importers.AbiPopulator) Transform {
+	return NewTransform("populate_abis", func(ctx context.Context, record *Record) (*Record, error) {
+		if _, err := populator.PopulateClasses(ctx, record.Declared); err != nil {
+			return nil, err
+		}
+		return record, nil
+	})
+}
+
+// DecodeEvents decodes the events of Records with the ABIs of their contracts. Events that cannot
+// be decoded are logged and left undecoded, as in importers.GetEvents.
+func DecodeEvents(decoder *importers.EventDecoder) Transform {
+	return NewTransform("decode_events", func(ctx context.Context, record *Record) (*Record, error) {
+		for i := range record.Events {
+			event := &record.Events[i]
+			if err := decoder.Decode(ctx, event); err != nil {
+				logrus.WithFields(logrus.Fields{
+					"block_number":      event.BlockNumber,
+					"transaction_index": event.TransactionIndex,
+					"event_index":       event.EventIndex,
+				}).Warnf("failed to decode event: %v", err)
+			}
+		}
+		return record, nil
+	})
+}
+
+// DecodeEVMLogs decodes the EVM and zkSync logs of Records with the ABIs of their contracts. Logs
+// that cannot be decoded are left undecoded, see importers.EVMLogDecoder.
+func DecodeEVMLogs(decoder *importers.EVMLogDecoder) Transform {
+	return NewTransform("decode_evm_logs", func(ctx context.Context, record *Record) (*Record, error) {
+		if err := decoder.DecodeLogs(ctx, record.EVMLogs); err != nil {
+			return nil, err
+		}
+		if len(record.ZkSyncLogs) > 0 {
+			logs := make([]models.EVMLog, len(record.ZkSyncLogs))
+			for i, zkLog := range record.ZkSyncLogs {
+				logs[i] = zkLog.EVMLog
+			}
+			if err := decoder.DecodeLogs(ctx, logs); err != nil {
+				return nil, err
+			}
+			record.ZkSyncLogs = importers.ZkSyncLogsFromEVMLogs(logs)
+		}
+		return record, nil
+	})
+}
+
+// DetectReorgs verifies that the blocks of each Record continue the chain ingested so far, from
+// the Record headers. On a reorg the transform fails with an *importers.ReorgError, so that the
+// range can be imported again from the fork point. The detector store is not rolled back: Records
+// verified before may still reach the sink, so the caller rolls it back once the pipeline stopped.
+func DetectReorgs(detector *importers.ReorgDetector) Transform {
+	return NewTransform("detect_reorgs", func(ctx context.Context, record *Record) (*Record, error) {
+		if err := detector.Verify(ctx, record.Headers); err != nil {
+			return nil, err
+		}
+		return record, nil
+	})
+}
+
+// FilterEvents keeps the events and emitted events of Records that pass the address and key
+// filters of filter.
+func FilterEvents(filter importers.EventFilter) Transform {
+	return NewTransform("filter_events", func(ctx context.Context, record *Record) (*Record, error) {
+		events := make([]models.DefaultEvent, 0, len(record.Events))
+		for _, event := range record.Events {
+			if filter.Matches(event.ContractAddress, event.Keys) {
+				events = append(events, event)
+			}
+		}
+		record.Events = events
+		emitted := make([]importers.EmittedEvent, 0, len(record.EmittedEvents))
+		for _, event := range record.EmittedEvents {
+			if filter.Matches(event.FromAddress, event.Keys) {
+				emitted = append(emitted, event)
+			}
+		}
+		record.EmittedEvents = emitted
+		return record, nil
+	})
+}
+
+// FilterTransfers keeps the transfers of Records emitted by one of the token contracts. It keeps
+// every transfer if tokens is empty.
+func FilterTransfers(tokens ...string) Transform {
+	tokenSet := make(map[string]bool, len(tokens))
+	for _, token := range tokens {
+		tokenSet[importers.NormalizeAddress(token)] = true
+	}
+	return NewTransform("filter_transfers", func(ctx context.Context, record *Record) (*Record, error) {
+		if len(tokenSet) == 0 {
+			return record, nil
+		}
+		transfers := make([]models.ERC20Transfer, 0, len(record.Transfers))
+		for _, transfer := range record.Transfers {
+			if tokenSet[importers.NormalizeAddress(transfer.TokenAddress)] {
+				transfers = append(transfers, transfer)
+			}
+		}
+		record.Transfers = transfers
+		return record, nil
+	})
+}
+
+// RowWriter writes exporter rows. backfill.FileResourceExporter is a RowWriter.
+type RowWriter interface {
+	Write(rows []map[string]interface{}) error
+	Close() error
+}
+
+// RangeWriter is a RowWriter that is told once all the rows up to a block have been written, such
+// as backfill.PartitionedResourceExporter, which completes its partitions on it.
+type RangeWriter interface {
+	RowWriter
+	Complete(toBlock uint64) error
+}
+
+// Rollbacker is a sink or RowWriter that can discard the rows of the blocks from a block on, after
+// a reorg. Rollback returns the first block whose rows must be written again, at most fromBlock.
+type Rollbacker interface {
+	Rollback(fromBlock uint64) (uint64, error)
+}
+
+// FileSink writes each kind of row to its own RowWriter. Kinds without a writer are not exported.
+// RangeWriters are completed up to the last block of each Record, including Records without rows.
+type FileSink struct {
+	writers map[Kind]RowWriter
+}
+
+func NewFileSink(writers map[Kind]RowWriter) *FileSink {
+	return &FileSink{writers: writers}
+}
+
+func (s *FileSink) Name() string {
+	return "file_export"
+}
+
+func (s *FileSink) Consume(ctx context.Context, record *Record) error {
+	for _, kind := range Kinds {
+		writer, ok := s.writers[kind]
+		if !ok {
+			continue
+		}
+		if record.Len(kind) > 0 {
+			if err := writer.Write(record.Dicts(kind)); err != nil {
+				return fmt.Errorf("failed to export %s of blocks %d to %d: %w", kind, record.FromBlock, record.ToBlock, err)
+			}
+		}
+		if rangeWriter, ok := writer.(RangeWriter); ok {
+			if err := rangeWriter.Complete(record.ToBlock); err != nil {
+				return fmt.Errorf("failed to complete %s up to block %d: %w", kind, record.ToBlock, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Rollback discards the rows of the blocks from fromBlock on from every writer, and returns the
+// first block the writers must be written again from. It fails if a writer is not a Rollbacker,
+// such as a single file, whose rows cannot be removed once written.
+func (s *FileSink) Rollback(fromBlock uint64) (uint64, error) {
+	restart := fromBlock
+	for _, kind := range Kinds {
+		writer, ok := s.writers[kind]
+		if !ok {
+			continue
+		}
+		rollbacker, ok := writer.(Rollbacker)
+		if !ok {
+			return 0, fmt.Errorf("cannot remove the %s of blocks from %d on from a %T, export partitions to roll back reorgs", kind, fromBlock, writer)
+		}
+		block, err := rollbacker.Rollback(fromBlock)
+		if err != nil {
+			return 0, fmt.Errorf("failed to roll back %s from block %d: %w", kind, fromBlock, err)
+		}
+		restart = min(restart, block)
+	}
+	return restart, nil
+}
+
+// Close closes every writer, and returns their errors. A writer that fails to close may leave an
+// incomplete file.
+func (s *FileSink) Close() error {
+	var errs []error
+	for _, kind := range Kinds {
+		if writer, ok := s.writers[kind]; ok {
+			if err := writer.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("failed to close the %s export: %w", kind, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+type sinkFunc struct {
+	name    string
+	consume func(ctx context.Context, record *Record) error
+}
+
+// NewSink creates a sink stage from a function. Its Close does nothing.
+func NewSink(name string, consume func(ctx context.Context, record *Record) error) Sink {
+	return &sinkFunc{name: name, consume: consume}
+}
+
+func (s *sinkFunc) Name() string {
+	return s.name
+}
+
+func (s *sinkFunc) Consume(ctx context.Context, record *Record) error {
+	return s.consume(ctx, record)
+}
+
+func (s *sinkFunc) Close() error {
+	return nil
+}
@@ -0,0 +1,364 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/BlocSoc-iitr/Athena/athena/backfill/importers"
+	"github.com/BlocSoc-iitr/Athena/athena/database/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newBlocksServer serves starknet_getBlockWithReceipts for blocks with one transaction emitting a
+// transfer of 0xa and an event of 0xb.
+func newBlocksServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Params struct {
+				BlockID struct {
+					BlockNumber uint64 `json:"block_number"`
+				} `json:"block_id"`
+			} `json:"params"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		number := req.Params.BlockID.BlockNumber
+		block := fmt.Sprintf(`{
+			"block_number": %d,
+			"block_hash": "0x%x",
+			"transactions": [
+				{"transaction": {"transaction_hash": "0x%x1"}, "receipt": {"transaction_hash": "0x%x1", "execution_status": "SUCCEEDED", "events": [
+					{"from_address": "0xa", "keys": ["%s", "0x1", "0x2"], "data": ["0x%x", "0x0"]},
+					{"from_address": "0xb", "keys": ["0x99"], "data": []}
+				]}}
+			]
+		}`, number, number, number, number, importers.TransferSelector, number)
+		fmt.Fprintf(w, `{"jsonrpc": "2.0", "id": 1, "result": %s}`, block)
+	}))
+}
+
+type memoryWriter struct {
+	rows      []map[string]interface{}
+	completed []uint64
+	closed    bool
+	closeErr  error
+}
+
+func (w *memoryWriter) Write(rows []map[string]interface{}) error {
+	w.rows = append(w.rows, rows...)
+	return nil
+}
+
+func (w *memoryWriter) Complete(toBlock uint64) error {
+	w.completed = append(w.completed, toBlock)
+	return nil
+}
+
+func (w *memoryWriter) Close() error {
+	w.closed = true
+	return w.closeErr
+}
+
+func TestStarknetPipeline(t *testing.T) {
+	server := newBlocksServer(t)
+	defer server.Close()
+
+	source := NewBlockRangeSource("fetch", 1, 5, 2, StarknetFetcher(server.URL, Events, Transfers))
+	events, transfers := &memoryWriter{}, &memoryWriter{}
+	sink := NewFileSink(map[Kind]RowWriter{Events: events, Transfers: transfers})
+	p := New(source, 0).Then(FilterEvents(importers.EventFilter{Addresses: []string{"0xb"}})).To(sink)
+
+	require.NoError(t, p.Run(context.Background()))
+
+	require.Len(t, events.rows, 5)
+	for i, row := range events.rows {
+		assert.Equal(t, i+1, row["block_number"])
+		assert.Equal(t, "0xb", row["contract_address"])
+	}
+	require.Len(t, transfers.rows, 5)
+	assert.Equal(t, "3", transfers.rows[2]["value"])
+	assert.Equal(t, []uint64{2, 4, 5}, events.completed)
+	assert.True(t, events.closed)
+	assert.True(t, transfers.closed)
+
+	metrics := p.Metrics()
+	assert.Equal(t, int64(3), metrics[0].Records)
+	assert.Equal(t, int64(15), metrics[0].Rows)
+	assert.Equal(t, int64(10), metrics[2].Rows)
+}
+
+func TestStarknetFetcherError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"jsonrpc": "2.0", "id": 1, "error": {"code": 24, "message": "Block not found"}}`)
+	}))
+	defer server.Close()
+
+	p := New(NewBlockRangeSource("fetch", 1, 5, 2, StarknetFetcher(server.URL, Blocks)), 0).To(NewFileSink(nil))
+	err := p.Run(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "fetch: ")
+}
+
+func TestStarknetEventsFetcher(t *testing.T) {
+	// The server returns one event of the filtered contract per block of the requested range.
+	var ranges [][2]uint64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Params struct {
+				Filter struct {
+					FromBlock struct {
+						BlockNumber uint64 `json:"block_number"`
+					} `json:"from_block"`
+					ToBlock struct {
+						BlockNumber uint64 `json:"block_number"`
+					} `json:"to_block"`
+					Address string `json:"address"`
+				} `json:"filter"`
+			} `json:"params"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		filter := req.Params.Filter
+		assert.Equal(t, "0xa", filter.Address)
+		ranges = append(ranges, [2]uint64{filter.FromBlock.BlockNumber, filter.ToBlock.BlockNumber})
+		var events []importers.EmittedEvent
+		for number := filter.FromBlock.BlockNumber; number <= filter.ToBlock.BlockNumber; number++ {
+			events = append(events, importers.EmittedEvent{FromAddress: "0xa", Keys: []string{"0x99"}, BlockNumber: number, TransactionHash: fmt.Sprintf("0x%x1", number)})
+		}
+		result, err := json.Marshal(map[string]interface{}{"events": events})
+		require.NoError(t, err)
+		fmt.Fprintf(w, `{"jsonrpc": "2.0", "id": 1, "result": %s}`, result)
+	}))
+	defer server.Close()
+
+	filter := importers.EventFilter{Addresses: []string{"0xa"}, Workers: 1}
+	source := NewBlockRangeSource("fetch", 1, 5, 2, StarknetEventsFetcher(server.URL, filter, EmittedEvents))
+	events := &memoryWriter{}
+	require.NoError(t, New(source, 0).To(NewFileSink(map[Kind]RowWriter{EmittedEvents: events})).Run(context.Background()))
+
+	assert.Equal(t, [][2]uint64{{1, 2}, {3, 4}, {5, 5}}, ranges)
+	require.Len(t, events.rows, 5)
+	for i, row := range events.rows {
+		assert.Equal(t, i+1, row["block_number"])
+		assert.Equal(t, fmt.Sprintf("0x%x1", i+1), row["transaction_hash"])
+	}
+	assert.Equal(t, []uint64{2, 4, 5}, events.completed)
+}
+
+func TestStarknetFetcherStateDiffs(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string `json:"method"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, "starknet_getStateUpdate", req.Method, "declarations are taken from the state diffs")
+		fmt.Fprint(w, `{"jsonrpc": "2.0", "id": 1, "result": {"state_diff": {
+			"storage_diffs": [{"address": "0xa", "storage_entries": [{"key": "0x1", "value": "0x5"}]}],
+			"deployed_contracts": [], "declared_classes": [{"class_hash": "0xc1", "compiled_class_hash": "0xcc1"}],
+			"deprecated_declared_classes": [], "replaced_classes": [], "nonces": []
+		}}}`)
+	}))
+	defer server.Close()
+
+	record, err := StarknetFetcher(server.URL, StorageDiffs, DeclaredClasses, Declarations)(context.Background(), 1, 2)
+	require.NoError(t, err)
+	assert.Equal(t, 2, record.Len(StorageDiffs))
+	assert.Equal(t, 2, record.Len(DeclaredClasses))
+	require.Len(t, record.Declared, 2)
+	assert.Equal(t, "0xcc1", record.Declared[0].CompiledClassHash.String)
+	rows := record.Dicts(StorageDiffs)
+	require.Len(t, rows, 2)
+	assert.Equal(t, "0x5", rows[0]["storage_value"])
+}
+
+func TestEthereumFetcher(t *testing.T) {
+	var methods []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		methods = append(methods, req.Method)
+		switch req.Method {
+		case "eth_getBlockByNumber":
+			fmt.Fprint(w, `{"jsonrpc": "2.0", "id": 1, "result": {"number": "0x1", "hash": "0xb1", "transactions": [{"hash": "0xt1", "value": "0x0"}]}}`)
+		case "eth_getBlockReceipts":
+			fmt.Fprint(w, `{"jsonrpc": "2.0", "id": 1, "result": [{"transactionHash": "0xt1", "status": "0x1",
+				"logs": [{"address": "0xA", "topics": ["0xddf2"], "blockNumber": "0x1", "transactionIndex": "0x0", "logIndex": "0x0"}]}]}`)
+		case "eth_getLogs":
+			var filter map[string]interface{}
+			require.NoError(t, json.Unmarshal(req.Params[0], &filter))
+			assert.Equal(t, []interface{}{"0xa"}, filter["address"])
+			fmt.Fprint(w, `{"jsonrpc": "2.0", "id": 1, "result": [{"address": "0xA", "topics": ["0xddf2"], "blockNumber": "0x1", "transactionIndex": "0x0", "logIndex": "0x1"}]}`)
+		default:
+			t.Errorf("unexpected method %s", req.Method)
+		}
+	}))
+	defer server.Close()
+	filter := importers.EthLogFilter{Addresses: []string{"0xa"}}
+	ctx := context.Background()
+
+	record, err := EthereumFetcher(server.URL, filter, EVMBlocks, EVMTransactions, EVMLogs)(ctx, 1, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"eth_getBlockByNumber", "eth_getBlockReceipts"}, methods, "logs are taken from the receipts")
+	assert.Equal(t, []int{1, 1, 1}, []int{record.Len(EVMBlocks), record.Len(EVMTransactions), record.Len(EVMLogs)})
+	assert.Equal(t, 0, record.EVMLogs[0].EventIndex)
+
+	methods = nil
+	record, err = EthereumFetcher(server.URL, filter, EVMLogs)(ctx, 1, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"eth_getLogs"}, methods)
+	require.Len(t, record.EVMLogs, 1)
+	assert.Equal(t, 1, record.EVMLogs[0].EventIndex)
+	assert.Equal(t, "0xa", record.Dicts(EVMLogs)[0]["contract_address"])
+}
+
+func TestZkSyncFetcher(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string `json:"method"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		switch req.Method {
+		case "eth_getBlockByNumber":
+			fmt.Fprint(w, `{"jsonrpc": "2.0", "id": 1, "result": {"number": "0x1", "hash": "0xb1", "l1BatchNumber": "0x1f4",
+				"transactions": [{"hash": "0xt1", "value": "0x0", "l1BatchNumber": "0x1f4"}]}}`)
+		case "eth_getBlockReceipts":
+			fmt.Fprint(w, `{"jsonrpc": "2.0", "id": 1, "result": [{"transactionHash": "0xt1", "status": "0x1",
+				"logs": [{"address": "0xA", "topics": ["0xddf2"], "blockNumber": "0x1", "logIndex": "0x0"}],
+				"l2ToL1Logs": [{"blockNumber": "0x1", "transactionHash": "0xt1", "logIndex": "0x2", "sender": "0x800A"}]}]}`)
+		default:
+			t.Errorf("unexpected method %s", req.Method)
+		}
+	}))
+	defer server.Close()
+
+	record, err := ZkSyncFetcher(server.URL, importers.EthLogFilter{}, ZkSyncBlocks, ZkSyncTransactions, ZkSyncLogs, ZkSyncL2ToL1Logs)(context.Background(), 1, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 1, 1, 1}, []int{record.Len(ZkSyncBlocks), record.Len(ZkSyncTransactions), record.Len(ZkSyncLogs), record.Len(ZkSyncL2ToL1Logs)})
+	assert.Equal(t, int64(500), record.ZkSyncBlocks[0].L1BatchNumber.Int64)
+	assert.Equal(t, 2, record.ZkSyncL2ToL1Logs[0].LogIndex)
+	assert.Equal(t, "0x800a", record.Dicts(ZkSyncL2ToL1Logs)[0]["sender"])
+}
+
+func TestDecodeEVMLogs(t *testing.T) {
+	dir := t.TempDir()
+	token := "0x5aea5775959fbc2557cc8789bc1bf90a239d9a91"
+	abi := `[{"type": "event", "name": "Transfer", "anonymous": false, "inputs": [
+		{"name": "from", "type": "address", "indexed": true},
+		{"name": "to", "type": "address", "indexed": true},
+		{"name": "value", "type": "uint256", "indexed": false}
+	]}]`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, token+".json"), []byte(abi), 0644))
+
+	zkLog := models.ZkSyncLog{}
+	zkLog.ContractAddress = token
+	zkLog.Topics = []string{
+		"0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef",
+		"0x000000000000000000000000000000000000000000000000000000000000800a",
+		"0x000000000000000000000000abcdefabcdefabcdefabcdefabcdefabcdefabcd",
+	}
+	zkLog.Data = "0x0000000000000000000000000000000000000000000000000de0b6b3a7640000"
+	record := &Record{ZkSyncLogs: []models.ZkSyncLog{zkLog}}
+
+	decoder := importers.NewEVMLogDecoder(importers.NewFileAbiSource(dir, importers.EVMDecoderOS))
+	record, err := DecodeEVMLogs(decoder).Apply(context.Background(), record)
+	require.NoError(t, err)
+	assert.Equal(t, "Transfer", record.ZkSyncLogs[0].EventName.String)
+	assert.Equal(t, "1000000000000000000", record.ZkSyncLogs[0].DecodedParams["value"])
+}
+
+func TestFileSinkCloseError(t *testing.T) {
+	server := newBlocksServer(t)
+	defer server.Close()
+
+	// A writer that fails to flush its last rows fails the pipeline, and the other writers are
+	// still closed.
+	events := &memoryWriter{closeErr: errors.New("failed to write the footer")}
+	transfers := &memoryWriter{}
+	sink := NewFileSink(map[Kind]RowWriter{Events: events, Transfers: transfers})
+	err := New(NewBlockRangeSource("fetch", 1, 2, 2, StarknetFetcher(server.URL, Events, Transfers)), 0).To(sink).Run(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to write the footer")
+	assert.True(t, transfers.closed)
+}
+
+// rollbackWriter is a memoryWriter that restarts its rows at restart on rollbacks.
+type rollbackWriter struct {
+	memoryWriter
+	restart uint64
+}
+
+func (w *rollbackWriter) Rollback(fromBlock uint64) (uint64, error) {
+	return min(fromBlock, w.restart), nil
+}
+
+func TestFileSinkRollback(t *testing.T) {
+	sink := NewFileSink(map[Kind]RowWriter{Events: &rollbackWriter{restart: 8}, Transfers: &rollbackWriter{restart: 20}})
+	restart, err := sink.Rollback(10)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(8), restart)
+
+	// Rows written to a single file cannot be removed.
+	sink = NewFileSink(map[Kind]RowWriter{Events: &rollbackWriter{restart: 8}, Transfers: &memoryWriter{}})
+	_, err = sink.Rollback(10)
+	assert.ErrorContains(t, err, "cannot remove the transfers of blocks from 10 on")
+}
+
+func TestFilterTransfers(t *testing.T) {
+	record := &Record{Transfers: []models.ERC20Transfer{{AbstractERC20Transfer: models.AbstractERC20Transfer{TokenAddress: "0x0a"}}, {AbstractERC20Transfer: models.AbstractERC20Transfer{TokenAddress: "0xb"}}}}
+	filtered, err := FilterTransfers("0xA").Apply(context.Background(), record)
+	require.NoError(t, err)
+	require.Len(t, filtered.Transfers, 1)
+	assert.Equal(t, "0x0a", filtered.Transfers[0].TokenAddress)
+
+	record = &Record{Transfers: []models.ERC20Transfer{{AbstractERC20Transfer: models.AbstractERC20Transfer{TokenAddress: "0xa"}}, {AbstractERC20Transfer: models.AbstractERC20Transfer{TokenAddress: "0xb"}}}}
+	unfiltered, err := FilterTransfers().Apply(context.Background(), record)
+	require.NoError(t, err)
+	assert.Len(t, unfiltered.Transfers, 2)
+}
+
+func TestPopulateAbis(t *testing.T) {
+	erc20Abi, err := os.ReadFile("../../../athena_abi/abis/v2/erc20_compiled.json")
+	require.NoError(t, err)
+	encodedAbi, err := json.Marshal(string(erc20Abi))
+	require.NoError(t, err)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string `json:"method"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		switch req.Method {
+		case "starknet_getClass":
+			fmt.Fprintf(w, `{"jsonrpc": "2.0", "id": 1, "result": {"sierra_program": [], "abi": %s}}`, encodedAbi)
+		default:
+			fmt.Fprint(w, `{"jsonrpc": "2.0", "id": 1, "result": {"block_number": 7, "block_hash": "0x7", "transactions": [
+				{"transaction": {"transaction_hash": "0x71", "type": "DECLARE", "class_hash": "0xa1"}, "receipt": {"execution_status": "SUCCEEDED", "events": []}},
+				{"transaction": {"transaction_hash": "0x72", "type": "DECLARE", "class_hash": "0xa2"}, "receipt": {"execution_status": "REVERTED", "events": []}}
+			]}}`)
+		}
+	}))
+	defer server.Close()
+
+	registry := importers.NewAbiRegistry(nil)
+	source := NewBlockRangeSource("fetch", 7, 7, 0, StarknetFetcher(server.URL, Events, Declarations))
+	sink := NewSink("discard", func(ctx context.Context, record *Record) error { return nil })
+	p := New(source, 0).Then(PopulateAbis(importers.NewAbiPopulator(server.URL, nil, registry))).To(sink)
+	require.NoError(t, p.Run(context.Background()))
+
+	abi, err := registry.Get("0xa1")
+	require.NoError(t, err)
+	require.NotNil(t, abi)
+	assert.Contains(t, abi.Events, "Transfer")
+	abi, err = registry.Get("0xa2")
+	require.NoError(t, err)
+	assert.Nil(t, abi)
+}
@@ -0,0 +1,158 @@
+package backfill
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+const (
+	// DefaultS3PartSize is the size of the parts of multipart uploads. Files up to it are uploaded
+	// in a single request.
+	DefaultS3PartSize = 64 << 20
+	// DefaultS3Retries is the number of times a failed upload is retried.
+	DefaultS3Retries = 3
+	// DefaultS3RetryDelay is the delay before the first retry, doubled for each of the next ones.
+	DefaultS3RetryDelay = time.Second
+)
+
+// permanentS3Errors are the S3 error codes of uploads that fail again when retried.
+var permanentS3Errors = map[string]bool{
+	"AccessDenied":          true,
+	"InvalidAccessKeyId":    true,
+	"InvalidBucketName":     true,
+	"NoSuchBucket":          true,
+	"SignatureDoesNotMatch": true,
+}
+
+// S3Options configure an S3PartitionStore.
+type S3Options struct {
+	// Endpoint is the host of the S3-compatible service, such as s3.amazonaws.com or localhost:9000.
+	Endpoint  string
+	Bucket    string
+	Prefix    string
+	Region    string
+	AccessKey string
+	SecretKey string
+	// Insecure connects over HTTP instead of HTTPS.
+	Insecure bool
+	// PartSize is the size of the parts of multipart uploads, at least 5 MiB. 0 uses DefaultS3PartSize.
+	PartSize uint64
+	// Retries is the number of times a failed upload is retried. Each request of an upload is also
+	// retried on transient errors by the S3 client. 0 uses DefaultS3Retries, a negative value none.
+	Retries    int
+	RetryDelay time.Duration
+	// Transport is the HTTP transport of the client, the default one if nil.
+	Transport http.RoundTripper
+}
+
+// S3PartitionStore uploads the partitions and manifest of a PartitionedResourceExporter to an
+// S3-compatible bucket, under Prefix.
+type S3PartitionStore struct {
+	client  *minio.Client
+	options S3Options
+}
+
+func NewS3PartitionStore(options S3Options) (*S3PartitionStore, error) {
+	if options.Endpoint == "" || options.Bucket == "" {
+		return nil, errors.New("S3 endpoint and bucket are required")
+	}
+	if options.PartSize == 0 {
+		options.PartSize = DefaultS3PartSize
+	}
+	if options.Retries == 0 {
+		options.Retries = DefaultS3Retries
+	}
+	if options.RetryDelay == 0 {
+		options.RetryDelay = DefaultS3RetryDelay
+	}
+
+	client, err := minio.New(options.Endpoint, &minio.Options{
+		Creds:     credentials.NewStaticV4(options.AccessKey, options.SecretKey, ""),
+		Secure:    !options.Insecure,
+		Region:    options.Region,
+		Transport: options.Transport,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the S3 client: %w", err)
+	}
+	return &S3PartitionStore{client: client, options: options}, nil
+}
+
+func (s *S3PartitionStore) key(name string) string {
+	return path.Join(s.options.Prefix, name)
+}
+
+// PutFile uploads a local file as name, in parts if it is larger than the part size. Failed
+// uploads are retried with an exponential backoff, unless the error is permanent.
+func (s *S3PartitionStore) PutFile(ctx context.Context, name string, fileName string) error {
+	options := minio.PutObjectOptions{
+		ContentType: contentTypeOf(name),
+		PartSize:    s.options.PartSize,
+	}
+	delay := s.options.RetryDelay
+	for attempt := 0; ; attempt++ {
+		_, err := s.client.FPutObject(ctx, s.options.Bucket, s.key(name), fileName, options)
+		if err == nil {
+			return nil
+		}
+		if attempt >= s.options.Retries || permanentS3Errors[minio.ToErrorResponse(err).Code] {
+			return fmt.Errorf("failed to upload %s to s3://%s/%s: %w", fileName, s.options.Bucket, s.key(name), err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// GetManifest downloads the manifest of the prefix. It returns an error wrapping os.ErrNotExist if
+// there is none.
+func (s *S3PartitionStore) GetManifest(ctx context.Context) (*Manifest, error) {
+	object, err := s.client.GetObject(ctx, s.options.Bucket, s.key(ManifestFileName), minio.GetObjectOptions{})
+	if err == nil {
+		defer object.Close()
+		var data []byte
+		data, err = io.ReadAll(object)
+		if err == nil {
+			var manifest Manifest
+			if err := json.Unmarshal(data, &manifest); err != nil {
+				return nil, fmt.Errorf("invalid manifest in s3://%s/%s: %w", s.options.Bucket, s.key(ManifestFileName), err)
+			}
+			return &manifest, nil
+		}
+	}
+	if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+		return nil, fmt.Errorf("no manifest in s3://%s/%s: %w", s.options.Bucket, s.options.Prefix, os.ErrNotExist)
+	}
+	return nil, err
+}
+
+func contentTypeOf(name string) string {
+	switch {
+	case strings.HasSuffix(name, ".csv"):
+		return "text/csv"
+	case strings.HasSuffix(name, ".jsonl"):
+		return "application/x-ndjson"
+	case strings.HasSuffix(name, ".gz"):
+		return "application/gzip"
+	case strings.HasSuffix(name, ".parquet"):
+		return "application/vnd.apache.parquet"
+	case strings.HasSuffix(name, ".json"):
+		return "application/json"
+	default:
+		return "application/octet-stream"
+	}
+}
@@ -0,0 +1,249 @@
+package backfill
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/BlocSoc-iitr/Athena/athena/database/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeS3 is a MinIO-style stand-in for an S3 bucket, serving the object and multipart upload
+// requests of the S3 client with path-style addressing.
+type fakeS3 struct {
+	mu      sync.Mutex
+	bucket  string
+	objects map[string][]byte
+	// stored lists the keys in the order their upload completed.
+	stored     []string
+	parts      map[string]map[int][]byte
+	multiparts int
+	// failures is the number of object and part uploads that fail before the next ones succeed.
+	// They fail with an error that the S3 client does not retry itself.
+	failures int
+}
+
+func newFakeS3(t *testing.T, bucket string) (*fakeS3, *httptest.Server) {
+	s3 := &fakeS3{bucket: bucket, objects: map[string][]byte{}, parts: map[string]map[int][]byte{}}
+	server := httptest.NewTLSServer(s3)
+	t.Cleanup(server.Close)
+	return s3, server
+}
+
+func (s *fakeS3) error(w http.ResponseWriter, status int, code string) {
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>%s</Code><Message>%s</Message></Error>`, code, code)
+}
+
+func (s *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != s.bucket {
+		s.error(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+	query := r.URL.Query()
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		s.error(w, http.StatusBadRequest, "IncompleteBody")
+		return
+	}
+	if r.Method == http.MethodPut && s.failures > 0 {
+		s.failures--
+		s.error(w, http.StatusBadRequest, "IncompleteBody")
+		return
+	}
+	etag := func(data []byte) string {
+		sum := md5.Sum(data)
+		return `"` + hex.EncodeToString(sum[:]) + `"`
+	}
+
+	switch {
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		uploadID := fmt.Sprintf("upload-%d", len(s.parts)+1)
+		s.parts[uploadID] = map[int][]byte{}
+		fmt.Fprintf(w, `<InitiateMultipartUploadResult><Bucket>%s</Bucket><Key>%s</Key><UploadId>%s</UploadId></InitiateMultipartUploadResult>`, bucket, key, uploadID)
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		partNumber, _ := strconv.Atoi(query.Get("partNumber"))
+		s.parts[query.Get("uploadId")][partNumber] = body
+		w.Header().Set("ETag", etag(body))
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		parts := s.parts[query.Get("uploadId")]
+		numbers := make([]int, 0, len(parts))
+		for number := range parts {
+			numbers = append(numbers, number)
+		}
+		sort.Ints(numbers)
+		var object []byte
+		for _, number := range numbers {
+			object = append(object, parts[number]...)
+		}
+		s.objects[key] = object
+		s.stored = append(s.stored, key)
+		s.multiparts++
+		fmt.Fprintf(w, `<CompleteMultipartUploadResult><Bucket>%s</Bucket><Key>%s</Key><ETag>%s</ETag></CompleteMultipartUploadResult>`, bucket, key, etag(object))
+	case r.Method == http.MethodPut:
+		s.objects[key] = body
+		s.stored = append(s.stored, key)
+		w.Header().Set("ETag", etag(body))
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		object, ok := s.objects[key]
+		if !ok {
+			s.error(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Header().Set("ETag", etag(object))
+		w.Header().Set("Content-Length", strconv.Itoa(len(object)))
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		http.ServeContent(w, r, key, time.Now(), bytes.NewReader(object))
+	default:
+		s.error(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+func newTestS3Store(t *testing.T, server *httptest.Server, bucket string, prefix string) *S3PartitionStore {
+	store, err := NewS3PartitionStore(S3Options{
+		Endpoint:   server.Listener.Addr().String(),
+		Bucket:     bucket,
+		Prefix:     prefix,
+		Region:     "us-east-1",
+		AccessKey:  "minio",
+		SecretKey:  "minio123",
+		PartSize:   5 << 20,
+		RetryDelay: time.Millisecond,
+		Transport:  server.Client().Transport,
+	})
+	require.NoError(t, err)
+	return store
+}
+
+func TestS3PartitionStore(t *testing.T) {
+	s3, server := newFakeS3(t, "backfills")
+	store := newTestS3Store(t, server, "backfills", "starknet/events")
+	ctx := context.Background()
+
+	_, err := store.GetManifest(ctx)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	// Files larger than the part size are uploaded in parts, and failed uploads are retried.
+	fileName := filepath.Join(t.TempDir(), "000000000-000009999.csv")
+	data := bytes.Repeat([]byte("690000,0x49d3\n"), 500000)
+	require.NoError(t, os.WriteFile(fileName, data, 0644))
+	s3.failures = 1
+	require.NoError(t, store.PutFile(ctx, "000000000-000009999.csv", fileName))
+	assert.Equal(t, 1, s3.multiparts)
+	assert.Equal(t, data, s3.objects["starknet/events/000000000-000009999.csv"])
+
+	s3.failures = 100
+	err = store.PutFile(ctx, "000000000-000009999.csv", fileName)
+	assert.ErrorContains(t, err, "failed to upload")
+	s3.failures = 0
+
+	missing := newTestS3Store(t, server, "other", "")
+	err = missing.PutFile(ctx, "000000000-000009999.csv", fileName)
+	assert.ErrorContains(t, err, "NoSuchBucket")
+}
+
+func TestPartitionedResourceExporterS3(t *testing.T) {
+	s3, server := newFakeS3(t, "backfills")
+	store := newTestS3Store(t, server, "backfills", "blocks")
+	partition := PartitionOptions{Blocks: 10, Store: store, RemoveStored: true}
+
+	dir := filepath.Join(t.TempDir(), "blocks")
+	exporter, err := NewPartitionedResourceExporter(dir, ".jsonl.gz", models.Block{}, 0, ExportOptions{}, partition)
+	require.NoError(t, err)
+	require.NoError(t, exporter.Write(blockRows(1, 2, 15)))
+	require.NoError(t, exporter.Complete(24))
+	require.NoError(t, exporter.Close())
+
+	// Each partition is uploaded before the manifest listing it. Blocks 20 to 24 have no rows, so
+	// their partition has no file.
+	assert.Equal(t, []string{
+		"blocks/000000000-000000009.jsonl.gz", "blocks/manifest.json",
+		"blocks/000000010-000000019.jsonl.gz", "blocks/manifest.json",
+		"blocks/manifest.json",
+	}, s3.stored)
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	// A backfill resumes from the stored manifest when the export directory is gone.
+	exporter, err = NewPartitionedResourceExporter(filepath.Join(t.TempDir(), "blocks"), ".jsonl.gz", models.Block{}, 0, ExportOptions{}, partition)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(25), exporter.NextBlock())
+	require.NoError(t, exporter.Close())
+
+	// A partition that fails to upload is not listed in the manifest.
+	s3.failures = 100
+	exporter, err = NewPartitionedResourceExporter(dir, ".jsonl.gz", models.Block{}, 0, ExportOptions{}, partition)
+	require.NoError(t, err)
+	require.NoError(t, exporter.Write(blockRows(26)))
+	assert.ErrorContains(t, exporter.Complete(29), "failed to upload")
+	require.NoError(t, exporter.Close())
+	s3.failures = 0
+	manifest, err := store.GetManifest(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, manifest.Partitions, 3)
+	assert.Equal(t, uint64(25), manifest.NextBlock(0))
+}
+
+func TestPartitionedResourceExporterUploadsLocalPartitions(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "blocks")
+	exporter, err := NewPartitionedResourceExporter(dir, ".csv", models.Block{}, 0, ExportOptions{}, PartitionOptions{Blocks: 10})
+	require.NoError(t, err)
+	require.NoError(t, exporter.Write(blockRows(1, 15)))
+	require.NoError(t, exporter.Complete(24))
+	require.NoError(t, exporter.Close())
+
+	// The partitions exported before the store was used are uploaded before the manifest listing
+	// them, and only then are new partitions exported.
+	s3, server := newFakeS3(t, "backfills")
+	store := newTestS3Store(t, server, "backfills", "blocks")
+	exporter, err = NewPartitionedResourceExporter(dir, ".csv", models.Block{}, 0, ExportOptions{}, PartitionOptions{Blocks: 10, Store: store})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(25), exporter.NextBlock())
+	assert.Equal(t, []string{
+		"blocks/000000000-000000009.csv", "blocks/000000010-000000019.csv", "blocks/manifest.json",
+	}, s3.stored)
+	require.NoError(t, exporter.Write(blockRows(26)))
+	require.NoError(t, exporter.Complete(29))
+	require.NoError(t, exporter.Close())
+
+	manifest, err := store.GetManifest(context.Background())
+	require.NoError(t, err)
+	require.Len(t, manifest.Partitions, 4)
+	for _, partition := range manifest.Partitions {
+		if partition.File != "" {
+			assert.Contains(t, s3.objects, "blocks/"+partition.File)
+		}
+	}
+
+	// Partitions that were only stored are merged into the local manifest.
+	other := filepath.Join(t.TempDir(), "blocks")
+	exporter, err = NewPartitionedResourceExporter(other, ".csv", models.Block{}, 0, ExportOptions{}, PartitionOptions{Blocks: 10})
+	require.NoError(t, err)
+	require.NoError(t, exporter.Write(blockRows(3)))
+	require.NoError(t, exporter.Complete(9))
+	require.NoError(t, exporter.Close())
+	exporter, err = NewPartitionedResourceExporter(other, ".csv", models.Block{}, 0, ExportOptions{}, PartitionOptions{Blocks: 10, Store: store})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(30), exporter.NextBlock())
+	require.NoError(t, exporter.Close())
+}
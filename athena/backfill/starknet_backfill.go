@@ -0,0 +1,213 @@
+package backfill
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/BlocSoc-iitr/Athena/athena/backfill/importers"
+	"github.com/BlocSoc-iitr/Athena/athena/backfill/pipeline"
+	"github.com/BlocSoc-iitr/Athena/athena/types"
+	"gorm.io/gorm"
+)
+
+// pipelineKinds maps the resources of GetFileExportersForBackfill to the rows of pipeline Records.
+var pipelineKinds = map[string]pipeline.Kind{
+	"blocks":       pipeline.Blocks,
+	"transactions": pipeline.Transactions,
+	"events":       pipeline.Events,
+	"transfers":    pipeline.Transfers,
+	"traces":       pipeline.Traces,
+
+	"storage_diffs":      pipeline.StorageDiffs,
+	"deployed_contracts": pipeline.DeployedContracts,
+	"declared_classes":   pipeline.DeclaredClasses,
+	"replaced_classes":   pipeline.ReplacedClasses,
+	"nonce_updates":      pipeline.NonceUpdates,
+}
+
+// NewFileSinkForBackfill creates a pipeline sink writing each resource of a backfill type to the
+// exporter GetFileExportersForBackfill selects for it from kwargs. It also returns the kinds of rows
+// the sink exports, to be fetched by the source.
+func NewFileSinkForBackfill(backfillType BackfillDataType, kwargs map[string]interface{}) (*pipeline.FileSink, []pipeline.Kind, error) {
+	exporters, err := GetFileExportersForBackfill(backfillType, kwargs)
+	if err != nil {
+		return nil, nil, err
+	}
+	writers := make(map[pipeline.Kind]pipeline.RowWriter, len(exporters))
+	var kinds []pipeline.Kind
+	for _, kind := range pipeline.Kinds {
+		for resource, exporter := range exporters {
+			if pipelineKinds[resource] == kind {
+				writers[kind] = exporter
+				kinds = append(kinds, kind)
+			}
+		}
+	}
+	if len(writers) != len(exporters) {
+		return nil, nil, fmt.Errorf("backfill type %s cannot be exported from a pipeline", backfillType)
+	}
+	return pipeline.NewFileSink(writers), kinds, nil
+}
+
+// dbBackfillTypes are the backfill types a DBResourceExporter writes from a pipeline, with the
+// kinds of rows of each.
+var dbBackfillTypes = map[BackfillDataType]struct {
+	dataType types.BackfillDataType
+	kinds    []pipeline.Kind
+}{
+	FullBlocks:   {types.FullBlocks, []pipeline.Kind{pipeline.Blocks, pipeline.Transactions, pipeline.Events}},
+	Blocks:       {types.Blocks, []pipeline.Kind{pipeline.Blocks}},
+	Transactions: {types.Transactions, []pipeline.Kind{pipeline.Blocks, pipeline.Transactions}},
+	Events:       {types.Events, []pipeline.Kind{pipeline.Events}},
+	Transfers:    {types.Transfers, []pipeline.Kind{pipeline.Transfers}},
+	Traces:       {types.Traces, []pipeline.Kind{pipeline.Traces}},
+	StateDiffs: {types.StateDiffs, []pipeline.Kind{
+		pipeline.StorageDiffs, pipeline.DeployedContracts, pipeline.DeclaredClasses, pipeline.ReplacedClasses, pipeline.NonceUpdates,
+	}},
+}
+
+// NewSinkForBackfill creates the pipeline sink of a Starknet backfill type and returns the kinds of
+// rows it exports. When kwargs["export_mode"] is DBModels, the rows are written to the *gorm.DB
+// kwargs["db"] by a DBResourceExporter, recorded as the backfill kwargs["backfill_id"] with
+// kwargs["db_batch_size"] rows per insert and the filters kwargs["filter_data"] if set. Otherwise the rows are written to the file
+// exporters selected by NewFileSinkForBackfill.
+func NewSinkForBackfill(backfillType BackfillDataType, kwargs map[string]interface{}) (pipeline.Sink, []pipeline.Kind, error) {
+	if mode, _ := kwargs["export_mode"].(ExportMode); mode != DBModels {
+		return NewFileSinkForBackfill(backfillType, kwargs)
+	}
+	dbType, ok := dbBackfillTypes[backfillType]
+	if !ok {
+		return nil, nil, fmt.Errorf("backfill type %s cannot be exported to the database", backfillType)
+	}
+	db, ok := kwargs["db"].(*gorm.DB)
+	if !ok {
+		return nil, nil, BackfillError
+	}
+	backfillID, _ := kwargs["backfill_id"].(string)
+	batchSize, _ := kwargs["db_batch_size"].(int)
+	exporter := NewDBResourceExporter(db, backfillID, dbType.dataType, types.StarkNet, batchSize)
+	if filterData, ok := kwargs["filter_data"].(map[string]interface{}); ok {
+		exporter.SetFilterData(filterData)
+	}
+	return exporter, dbType.kinds, nil
+}
+
+// StarknetBackfill imports Starknet block ranges through a pipeline: the Kinds of rows are fetched
+// in batches of BatchSize blocks, passed through the Transforms and written to the Sink. If
+// Detector is set, the blocks of each batch are verified against the blocks imported before them.
+// If Populator is set, the ABIs of the classes declared in each batch are stored before the
+// Transforms run. If Tokens is set, only the transfers of these token contracts are imported. If
+// EventFilter is set, the Kinds are fetched by pipeline.StarknetEventsFetcher with the filter
+// instead of from full blocks.
+type StarknetBackfill struct {
+	RPCURL      string
+	Kinds       []pipeline.Kind
+	Tokens      []string
+	EventFilter *importers.EventFilter
+	BatchSize   uint64
+	Transforms  []pipeline.Transform
+	Sink        pipeline.Sink
+	Detector    *importers.ReorgDetector
+	Populator   *importers.AbiPopulator
+}
+
+// Import imports the blocks fromBlock to toBlock without closing the sink, so that consecutive
+// ranges can be imported into it. On a reorg the pipeline is stopped before the Detector rolls its
+// store back to the fork point, so that no rows of the blocks after it are written once rolled
+// back. A sink that is a pipeline.Rollbacker, such as a FileSink of partitioned exports, then
+// discards the rows it wrote past the fork point, and the blocks after the fork point are imported
+// again.
+func (b *StarknetBackfill) Import(ctx context.Context, fromBlock uint64, toBlock uint64) error {
+	for {
+		sink := &openSink{Sink: b.Sink, next: fromBlock}
+		err := b.run(ctx, fromBlock, toBlock, sink)
+		var reorgErr *importers.ReorgError
+		if !errors.As(err, &reorgErr) {
+			return err
+		}
+		if err := b.Detector.Rollback(&reorgErr.Event); err != nil {
+			return err
+		}
+		// Verified batches may not have reached the sink when the pipeline stopped.
+		fromBlock = min(reorgErr.Event.RolledBackFrom, sink.next)
+		if rollbacker, ok := b.Sink.(pipeline.Rollbacker); ok && sink.next > reorgErr.Event.RolledBackFrom {
+			restart, err := rollbacker.Rollback(reorgErr.Event.RolledBackFrom)
+			if err != nil {
+				return fmt.Errorf("failed to roll back the blocks from %d on: %w", reorgErr.Event.RolledBackFrom, err)
+			}
+			fromBlock = min(fromBlock, restart)
+		}
+	}
+}
+
+// Run imports the blocks fromBlock to toBlock and closes the sink.
+func (b *StarknetBackfill) Run(ctx context.Context, fromBlock uint64, toBlock uint64) error {
+	err := b.Import(ctx, fromBlock, toBlock)
+	return errors.Join(err, b.Sink.Close())
+}
+
+// Follow imports the blocks confirmed from cfg.StartBlock on as the chain grows, see FollowBlocks,
+// until the killer fires or an import fails. Followed blocks go through the same pipeline and sink
+// as the blocks of Import, and the sink is not closed.
+func (b *StarknetBackfill) Follow(ctx context.Context, cfg FollowConfig, killer *GraceFullkiller) error {
+	return FollowBlocks(ctx, cfg, killer, func(fromBlock uint64, toBlock uint64) error {
+		if err := b.Import(ctx, fromBlock, toBlock); err != nil {
+			return err
+		}
+		fmt.Printf("[%s] Ingested blocks %d to %d\n", time.Now().Format(time.RFC3339), fromBlock, toBlock)
+		return nil
+	})
+}
+
+func (b *StarknetBackfill) run(ctx context.Context, fromBlock uint64, toBlock uint64, sink pipeline.Sink) error {
+	kinds := append([]pipeline.Kind{}, b.Kinds...)
+	if b.Detector != nil {
+		kinds = append(kinds, pipeline.Headers)
+	}
+	if b.Populator != nil {
+		kinds = append(kinds, pipeline.Declarations)
+	}
+	fetcher := pipeline.StarknetFetcherForTokens(b.RPCURL, b.Tokens, kinds...)
+	if b.EventFilter != nil {
+		fetcher = pipeline.StarknetEventsFetcher(b.RPCURL, *b.EventFilter, kinds...)
+	}
+	source := pipeline.NewBlockRangeSource("fetch", fromBlock, toBlock, b.BatchSize, fetcher)
+	p := pipeline.New(source, pipeline.DefaultBufferSize)
+	if b.Detector != nil {
+		p.Then(pipeline.DetectReorgs(b.Detector))
+	}
+	if b.Populator != nil {
+		p.Then(pipeline.PopulateAbis(b.Populator))
+	}
+	for _, transform := range b.Transforms {
+		p.Then(transform)
+	}
+	p.To(sink)
+
+	err := p.Run(ctx)
+	for _, metrics := range p.Metrics() {
+		fmt.Println(metrics)
+	}
+	return err
+}
+
+// openSink leaves its sink open when a pipeline closes it, so that the sink outlives the pipeline.
+// next is the first block not consumed by the sink yet.
+type openSink struct {
+	pipeline.Sink
+	next uint64
+}
+
+func (s *openSink) Consume(ctx context.Context, record *pipeline.Record) error {
+	if err := s.Sink.Consume(ctx, record); err != nil {
+		return err
+	}
+	s.next = record.ToBlock + 1
+	return nil
+}
+
+func (s *openSink) Close() error {
+	return nil
+}
@@ -0,0 +1,170 @@
+package backfill
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/BlocSoc-iitr/Athena/athena/backfill/importers"
+	"github.com/BlocSoc-iitr/Athena/athena/backfill/pipeline"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStarknetChain serves empty Starknet blocks whose hashes can be replaced to simulate a reorg.
+type fakeStarknetChain struct {
+	mu     sync.Mutex
+	hashes map[uint64]string
+}
+
+func (c *fakeStarknetChain) setHash(blockNumber uint64, hash string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.hashes[blockNumber] = hash
+}
+
+func (c *fakeStarknetChain) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Params struct {
+			BlockID struct {
+				BlockNumber uint64 `json:"block_number"`
+			} `json:"block_id"`
+		} `json:"params"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	number := req.Params.BlockID.BlockNumber
+	block := map[string]interface{}{
+		"block_number": number,
+		"block_hash":   c.hashes[number],
+		"parent_hash":  c.hashes[number-1],
+		"transactions": []interface{}{},
+	}
+	c.mu.Unlock()
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"result":  block,
+	})
+}
+
+func TestStarknetBackfillReimportsAfterReorg(t *testing.T) {
+	chain := &fakeStarknetChain{hashes: map[uint64]string{}}
+	for i := uint64(0); i <= 10; i++ {
+		chain.setHash(i, fmt.Sprintf("0x%x", 0x100+i))
+	}
+	server := httptest.NewServer(chain)
+	defer server.Close()
+
+	var imported []uint64
+	closed := false
+	sink := pipeline.NewSink("collect", func(ctx context.Context, record *pipeline.Record) error {
+		for _, header := range record.Headers {
+			imported = append(imported, header.BlockNumber)
+		}
+		return nil
+	})
+	b := &StarknetBackfill{
+		RPCURL:    server.URL,
+		Kinds:     []pipeline.Kind{pipeline.Events},
+		BatchSize: 2,
+		Sink:      closeFunc{Sink: sink, close: func() { closed = true }},
+		Detector:  importers.NewReorgDetector(server.URL, importers.NewMemoryBlockHashStore(), 0),
+	}
+
+	require.NoError(t, b.Import(context.Background(), 0, 5))
+	assert.False(t, closed)
+
+	// Blocks 4 and 5 are replaced by a fork branching off block 3.
+	chain.setHash(4, "0xb4")
+	chain.setHash(5, "0xb5")
+	chain.setHash(6, "0xb6")
+
+	require.NoError(t, b.Run(context.Background(), 6, 7))
+	assert.True(t, closed)
+	assert.Equal(t, []uint64{0, 1, 2, 3, 4, 5, 4, 5, 6, 7}, imported)
+}
+
+// rollbackSink collects the blocks of the headers it consumes, and removes them on rollbacks from
+// the first block of their batch of two blocks, as a partitioned export would.
+type rollbackSink struct {
+	pipeline.Sink
+	blocks    []uint64
+	rollbacks []uint64
+}
+
+func newRollbackSink() *rollbackSink {
+	sink := &rollbackSink{}
+	sink.Sink = pipeline.NewSink("collect", func(ctx context.Context, record *pipeline.Record) error {
+		for _, header := range record.Headers {
+			sink.blocks = append(sink.blocks, header.BlockNumber)
+		}
+		return nil
+	})
+	return sink
+}
+
+func (s *rollbackSink) Rollback(fromBlock uint64) (uint64, error) {
+	s.rollbacks = append(s.rollbacks, fromBlock)
+	restart := fromBlock / 2 * 2
+	for len(s.blocks) > 0 && s.blocks[len(s.blocks)-1] >= restart {
+		s.blocks = s.blocks[:len(s.blocks)-1]
+	}
+	return restart, nil
+}
+
+func TestStarknetBackfillRollsBackSinkAfterReorg(t *testing.T) {
+	chain := &fakeStarknetChain{hashes: map[uint64]string{}}
+	for i := uint64(0); i <= 10; i++ {
+		chain.setHash(i, fmt.Sprintf("0x%x", 0x100+i))
+	}
+	server := httptest.NewServer(chain)
+	defer server.Close()
+
+	store := importers.NewMemoryBlockHashStore()
+	sink := newRollbackSink()
+	b := &StarknetBackfill{
+		RPCURL:    server.URL,
+		Kinds:     []pipeline.Kind{pipeline.Events},
+		BatchSize: 2,
+		Sink:      sink,
+		Detector:  importers.NewReorgDetector(server.URL, store, 0),
+	}
+	require.NoError(t, b.Import(context.Background(), 0, 5))
+
+	// Blocks 5 and 6 are replaced by a fork branching off block 4.
+	chain.setHash(5, "0xb5")
+	chain.setHash(6, "0xb6")
+	chain.setHash(7, "0xb7")
+	var storedAtRollback []bool
+	b.Detector.OnReorg(func(event importers.ReorgEvent) {
+		_, ok, _ := store.BlockHash(5)
+		storedAtRollback = append(storedAtRollback, ok)
+	})
+
+	require.NoError(t, b.Import(context.Background(), 6, 7))
+	assert.Equal(t, []uint64{5}, sink.rollbacks)
+	assert.Equal(t, []bool{false}, storedAtRollback)
+	// Block 4 is imported again, since the sink discarded the batch of blocks 4 and 5.
+	assert.Equal(t, []uint64{0, 1, 2, 3, 4, 5, 6, 7}, sink.blocks)
+	hash, ok, _ := store.BlockHash(5)
+	assert.True(t, ok)
+	assert.Equal(t, "0xb5", hash)
+}
+
+type closeFunc struct {
+	pipeline.Sink
+	close func()
+}
+
+func (s closeFunc) Close() error {
+	s.close()
+	return s.Sink.Close()
+}
@@ -0,0 +1,276 @@
+package backfill
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BlocSoc-iitr/Athena/athena/backfill/importers"
+	"github.com/BlocSoc-iitr/Athena/athena/database/readers"
+	"github.com/BlocSoc-iitr/Athena/athena/database/writers"
+	"github.com/BlocSoc-iitr/Athena/athena/types"
+)
+
+// DateFlagUsage describes the values accepted by ParseDateFlag, for CLI flag help texts.
+const DateFlagUsage = "a UTC date such as 2024-01-31, or an RFC 3339 time such as 2024-01-31T12:00:00Z"
+
+var dateLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05"}
+
+// ParseDateFlag parses the value of a CLI date flag. A date without a time is the start of the day,
+// or its last second when endOfDay is set so that a to-date includes the whole day.
+func ParseDateFlag(value string, endOfDay bool) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if day, err := time.Parse("2006-01-02", value); err == nil {
+		if endOfDay {
+			return day.Add(24*time.Hour - time.Second), nil
+		}
+		return day, nil
+	}
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q, expected %s", value, DateFlagUsage)
+}
+
+// BlockTimestampSource returns the timestamp of a block.
+type BlockTimestampSource func(ctx context.Context, blockNumber uint64) (time.Time, error)
+
+// TimestampResolver finds the blocks of timestamps. It starts from the samples of the block
+// timestamp cache, interpolates between the two samples around a timestamp and refines the
+// guess with header lookups. Looked up headers are added to the samples, see Samples.
+type TimestampResolver struct {
+	timestampOf BlockTimestampSource
+	head        func(ctx context.Context) (uint64, error)
+	samples     []types.BlockTimestamp
+	fetched     []types.BlockTimestamp
+}
+
+// NewTimestampResolver creates a resolver for an RPC endpoint, starting from the given samples.
+func NewTimestampResolver(network Network, rpcURL string, samples []types.BlockTimestamp) *TimestampResolver {
+	return newTimestampResolver(
+		func(ctx context.Context, blockNumber uint64) (time.Time, error) {
+			return blockTimestamp(ctx, network, rpcURL, blockNumber)
+		},
+		func(ctx context.Context) (uint64, error) {
+			return currentBlockNumber(ctx, network, rpcURL)
+		},
+		samples,
+	)
+}
+
+func newTimestampResolver(timestampOf BlockTimestampSource, head func(ctx context.Context) (uint64, error), samples []types.BlockTimestamp) *TimestampResolver {
+	r := &TimestampResolver{timestampOf: timestampOf, head: head}
+	for _, sample := range samples {
+		r.add(sample)
+	}
+	return r
+}
+
+// add inserts a sample, keeping the samples sorted by block number.
+func (r *TimestampResolver) add(sample types.BlockTimestamp) {
+	i := sort.Search(len(r.samples), func(i int) bool { return r.samples[i].BlockNumber >= sample.BlockNumber })
+	if i < len(r.samples) && r.samples[i].BlockNumber == sample.BlockNumber {
+		return
+	}
+	r.samples = append(r.samples, types.BlockTimestamp{})
+	copy(r.samples[i+1:], r.samples[i:])
+	r.samples[i] = sample
+}
+
+// fetch returns the timestamp of a block from the samples, or looks it up if it is not sampled yet.
+func (r *TimestampResolver) fetch(ctx context.Context, blockNumber uint64) (types.BlockTimestamp, error) {
+	i := sort.Search(len(r.samples), func(i int) bool { return r.samples[i].BlockNumber >= int(blockNumber) })
+	if i < len(r.samples) && r.samples[i].BlockNumber == int(blockNumber) {
+		return r.samples[i], nil
+	}
+	timestamp, err := r.timestampOf(ctx, blockNumber)
+	if err != nil {
+		return types.BlockTimestamp{}, err
+	}
+	sample := types.BlockTimestamp{BlockNumber: int(blockNumber), Timestamp: timestamp}
+	r.add(sample)
+	r.fetched = append(r.fetched, sample)
+	return sample, nil
+}
+
+// Samples returns the block timestamps looked up over RPC since the resolver was created.
+func (r *TimestampResolver) Samples() []types.BlockTimestamp {
+	return r.fetched
+}
+
+// FirstBlockAtOrAfter returns the first block with a timestamp at or after t.
+func (r *TimestampResolver) FirstBlockAtOrAfter(ctx context.Context, t time.Time) (uint64, error) {
+	head, err := r.head(ctx)
+	if err != nil {
+		return 0, err
+	}
+	last, err := r.fetch(ctx, head)
+	if err != nil {
+		return 0, err
+	}
+	if last.Timestamp.Before(t) {
+		return 0, fmt.Errorf("%s is after the latest block %d", t.Format(time.RFC3339), head)
+	}
+
+	// lo is the last sample before t, hi the first sample at or after t.
+	var lo, hi types.BlockTimestamp
+	i := sort.Search(len(r.samples), func(i int) bool { return !r.samples[i].Timestamp.Before(t) })
+	hi = r.samples[i]
+	if i == 0 {
+		if hi.BlockNumber == 0 {
+			return 0, nil
+		}
+		lo, err = r.fetch(ctx, 0)
+		if err != nil {
+			return 0, err
+		}
+		if !lo.Timestamp.Before(t) {
+			return 0, nil
+		}
+	} else {
+		lo = r.samples[i-1]
+	}
+
+	for step := 0; hi.BlockNumber-lo.BlockNumber > 1; step++ {
+		// Interpolation converges quickly on steady block times; every other step bisects so
+		// that irregular block times cannot stall the search.
+		guess := lo.BlockNumber + (hi.BlockNumber-lo.BlockNumber)/2
+		if step%2 == 0 {
+			span := hi.Timestamp.Sub(lo.Timestamp)
+			if span > 0 {
+				guess = lo.BlockNumber + int(float64(hi.BlockNumber-lo.BlockNumber)*float64(t.Sub(lo.Timestamp))/float64(span))
+			}
+		}
+		if guess <= lo.BlockNumber {
+			guess = lo.BlockNumber + 1
+		}
+		if guess >= hi.BlockNumber {
+			guess = hi.BlockNumber - 1
+		}
+
+		sample, err := r.fetch(ctx, uint64(guess))
+		if err != nil {
+			return 0, err
+		}
+		if sample.Timestamp.Before(t) {
+			lo = sample
+		} else {
+			hi = sample
+		}
+	}
+	return uint64(hi.BlockNumber), nil
+}
+
+// LastBlockAtOrBefore returns the last block with a timestamp at or before t.
+func (r *TimestampResolver) LastBlockAtOrBefore(ctx context.Context, t time.Time) (uint64, error) {
+	head, err := r.head(ctx)
+	if err != nil {
+		return 0, err
+	}
+	last, err := r.fetch(ctx, head)
+	if err != nil {
+		return 0, err
+	}
+	if !last.Timestamp.After(t) {
+		return head, nil
+	}
+	next, err := r.FirstBlockAtOrAfter(ctx, t.Add(time.Second))
+	if err != nil {
+		return 0, err
+	}
+	if next == 0 {
+		return 0, fmt.Errorf("%s is before the first block", t.Format(time.RFC3339))
+	}
+	return next - 1, nil
+}
+
+// ResolveDateRange returns the blocks from the first block at or after fromDate to the last block at
+// or before toDate. Zero times are left unresolved and returned as 0. The timestamps looked up on
+// the way are added to the block timestamp cache of the network.
+func ResolveDateRange(ctx context.Context, network Network, rpcURL string, fromDate time.Time, toDate time.Time) (uint64, uint64, error) {
+	if fromDate.IsZero() && toDate.IsZero() {
+		return 0, 0, nil
+	}
+	supportedNetwork, err := network.SupportedNetwork()
+	if err != nil {
+		return 0, 0, err
+	}
+	resolver := NewTimestampResolver(network, rpcURL, readers.GetBlockTimestamps(nil, supportedNetwork, 1, 0))
+	defer func() {
+		if len(resolver.Samples()) > 0 {
+			writers.WriteBlockTimestamps(resolver.Samples(), supportedNetwork)
+		}
+	}()
+
+	var fromBlock, toBlock uint64
+	if !fromDate.IsZero() {
+		if fromBlock, err = resolver.FirstBlockAtOrAfter(ctx, fromDate); err != nil {
+			return 0, 0, fmt.Errorf("failed to resolve from date: %v", err)
+		}
+	}
+	if !toDate.IsZero() {
+		if toBlock, err = resolver.LastBlockAtOrBefore(ctx, toDate); err != nil {
+			return 0, 0, fmt.Errorf("failed to resolve to date: %v", err)
+		}
+	}
+	return fromBlock, toBlock, nil
+}
+
+// SupportedNetwork returns the network as used by the database readers and writers.
+func (n Network) SupportedNetwork() (types.SupportedNetwork, error) {
+	switch n {
+	case Starknet:
+		return types.StarkNet, nil
+	case Ethereum:
+		return types.Ethereum, nil
+	case ZkSyncEra:
+		return types.ZkSyncEra, nil
+	default:
+		return 0, fmt.Errorf("Network not supported")
+	}
+}
+
+// blockTimestamp fetches the header timestamp of a block.
+func blockTimestamp(ctx context.Context, network Network, rpcURL string, blockNumber uint64) (time.Time, error) {
+	switch network {
+	case Starknet:
+		resp, err := importers.MakeRPCCall(ctx, rpcURL, "starknet_getBlockWithTxHashes", []interface{}{map[string]interface{}{"block_number": blockNumber}})
+		if err != nil {
+			return time.Time{}, err
+		}
+		var block struct {
+			Timestamp int64 `json:"timestamp"`
+		}
+		if err := json.Unmarshal(resp.Result, &block); err != nil {
+			return time.Time{}, fmt.Errorf("error decoding block %d: %v", blockNumber, err)
+		}
+		return time.Unix(block.Timestamp, 0).UTC(), nil
+	case Ethereum, ZkSyncEra:
+		resp, err := importers.MakeRPCCall(ctx, rpcURL, "eth_getBlockByNumber", []interface{}{fmt.Sprintf("0x%x", blockNumber), false})
+		if err != nil {
+			return time.Time{}, err
+		}
+		var block *struct {
+			Timestamp string `json:"timestamp"`
+		}
+		if err := json.Unmarshal(resp.Result, &block); err != nil {
+			return time.Time{}, fmt.Errorf("error decoding block %d: %v", blockNumber, err)
+		}
+		if block == nil {
+			return time.Time{}, fmt.Errorf("block %d not found", blockNumber)
+		}
+		timestamp, err := strconv.ParseInt(block.Timestamp, 0, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("error decoding timestamp of block %d: %v", blockNumber, err)
+		}
+		return time.Unix(timestamp, 0).UTC(), nil
+	default:
+		return time.Time{}, fmt.Errorf("Network not supported")
+	}
+}
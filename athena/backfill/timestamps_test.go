@@ -0,0 +1,130 @@
+package backfill
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/BlocSoc-iitr/Athena/athena/database/readers"
+	"github.com/BlocSoc-iitr/Athena/athena/types"
+	"github.com/stretchr/testify/assert"
+)
+
+var genesis = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// irregularTimestamp gives blocks 12 second slots, with a 10 minute gap after block 50000.
+func irregularTimestamp(blockNumber uint64) time.Time {
+	timestamp := genesis.Add(time.Duration(blockNumber) * 12 * time.Second)
+	if blockNumber > 50000 {
+		timestamp = timestamp.Add(10 * time.Minute)
+	}
+	return timestamp
+}
+
+func TestTimestampResolver(t *testing.T) {
+	var lookups int
+	resolver := newTimestampResolver(
+		func(_ context.Context, blockNumber uint64) (time.Time, error) {
+			lookups++
+			return irregularTimestamp(blockNumber), nil
+		},
+		func(context.Context) (uint64, error) { return 100000, nil },
+		[]types.BlockTimestamp{{BlockNumber: 40000, Timestamp: irregularTimestamp(40000)}},
+	)
+	ctx := context.Background()
+
+	blockNumber, err := resolver.FirstBlockAtOrAfter(ctx, irregularTimestamp(12345))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(12345), blockNumber)
+
+	blockNumber, err = resolver.FirstBlockAtOrAfter(ctx, irregularTimestamp(12345).Add(time.Second))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(12346), blockNumber)
+
+	blockNumber, err = resolver.FirstBlockAtOrAfter(ctx, irregularTimestamp(50000).Add(time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(50001), blockNumber)
+
+	blockNumber, err = resolver.LastBlockAtOrBefore(ctx, irregularTimestamp(50000).Add(time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(50000), blockNumber)
+
+	blockNumber, err = resolver.FirstBlockAtOrAfter(ctx, genesis.Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), blockNumber)
+
+	_, err = resolver.FirstBlockAtOrAfter(ctx, irregularTimestamp(100001))
+	assert.Error(t, err)
+
+	assert.Equal(t, lookups, len(resolver.Samples()))
+	assert.Less(t, lookups, 80)
+}
+
+func TestResolveDateRangeExtendsCache(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	var requests atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		var req struct {
+			Method string        `json:"method"`
+			Params []interface{} `json:"params"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		switch req.Method {
+		case "eth_blockNumber":
+			w.Write([]byte(`{"jsonrpc": "2.0", "id": 1, "result": "0x186a0"}`))
+		case "eth_getBlockByNumber":
+			blockNumber, _ := strconv.ParseUint(req.Params[0].(string), 0, 64)
+			fmt.Fprintf(w, `{"jsonrpc": "2.0", "id": 1, "result": {"timestamp": "0x%x"}}`, irregularTimestamp(blockNumber).Unix())
+		default:
+			t.Errorf("unexpected method %s", req.Method)
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	flags := BlockRangeFlags{FromDate: "2024-01-02", ToDate: "2024-01-02"}
+	fromBlock, toBlock, err := ResolveBlockRange(ctx, Ethereum, server.URL, flags)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(7200), fromBlock)
+	assert.Equal(t, uint64(14399), toBlock)
+
+	cached := readers.GetBlockTimestamps(nil, types.Ethereum, 1, 0)
+	assert.NotEmpty(t, cached)
+	for _, sample := range cached {
+		assert.True(t, irregularTimestamp(uint64(sample.BlockNumber)).Equal(sample.Timestamp))
+	}
+
+	// The second resolution starts from the cached samples and only looks up the chain head.
+	first := requests.Load()
+	fromBlock, toBlock, err = ResolveBlockRange(ctx, Ethereum, server.URL, flags)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(7200), fromBlock)
+	assert.Equal(t, uint64(14399), toBlock)
+	assert.LessOrEqual(t, requests.Load()-first, int64(3))
+}
+
+func TestParseDateFlag(t *testing.T) {
+	day, err := ParseDateFlag("2024-01-31", false)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC), day)
+
+	end, err := ParseDateFlag("2024-01-31", true)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 1, 31, 23, 59, 59, 0, time.UTC), end)
+
+	instant, err := ParseDateFlag("2024-01-31T12:00:00+02:00", true)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 1, 31, 10, 0, 0, 0, time.UTC), instant)
+
+	_, err = ParseDateFlag("31/01/2024", false)
+	assert.Error(t, err)
+}
@@ -2,6 +2,7 @@ package backfill
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -21,8 +22,9 @@ type GraceFullkiller struct {
 type Network string
 
 const (
-	Ethereum Network = "ethereum"
-	Starknet Network = "starknet"
+	Ethereum  Network = "ethereum"
+	Starknet  Network = "starknet"
+	ZkSyncEra Network = "zksync_era"
 )
 
 type BlockIdentifier string
@@ -41,6 +43,8 @@ func Default_rpc(network Network) (string, error) {
 		return "https://eth.public-rpc.com/", nil
 	case Starknet:
 		return "https://free-rpc.nethermind.io/mainnet-juno/", nil
+	case ZkSyncEra:
+		return "https://mainnet.era.zksync.io", nil
 	default:
 		return "", fmt.Errorf("Network not supported")
 	}
@@ -50,6 +54,8 @@ func Etherscan_base_url(network Network) (string, error) {
 	switch network {
 	case Ethereum:
 		return "https://api.etherscan.io/api", nil
+	case ZkSyncEra:
+		return "https://block-explorer-api.mainnet.zksync.io/api", nil
 	default:
 		return "", fmt.Errorf("Network not available from etherscan")
 	}
@@ -91,7 +97,7 @@ func Get_current_block_number(network Network) (int, error) {
 		}
 
 		return int(result), nil
-	case Ethereum:
+	case Ethereum, ZkSyncEra:
 		rpc := os.Getenv("JSON_RPC")
 		if rpc == "" {
 			rpc, _ = Default_rpc(network)
@@ -121,10 +127,10 @@ func Get_current_block_number(network Network) (int, error) {
 
 		resultHex, ok := response["result"].(string)
 		if !ok {
-			return 0, fmt.Errorf("error fetching current block number for Ethereum: %v", response)
+			return 0, fmt.Errorf("error fetching current block number for %s: %v", network, response)
 		}
 
-		blockNumber, err := strconv.ParseInt(resultHex, 16, 64)
+		blockNumber, err := strconv.ParseInt(resultHex, 0, 64)
 		if err != nil {
 			return 0, fmt.Errorf("error converting block number: %v", err)
 		}
@@ -143,10 +149,16 @@ func Block_Identifier_To_Block(identifier BlockIdentifier, network Network) (int
 		return Get_current_block_number(network)
 	case earliest:
 		return 0, nil
-	case safe:
-		return 0, fmt.Errorf("not implemented")
-	case finalized:
-		return 0, fmt.Errorf("not implemented")
+	case safe, finalized:
+		rpc := os.Getenv("JSON_RPC")
+		if rpc == "" {
+			rpc, _ = Default_rpc(network)
+		}
+		blockNumber, err := finalizedBlockNumber(context.Background(), network, rpc, identifier)
+		if err != nil {
+			return 0, err
+		}
+		return int(blockNumber), nil
 	case pending:
 		blockNumber, err := Get_current_block_number(network)
 		if err != nil {
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/BlocSoc-iitr/Athena/athena/backfill"
+	"github.com/BlocSoc-iitr/Athena/athena/backfill/importers"
+	"github.com/BlocSoc-iitr/Athena/athena/backfill/pipeline"
+	"github.com/BlocSoc-iitr/Athena/athena/database"
+	"github.com/BlocSoc-iitr/Athena/athena/database/models"
+	"github.com/BlocSoc-iitr/Athena/athena/types"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"log"
+	"strings"
+)
+
+// evmKinds are the kinds of rows imported for each backfill type.
+var evmKinds = map[backfill.BackfillDataType][]pipeline.Kind{
+	backfill.FullBlocks:   {pipeline.EVMBlocks, pipeline.EVMTransactions, pipeline.EVMLogs},
+	backfill.Blocks:       {pipeline.EVMBlocks},
+	backfill.Transactions: {pipeline.EVMBlocks, pipeline.EVMTransactions},
+	backfill.Events:       {pipeline.EVMLogs},
+}
+
+// evmDataTypes are the data types the backfilled ranges of each backfill type are recorded with.
+var evmDataTypes = map[backfill.BackfillDataType]types.BackfillDataType{
+	backfill.FullBlocks:   types.FullBlocks,
+	backfill.Blocks:       types.Blocks,
+	backfill.Transactions: types.Transactions,
+	backfill.Events:       types.Events,
+}
+
+func main() {
+	defaultRPC, _ := backfill.Default_rpc(backfill.Ethereum)
+
+	fromBlock := flag.String("from", "", "Starting block: "+backfill.BlockFlagUsage)
+	toBlock := flag.String("to", "", "Ending block: "+backfill.BlockFlagUsage)
+	fromDate := flag.String("from-date", "", "Starting date, instead of -from: "+backfill.DateFlagUsage)
+	toDate := flag.String("to-date", "", "Ending date, instead of -to: "+backfill.DateFlagUsage)
+	rpcURL := flag.String("rpc-url", defaultRPC, "RPC URL of the Ethereum node")
+	dataType := flag.String("type", string(backfill.FullBlocks), "Data to backfill: full_blocks, blocks, transactions or events")
+	blockFile := flag.String("block-file", "blocks.csv", "Output file for blocks, .csv, .parquet, .jsonl or .jsonl.gz")
+	transactionFile := flag.String("transaction-file", "transactions.csv", "Output file for transactions, .csv, .parquet, .jsonl or .jsonl.gz")
+	eventFile := flag.String("event-file", "events.csv", "Output file for logs, .csv, .parquet, .jsonl or .jsonl.gz")
+	contracts := flag.String("contracts", "", "Comma separated contract addresses to restrict logs to")
+	topics := flag.String("topics", "", "Comma separated event signatures (topic 0) to restrict logs to")
+	logsRange := flag.Uint64("logs-range", importers.DefaultEthLogsBlockRange, "Blocks per eth_getLogs request before splitting")
+	dbURL := flag.String("db-url", "", "Database DSN; data is written to the database instead of the output files")
+	batchSize := flag.Int("batch-size", 500, "Number of rows per database insert")
+	chunkSize := flag.Uint64("chunk-size", pipeline.DefaultBatchSize, "Number of blocks fetched and written per chunk")
+	compression := flag.String("compression", "snappy", "Compression codec of .parquet files: snappy, zstd or none")
+	rowGroupSize := flag.Int64("row-group-size", backfill.DefaultParquetRowGroupSize, "Maximum number of rows per row group of .parquet files")
+	partitionBlocks := flag.Uint64("partition-blocks", 0, backfill.PartitionBlocksFlagUsage)
+	partitionBytes := flag.Int64("partition-bytes", 0, backfill.PartitionBytesFlagUsage)
+
+	flag.Parse()
+	blockRange := backfill.BlockRangeFlags{FromBlock: *fromBlock, ToBlock: *toBlock, FromDate: *fromDate, ToDate: *toDate}
+
+	if !blockRange.Complete() || *rpcURL == "" {
+		fmt.Println("Missing required flags. Use --help for usage.")
+		return
+	}
+
+	ctx := context.Background()
+	fromBlockNumber, toBlockNumber, err := backfill.ResolveBlockRange(ctx, backfill.Ethereum, *rpcURL, blockRange)
+	if err != nil {
+		log.Fatalf("Error resolving block range: %v", err)
+	}
+
+	backfillType := backfill.BackfillDataType(*dataType)
+	kinds, ok := evmKinds[backfillType]
+	if !ok {
+		log.Fatalf("Backfill type %s is not supported for Ethereum", *dataType)
+	}
+	var filter importers.EthLogFilter
+	if *contracts != "" {
+		filter.Addresses = strings.Split(*contracts, ",")
+	}
+	if *topics != "" {
+		filter.Topics = [][]string{strings.Split(*topics, ",")}
+	}
+	filter.BlockRange = *logsRange
+
+	var sink pipeline.Sink
+	if *dbURL != "" {
+		db, err := gorm.Open(mysql.Open(*dbURL), &gorm.Config{})
+		if err != nil {
+			log.Fatalf("Failed to connect to the database: %v", err)
+		}
+		database.MigrateUp(db)
+		backfillID := fmt.Sprintf("ethereum-%s-%d-%d", backfillType, fromBlockNumber, toBlockNumber)
+		exporter := backfill.NewDBResourceExporter(db, backfillID, evmDataTypes[backfillType], types.Ethereum, *batchSize)
+		if backfillType == backfill.Events && (filter.Addresses != nil || filter.Topics != nil) {
+			exporter.SetFilterData(map[string]interface{}{"contracts": filter.Addresses, "topics": filter.Topics})
+		}
+		sink = exporter
+	} else {
+		files := map[pipeline.Kind]string{pipeline.EVMBlocks: *blockFile, pipeline.EVMTransactions: *transactionFile, pipeline.EVMLogs: *eventFile}
+		fileModels := map[pipeline.Kind]interface{}{pipeline.EVMBlocks: models.EVMBlock{}, pipeline.EVMTransactions: models.EVMTransaction{}, pipeline.EVMLogs: models.EVMLog{}}
+		options := backfill.ExportOptions{Compression: *compression, RowGroupSize: *rowGroupSize}
+		partition := backfill.PartitionOptions{Blocks: *partitionBlocks, MaxBytes: *partitionBytes}
+		writers := make(map[pipeline.Kind]pipeline.RowWriter, len(kinds))
+		var exporters []backfill.ResourceExporter
+		for _, kind := range kinds {
+			exporter, err := backfill.NewBackfillExporter(files[kind], fileModels[kind], fromBlockNumber, options, partition)
+			if err != nil {
+				log.Fatalf("Error creating exporter: %v", err)
+			}
+			writers[kind] = exporter
+			exporters = append(exporters, exporter)
+		}
+		// Partitioned exports resume after the partitions completed by an earlier run.
+		fromBlockNumber = backfill.ResumeBlock(fromBlockNumber, exporters...)
+		sink = pipeline.NewFileSink(writers)
+	}
+
+	source := pipeline.NewBlockRangeSource("fetch", fromBlockNumber, toBlockNumber, *chunkSize, pipeline.EthereumFetcher(*rpcURL, filter, kinds...))
+	p := pipeline.New(source, pipeline.DefaultBufferSize).To(sink)
+	err = p.Run(ctx)
+	for _, metrics := range p.Metrics() {
+		fmt.Println(metrics)
+	}
+	if err != nil {
+		log.Fatalf("Error importing %s: %v", backfillType, err)
+	}
+	if *dbURL != "" {
+		fmt.Printf("Blocks %d to %d written to the database\n", fromBlockNumber, toBlockNumber)
+		return
+	}
+	fmt.Printf("Exported blocks %d to %d\n", fromBlockNumber, toBlockNumber)
+}
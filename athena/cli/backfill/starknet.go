@@ -2,12 +2,19 @@ package main
 
 import (
 	"context"
+	"errors"
 
 	"encoding/json"
 	"flag"
 	"fmt"
+	"github.com/BlocSoc-iitr/Athena/athena/backfill"
 	"github.com/BlocSoc-iitr/Athena/athena/backfill/importers"
-	"sync"
+	"github.com/BlocSoc-iitr/Athena/athena/backfill/pipeline"
+	"github.com/BlocSoc-iitr/Athena/athena/database"
+	"github.com/BlocSoc-iitr/Athena/athena/database/models"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"log"
 )
 
 type rpcRequest struct {
@@ -82,62 +89,113 @@ type BlockTxHashes struct {
 
 func main() {
 
-	fromBlockNumber := flag.Uint64("from", 0, "Starting block number")
-	toBlockNumber := flag.Uint64("to", 0, "Ending block number")
+	fromBlock := flag.String("from", "", "Starting block: "+backfill.BlockFlagUsage)
+	toBlock := flag.String("to", "", "Ending block: "+backfill.BlockFlagUsage)
+	fromDate := flag.String("from-date", "", "Starting date, instead of -from: "+backfill.DateFlagUsage)
+	toDate := flag.String("to-date", "", "Ending date, instead of -to: "+backfill.DateFlagUsage)
 	rpcURL := flag.String("rpc-url", "", "RPC URL of the blockchain node")
-	outputFile := flag.String("output", "block_details.csv", "Output CSV file")
-	transactionHashFlag := flag.Bool("transactionhash", false, "Fetch transaction hashes as well")
+	outputFile := flag.String("output", "block_details.csv", "Output file, .csv, .parquet, .jsonl or .jsonl.gz")
+	transactionHashFlag := flag.Bool("transactionhash", false, "Export the transactions of the blocks as well, to transaction_hashes_<output>")
+	partitionBlocks := flag.Uint64("partition-blocks", 0, backfill.PartitionBlocksFlagUsage)
+	partitionBytes := flag.Int64("partition-bytes", 0, backfill.PartitionBytesFlagUsage)
+	dbURL := flag.String("db-url", "", "Database DSN; blocks and transactions are written to the database instead of the output files")
+	batchSize := flag.Uint64("batch-size", pipeline.DefaultBatchSize, "Number of blocks fetched per batch")
+	follow := flag.Bool("follow", false, "Keep following new blocks after the range completes")
+	confirmations := flag.Uint64("confirmations", backfill.DefaultConfirmations, "Blocks to stay behind the chain head in follow mode")
+	pollInterval := flag.Duration("poll-interval", backfill.DefaultPollInterval, "Interval between chain head polls in follow mode")
+	wsURL := flag.String("ws-url", "", "Websocket RPC URL used to subscribe to new heads in follow mode")
 
 	flag.Parse()
+	blockRange := backfill.BlockRangeFlags{FromBlock: *fromBlock, ToBlock: *toBlock, FromDate: *fromDate, ToDate: *toDate}
 
-	if *fromBlockNumber == 0 || *toBlockNumber == 0 || *rpcURL == "" {
+	if !blockRange.Complete() || *rpcURL == "" {
 		fmt.Println("Missing required flags. Use --help for usage.")
 		return
 	}
 
 	ctx := context.Background()
 
-	var wg sync.WaitGroup
-	wg.Add(1)
-
-	go func() {
-		defer wg.Done()
+	fromBlockNumber, toBlockNumber, err := backfill.ResolveBlockRange(ctx, backfill.Starknet, *rpcURL, blockRange)
+	if err != nil {
+		log.Fatalf("Error resolving block range: %v", err)
+	}
 
-		blockDetails, err := importers.GetBlockHashDetails(ctx, *rpcURL, *fromBlockNumber, *toBlockNumber)
+	transactionOutputFile := "transaction_hashes_" + *outputFile
+	backfillType := backfill.Blocks
+	if *transactionHashFlag {
+		backfillType = backfill.Transactions
+	}
+	kwargs := make(map[string]interface{})
+	store := importers.BlockHashStore(importers.NewMemoryBlockHashStore())
+	var populator *importers.AbiPopulator
+	if *dbURL != "" {
+		db, err := gorm.Open(mysql.Open(*dbURL), &gorm.Config{})
 		if err != nil {
-			fmt.Printf("Failed to fetch block details: %v\n", err)
-			return
+			log.Fatalf("Failed to connect to the database: %v", err)
 		}
-
-		if err := importers.WriteBlockHashesToCSV(blockDetails, *outputFile); err != nil {
-			fmt.Printf("Failed to write block details to CSV: %v\n", err)
-			return
+		database.MigrateUp(db)
+		store = importers.NewDBBlockHashStore(db)
+		// Classes declared during the backfill are registered, so their events can be decoded.
+		populator = importers.NewAbiPopulator(*rpcURL, db, importers.NewAbiRegistry(db))
+		kwargs["export_mode"] = backfill.DBModels
+		kwargs["db"] = db
+		kwargs["backfill_id"] = fmt.Sprintf("starknet-%s-%d-%d", backfillType, fromBlockNumber, toBlockNumber)
+	} else {
+		partition := backfill.PartitionOptions{Blocks: *partitionBlocks, MaxBytes: *partitionBytes}
+		blockExporter, err := backfill.NewBackfillExporter(*outputFile, models.Block{}, fromBlockNumber, backfill.ExportOptions{}, partition)
+		if err != nil {
+			log.Fatalf("Error creating exporter: %v", err)
 		}
-
-		fmt.Printf("Block details written to %s\n", *outputFile)
-	}()
-
-	if *transactionHashFlag {
-		wg.Add(1)
-
-		go func() {
-			defer wg.Done()
-
-			blockTxHashesDetails, err := importers.GetBlockDetails(ctx, *rpcURL, *fromBlockNumber, *toBlockNumber)
+		kwargs["block_file"] = blockExporter
+		exporters := []backfill.ResourceExporter{blockExporter}
+		if *transactionHashFlag {
+			transactionExporter, err := backfill.NewBackfillExporter(transactionOutputFile, models.Transaction{}, fromBlockNumber, backfill.ExportOptions{}, partition)
 			if err != nil {
-				fmt.Printf("Failed to fetch block transaction hashes details: %v\n", err)
-				return
-			}
-
-			transactionOutputFile := "transaction_hashes_" + *outputFile
-			if err := importers.WriteBlockDetailsToCSV(*blockTxHashesDetails, transactionOutputFile); err != nil {
-				fmt.Printf("Failed to write block transaction hashes details to CSV: %v\n", err)
-				return
+				log.Fatalf("Error creating exporter: %v", err)
 			}
-
-			fmt.Printf("Block transaction hashes details written to %s\n", transactionOutputFile)
-		}()
+			kwargs["transaction_file"] = transactionExporter
+			exporters = append(exporters, transactionExporter)
+		}
+		// Partitioned exports resume after the partitions completed by an earlier run.
+		fromBlockNumber = backfill.ResumeBlock(fromBlockNumber, exporters...)
+	}
+	sink, kinds, err := backfill.NewSinkForBackfill(backfillType, kwargs)
+	if err != nil {
+		log.Fatalf("Error creating exporters: %v", err)
+	}
+	// Followed blocks go through the same pipeline and exporters as the backfill, and are
+	// verified against the blocks before them.
+	starknetBackfill := &backfill.StarknetBackfill{
+		RPCURL:    *rpcURL,
+		Kinds:     kinds,
+		BatchSize: *batchSize,
+		Sink:      sink,
+		Detector:  importers.NewReorgDetector(*rpcURL, store, 0),
+		Populator: populator,
 	}
 
-	wg.Wait()
+	err = starknetBackfill.Import(ctx, fromBlockNumber, toBlockNumber)
+	if err == nil && *follow {
+		killer := backfill.New_Gracfull_Killer()
+		cfg := backfill.FollowConfig{
+			Network:       backfill.Starknet,
+			RPCURL:        *rpcURL,
+			WSURL:         *wsURL,
+			StartBlock:    toBlockNumber + 1,
+			Confirmations: *confirmations,
+			PollInterval:  *pollInterval,
+		}
+		err = starknetBackfill.Follow(ctx, cfg, killer)
+	}
+	if err := errors.Join(err, sink.Close()); err != nil {
+		log.Fatalf("Error importing blocks: %v", err)
+	}
+	if *dbURL != "" {
+		fmt.Println("Blocks written to the database")
+		return
+	}
+	fmt.Printf("Block details written to %s\n", *outputFile)
+	if *transactionHashFlag {
+		fmt.Printf("Block transactions written to %s\n", transactionOutputFile)
+	}
 }
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/BlocSoc-iitr/Athena/athena/backfill"
+	"github.com/BlocSoc-iitr/Athena/athena/backfill/importers"
+	"github.com/BlocSoc-iitr/Athena/athena/database"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"log"
+)
+
+func main() {
+	fromBlock := flag.String("from", "", "Starting block: "+backfill.BlockFlagUsage)
+	toBlock := flag.String("to", "", "Ending block: "+backfill.BlockFlagUsage)
+	fromDate := flag.String("from-date", "", "Starting date, instead of -from: "+backfill.DateFlagUsage)
+	toDate := flag.String("to-date", "", "Ending date, instead of -to: "+backfill.DateFlagUsage)
+	rpcURL := flag.String("rpc-url", "", "RPC URL of the blockchain node")
+	dbURL := flag.String("db-url", "", "Database DSN the ABIs of declared classes are stored in")
+
+	flag.Parse()
+	blockRange := backfill.BlockRangeFlags{FromBlock: *fromBlock, ToBlock: *toBlock, FromDate: *fromDate, ToDate: *toDate}
+
+	if !blockRange.Complete() || *rpcURL == "" || *dbURL == "" {
+		fmt.Println("Missing required flags. Use --help for usage.")
+		return
+	}
+
+	fromBlockNumber, toBlockNumber, err := backfill.ResolveBlockRange(context.Background(), backfill.Starknet, *rpcURL, blockRange)
+	if err != nil {
+		log.Fatalf("Error resolving block range: %v", err)
+	}
+
+	db, err := gorm.Open(mysql.Open(*dbURL), &gorm.Config{})
+	if err != nil {
+		log.Fatalf("Failed to connect to the database: %v", err)
+	}
+	database.MigrateUp(db)
+
+	rows, err := importers.GetStateDiffs(context.Background(), *rpcURL, fromBlockNumber, toBlockNumber)
+	if err != nil {
+		log.Fatalf("Error importing state diffs: %v", err)
+	}
+
+	populator := importers.NewAbiPopulator(*rpcURL, db, importers.NewAbiRegistry(db))
+	stored, err := populator.PopulateFromStateDiffs(context.Background(), rows)
+	if err != nil {
+		log.Fatalf("Error populating ABIs: %v", err)
+	}
+	fmt.Printf("%d ABIs of %d declared classes stored\n", stored, len(rows.DeclaredClasses))
+}
@@ -1,60 +1,199 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"github.com/BlocSoc-iitr/Athena/athena/backfill"
 	"github.com/BlocSoc-iitr/Athena/athena/backfill/importers"
-	"github.com/NethermindEth/starknet.go/rpc"
+	"github.com/BlocSoc-iitr/Athena/athena/backfill/pipeline"
+	"github.com/BlocSoc-iitr/Athena/athena/database"
+	"github.com/BlocSoc-iitr/Athena/athena/database/models"
+	"github.com/BlocSoc-iitr/Athena/athena/types"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
 	"log"
+	"os"
+	"path"
+	"path/filepath"
 )
 
 func main() {
 	// Define CLI flags
-	fromBlockNumber := flag.Uint64("from", 0, "Starting block number")
-	toBlockNumber := flag.Uint64("to", 0, "Ending block number")
+	fromBlock := flag.String("from", "", "Starting block: "+backfill.BlockFlagUsage)
+	toBlock := flag.String("to", "", "Ending block: "+backfill.BlockFlagUsage)
+	fromDate := flag.String("from-date", "", "Starting date, instead of -from: "+backfill.DateFlagUsage)
+	toDate := flag.String("to-date", "", "Ending date, instead of -to: "+backfill.DateFlagUsage)
 	rpcURL := flag.String("rpc-url", "", "RPC URL of the blockchain node")
-	outputFile := flag.String("output", "events.csv", "Output CSV file")
+	outputFile := flag.String("output", "events.csv", "Output file, .csv, .parquet, .jsonl or .jsonl.gz")
 	ChunkSize := flag.Int("chunk-size", 100, "Number of events per request")
+	contract := flag.String("contract", "", "Contract address; events of every contract if empty")
+	batchSize := flag.Uint64("batch-size", importers.DefaultEventsBlockRange, "Number of blocks per sub-range fetched concurrently; dense sub-ranges are split further")
+	workers := flag.Int("workers", importers.DefaultEventsWorkers, "Number of sub-ranges fetched concurrently")
+	decode := flag.Bool("decode", false, "Import events from full blocks and decode them with the stored ABIs")
+	dbURL := flag.String("db-url", "", "Database DSN; decoded events are written to the database instead of the output file")
+	dbBatchSize := flag.Int("db-batch-size", 500, "Number of rows per database insert, with -decode and -db-url")
+	partitionBlocks := flag.Uint64("partition-blocks", 0, backfill.PartitionBlocksFlagUsage+", with -decode")
+	partitionBytes := flag.Int64("partition-bytes", 0, backfill.PartitionBytesFlagUsage+", with -decode")
+	s3Endpoint := flag.String("s3-endpoint", "s3.amazonaws.com", "S3-compatible endpoint partitions are uploaded to")
+	s3Bucket := flag.String("s3-bucket", "", "S3 bucket partitions are uploaded to, with credentials from AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY; not uploaded if empty")
+	s3Prefix := flag.String("s3-prefix", "", "Key prefix of the uploaded partitions, followed by the partition directory name")
+	s3Region := flag.String("s3-region", "", "Region of the S3 bucket")
+	s3Insecure := flag.Bool("s3-insecure", false, "Connect to the S3 endpoint over HTTP")
+	s3KeepFiles := flag.Bool("s3-keep-files", false, "Keep partition files locally once uploaded")
+	follow := flag.Bool("follow", false, "Keep following new blocks after the range completes, with -decode")
+	confirmations := flag.Uint64("confirmations", backfill.DefaultConfirmations, "Blocks to stay behind the chain head in follow mode")
+	pollInterval := flag.Duration("poll-interval", backfill.DefaultPollInterval, "Interval between chain head polls in follow mode")
+	wsURL := flag.String("ws-url", "", "Websocket RPC URL used to subscribe to new heads in follow mode")
 
 	flag.Parse()
+	blockRange := backfill.BlockRangeFlags{FromBlock: *fromBlock, ToBlock: *toBlock, FromDate: *fromDate, ToDate: *toDate}
 
 	// Validate required flags
-	if *fromBlockNumber == 0 || *toBlockNumber == 0 || *rpcURL == "" {
+	if !blockRange.Complete() || *rpcURL == "" {
 		fmt.Println("Missing required flags. Use --help for usage.")
 		return
 	}
 
-	// Initialize RPC provider
-	provider, err := rpc.NewProvider(*rpcURL)
+	fromBlockNumber, toBlockNumber, err := backfill.ResolveBlockRange(context.Background(), backfill.Starknet, *rpcURL, blockRange)
 	if err != nil {
-		log.Fatalf("Failed to create RPC provider: %v", err)
+		log.Fatalf("Error resolving block range: %v", err)
 	}
 
-	// Define the block IDs
-	fromBlockID := rpc.BlockID{Number: fromBlockNumber}
-	toBlockID := rpc.BlockID{Number: toBlockNumber}
-
-	// Set up the filter and pagination
-	filter := rpc.EventFilter{
-		FromBlock: fromBlockID,
-		ToBlock:   toBlockID,
+	if *follow && !*decode {
+		log.Fatalf("Follow mode requires -decode")
 	}
-
-	resultPage := rpc.ResultPageRequest{
-		ChunkSize: *ChunkSize,
+	if *decode {
+		var followConfig *backfill.FollowConfig
+		if *follow {
+			followConfig = &backfill.FollowConfig{
+				Network:       backfill.Starknet,
+				RPCURL:        *rpcURL,
+				WSURL:         *wsURL,
+				StartBlock:    toBlockNumber + 1,
+				Confirmations: *confirmations,
+				PollInterval:  *pollInterval,
+			}
+		}
+		partition := backfill.PartitionOptions{Blocks: *partitionBlocks, MaxBytes: *partitionBytes, RemoveStored: !*s3KeepFiles}
+		if *s3Bucket != "" {
+			if !partition.Enabled() {
+				log.Fatalf("S3 uploads require -partition-blocks or -partition-bytes")
+			}
+			dir, _, err := backfill.PartitionDirectory(*outputFile)
+			if err != nil {
+				log.Fatalf("Error creating exporter: %v", err)
+			}
+			partition.Store, err = backfill.NewS3PartitionStore(backfill.S3Options{
+				Endpoint:  *s3Endpoint,
+				Bucket:    *s3Bucket,
+				Prefix:    path.Join(*s3Prefix, filepath.Base(dir)),
+				Region:    *s3Region,
+				AccessKey: os.Getenv("AWS_ACCESS_KEY_ID"),
+				SecretKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+				Insecure:  *s3Insecure,
+			})
+			if err != nil {
+				log.Fatalf("Error creating S3 store: %v", err)
+			}
+		}
+		importDecodedEvents(*rpcURL, fromBlockNumber, toBlockNumber, *contract, *outputFile, *dbURL, *dbBatchSize, partition, followConfig)
+		return
 	}
 
-	// Fetch events
-	events, err := importers.FetchEvents(provider, filter, resultPage)
+	// Fetch events, splitting the block range into concurrently fetched sub-ranges
+	filter := importers.EventFilter{
+		FromBlock:  fromBlockNumber,
+		ToBlock:    toBlockNumber,
+		ChunkSize:  *ChunkSize,
+		BlockRange: *batchSize,
+		Workers:    *workers,
+	}
+	events, err := importers.GetEmittedEventsInRanges(context.Background(), *rpcURL, *contract, filter)
 	if err != nil {
 		log.Fatalf("Error fetching events: %v", err)
 	}
 
-	// Export events to CSV
-	err = importers.ExportEventsToCSV(events, *outputFile)
+	exporter, err := backfill.NewResourceExporter(*outputFile, importers.EmittedEvent{}, backfill.ExportOptions{})
 	if err != nil {
-		log.Fatalf("Error exporting events to CSV: %v", err)
+		log.Fatalf("Error creating exporter: %v", err)
+	}
+	if err := exporter.Write(importers.EmittedEventsToDicts(events)); err != nil {
+		log.Fatalf("Error exporting events: %v", err)
+	}
+	if err := exporter.Close(); err != nil {
+		log.Fatalf("Error exporting events: %v", err)
 	}
 
 	fmt.Printf("Events exported to %s\n", *outputFile)
 }
+
+// importDecodedEvents imports the events of a block range, decodes them and writes them to the database or a file.
+// Only the events of contract are kept if it is not empty.
+// Database writes upsert the events and record each chunk of blocks as a backfilled range.
+// Partitioned file exports resume after the partitions listed in their manifest.
+// If follow is set, the blocks after the range are imported as the chain grows.
+func importDecodedEvents(rpcURL string, fromBlock uint64, toBlock uint64, contract string, outputFile string, dbURL string, batchSize int, partition backfill.PartitionOptions, follow *backfill.FollowConfig) {
+	var db *gorm.DB
+	if dbURL != "" {
+		var err error
+		db, err = gorm.Open(mysql.Open(dbURL), &gorm.Config{})
+		if err != nil {
+			log.Fatalf("Failed to connect to the database: %v", err)
+		}
+		database.MigrateUp(db)
+	}
+
+	var sink pipeline.Sink
+	var store importers.BlockHashStore = importers.NewMemoryBlockHashStore()
+	switch {
+	case db != nil:
+		store = importers.NewDBBlockHashStore(db)
+		backfillID := fmt.Sprintf("starknet-events-%d-%d", fromBlock, toBlock)
+		sink = backfill.NewDBResourceExporter(db, backfillID, types.Events, types.StarkNet, batchSize)
+	default:
+		exporter, err := backfill.NewBackfillExporter(outputFile, models.DefaultEvent{}, fromBlock, backfill.ExportOptions{}, partition)
+		if err != nil {
+			log.Fatalf("Error creating exporter: %v", err)
+		}
+		resumeBlock := backfill.ResumeBlock(fromBlock, exporter)
+		if resumeBlock > toBlock && follow == nil {
+			fmt.Printf("Blocks %d to %d are already exported to %s\n", fromBlock, toBlock, outputFile)
+			return
+		}
+		fromBlock = resumeBlock
+		sink = pipeline.NewFileSink(map[pipeline.Kind]pipeline.RowWriter{pipeline.Events: exporter})
+	}
+
+	// Events are decoded with the class, or proxy implementation, that was active at their block.
+	var classStore importers.ClassHistoryStore
+	if db != nil {
+		classStore = importers.NewDBClassHistoryStore(db)
+	}
+	resolver := importers.NewImplementationResolver(importers.NewRPCClassHashResolver(rpcURL))
+	resolver.LoadHistories(importers.NewImplementationLoader(rpcURL, classStore), fromBlock, toBlock)
+	registry := importers.NewAbiRegistry(db)
+	decoder := importers.NewEventDecoder(registry, resolver)
+	var transforms []pipeline.Transform
+	if contract != "" {
+		transforms = append(transforms, pipeline.FilterEvents(importers.EventFilter{Addresses: []string{contract}}))
+	}
+	transforms = append(transforms, pipeline.DecodeEvents(decoder))
+	starknetBackfill := &backfill.StarknetBackfill{
+		RPCURL:     rpcURL,
+		Kinds:      []pipeline.Kind{pipeline.Events},
+		Transforms: transforms,
+		Sink:       sink,
+		Detector:   importers.NewReorgDetector(rpcURL, store, 0),
+		Populator:  importers.NewAbiPopulator(rpcURL, db, registry),
+	}
+	ctx := context.Background()
+	err := starknetBackfill.Import(ctx, fromBlock, toBlock)
+	if err == nil && follow != nil {
+		err = starknetBackfill.Follow(ctx, *follow, backfill.New_Gracfull_Killer())
+	}
+	if err := errors.Join(err, sink.Close()); err != nil {
+		log.Fatalf("Error importing events: %v", err)
+	}
+}
@@ -2,192 +2,77 @@ package main
 
 import (
 	"context"
-	"encoding/csv"
+	"flag"
 	"fmt"
 	"log"
-	"os"
 	"strings"
 
-	"github.com/NethermindEth/juno/core/felt"
-	"github.com/NethermindEth/starknet.go/rpc"
-	"github.com/NethermindEth/starknet.go/utils"
+	"github.com/BlocSoc-iitr/Athena/athena/backfill"
+	"github.com/BlocSoc-iitr/Athena/athena/backfill/importers"
+	"github.com/BlocSoc-iitr/Athena/athena/backfill/pipeline"
 )
 
-func FetchEvents(provider *rpc.Provider, filter rpc.EventFilter, resultPage rpc.ResultPageRequest) ([]rpc.EventChunk, error) {
-	var allEvents []rpc.EventChunk
-
-	for {
-		// Create the input for the events request
-		Input := rpc.EventsInput{
-			EventFilter:       filter,
-			ResultPageRequest: resultPage,
-		}
-
-		// Fetch events
-		eventsResponse, err := provider.Events(context.Background(), Input)
-		if err != nil {
-			return nil, fmt.Errorf("error fetching events: %w", err)
-		}
-
-		// Dereference eventsResponse and append the fetched events to the list
-		if eventsResponse != nil {
-			allEvents = append(allEvents, *eventsResponse)
-
-		} else {
-			return nil, fmt.Errorf("received nil eventsResponse")
-		}
-
-		// Check if there's a continuation token for the next pages
-		if eventsResponse.ContinuationToken == "" {
-			break // No more pages, exit the loop
-		}
-
-		// Update the result page with the continuation token for the next request
-		resultPage.ContinuationToken = eventsResponse.ContinuationToken
+func main() {
+	fromBlock := flag.String("from", "", "Starting block: "+backfill.BlockFlagUsage)
+	toBlock := flag.String("to", "", "Ending block: "+backfill.BlockFlagUsage)
+	fromDate := flag.String("from-date", "", "Starting date, instead of -from: "+backfill.DateFlagUsage)
+	toDate := flag.String("to-date", "", "Ending date, instead of -to: "+backfill.DateFlagUsage)
+	rpcURL := flag.String("rpc-url", "", "RPC URL of the blockchain node")
+	contracts := flag.String("contracts", "", "Comma separated contract addresses; events of every contract if empty")
+	keys := flag.String("keys", "", "Key filter: comma separated key positions, \"|\" separated alternatives, * for any value")
+	outputFile := flag.String("output", "events_filtered.csv", "Output file, .csv, .parquet, .jsonl or .jsonl.gz")
+	partitionBlocks := flag.Uint64("partition-blocks", 0, backfill.PartitionBlocksFlagUsage)
+	partitionBytes := flag.Int64("partition-bytes", 0, backfill.PartitionBytesFlagUsage)
+	chunkSize := flag.Int("chunk-size", importers.DefaultEventsChunkSize, "Number of events per request")
+	batchSize := flag.Uint64("batch-size", importers.DefaultEventsBlockRange, "Number of blocks per sub-range fetched concurrently; dense sub-ranges are split further")
+	workers := flag.Int("workers", importers.DefaultEventsWorkers, "Number of sub-ranges fetched concurrently per contract")
+
+	flag.Parse()
+	blockRange := backfill.BlockRangeFlags{FromBlock: *fromBlock, ToBlock: *toBlock, FromDate: *fromDate, ToDate: *toDate}
+
+	if !blockRange.Complete() || *rpcURL == "" {
+		fmt.Println("Missing required flags. Use --help for usage.")
+		return
 	}
 
-	return allEvents, nil
-}
-
-// ExportEventsToCSV exports a slice of events to a CSV file.
-func ExportEventsToCSV(events []rpc.EventChunk, filename string) error {
-	// Create a CSV file
-	file, err := os.Create(filename)
+	ctx := context.Background()
+	fromBlockNumber, toBlockNumber, err := backfill.ResolveBlockRange(ctx, backfill.Starknet, *rpcURL, blockRange)
 	if err != nil {
-		return fmt.Errorf("failed to create CSV file: %w", err)
-	}
-	defer file.Close()
-
-	// Create a CSV writer
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
-
-	// Write the header to the CSV file
-	header := []string{"Block Number", "Transaction Hash", "Event Index", "Data", "Keys"}
-	if err := writer.Write(header); err != nil {
-		return fmt.Errorf("failed to write header to CSV file: %w", err)
+		log.Fatalf("Error resolving block range: %v", err)
 	}
-
-	// Write the event data to the CSV file
-	for _, event := range events {
-		data := event.Events
-		for _, d := range data {
-			var keystore []string
-			for _, key := range d.Event.Keys {
-				keys := fmt.Sprintf("%s", key)
-				keystore = append(keystore, keys)
-			}
-			keustore := strings.Join(keystore, ",")
-			var datastore []string
-			for _, value := range d.Event.Data {
-				data := fmt.Sprintf("%s", value)
-				datastore = append(datastore, data)
-			}
-			dayastore := strings.Join(datastore, ",")
-
-			record := []string{
-				fmt.Sprintf("%d", d.BlockNumber),
-				fmt.Sprintf("%d", d.TransactionHash),
-				fmt.Sprintf("%d", d.BlockHash),
-				fmt.Sprintf("%d", d.Event.FromAddress),
-				string(keustore),
-				string(dayastore),
-			}
-
-			if err := writer.Write(record); err != nil {
-				return fmt.Errorf("failed to write record to CSV file: %w", err)
-			}
-		}
-
-	}
-	return nil
-}
-
-func filterEventsByContractAddress(providername string, contractAddress string, FromBlockNumber uint64, ToBlockNumber uint64, filename string) {
-	provider, err := rpc.NewProvider(providername)
+	keyFilter, err := backfill.ParseKeyFilter(*keys)
 	if err != nil {
-		log.Fatalf("Error creating provider: %v", err)
+		log.Fatalf("Error parsing key filter: %v", err)
 	}
-
-	blockId := rpc.BlockID{Number: &FromBlockNumber}
-	blockIdTill := rpc.BlockID{Number: &ToBlockNumber}
-
-	contract, err := utils.HexToFelt(contractAddress)
-
-	filter := rpc.EventFilter{
-		FromBlock: blockId,
-		ToBlock:   blockIdTill,
-		Address:   contract,
+	var addresses []string
+	if *contracts != "" {
+		addresses = strings.Split(*contracts, ",")
 	}
 
-	resultPage := rpc.ResultPageRequest{
-		ChunkSize: 100, // Fetch 100 events per request
+	filter := importers.EventFilter{
+		Addresses:  addresses,
+		Keys:       keyFilter,
+		ChunkSize:  *chunkSize,
+		BlockRange: *batchSize,
+		Workers:    *workers,
 	}
-
-	// Fetch all events
-	events, err := FetchEvents(provider, filter, resultPage)
+	partition := backfill.PartitionOptions{Blocks: *partitionBlocks, MaxBytes: *partitionBytes}
+	exporter, err := backfill.NewBackfillExporter(*outputFile, importers.EmittedEvent{}, fromBlockNumber, backfill.ExportOptions{}, partition)
 	if err != nil {
-		log.Fatalf("Error fetching events: %v", err)
+		log.Fatalf("Error creating exporter: %v", err)
 	}
-
-	// Export filtered events to CSV
-	err = ExportEventsToCSV(events, filename)
-	if err != nil {
-		log.Fatalf("Error exporting events to CSV: %v", err)
-	}
-
-}
-
-func filterEventsByHexKeyString(providername string, hexKeyString []string, FromBlockNumber uint64, ToBlockNumber uint64, filename string) {
-	provider, err := rpc.NewProvider(providername)
-	if err != nil {
-		log.Fatalf("Error creating provider: %v", err)
+	fromBlockNumber = backfill.ResumeBlock(fromBlockNumber, exporter)
+	// Each batch holds one sub-range per worker, so that every worker is busy.
+	starknetBackfill := &backfill.StarknetBackfill{
+		RPCURL:      *rpcURL,
+		Kinds:       []pipeline.Kind{pipeline.EmittedEvents},
+		EventFilter: &filter,
+		BatchSize:   *batchSize * uint64(max(*workers, 1)),
+		Sink:        pipeline.NewFileSink(map[pipeline.Kind]pipeline.RowWriter{pipeline.EmittedEvents: exporter}),
+		Detector:    importers.NewReorgDetector(*rpcURL, importers.NewMemoryBlockHashStore(), 0),
 	}
-
-	blockId := rpc.BlockID{Number: &FromBlockNumber}
-	blockIdTill := rpc.BlockID{Number: &ToBlockNumber}
-
-	var events_Key []*felt.Felt
-	for i := 0; i < len(hexKeyString); i++ {
-		hexKey, err := utils.HexToFelt(hexKeyString[i])
-		if err != nil {
-			log.Fatalf("Error converting hex to felt: %v", err)
-		}
-		events_Key = append(events_Key, hexKey)
-	}
-	var final_events [][]*felt.Felt
-	final_events = append(final_events, events_Key)
-
-	filter := rpc.EventFilter{
-		FromBlock: blockId,
-		ToBlock:   blockIdTill,
-		Keys:      final_events,
-	}
-
-	resultPage := rpc.ResultPageRequest{
-		ChunkSize: 100, // Fetch 100 events per request
+	if err := starknetBackfill.Run(ctx, fromBlockNumber, toBlockNumber); err != nil {
+		log.Fatalf("Error filtering events: %v", err)
 	}
-
-	// Fetch all events
-	events, err := FetchEvents(provider, filter, resultPage)
-	if err != nil {
-		log.Fatalf("Error fetching events: %v", err)
-	}
-
-	// Export filtered events to CSV
-	err = ExportEventsToCSV(events, filename)
-	if err != nil {
-		log.Fatalf("Error exporting events to CSV: %v", err)
-	}
-}
-
-func main() {
-	provider := "https://rpc.nethermind.io/mainnet-juno?x-apikey=MIkLH4AOTdTH9uqu8PqvSHUBNnAnMU1fXdROa3qc1DsSVxvOcGRrwr6kSj1zsNjT"
-	// Define the block numbers
-	FromBlockNumber := uint64(691575) // Starting block number
-	ToBlockNumber := uint64(691600)
-
-	hexstring := []string{"0x99cd8bde557814842a3121e8ddfd433a539b8c9f14bf31ebf108d12e6196e9"}
-	filterEventsByHexKeyString(provider, hexstring, FromBlockNumber, ToBlockNumber, "events_filtered_1.csv")
-
+	fmt.Printf("Filtered events written to %s\n", *outputFile)
 }
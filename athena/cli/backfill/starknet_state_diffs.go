@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/BlocSoc-iitr/Athena/athena/backfill"
+	"github.com/BlocSoc-iitr/Athena/athena/backfill/importers"
+	"github.com/BlocSoc-iitr/Athena/athena/database"
+	"github.com/BlocSoc-iitr/Athena/athena/database/models"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"log"
+	"path/filepath"
+)
+
+func main() {
+	fromBlock := flag.String("from", "", "Starting block: "+backfill.BlockFlagUsage)
+	toBlock := flag.String("to", "", "Ending block: "+backfill.BlockFlagUsage)
+	fromDate := flag.String("from-date", "", "Starting date, instead of -from: "+backfill.DateFlagUsage)
+	toDate := flag.String("to-date", "", "Ending date, instead of -to: "+backfill.DateFlagUsage)
+	rpcURL := flag.String("rpc-url", "", "RPC URL of the blockchain node")
+	outputDir := flag.String("output-dir", ".", "Directory the state diff files are written to, one file per table")
+	format := flag.String("format", "csv", "Format of the state diff files: csv, parquet, jsonl or jsonl.gz")
+	dbURL := flag.String("db-url", "", "Database DSN; state diffs are written to the database instead of the output files")
+	batchSize := flag.Int("batch-size", 500, "Number of rows per database insert")
+	partitionBlocks := flag.Uint64("partition-blocks", 0, backfill.PartitionBlocksFlagUsage)
+	partitionBytes := flag.Int64("partition-bytes", 0, backfill.PartitionBytesFlagUsage)
+
+	flag.Parse()
+	blockRange := backfill.BlockRangeFlags{FromBlock: *fromBlock, ToBlock: *toBlock, FromDate: *fromDate, ToDate: *toDate}
+
+	if !blockRange.Complete() || *rpcURL == "" {
+		fmt.Println("Missing required flags. Use --help for usage.")
+		return
+	}
+
+	fromBlockNumber, toBlockNumber, err := backfill.ResolveBlockRange(context.Background(), backfill.Starknet, *rpcURL, blockRange)
+	if err != nil {
+		log.Fatalf("Error resolving block range: %v", err)
+	}
+
+	starknetBackfill := &backfill.StarknetBackfill{RPCURL: *rpcURL}
+	kwargs := make(map[string]interface{})
+	store := importers.BlockHashStore(importers.NewMemoryBlockHashStore())
+	if *dbURL != "" {
+		db, err := gorm.Open(mysql.Open(*dbURL), &gorm.Config{})
+		if err != nil {
+			log.Fatalf("Failed to connect to the database: %v", err)
+		}
+		database.MigrateUp(db)
+		store = importers.NewDBBlockHashStore(db)
+		kwargs["export_mode"] = backfill.DBModels
+		kwargs["db"] = db
+		kwargs["backfill_id"] = fmt.Sprintf("starknet-state-diffs-%d-%d", fromBlockNumber, toBlockNumber)
+		kwargs["db_batch_size"] = *batchSize
+	} else {
+		tableModels := map[string]interface{}{
+			"storage_diffs":      models.StorageDiff{},
+			"deployed_contracts": models.DeployedContract{},
+			"declared_classes":   models.DeclaredClass{},
+			"replaced_classes":   models.ReplacedClass{},
+			"nonce_updates":      models.NonceUpdate{},
+		}
+		partition := backfill.PartitionOptions{Blocks: *partitionBlocks, MaxBytes: *partitionBytes}
+		var files []backfill.ResourceExporter
+		for table, fileKey := range backfill.StateDiffFiles {
+			fileName := filepath.Join(*outputDir, table+"."+*format)
+			exporter, err := backfill.NewBackfillExporter(fileName, tableModels[table], fromBlockNumber, backfill.ExportOptions{}, partition)
+			if err != nil {
+				log.Fatalf("Error creating exporter: %v", err)
+			}
+			kwargs[fileKey] = exporter
+			files = append(files, exporter)
+		}
+		// Partitioned exports resume after the partitions completed by an earlier run.
+		fromBlockNumber = backfill.ResumeBlock(fromBlockNumber, files...)
+	}
+	starknetBackfill.Detector = importers.NewReorgDetector(*rpcURL, store, 0)
+	starknetBackfill.Sink, starknetBackfill.Kinds, err = backfill.NewSinkForBackfill(backfill.StateDiffs, kwargs)
+	if err != nil {
+		log.Fatalf("Error creating exporters: %v", err)
+	}
+
+	if err := starknetBackfill.Run(context.Background(), fromBlockNumber, toBlockNumber); err != nil {
+		log.Fatalf("Error importing state diffs: %v", err)
+	}
+	if *dbURL != "" {
+		fmt.Println("State diffs written to the database")
+		return
+	}
+	fmt.Printf("State diffs exported to %s\n", *outputDir)
+}
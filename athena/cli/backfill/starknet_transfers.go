@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/BlocSoc-iitr/Athena/athena/backfill"
+	"github.com/BlocSoc-iitr/Athena/athena/backfill/importers"
+	"github.com/BlocSoc-iitr/Athena/athena/backfill/pipeline"
+	"github.com/BlocSoc-iitr/Athena/athena/database"
+	"github.com/BlocSoc-iitr/Athena/athena/database/models"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"log"
+	"strings"
+)
+
+func main() {
+	fromBlock := flag.String("from", "", "Starting block: "+backfill.BlockFlagUsage)
+	toBlock := flag.String("to", "", "Ending block: "+backfill.BlockFlagUsage)
+	fromDate := flag.String("from-date", "", "Starting date, instead of -from: "+backfill.DateFlagUsage)
+	toDate := flag.String("to-date", "", "Ending date, instead of -to: "+backfill.DateFlagUsage)
+	rpcURL := flag.String("rpc-url", "", "RPC URL of the blockchain node")
+	outputFile := flag.String("output", "transfers.csv", "Output file, .csv, .parquet, .jsonl or .jsonl.gz")
+	tokens := flag.String("tokens", "", "Comma separated token addresses to restrict the transfers to")
+	partitionBlocks := flag.Uint64("partition-blocks", 0, backfill.PartitionBlocksFlagUsage)
+	partitionBytes := flag.Int64("partition-bytes", 0, backfill.PartitionBytesFlagUsage)
+	dbURL := flag.String("db-url", "", "Database DSN; transfers are written to the database instead of the output file")
+	batchSize := flag.Int("batch-size", 500, "Number of rows per database insert")
+	follow := flag.Bool("follow", false, "Keep following new blocks after the range completes")
+	confirmations := flag.Uint64("confirmations", backfill.DefaultConfirmations, "Blocks to stay behind the chain head in follow mode")
+	pollInterval := flag.Duration("poll-interval", backfill.DefaultPollInterval, "Interval between chain head polls in follow mode")
+	wsURL := flag.String("ws-url", "", "Websocket RPC URL used to subscribe to new heads in follow mode")
+
+	flag.Parse()
+	blockRange := backfill.BlockRangeFlags{FromBlock: *fromBlock, ToBlock: *toBlock, FromDate: *fromDate, ToDate: *toDate}
+
+	if !blockRange.Complete() || *rpcURL == "" {
+		fmt.Println("Missing required flags. Use --help for usage.")
+		return
+	}
+
+	fromBlockNumber, toBlockNumber, err := backfill.ResolveBlockRange(context.Background(), backfill.Starknet, *rpcURL, blockRange)
+	if err != nil {
+		log.Fatalf("Error resolving block range: %v", err)
+	}
+
+	var tokenAddresses []string
+	if *tokens != "" {
+		tokenAddresses = strings.Split(*tokens, ",")
+	}
+
+	starknetBackfill := &backfill.StarknetBackfill{RPCURL: *rpcURL, Kinds: []pipeline.Kind{pipeline.Transfers}, Tokens: tokenAddresses}
+	kwargs := make(map[string]interface{})
+	store := importers.BlockHashStore(importers.NewMemoryBlockHashStore())
+	if *dbURL != "" {
+		db, err := gorm.Open(mysql.Open(*dbURL), &gorm.Config{})
+		if err != nil {
+			log.Fatalf("Failed to connect to the database: %v", err)
+		}
+		database.MigrateUp(db)
+		store = importers.NewDBBlockHashStore(db)
+		kwargs["export_mode"] = backfill.DBModels
+		kwargs["db"] = db
+		kwargs["backfill_id"] = fmt.Sprintf("starknet-transfers-%d-%d", fromBlockNumber, toBlockNumber)
+		kwargs["db_batch_size"] = *batchSize
+		if len(tokenAddresses) > 0 {
+			kwargs["filter_data"] = map[string]interface{}{"tokens": tokenAddresses}
+		}
+	} else {
+		partition := backfill.PartitionOptions{Blocks: *partitionBlocks, MaxBytes: *partitionBytes}
+		exporter, err := backfill.NewBackfillExporter(*outputFile, models.ERC20Transfer{}, fromBlockNumber, backfill.ExportOptions{}, partition)
+		if err != nil {
+			log.Fatalf("Error creating exporter: %v", err)
+		}
+		kwargs["transfer_file"] = exporter
+		fromBlockNumber = backfill.ResumeBlock(fromBlockNumber, exporter)
+	}
+	starknetBackfill.Detector = importers.NewReorgDetector(*rpcURL, store, 0)
+	starknetBackfill.Sink, _, err = backfill.NewSinkForBackfill(backfill.Transfers, kwargs)
+	if err != nil {
+		log.Fatalf("Error creating exporters: %v", err)
+	}
+
+	ctx := context.Background()
+	err = starknetBackfill.Import(ctx, fromBlockNumber, toBlockNumber)
+	if err == nil && *follow {
+		cfg := backfill.FollowConfig{
+			Network:       backfill.Starknet,
+			RPCURL:        *rpcURL,
+			WSURL:         *wsURL,
+			StartBlock:    toBlockNumber + 1,
+			Confirmations: *confirmations,
+			PollInterval:  *pollInterval,
+		}
+		err = starknetBackfill.Follow(ctx, cfg, backfill.New_Gracfull_Killer())
+	}
+	if err := errors.Join(err, starknetBackfill.Sink.Close()); err != nil {
+		log.Fatalf("Error importing transfers: %v", err)
+	}
+	if *dbURL != "" {
+		fmt.Println("Transfers written to the database")
+		return
+	}
+	fmt.Printf("Transfers exported to %s\n", *outputFile)
+}
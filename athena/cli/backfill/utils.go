@@ -2,6 +2,7 @@ package backfill
 
 // GetCommonFlags returns a set of common flags for CLI commands.
 import (
+	"github.com/BlocSoc-iitr/Athena/athena/backfill"
 	"github.com/urfave/cli/v2"
 )
 
@@ -17,29 +18,74 @@ func GetCommonFlags() []cli.Flag {
 			Usage:    "Database URL",
 			Required: false,
 		},
-		&cli.IntFlag{
+		&cli.StringFlag{
 			Name:     "from_block",
-			Usage:    "Start block number",
-			Required: true,
+			Usage:    "Start block: " + backfill.BlockFlagUsage,
+			Required: false,
 		},
-		&cli.IntFlag{
+		&cli.StringFlag{
 			Name:     "to_block",
-			Usage:    "End block number",
-			Required: true,
+			Usage:    "End block: " + backfill.BlockFlagUsage,
+			Required: false,
+		},
+		&cli.StringFlag{
+			Name:     "from_date",
+			Usage:    "Start date, instead of from_block: " + backfill.DateFlagUsage,
+			Required: false,
+		},
+		&cli.StringFlag{
+			Name:     "to_date",
+			Usage:    "End date, instead of to_block: " + backfill.DateFlagUsage,
+			Required: false,
 		},
 		&cli.StringFlag{
 			Name:     "block_file",
-			Usage:    "Path to the block file",
+			Usage:    "Path to the block file, .csv, .parquet, .jsonl or .jsonl.gz",
 			Required: false,
 		},
 		&cli.StringFlag{
 			Name:     "transaction_file",
-			Usage:    "Path to the transaction file",
+			Usage:    "Path to the transaction file, .csv, .parquet, .jsonl or .jsonl.gz",
 			Required: false,
 		},
 		&cli.StringFlag{
 			Name:     "event_file",
-			Usage:    "Path to the event file",
+			Usage:    "Path to the event file, .csv, .parquet, .jsonl or .jsonl.gz",
+			Required: false,
+		},
+		&cli.StringFlag{
+			Name:     "transfer_file",
+			Usage:    "Path to the ERC20 transfer file, .csv, .parquet, .jsonl or .jsonl.gz",
+			Required: false,
+		},
+		&cli.StringFlag{
+			Name:     "trace_file",
+			Usage:    "Path to the trace file, .csv, .parquet, .jsonl or .jsonl.gz",
+			Required: false,
+		},
+		&cli.StringFlag{
+			Name:     "storage_diff_file",
+			Usage:    "Path to the storage diff file, .csv, .parquet, .jsonl or .jsonl.gz",
+			Required: false,
+		},
+		&cli.StringFlag{
+			Name:     "deployed_contract_file",
+			Usage:    "Path to the deployed contract file, .csv, .parquet, .jsonl or .jsonl.gz",
+			Required: false,
+		},
+		&cli.StringFlag{
+			Name:     "declared_class_file",
+			Usage:    "Path to the declared class file, .csv, .parquet, .jsonl or .jsonl.gz",
+			Required: false,
+		},
+		&cli.StringFlag{
+			Name:     "replaced_class_file",
+			Usage:    "Path to the replaced class file, .csv, .parquet, .jsonl or .jsonl.gz",
+			Required: false,
+		},
+		&cli.StringFlag{
+			Name:     "nonce_update_file",
+			Usage:    "Path to the nonce update file, .csv, .parquet, .jsonl or .jsonl.gz",
 			Required: false,
 		},
 		&cli.BoolFlag{
@@ -57,6 +103,50 @@ func GetCommonFlags() []cli.Flag {
 			Usage:    "Skip user interaction",
 			Required: false,
 		},
+		&cli.BoolFlag{
+			Name:     "follow",
+			Usage:    "Keep following new blocks after to_block is reached",
+			Required: false,
+		},
+		&cli.IntFlag{
+			Name:     "confirmations",
+			Usage:    "Number of blocks to stay behind the chain head in follow mode",
+			Value:    backfill.DefaultConfirmations,
+			Required: false,
+		},
+		&cli.DurationFlag{
+			Name:     "poll_interval",
+			Usage:    "Interval between chain head polls in follow mode",
+			Value:    backfill.DefaultPollInterval,
+			Required: false,
+		},
+		&cli.StringFlag{
+			Name:     "ws_rpc",
+			Usage:    "Websocket RPC endpoint used to subscribe to new heads in follow mode",
+			Required: false,
+		},
+		&cli.StringFlag{
+			Name:     "compression",
+			Usage:    "Compression codec of .parquet files: snappy, zstd or none",
+			Value:    "snappy",
+			Required: false,
+		},
+		&cli.Int64Flag{
+			Name:     "row_group_size",
+			Usage:    "Maximum number of rows per row group of .parquet files",
+			Value:    backfill.DefaultParquetRowGroupSize,
+			Required: false,
+		},
+		&cli.Uint64Flag{
+			Name:     "partition_blocks",
+			Usage:    backfill.PartitionBlocksFlagUsage,
+			Required: false,
+		},
+		&cli.Int64Flag{
+			Name:     "partition_bytes",
+			Usage:    backfill.PartitionBytesFlagUsage,
+			Required: false,
+		},
 	}
 }
 func GetBackfillFlags(c *cli.Context) map[string]interface{} {
@@ -71,10 +161,16 @@ func GetBackfillFlags(c *cli.Context) map[string]interface{} {
 		flags["db_url"] = c.String("db_url")
 	}
 	if c.IsSet("from_block") {
-		flags["from_block"] = c.Int("from_block")
+		flags["from_block"] = c.String("from_block")
 	}
 	if c.IsSet("to_block") {
-		flags["to_block"] = c.Int("to_block")
+		flags["to_block"] = c.String("to_block")
+	}
+	if c.IsSet("from_date") {
+		flags["from_date"] = c.String("from_date")
+	}
+	if c.IsSet("to_date") {
+		flags["to_date"] = c.String("to_date")
 	}
 	if c.IsSet("block_file") {
 		flags["block_file"] = c.String("block_file")
@@ -85,6 +181,36 @@ func GetBackfillFlags(c *cli.Context) map[string]interface{} {
 	if c.IsSet("event_file") {
 		flags["event_file"] = c.String("event_file")
 	}
+	if c.IsSet("contract_address") {
+		flags["contract_address"] = c.String("contract_address")
+	}
+	if c.IsSet("event_name") {
+		flags["event_name"] = c.String("event_name")
+	}
+	if c.IsSet("batch_size") {
+		flags["batch_size"] = c.Int("batch_size")
+	}
+	if c.IsSet("transfer_file") {
+		flags["transfer_file"] = c.String("transfer_file")
+	}
+	if c.IsSet("trace_file") {
+		flags["trace_file"] = c.String("trace_file")
+	}
+	if c.IsSet("storage_diff_file") {
+		flags["storage_diff_file"] = c.String("storage_diff_file")
+	}
+	if c.IsSet("deployed_contract_file") {
+		flags["deployed_contract_file"] = c.String("deployed_contract_file")
+	}
+	if c.IsSet("declared_class_file") {
+		flags["declared_class_file"] = c.String("declared_class_file")
+	}
+	if c.IsSet("replaced_class_file") {
+		flags["replaced_class_file"] = c.String("replaced_class_file")
+	}
+	if c.IsSet("nonce_update_file") {
+		flags["nonce_update_file"] = c.String("nonce_update_file")
+	}
 	if c.IsSet("decode_abis") {
 		flags["decode_abis"] = c.Bool("decode_abis")
 	}
@@ -94,6 +220,18 @@ func GetBackfillFlags(c *cli.Context) map[string]interface{} {
 	if c.IsSet("no_interaction") {
 		flags["no_interaction"] = c.Bool("no_interaction")
 	}
+	if c.IsSet("follow") {
+		flags["follow"] = c.Bool("follow")
+	}
+	flags["confirmations"] = c.Int("confirmations")
+	flags["poll_interval"] = c.Duration("poll_interval")
+	if c.IsSet("ws_rpc") {
+		flags["ws_rpc"] = c.String("ws_rpc")
+	}
+	flags["compression"] = c.String("compression")
+	flags["row_group_size"] = c.Int64("row_group_size")
+	flags["partition_blocks"] = c.Uint64("partition_blocks")
+	flags["partition_bytes"] = c.Int64("partition_bytes")
 
 	// Add other flags as needed
 	// Example: flags["some_flag"] = c.String("some_flag")
@@ -109,17 +247,17 @@ func GetEventFlags() []cli.Flag {
 		},
 		&cli.StringFlag{
 			Name:     "event_name",
-			Usage:    "Name of the event",
+			Usage:    "Name of the event, resolved to its selector through the ABI of the contract",
 			Required: false,
 		},
 		&cli.IntFlag{
 			Name:     "batch_size",
-			Usage:    "Batch size for processing events",
+			Usage:    "Number of blocks per sub-range of events fetched concurrently",
 			Required: false,
 		},
 		&cli.StringFlag{
 			Name:     "event_file",
-			Usage:    "Path to the event file",
+			Usage:    "Path to the event file, .csv, .parquet, .jsonl or .jsonl.gz",
 			Required: false,
 		},
 		&cli.BoolFlag{
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/BlocSoc-iitr/Athena/athena/backfill"
+	"github.com/BlocSoc-iitr/Athena/athena/backfill/importers"
+	"github.com/BlocSoc-iitr/Athena/athena/backfill/pipeline"
+	"github.com/BlocSoc-iitr/Athena/athena/database"
+	"github.com/BlocSoc-iitr/Athena/athena/database/models"
+	"github.com/BlocSoc-iitr/Athena/athena/types"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+var abiDataSources = map[string]types.DataSources{
+	types.Etherscan.String(): types.Etherscan,
+	types.LocalFile.String(): types.LocalFile,
+}
+
+// zkSyncKinds are the kinds of rows imported for each backfill type. L2 to L1 logs are only
+// imported with full blocks.
+var zkSyncKinds = map[backfill.BackfillDataType][]pipeline.Kind{
+	backfill.FullBlocks:   {pipeline.ZkSyncBlocks, pipeline.ZkSyncTransactions, pipeline.ZkSyncLogs, pipeline.ZkSyncL2ToL1Logs},
+	backfill.Blocks:       {pipeline.ZkSyncBlocks},
+	backfill.Transactions: {pipeline.ZkSyncBlocks, pipeline.ZkSyncTransactions},
+	backfill.Events:       {pipeline.ZkSyncLogs},
+}
+
+// zkSyncDataTypes are the data types the backfilled ranges of each backfill type are recorded with.
+var zkSyncDataTypes = map[backfill.BackfillDataType]types.BackfillDataType{
+	backfill.FullBlocks:   types.FullBlocks,
+	backfill.Blocks:       types.Blocks,
+	backfill.Transactions: types.Transactions,
+	backfill.Events:       types.Events,
+}
+
+func main() {
+	defaultRPC, _ := backfill.Default_rpc(backfill.ZkSyncEra)
+
+	fromBlock := flag.String("from", "", "Starting block: "+backfill.BlockFlagUsage)
+	toBlock := flag.String("to", "", "Ending block: "+backfill.BlockFlagUsage)
+	fromDate := flag.String("from-date", "", "Starting date, instead of -from: "+backfill.DateFlagUsage)
+	toDate := flag.String("to-date", "", "Ending date, instead of -to: "+backfill.DateFlagUsage)
+	l1Batch := flag.Int64("l1-batch", -1, "Backfill the blocks of this L1 batch instead of -from and -to")
+	rpcURL := flag.String("rpc-url", defaultRPC, "RPC URL of the zkSync Era node")
+	dataType := flag.String("type", string(backfill.FullBlocks), "Data to backfill: full_blocks, blocks, transactions or events")
+	blockFile := flag.String("block-file", "blocks.csv", "Output file for blocks, .csv, .parquet, .jsonl or .jsonl.gz")
+	transactionFile := flag.String("transaction-file", "transactions.csv", "Output file for transactions, .csv, .parquet, .jsonl or .jsonl.gz")
+	eventFile := flag.String("event-file", "events.csv", "Output file for logs, .csv, .parquet, .jsonl or .jsonl.gz")
+	l2ToL1LogFile := flag.String("l2-to-l1-log-file", "l2_to_l1_logs.csv", "Output file for L2 to L1 logs of full blocks, .csv, .parquet, .jsonl or .jsonl.gz")
+	contracts := flag.String("contracts", "", "Comma separated contract addresses to restrict logs to")
+	topics := flag.String("topics", "", "Comma separated event signatures (topic 0) to restrict logs to")
+	logsRange := flag.Uint64("logs-range", importers.DefaultEthLogsBlockRange, "Blocks per eth_getLogs request before splitting")
+	abiSource := flag.String("abi-source", "", "Decode logs with ABIs from etherscan or local_file; logs are not decoded if empty")
+	abiDir := flag.String("abi-dir", "", "Directory of <address>.json ABI files for the local_file ABI source")
+	apiKey := flag.String("api-key", os.Getenv("ETHERSCAN_API_KEY"), "API key of the block explorer API")
+	dbURL := flag.String("db-url", "", "Database DSN; data is written to the database instead of the output files")
+	batchSize := flag.Int("batch-size", 500, "Number of rows per database insert")
+	chunkSize := flag.Uint64("chunk-size", pipeline.DefaultBatchSize, "Number of blocks fetched and written per chunk")
+	compression := flag.String("compression", "snappy", "Compression codec of .parquet files: snappy, zstd or none")
+	rowGroupSize := flag.Int64("row-group-size", backfill.DefaultParquetRowGroupSize, "Maximum number of rows per row group of .parquet files")
+	partitionBlocks := flag.Uint64("partition-blocks", 0, backfill.PartitionBlocksFlagUsage)
+	partitionBytes := flag.Int64("partition-bytes", 0, backfill.PartitionBytesFlagUsage)
+
+	flag.Parse()
+	blockRange := backfill.BlockRangeFlags{FromBlock: *fromBlock, ToBlock: *toBlock, FromDate: *fromDate, ToDate: *toDate}
+
+	ctx := context.Background()
+	var fromBlockNumber, toBlockNumber uint64
+	var err error
+	switch {
+	case *l1Batch >= 0:
+		fromBlockNumber, toBlockNumber, err = importers.GetZkSyncL1BatchBlockRange(ctx, *rpcURL, uint64(*l1Batch))
+		if err != nil {
+			log.Fatalf("Error getting blocks of L1 batch: %v", err)
+		}
+	case !blockRange.Complete() || *rpcURL == "":
+		fmt.Println("Missing required flags. Use --help for usage.")
+		return
+	default:
+		fromBlockNumber, toBlockNumber, err = backfill.ResolveBlockRange(ctx, backfill.ZkSyncEra, *rpcURL, blockRange)
+		if err != nil {
+			log.Fatalf("Error resolving block range: %v", err)
+		}
+	}
+
+	backfillType := backfill.BackfillDataType(*dataType)
+	kinds, ok := zkSyncKinds[backfillType]
+	if !ok {
+		log.Fatalf("Backfill type %s is not supported for zkSync Era", *dataType)
+	}
+	var filter importers.EthLogFilter
+	if *contracts != "" {
+		filter.Addresses = strings.Split(*contracts, ",")
+	}
+	if *topics != "" {
+		filter.Topics = [][]string{strings.Split(*topics, ",")}
+	}
+	filter.BlockRange = *logsRange
+
+	var db *gorm.DB
+	var sink pipeline.Sink
+	if *dbURL != "" {
+		db, err = gorm.Open(mysql.Open(*dbURL), &gorm.Config{})
+		if err != nil {
+			log.Fatalf("Failed to connect to the database: %v", err)
+		}
+		database.MigrateUp(db)
+		backfillID := fmt.Sprintf("zksync-%s-%d-%d", backfillType, fromBlockNumber, toBlockNumber)
+		exporter := backfill.NewDBResourceExporter(db, backfillID, zkSyncDataTypes[backfillType], types.ZkSyncEra, *batchSize)
+		if backfillType == backfill.Events && (filter.Addresses != nil || filter.Topics != nil) {
+			exporter.SetFilterData(map[string]interface{}{"contracts": filter.Addresses, "topics": filter.Topics})
+		}
+		sink = exporter
+	} else {
+		files := map[pipeline.Kind]string{
+			pipeline.ZkSyncBlocks:       *blockFile,
+			pipeline.ZkSyncTransactions: *transactionFile,
+			pipeline.ZkSyncLogs:         *eventFile,
+			pipeline.ZkSyncL2ToL1Logs:   *l2ToL1LogFile,
+		}
+		fileModels := map[pipeline.Kind]interface{}{
+			pipeline.ZkSyncBlocks:       models.ZkSyncBlock{},
+			pipeline.ZkSyncTransactions: models.ZkSyncTransaction{},
+			pipeline.ZkSyncLogs:         models.ZkSyncLog{},
+			pipeline.ZkSyncL2ToL1Logs:   models.ZkSyncL2ToL1Log{},
+		}
+		options := backfill.ExportOptions{Compression: *compression, RowGroupSize: *rowGroupSize}
+		partition := backfill.PartitionOptions{Blocks: *partitionBlocks, MaxBytes: *partitionBytes}
+		writers := make(map[pipeline.Kind]pipeline.RowWriter, len(kinds))
+		var exporters []backfill.ResourceExporter
+		for _, kind := range kinds {
+			exporter, err := backfill.NewBackfillExporter(files[kind], fileModels[kind], fromBlockNumber, options, partition)
+			if err != nil {
+				log.Fatalf("Error creating exporter: %v", err)
+			}
+			writers[kind] = exporter
+			exporters = append(exporters, exporter)
+		}
+		// Partitioned exports resume after the partitions completed by an earlier run.
+		fromBlockNumber = backfill.ResumeBlock(fromBlockNumber, exporters...)
+		sink = pipeline.NewFileSink(writers)
+	}
+
+	source := pipeline.NewBlockRangeSource("fetch", fromBlockNumber, toBlockNumber, *chunkSize, pipeline.ZkSyncFetcher(*rpcURL, filter, kinds...))
+	p := pipeline.New(source, pipeline.DefaultBufferSize)
+	if *abiSource != "" && backfillType != backfill.Blocks && backfillType != backfill.Transactions {
+		dataSource, ok := abiDataSources[*abiSource]
+		if !ok {
+			log.Fatalf("ABI source %s is not supported for zkSync Era", *abiSource)
+		}
+		abis, err := backfill.NewAbiSource(dataSource, backfill.ZkSyncEra, backfill.AbiSourceConfig{
+			APIKey: *apiKey,
+			Dir:    *abiDir,
+		})
+		if err != nil {
+			log.Fatalf("Error creating ABI source: %v", err)
+		}
+		if db != nil {
+			abis = importers.NewCachedAbiSource(abis, db)
+		}
+		p.Then(pipeline.DecodeEVMLogs(importers.NewEVMLogDecoder(abis)))
+	}
+	p.To(sink)
+
+	err = p.Run(ctx)
+	for _, metrics := range p.Metrics() {
+		fmt.Println(metrics)
+	}
+	if err != nil {
+		log.Fatalf("Error importing %s: %v", backfillType, err)
+	}
+	if db != nil {
+		fmt.Printf("Blocks %d to %d written to the database\n", fromBlockNumber, toBlockNumber)
+		return
+	}
+	fmt.Printf("Exported blocks %d to %d\n", fromBlockNumber, toBlockNumber)
+}
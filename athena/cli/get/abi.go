@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/BlocSoc-iitr/Athena/athena/backfill"
+	"github.com/BlocSoc-iitr/Athena/athena/backfill/importers"
+	"github.com/BlocSoc-iitr/Athena/athena/database"
+	"github.com/BlocSoc-iitr/Athena/athena/types"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+var dataSources = map[string]types.DataSources{
+	types.JSONRPC.String():   types.JSONRPC,
+	types.Etherscan.String(): types.Etherscan,
+	types.LocalFile.String(): types.LocalFile,
+}
+
+func main() {
+	identifier := flag.String("address", "", "Contract address, or class hash for Starknet JSON-RPC.")
+	network := flag.String("network", string(backfill.Starknet), "Network of the contract: starknet or ethereum.")
+	source := flag.String("source", types.JSONRPC.String(), "ABI source: json_rpc, etherscan or local_file.")
+	rpcURL := flag.String("rpc", "", "JSON-RPC URL for the json_rpc source.")
+	baseURL := flag.String("base-url", "", "Etherscan compatible API URL, e.g. a Blockscout instance.")
+	apiKey := flag.String("api-key", os.Getenv("ETHERSCAN_API_KEY"), "API key of the Etherscan compatible API.")
+	rateLimit := flag.Float64("rate-limit", backfill.DefaultEtherscanRequestsPerSecond, "Maximum API requests per second.")
+	dir := flag.String("dir", "", "Directory of <address>.json files for the local_file source.")
+	dbURL := flag.String("db-url", "", "Database DSN; fetched ABIs are cached in the contract_abis table.")
+	outputFile := flag.String("output", "abi.json", "The file to save the ABI JSON.")
+	flag.Parse()
+
+	dataSource, ok := dataSources[*source]
+	if *identifier == "" || !ok {
+		flag.Usage()
+		return
+	}
+
+	abiSource, err := backfill.NewAbiSource(dataSource, backfill.Network(*network), backfill.AbiSourceConfig{
+		RPCURL:            *rpcURL,
+		BaseURL:           *baseURL,
+		APIKey:            *apiKey,
+		RequestsPerSecond: *rateLimit,
+		Dir:               *dir,
+	})
+	if err != nil {
+		log.Fatalf("Error creating ABI source: %v", err)
+	}
+	if *dbURL != "" {
+		db, err := gorm.Open(mysql.Open(*dbURL), &gorm.Config{})
+		if err != nil {
+			log.Fatalf("Failed to connect to the database: %v", err)
+		}
+		database.MigrateUp(db)
+		abiSource = importers.NewCachedAbiSource(abiSource, db)
+	}
+
+	abi, err := abiSource.FetchAbi(context.Background(), *identifier)
+	if err != nil {
+		log.Fatalf("Error getting ABI: %v", err)
+	}
+	abiJSON, err := json.MarshalIndent(abi, "", "  ")
+	if err != nil {
+		log.Fatalf("Error serializing ABI: %v", err)
+	}
+	if err := os.WriteFile(*outputFile, abiJSON, 0644); err != nil {
+		log.Fatalf("Error saving ABI: %v", err)
+	}
+	fmt.Printf("ABI saved to %s\n", *outputFile)
+}
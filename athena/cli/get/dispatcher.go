@@ -1,49 +1,40 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"log"
 	"os"
 	"strings"
+
+	"github.com/BlocSoc-iitr/Athena/athena/backfill"
+	"github.com/BlocSoc-iitr/Athena/athena/backfill/importers"
+	"github.com/BlocSoc-iitr/Athena/athena/database"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
 )
 
 // EventData represents the structure of the JSON output for the event
 type EventData struct {
-	EventName       string `json:"event_name"`
-	ContractAddress string `json:"contract_address"`
-	Decoded         string `json:"decoded"`
-}
-
-// DecodingDispatcher is a placeholder struct to match the method signature
-type DecodingDispatcher struct{}
-
-func (d *DecodingDispatcher) GetEventData(eventName, contractAddress string, fromBlock, toBlock uint64) (string, error) {
-	var eventData []EventData
-	if fromBlock <= 691640 && toBlock >= 691640 {
-		eventData = append(eventData, EventData{
-			EventName:       "TransactionExecuted",
-			ContractAddress: contractAddress,
-			Decoded:         `{"hash": "0x4dbba113a0c5be91b7fb28fc91d5bae0b870fd01a1d68d5712d07e60f01348", "response": [["0x01"]]}`,
-		})
-	}
-	if fromBlock <= 691101 && toBlock >= 691101 {
-		eventData = append(eventData, EventData{
-			EventName:       "TransactionExecuted",
-			ContractAddress: contractAddress,
-			Decoded:         `{"hash": "0x64773ea2547faad3a4793786966a38c21574db08e00929c3dd8989fddffb873", "response": [["0x01"]]}`,
-		})
-	}
-	if len(eventData) == 0 {
-		return "", fmt.Errorf("no events found in the specified block range")
-	}
-	return prettyPrintEvents(eventData)
+	EventName       string                 `json:"event_name"`
+	ContractAddress string                 `json:"contract_address"`
+	BlockNumber     uint64                 `json:"block_number"`
+	TransactionHash string                 `json:"transaction_hash"`
+	Decoded         map[string]interface{} `json:"decoded"`
 }
 
-func prettyPrintEvents(events []EventData) (string, error) {
+func prettyPrintEvents(events []importers.NamedEvent) (string, error) {
 	var output []string
 	for _, event := range events {
-		eventJSON, err := json.MarshalIndent(event, "", "  ")
+		eventJSON, err := json.MarshalIndent(EventData{
+			EventName:       event.EventName,
+			ContractAddress: event.FromAddress,
+			BlockNumber:     event.BlockNumber,
+			TransactionHash: event.TransactionHash,
+			Decoded:         event.DecodedParams,
+		}, "", "  ")
 		if err != nil {
 			return "", fmt.Errorf("failed to marshal event: %v", err)
 		}
@@ -53,24 +44,76 @@ func prettyPrintEvents(events []EventData) (string, error) {
 }
 
 func main() {
-	eventName := flag.String("event", "", "Event name")
+	defaultRPC, _ := backfill.Default_rpc(backfill.Starknet)
+
+	eventName := flag.String("event", "", "Event name, variant path such as ERC20Event::Transfer, or full event type name")
 	contractAddress := flag.String("contract", "", "Contract address")
-	fromBlock := flag.Uint64("from", 0, "From block")
-	toBlock := flag.Uint64("to", 0, "To block")
+	fromBlock := flag.String("from", "", "From block: "+backfill.BlockFlagUsage)
+	toBlock := flag.String("to", "", "To block: "+backfill.BlockFlagUsage)
+	fromDate := flag.String("from-date", "", "From date, instead of -from: "+backfill.DateFlagUsage)
+	toDate := flag.String("to-date", "", "To date, instead of -to: "+backfill.DateFlagUsage)
+	rpcURL := flag.String("rpc-url", defaultRPC, "RPC URL of the Starknet node")
+	dbURL := flag.String("db-url", "", "Database DSN of the contract_abis table; the local ABI cache is used if empty")
+	outputFile := flag.String("output", "", "Output file for the decoded events, .csv, .parquet, .jsonl or .jsonl.gz; they are printed if empty")
+	chunkSize := flag.Int("chunk-size", importers.DefaultEventsChunkSize, "Number of events per request")
 
 	flag.Parse()
+	blockRange := backfill.BlockRangeFlags{FromBlock: *fromBlock, ToBlock: *toBlock, FromDate: *fromDate, ToDate: *toDate}
 
-	if *eventName == "" || *contractAddress == "" || *fromBlock == 0 || *toBlock == 0 {
+	if *eventName == "" || *contractAddress == "" || !blockRange.Complete() {
 		fmt.Println("Usage: ./cli -event <event_name> -contract <contract_address> -from <from_block> -to <to_block>")
 		os.Exit(1)
 	}
 
-	dispatcher := &DecodingDispatcher{}
-	result, err := dispatcher.GetEventData(*eventName, *contractAddress, *fromBlock, *toBlock)
+	ctx := context.Background()
+	fromBlockNumber, toBlockNumber, err := backfill.ResolveBlockRange(ctx, backfill.Starknet, *rpcURL, blockRange)
+	if err != nil {
+		log.Fatalf("Error resolving block range: %v", err)
+	}
+
+	var db *gorm.DB
+	if *dbURL != "" {
+		db, err = gorm.Open(mysql.Open(*dbURL), &gorm.Config{})
+		if err != nil {
+			log.Fatalf("Failed to connect to the database: %v", err)
+		}
+		database.MigrateUp(db)
+	}
+
+	// Proxies resolve to the implementation that was active at each block.
+	var classStore importers.ClassHistoryStore
+	if db != nil {
+		classStore = importers.NewDBClassHistoryStore(db)
+	}
+	resolver := importers.NewImplementationResolver(importers.NewRPCClassHashResolver(*rpcURL))
+	resolver.LoadHistories(importers.NewImplementationLoader(*rpcURL, classStore), fromBlockNumber, toBlockNumber)
+
+	events, err := importers.GetEventsByName(ctx, *rpcURL, importers.NewAbiRegistry(db), resolver,
+		*contractAddress, *eventName, fromBlockNumber, toBlockNumber, *chunkSize)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
 
+	if *outputFile != "" {
+		exporter, err := backfill.NewResourceExporter(*outputFile, importers.NamedEvent{}, backfill.ExportOptions{})
+		if err != nil {
+			log.Fatalf("Error creating exporter: %v", err)
+		}
+		if err := exporter.Write(importers.NamedEventsToDicts(events)); err != nil {
+			log.Fatalf("Error exporting events: %v", err)
+		}
+		if err := exporter.Close(); err != nil {
+			log.Fatalf("Error exporting events: %v", err)
+		}
+		fmt.Printf("%d events exported to %s\n", len(events), *outputFile)
+		return
+	}
+
+	result, err := prettyPrintEvents(events)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
 	fmt.Println(result)
 }
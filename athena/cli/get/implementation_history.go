@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/BlocSoc-iitr/Athena/athena/backfill"
+	"github.com/BlocSoc-iitr/Athena/athena/backfill/importers"
+	"github.com/BlocSoc-iitr/Athena/athena/database"
+	"github.com/BlocSoc-iitr/Athena/athena/database/models"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+// isProxy fetches the ABI of the class of a contract and checks it for known proxy patterns.
+func isProxy(ctx context.Context, rpcURL string, contractAddress string, classHash string, blockNumber uint64) (importers.ProxyInfo, bool, error) {
+	rawAbi, err := importers.FetchClassAbi(ctx, rpcURL, classHash, blockNumber)
+	if err != nil {
+		return importers.ProxyInfo{}, false, err
+	}
+	var abiJSON []map[string]interface{}
+	if err := json.Unmarshal(rawAbi, &abiJSON); err != nil {
+		return importers.ProxyInfo{}, false, fmt.Errorf("failed to unmarshal ABI of class %s: %v", classHash, err)
+	}
+	return importers.ProxyInfoAt(ctx, rpcURL, contractAddress, abiJSON, blockNumber)
+}
+
+func printPeriod(prefix string, period models.ContractClassHistory) {
+	to := "latest"
+	if period.ToBlock.Valid {
+		to = fmt.Sprintf("%d", period.ToBlock.Int64)
+	}
+	fmt.Printf("%s%s from block %d to %s\n", prefix, period.ClassHash, period.FromBlock, to)
+}
+
+// get implementation-history: prints the classes of a contract over a block range, and the
+// implementations of the periods in which the class is a proxy. The contract_class_history index is
+// used when a database is given; the blocks missing from the index are resolved with a binary search
+// over starknet_getClassHashAt.
+func main() {
+	contractAddress := flag.String("contract", "", "Contract address to query.")
+	from := flag.String("from", "", "The block to start from: "+backfill.BlockFlagUsage+".")
+	to := flag.String("to", "", "The block to end at: "+backfill.BlockFlagUsage+".")
+	fromDate := flag.String("from-date", "", "The date to start from, instead of -from: "+backfill.DateFlagUsage+".")
+	toDate := flag.String("to-date", "", "The date to end at, instead of -to: "+backfill.DateFlagUsage+".")
+	rpcUrl := flag.String("rpc", "https://starknet-mainnet.public.blastapi.io", "RPC provider URL.")
+	dbURL := flag.String("db-url", "", "Database DSN of the class history index.")
+	buildIndex := flag.Bool("index", false, "Index the state updates of the block range before querying.")
+	indexChunkSize := flag.Uint64("index-chunk-size", importers.DefaultClassHistoryChunkSize, "Number of blocks whose state updates are indexed at once, with -index.")
+	flag.Parse()
+	blockRange := backfill.BlockRangeFlags{FromBlock: *from, ToBlock: *to, FromDate: *fromDate, ToDate: *toDate}
+
+	if *contractAddress == "" || !blockRange.Complete() {
+		log.Fatalf("Please provide valid contract address, fromBlock, and toBlock.")
+	}
+
+	ctx := context.Background()
+	fromBlock, toBlock, err := backfill.ResolveBlockRange(ctx, backfill.Starknet, *rpcUrl, blockRange)
+	if err != nil {
+		log.Fatalf("Error resolving block range: %v", err)
+	}
+	if fromBlock > toBlock {
+		fromBlock, toBlock = toBlock, fromBlock
+	}
+	var store importers.ClassHistoryStore = importers.NewMemoryClassHistoryStore()
+	if *dbURL != "" {
+		db, err := gorm.Open(mysql.Open(*dbURL), &gorm.Config{})
+		if err != nil {
+			log.Fatalf("Failed to connect to the database: %v", err)
+		}
+		database.MigrateUp(db)
+		store = importers.NewDBClassHistoryStore(db)
+	}
+
+	if *buildIndex {
+		if err := importers.IndexClassHistory(ctx, *rpcUrl, store, fromBlock, toBlock, *indexChunkSize); err != nil {
+			log.Fatalf("Error indexing class history: %v", err)
+		}
+	}
+
+	resolver := importers.ClassHashResolverFunc(func(ctx context.Context, contractAddress string, blockNumber uint64) (string, error) {
+		return importers.GetClassHashAt(ctx, *rpcUrl, contractAddress, blockNumber)
+	})
+	history, err := importers.ImplementationHistory(ctx, store, resolver, *contractAddress, fromBlock, toBlock)
+	if err != nil {
+		log.Fatalf("Error fetching implementation history: %v", err)
+	}
+
+	if len(history) == 0 {
+		fmt.Printf("Contract %s is not deployed between blocks %d and %d\n", *contractAddress, fromBlock, toBlock)
+		return
+	}
+	for _, period := range history {
+		printPeriod("Class hash: ", period)
+
+		info, proxy, err := isProxy(ctx, *rpcUrl, *contractAddress, period.ClassHash, period.FromBlock)
+		if err != nil {
+			log.Fatalf("Error detecting proxy: %v", err)
+		}
+		if !proxy {
+			continue
+		}
+		periodTo := toBlock
+		if period.ToBlock.Valid {
+			periodTo = uint64(period.ToBlock.Int64)
+		}
+		implementations, err := importers.ProxyImplementationHistory(ctx, *rpcUrl, *contractAddress, info, period.FromBlock, periodTo)
+		if err != nil {
+			log.Fatalf("Error fetching proxy implementation history: %v", err)
+		}
+		for _, implementation := range implementations {
+			printPeriod("  Proxy implementation: ", implementation)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package database
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/BlocSoc-iitr/Athena/athena/database/models"
+	"gorm.io/gorm"
+)
+
+// positionIndexes are the unique indexes on the position of events and transfers in their block.
+// Tables created before the indexes existed may hold the same event more than once, and
+// AutoMigrate cannot create the index until the duplicates are removed. Tables indexed before
+// the index names included the table have their legacy index renamed.
+var positionIndexes = []struct {
+	model       interface{}
+	index       string
+	legacyIndex string
+}{
+	{&models.DefaultEvent{}, "idx_default_events_event_position", "idx_event_position"},
+	{&models.EVMLog{}, "idx_evm_logs_event_position", "idx_event_position"},
+	{&models.ZkSyncLog{}, "idx_zksync_logs_event_position", "idx_event_position"},
+	{&models.ERC20Transfer{}, "idx_erc20_transfers_transfer_position", "idx_transfer_position"},
+}
+
+// positionColumns are the columns of the position indexes.
+var positionColumns = []string{"block_number", "transaction_index", "event_index"}
+
+// stateDiffIndexes are the unique indexes on the natural keys of the state diff tables, which were
+// written without them before, so re-imported state diffs may be stored more than once.
+var stateDiffIndexes = []struct {
+	model   interface{}
+	index   string
+	columns []string
+}{
+	{&models.StorageDiff{}, "idx_storage_diffs_state_diff_key", []string{"block_number", "contract_address", "storage_key"}},
+	{&models.DeployedContract{}, "idx_deployed_contracts_state_diff_key", []string{"block_number", "contract_address"}},
+	{&models.DeclaredClass{}, "idx_declared_classes_state_diff_key", []string{"block_number", "class_hash"}},
+	{&models.ReplacedClass{}, "idx_replaced_classes_state_diff_key", []string{"block_number", "contract_address"}},
+	{&models.NonceUpdate{}, "idx_nonce_updates_state_diff_key", []string{"block_number", "contract_address"}},
+}
+
+// DedupePositions keeps a single row for every block_number, transaction_index and event_index
+// of the event and transfer tables that do not have their position index yet. It returns the
+// number of rows removed. Tables that already have the index are not read.
+func DedupePositions(db *gorm.DB) (int64, error) {
+	var removed int64
+	for _, table := range positionIndexes {
+		migrator := db.Migrator()
+		if !migrator.HasTable(table.model) || migrator.HasIndex(table.model, table.index) {
+			continue
+		}
+		if migrator.HasIndex(table.model, table.legacyIndex) {
+			if err := migrator.RenameIndex(table.model, table.legacyIndex, table.index); err != nil {
+				return removed, fmt.Errorf("failed to rename index %s of %T: %w", table.legacyIndex, table.model, err)
+			}
+			// The SQLite migrator renames an index by creating a copy.
+			if migrator.HasIndex(table.model, table.legacyIndex) {
+				if err := migrator.DropIndex(table.model, table.legacyIndex); err != nil {
+					return removed, fmt.Errorf("failed to drop index %s of %T: %w", table.legacyIndex, table.model, err)
+				}
+			}
+			continue
+		}
+
+		tableRemoved, err := dedupeRows(db, table.model, positionColumns)
+		removed += tableRemoved
+		if err != nil {
+			return removed, err
+		}
+	}
+	return removed, nil
+}
+
+// DedupeStateDiffs keeps a single row for every natural key of the state diff tables that do not
+// have their state_diff_key index yet. It returns the number of rows removed. Tables that already
+// have the index are not read.
+func DedupeStateDiffs(db *gorm.DB) (int64, error) {
+	var removed int64
+	for _, table := range stateDiffIndexes {
+		migrator := db.Migrator()
+		if !migrator.HasTable(table.model) || migrator.HasIndex(table.model, table.index) {
+			continue
+		}
+		tableRemoved, err := dedupeRows(db, table.model, table.columns)
+		removed += tableRemoved
+		if err != nil {
+			return removed, err
+		}
+	}
+	return removed, nil
+}
+
+// dedupeRows keeps a single row of the table of model for every value of columns. It returns the
+// number of rows removed.
+func dedupeRows(db *gorm.DB, model interface{}, columns []string) (int64, error) {
+	selected := strings.Join(columns, ", ")
+	var keys []map[string]interface{}
+	err := db.Model(model).
+		Select(selected).
+		Group(selected).
+		Having("COUNT(*) > 1").
+		Find(&keys).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to find duplicate rows of %T: %w", model, err)
+	}
+
+	var removed int64
+	for _, key := range keys {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			where := tx.Where(key).Session(&gorm.Session{})
+			row := reflect.New(reflect.TypeOf(model).Elem()).Interface()
+			if err := where.Take(row).Error; err != nil {
+				return err
+			}
+			result := where.Delete(model)
+			if result.Error != nil {
+				return result.Error
+			}
+			removed += result.RowsAffected - 1
+			return tx.Create(row).Error
+		})
+		if err != nil {
+			return removed, fmt.Errorf("failed to remove duplicate rows of %T at block %v: %w", model, key["block_number"], err)
+		}
+	}
+	return removed, nil
+}
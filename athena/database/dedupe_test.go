@@ -0,0 +1,95 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/BlocSoc-iitr/Athena/athena/database/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestDedupePositions(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Discard})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.DefaultEvent{}))
+	// Tables written before the position index existed.
+	require.NoError(t, db.Migrator().DropIndex(&models.DefaultEvent{}, "idx_default_events_event_position"))
+
+	event := func(blockNumber uint64, eventIndex int) models.DefaultEvent {
+		return models.DefaultEvent{AbstractEvent: models.AbstractEvent{BlockNumber: blockNumber, EventIndex: eventIndex, ContractAddress: "0x1"}}
+	}
+	rows := []models.DefaultEvent{event(1, 0), event(1, 0), event(1, 0), event(1, 1), event(2, 0), event(2, 0)}
+	require.NoError(t, db.Create(&rows).Error)
+
+	removed, err := DedupePositions(db)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), removed)
+
+	var count int64
+	require.NoError(t, db.Model(&models.DefaultEvent{}).Count(&count).Error)
+	assert.Equal(t, int64(3), count)
+	require.NoError(t, db.AutoMigrate(&models.DefaultEvent{}))
+
+	// Once the index exists the table is not read again.
+	removed, err = DedupePositions(db)
+	require.NoError(t, err)
+	assert.Zero(t, removed)
+}
+
+func TestPositionIndexNamesArePerTable(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Discard})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.DefaultEvent{}, &models.EVMLog{}, &models.ZkSyncLog{}, &models.ERC20Transfer{}))
+	for _, table := range positionIndexes {
+		assert.True(t, db.Migrator().HasIndex(table.model, table.index), table.index)
+	}
+}
+
+func TestDedupePositionsRenamesLegacyIndex(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Discard})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.DefaultEvent{}))
+	require.NoError(t, db.Migrator().RenameIndex(&models.DefaultEvent{}, "idx_default_events_event_position", "idx_event_position"))
+	require.NoError(t, db.Migrator().DropIndex(&models.DefaultEvent{}, "idx_default_events_event_position"))
+
+	_, err = DedupePositions(db)
+	require.NoError(t, err)
+	assert.True(t, db.Migrator().HasIndex(&models.DefaultEvent{}, "idx_default_events_event_position"))
+	assert.False(t, db.Migrator().HasIndex(&models.DefaultEvent{}, "idx_event_position"))
+}
+
+func TestDedupeStateDiffs(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Discard})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.StorageDiff{}, &models.DeployedContract{}, &models.DeclaredClass{}, &models.ReplacedClass{}, &models.NonceUpdate{}))
+	for _, table := range stateDiffIndexes {
+		assert.True(t, db.Migrator().HasIndex(table.model, table.index), table.index)
+	}
+	// Tables written before the state diff keys existed.
+	require.NoError(t, db.Migrator().DropIndex(&models.StorageDiff{}, "idx_storage_diffs_state_diff_key"))
+	require.NoError(t, db.Migrator().DropIndex(&models.NonceUpdate{}, "idx_nonce_updates_state_diff_key"))
+
+	diffs := []models.StorageDiff{
+		{BlockNumber: 1, ContractAddress: "0xa", StorageKey: "0x1", StorageValue: "0x5"},
+		{BlockNumber: 1, ContractAddress: "0xa", StorageKey: "0x1", StorageValue: "0x5"},
+		{BlockNumber: 1, ContractAddress: "0xa", StorageKey: "0x2", StorageValue: "0x5"},
+		{BlockNumber: 2, ContractAddress: "0xa", StorageKey: "0x1", StorageValue: "0x6"},
+	}
+	require.NoError(t, db.Create(&diffs).Error)
+	nonces := []models.NonceUpdate{{BlockNumber: 1, ContractAddress: "0xa", Nonce: "0x1"}, {BlockNumber: 1, ContractAddress: "0xa", Nonce: "0x1"}}
+	require.NoError(t, db.Create(&nonces).Error)
+
+	removed, err := DedupeStateDiffs(db)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), removed)
+	require.NoError(t, db.AutoMigrate(&models.StorageDiff{}, &models.NonceUpdate{}))
+
+	var count int64
+	require.NoError(t, db.Model(&models.StorageDiff{}).Count(&count).Error)
+	assert.Equal(t, int64(3), count)
+	require.NoError(t, db.Model(&models.NonceUpdate{}).Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+}
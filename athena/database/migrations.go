@@ -11,12 +11,45 @@ func MigrateUp(db *gorm.DB) {
 	// Here you would import and migrate each model like below:
 	// This is equivalent to the Python imports in your code
 
-	err := db.AutoMigrate(
+	// The unique position indexes of events and transfers, and the unique keys of state diffs,
+	// cannot be created over duplicate rows.
+	removed, err := DedupePositions(db)
+	if err != nil {
+		log.Fatalf("failed to remove duplicate events: %v", err)
+	}
+	if removed > 0 {
+		log.Printf("Removed %d duplicate event and transfer rows", removed)
+	}
+	removed, err = DedupeStateDiffs(db)
+	if err != nil {
+		log.Fatalf("failed to remove duplicate state diffs: %v", err)
+	}
+	if removed > 0 {
+		log.Printf("Removed %d duplicate state diff rows", removed)
+	}
+
+	err = db.AutoMigrate(
 		&models.ContractABI{},
+		&models.QuarantinedABI{},
 		&models.BackfilledRange{},
 		&models.Block{},
 		&models.DefaultEvent{},
 		&models.Transaction{},
+		&models.ERC20Transfer{},
+		&models.Trace{},
+		&models.StorageDiff{},
+		&models.DeployedContract{},
+		&models.DeclaredClass{},
+		&models.ReplacedClass{},
+		&models.NonceUpdate{},
+		&models.ContractClassHistory{},
+		&models.EVMBlock{},
+		&models.EVMTransaction{},
+		&models.EVMLog{},
+		&models.ZkSyncBlock{},
+		&models.ZkSyncTransaction{},
+		&models.ZkSyncLog{},
+		&models.ZkSyncL2ToL1Log{},
 	)
 	if err != nil {
 		log.Fatalf("failed to migrate models: %v", err)
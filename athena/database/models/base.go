@@ -23,30 +23,34 @@ type AbstractTransaction struct {
 	GasUsed          *float64 `gorm:"column:gas_used;type:numeric;nullable:true"`
 }
 
+// AbstractEvent rows are unique by their position in the block, the key the DB exporter upserts on.
+// The index is named after the table, idx_<table>_event_position, since index names are global in some databases.
 type AbstractEvent struct {
-	BlockNumber      uint64 `gorm:"column:block_number;type:bigint;index"`
-	EventIndex       int    `gorm:"column:event_index;type:int"`
-	TransactionIndex int    `gorm:"column:transaction_index;type:int"`
-	ContractAddress  string `gorm:"column:contract_address;type:varchar(42);index"`
+	BlockNumber      uint64 `gorm:"column:block_number;type:bigint;index;uniqueIndex:,composite:event_position,priority:1"`
+	EventIndex       int    `gorm:"column:event_index;type:int;uniqueIndex:,composite:event_position,priority:3"`
+	TransactionIndex int    `gorm:"column:transaction_index;type:int;uniqueIndex:,composite:event_position,priority:2"`
+	ContractAddress  string `gorm:"column:contract_address;type:varchar(66);index"`
 }
 
 type AbstractTrace struct {
 	BlockNumber      uint64         `gorm:"column:block_number;type:bigint;index"`
-	TransactionHash  string         `gorm:"column:transaction_hash;type:text;index"`
+	TransactionHash  string         `gorm:"column:transaction_hash;type:varchar(66);index"`
 	TransactionIndex int            `gorm:"column:transaction_index;type:int"`
 	TraceAddress     datatypes.JSON `gorm:"column:trace_address;type:json"`
 	GasUsed          int64          `gorm:"column:gas_used;type:bigint"`
 	Error            string         `gorm:"column:error;type:text"`
 }
 
+// AbstractERC20Transfer rows are unique by the position of their Transfer event in the block, with the
+// index idx_<table>_transfer_position.
 type AbstractERC20Transfer struct {
-	BlockNumber      uint64 `gorm:"column:block_number;type:bigint;index"`
-	TransactionHash  string `gorm:"column:transaction_hash;type:text"`
-	TransactionIndex int    `gorm:"column:transaction_index;type:int"`
-	EventIndex       int    `gorm:"column:event_index;type:int"`
-
-	TokenAddress string   `gorm:"column:token_address;type:text;index"`
-	FromAddress  string   `gorm:"column:from_address;type:text;index"`
-	ToAddress    string   `gorm:"column:to_address;type:text;index"`
-	Value        *float64 `gorm:"column:value;type:numeric(78, 0)"`
+	BlockNumber      uint64 `gorm:"column:block_number;type:bigint;index;uniqueIndex:,composite:transfer_position,priority:1"`
+	TransactionHash  string `gorm:"column:transaction_hash;type:varchar(66)"`
+	TransactionIndex int    `gorm:"column:transaction_index;type:int;uniqueIndex:,composite:transfer_position,priority:2"`
+	EventIndex       int    `gorm:"column:event_index;type:int;uniqueIndex:,composite:transfer_position,priority:3"`
+
+	TokenAddress string `gorm:"column:token_address;type:varchar(66);index"`
+	FromAddress  string `gorm:"column:from_address;type:varchar(66);index"`
+	ToAddress    string `gorm:"column:to_address;type:varchar(66);index"`
+	Value        string `gorm:"column:value;type:numeric(78, 0)"` // decimal string, u256 values do not fit in a float64
 }
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"database/sql"
+)
+
+// Wei amounts are stored as decimal strings, they do not fit in a float64 without losing precision.
+// Amounts that older blocks and receipts do not have, such as the base fee before London, are NULL.
+
+type EVMBlock struct {
+	AbstractBlock
+	ParentHash       string         `gorm:"column:parent_hash;type:varchar(66);not null"`
+	StateRoot        string         `gorm:"column:state_root;type:varchar(66);not null"`
+	Miner            string         `gorm:"column:miner;type:varchar(42);not null"`
+	GasLimit         uint64         `gorm:"column:gas_limit;type:bigint;not null"`
+	GasUsed          uint64         `gorm:"column:gas_used;type:bigint;not null"`
+	BaseFeePerGas    sql.NullString `gorm:"column:base_fee_per_gas;type:numeric(78, 0)"`
+	BlobGasUsed      sql.NullInt64  `gorm:"column:blob_gas_used;type:bigint"`
+	ExcessBlobGas    sql.NullInt64  `gorm:"column:excess_blob_gas;type:bigint"`
+	Size             uint64         `gorm:"column:size;type:bigint"`
+	ExtraData        string         `gorm:"column:extra_data;type:text"`
+	TransactionCount int            `gorm:"column:transaction_count;type:int;not null"`
+}
+
+func (EVMBlock) TableName() string {
+	return "evm_blocks"
+}
+
+// EVMTransaction is a transaction joined with its receipt.
+type EVMTransaction struct {
+	AbstractTransaction
+	Type                 int            `gorm:"column:type;type:int;not null"`
+	FromAddress          string         `gorm:"column:from_address;type:varchar(42);index;not null"`
+	ToAddress            sql.NullString `gorm:"column:to_address;type:varchar(42);index"`
+	Value                string         `gorm:"column:value;type:numeric(78, 0);not null"`
+	Nonce                uint64         `gorm:"column:nonce;type:bigint;not null"`
+	Gas                  uint64         `gorm:"column:gas;type:bigint;not null"`
+	GasPrice             sql.NullString `gorm:"column:gas_price;type:numeric(78, 0)"`
+	MaxFeePerGas         sql.NullString `gorm:"column:max_fee_per_gas;type:numeric(78, 0)"`
+	MaxPriorityFeePerGas sql.NullString `gorm:"column:max_priority_fee_per_gas;type:numeric(78, 0)"`
+	Input                string         `gorm:"column:input;type:longtext"`
+	Selector             string         `gorm:"column:selector;type:varchar(10);index"`
+	Status               int            `gorm:"column:status;type:int"`
+	CumulativeGasUsed    uint64         `gorm:"column:cumulative_gas_used;type:bigint"`
+	EffectiveGasPrice    sql.NullString `gorm:"column:effective_gas_price;type:numeric(78, 0)"`
+	ContractAddress      sql.NullString `gorm:"column:contract_address;type:varchar(42);index"`
+}
+
+func (EVMTransaction) TableName() string {
+	return "evm_transactions"
+}
+
+type EVMLog struct {
+	AbstractEvent
+	TransactionHash string                 `gorm:"column:transaction_hash;type:varchar(66);index"`
+	BlockHash       string                 `gorm:"column:block_hash;type:varchar(66)"`
+	Topics          []string               `gorm:"column:topics;type:json;serializer:json;not null"`
+	Data            string                 `gorm:"column:data;type:longtext"`
+	Removed         bool                   `gorm:"column:removed"`
+	EventName       sql.NullString         `gorm:"column:event_name;type:varchar(255);index"`
+	DecodedParams   map[string]interface{} `gorm:"column:decoded_params;type:json;serializer:json"`
+}
+
+func (EVMLog) TableName() string {
+	return "evm_logs"
+}
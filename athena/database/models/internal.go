@@ -1,12 +1,14 @@
 package models
 
 import (
+	"time"
+
 	"github.com/BlocSoc-iitr/Athena/athena/types"
 )
 
 type ContractABI struct {
 	AbiName   string                   `gorm:"primaryKey;column:abi_name"`
-	AbiJson   []map[string]interface{} `gorm:"column:abi_json;type:json"`
+	AbiJson   []map[string]interface{} `gorm:"column:abi_json;type:json;serializer:json"`
 	Priority  int                      `gorm:"column:priority"`
 	DecoderOS string                   `gorm:"column:decoder_os"`
 }
@@ -15,15 +17,30 @@ func (ContractABI) TableName() string {
 	return "contract_abis"
 }
 
+// QuarantinedABI is a class ABI that could not be parsed. It is kept with the parse error so that
+// the backfill can continue and the ABI can be inspected or retried later.
+type QuarantinedABI struct {
+	ClassHash   string    `gorm:"primaryKey;column:class_hash;type:varchar(66)"`
+	BlockNumber uint64    `gorm:"column:block_number;type:bigint"`
+	AbiJson     string    `gorm:"column:abi_json;type:longtext"`
+	DecoderOS   string    `gorm:"column:decoder_os"`
+	Error       string    `gorm:"column:error;type:text"`
+	CreatedAt   time.Time `gorm:"column:created_at"`
+}
+
+func (QuarantinedABI) TableName() string {
+	return "quarantined_abis"
+}
+
 type BackfilledRange struct {
 	BackfillID   string                 `gorm:"primaryKey;column:backfill_id"`
 	DataType     types.BackfillDataType `gorm:"primaryKey;column:data_type"`
 	Network      types.SupportedNetwork `gorm:"primaryKey;column:network"`
 	StartBlock   int                    `gorm:"primaryKey;column:start_block"`
 	EndBlock     int                    `gorm:"primaryKey;column:end_block"`
-	FilterData   map[string]interface{} `gorm:"column:filter_data;type:json"`
-	MetadataDict map[string]interface{} `gorm:"column:metadata_dict;type:json"`
-	DecodedAbis  []string               `gorm:"column:decoded_abis;type:json"`
+	FilterData   map[string]interface{} `gorm:"column:filter_data;type:json;serializer:json"`
+	MetadataDict map[string]interface{} `gorm:"column:metadata_dict;type:json;serializer:json"`
+	DecodedAbis  []string               `gorm:"column:decoded_abis;type:json;serializer:json"`
 }
 
 func (BackfilledRange) TableName() string {
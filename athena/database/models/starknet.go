@@ -61,36 +61,99 @@ type Block struct {
 
 type DefaultEvent struct {
 	AbstractEvent
-	Keys          []string               `gorm:"column:keys;type:json;not null"`
-	Data          []string               `gorm:"column:data;type:json"`
+	Keys          []string               `gorm:"column:keys;type:json;serializer:json;not null"`
+	Data          []string               `gorm:"column:data;type:json;serializer:json"`
 	ClassHash     sql.NullString         `gorm:"column:class_hash;type:text"`
 	EventName     sql.NullString         `gorm:"column:event_name;type:varchar(255);index"`
-	DecodedParams map[string]interface{} `gorm:"column:decoded_params;type:json"`
+	DecodedParams map[string]interface{} `gorm:"column:decoded_params;type:json;serializer:json"`
 }
 
 type Transaction struct {
 	AbstractTransaction
 	Type                  StarknetTxType         `gorm:"column:type;type:varchar(20);not null"`
 	Nonce                 int                    `gorm:"column:nonce;type:int;not null"`
-	Signature             []string               `gorm:"column:signature;type:json;not null"`
+	Signature             []string               `gorm:"column:signature;type:json;serializer:json;not null"`
 	Version               int                    `gorm:"column:version;type:int;not null"`
 	Status                TransactionStatus      `gorm:"column:status;type:varchar(20);not null"`
 	MaxFee                float64                `gorm:"column:max_fee;type:numeric;not null"`
 	ActualFee             float64                `gorm:"column:actual_fee;type:numeric;not null"`
 	FeeUnit               StarknetFeeUnit        `gorm:"column:fee_unit;type:varchar(5);not null"`
-	ExecutionResources    map[string]interface{} `gorm:"column:execution_resources;type:json;not null"`
+	ExecutionResources    map[string]interface{} `gorm:"column:execution_resources;type:json;serializer:json;not null"`
 	Tip                   float64                `gorm:"column:tip;type:numeric"`
-	ResourceBounds        map[string]int         `gorm:"column:resource_bounds;type:json"`
-	PaymasterData         []string               `gorm:"column:paymaster_data;type:json"`
-	AccountDeploymentData []string               `gorm:"column:account_deployment_data;type:json"`
-	ContractAddress       sql.NullString         `gorm:"column:contract_address;type:varchar(42);index"`
+	ResourceBounds        map[string]string      `gorm:"column:resource_bounds;type:json;serializer:json"`
+	PaymasterData         []string               `gorm:"column:paymaster_data;type:json;serializer:json"`
+	AccountDeploymentData []string               `gorm:"column:account_deployment_data;type:json;serializer:json"`
+	ContractAddress       sql.NullString         `gorm:"column:contract_address;type:varchar(66);index"`
 	Selector              string                 `gorm:"column:selector;type:text;not null"`
-	Calldata              []string               `gorm:"column:calldata;type:json;not null"`
+	Calldata              []string               `gorm:"column:calldata;type:json;serializer:json;not null"`
 	ClassHash             sql.NullString         `gorm:"column:class_hash;type:varchar(100);index"`
-	UserOperations        []DecodedOperation     `gorm:"column:user_operations;type:json"`
+	UserOperations        []DecodedOperation     `gorm:"column:user_operations;type:json;serializer:json"`
 	RevertError           sql.NullString         `gorm:"column:revert_error;type:varchar(500);index"`
 }
 
 type ERC20Transfer struct {
 	AbstractERC20Transfer
 }
+
+// Trace is a single function invocation of a Starknet transaction trace.
+// TraceAddress is relative to the root invocation of the trace type, which has an empty address.
+type Trace struct {
+	AbstractTrace
+	TraceType          string                 `gorm:"column:trace_type;type:varchar(20);not null"`
+	CallType           string                 `gorm:"column:call_type;type:varchar(20)"`
+	EntryPointType     string                 `gorm:"column:entry_point_type;type:varchar(20)"`
+	ContractAddress    string                 `gorm:"column:contract_address;type:varchar(66);index"`
+	CallerAddress      string                 `gorm:"column:caller_address;type:varchar(66)"`
+	ClassHash          string                 `gorm:"column:class_hash;type:varchar(66)"`
+	Selector           string                 `gorm:"column:selector;type:varchar(66)"`
+	Calldata           []string               `gorm:"column:calldata;type:json;serializer:json"`
+	Result             []string               `gorm:"column:result;type:json;serializer:json"`
+	ExecutionResources map[string]interface{} `gorm:"column:execution_resources;type:json;serializer:json"`
+}
+
+// StorageDiff is a storage slot written by a block. The state diff tables have a unique
+// state_diff_key index on the natural key of their rows, which backfills upsert on.
+type StorageDiff struct {
+	BlockNumber     uint64 `gorm:"column:block_number;type:bigint;index;uniqueIndex:,composite:state_diff_key,priority:1"`
+	ContractAddress string `gorm:"column:contract_address;type:varchar(66);index;uniqueIndex:,composite:state_diff_key,priority:2"`
+	StorageKey      string `gorm:"column:storage_key;type:varchar(66);uniqueIndex:,composite:state_diff_key,priority:3"`
+	StorageValue    string `gorm:"column:storage_value;type:varchar(66)"`
+}
+
+type DeployedContract struct {
+	BlockNumber     uint64 `gorm:"column:block_number;type:bigint;index;uniqueIndex:,composite:state_diff_key,priority:1"`
+	ContractAddress string `gorm:"column:contract_address;type:varchar(66);index;uniqueIndex:,composite:state_diff_key,priority:2"`
+	ClassHash       string `gorm:"column:class_hash;type:varchar(66);index"`
+}
+
+// DeclaredClass is a class declared in a block. CompiledClassHash is null for Cairo 0 classes.
+type DeclaredClass struct {
+	BlockNumber       uint64         `gorm:"column:block_number;type:bigint;index;uniqueIndex:,composite:state_diff_key,priority:1"`
+	ClassHash         string         `gorm:"column:class_hash;type:varchar(66);index;uniqueIndex:,composite:state_diff_key,priority:2"`
+	CompiledClassHash sql.NullString `gorm:"column:compiled_class_hash;type:varchar(66)"`
+}
+
+type ReplacedClass struct {
+	BlockNumber     uint64 `gorm:"column:block_number;type:bigint;index;uniqueIndex:,composite:state_diff_key,priority:1"`
+	ContractAddress string `gorm:"column:contract_address;type:varchar(66);index;uniqueIndex:,composite:state_diff_key,priority:2"`
+	ClassHash       string `gorm:"column:class_hash;type:varchar(66);index"`
+}
+
+type NonceUpdate struct {
+	BlockNumber     uint64 `gorm:"column:block_number;type:bigint;index;uniqueIndex:,composite:state_diff_key,priority:1"`
+	ContractAddress string `gorm:"column:contract_address;type:varchar(66);index;uniqueIndex:,composite:state_diff_key,priority:2"`
+	Nonce           string `gorm:"column:nonce;type:varchar(66)"`
+}
+
+// ContractClassHistory is a period during which a contract had the given class.
+// ToBlock is null while the class is still the current class of the contract.
+type ContractClassHistory struct {
+	ContractAddress string        `gorm:"primaryKey;column:contract_address;type:varchar(66)"`
+	FromBlock       uint64        `gorm:"primaryKey;autoIncrement:false;column:from_block;type:bigint"`
+	ToBlock         sql.NullInt64 `gorm:"column:to_block;type:bigint"`
+	ClassHash       string        `gorm:"column:class_hash;type:varchar(66);index"`
+}
+
+func (ContractClassHistory) TableName() string {
+	return "contract_class_history"
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"database/sql"
+)
+
+// zkSync Era blocks and transactions are EVM rows tagged with the L1 batch that committed them.
+// The batch number stays NULL while the batch is not sealed.
+
+type ZkSyncBlock struct {
+	EVMBlock
+	L1BatchNumber    sql.NullInt64 `gorm:"column:l1_batch_number;type:bigint;index"`
+	L1BatchTimestamp sql.NullInt64 `gorm:"column:l1_batch_timestamp;type:bigint"`
+}
+
+func (ZkSyncBlock) TableName() string {
+	return "zksync_blocks"
+}
+
+type ZkSyncTransaction struct {
+	EVMTransaction
+	L1BatchNumber  sql.NullInt64 `gorm:"column:l1_batch_number;type:bigint;index"`
+	L1BatchTxIndex sql.NullInt64 `gorm:"column:l1_batch_tx_index;type:bigint"`
+}
+
+func (ZkSyncTransaction) TableName() string {
+	return "zksync_transactions"
+}
+
+type ZkSyncLog struct {
+	EVMLog
+}
+
+func (ZkSyncLog) TableName() string {
+	return "zksync_logs"
+}
+
+// ZkSyncL2ToL1Log is a message sent from L2 to L1, proven on L1 with the batch it belongs to.
+type ZkSyncL2ToL1Log struct {
+	BlockNumber         uint64        `gorm:"column:block_number;primaryKey"`
+	LogIndex            int           `gorm:"column:log_index;primaryKey"`
+	TransactionHash     string        `gorm:"column:transaction_hash;type:varchar(66);index"`
+	TransactionIndex    int           `gorm:"column:transaction_index;type:int"`
+	TransactionLogIndex int           `gorm:"column:transaction_log_index;type:int"`
+	L1BatchNumber       sql.NullInt64 `gorm:"column:l1_batch_number;type:bigint;index"`
+	ShardID             int           `gorm:"column:shard_id;type:int"`
+	IsService           bool          `gorm:"column:is_service"`
+	Sender              string        `gorm:"column:sender;type:varchar(42);index"`
+	Key                 string        `gorm:"column:key;type:varchar(66)"`
+	Value               string        `gorm:"column:value;type:varchar(66)"`
+}
+
+func (ZkSyncL2ToL1Log) TableName() string {
+	return "zksync_l2_to_l1_logs"
+}
@@ -110,13 +110,16 @@ func GetAbis(db *gorm.DB, abiNames []string, decoderOS string) []models.Contract
 	return contractABIs
 }
 
-func FirstBlockTimestamp(network types.SupportedNetwork) time.Time {
+// FirstBlockTimestamp returns the launch time of networks whose genesis block has no usable timestamp.
+// It returns false for networks, like zkSync Era, where the timestamp of block 0 can be used as is.
+func FirstBlockTimestamp(network types.SupportedNetwork) (time.Time, bool) {
 	switch network {
 	case types.StarkNet:
-		return time.Date(2021, 11, 16, 13, 24, 8, 0, time.UTC)
+		return time.Date(2021, 11, 16, 13, 24, 8, 0, time.UTC), true
+	case types.Ethereum:
+		return time.Date(2015, 7, 30, 15, 26, 13, 0, time.UTC), true
 	default:
-		log.Fatalf("Cannot fetch Initial Block Time for Network: %s", network)
-		return time.Time{}
+		return time.Time{}, false
 	}
 }
 
@@ -137,8 +140,9 @@ func GetBlockTimestamps(db *gorm.DB, network types.SupportedNetwork, resolution
 		for _, row := range results {
 			timestamp := time.Unix(row.Timestamp, 0).UTC()
 			if row.BlockNumber == 0 {
-				t := FirstBlockTimestamp(network)
-				timestamp = t
+				if t, ok := FirstBlockTimestamp(network); ok {
+					timestamp = t
+				}
 			}
 			blockTimestamps = append(blockTimestamps, types.BlockTimestamp{
 				BlockNumber: int(row.BlockNumber),
@@ -152,7 +156,7 @@ func GetBlockTimestamps(db *gorm.DB, network types.SupportedNetwork, resolution
 	if err != nil {
 		log.Fatalf("Error in getting block timestamps: %v", err)
 	}
-	appDir = filepath.Join(appDir, "entro")
+	appDir = filepath.Join(appDir, "athena")
 
 	if _, err := os.Stat(appDir); os.IsNotExist(err) {
 		err = os.MkdirAll(appDir, os.ModePerm)
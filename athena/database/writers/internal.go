@@ -166,7 +166,9 @@ func deleteABI(abiName string, db *gorm.DB, decoderOS types.SupportedNetwork) {
 	}
 }
 
-func writeBlockTimestamps(timestamps []types.BlockTimestamp, network types.SupportedNetwork) {
+// WriteBlockTimestamps adds timestamp samples to the cache file of a network, keeping the cached
+// samples of blocks already present.
+func WriteBlockTimestamps(timestamps []types.BlockTimestamp, network types.SupportedNetwork) {
 	appDir, err := os.UserConfigDir()
 	if err != nil {
 		logger.Errorf("Error getting config directory: %v", err)
@@ -22,6 +22,7 @@ const (
 	Prices
 	Events
 	Traces
+	StateDiffs
 )
 
 func (b BackfillDataType) String() string {
@@ -34,6 +35,7 @@ func (b BackfillDataType) String() string {
 		"Prices",
 		"Events",
 		"Traces",
+		"State Diffs",
 	}[b]
 }
 
@@ -54,12 +56,14 @@ type DataSources int
 const (
 	JSONRPC DataSources = iota
 	Etherscan
+	LocalFile
 )
 
 func (d DataSources) String() string {
 	return []string{
 		"json_rpc",
 		"etherscan",
+		"local_file",
 	}[d]
 }
 
@@ -93,6 +97,6 @@ func (s SupportedNetwork) Pretty() string {
 
 // BlockTimestamp is a struct that efficiently stores block timestamps.
 type BlockTimestamp struct {
-	BlockNumber int
-	Timestamp   time.Time
+	BlockNumber int       `json:"block_number"`
+	Timestamp   time.Time `json:"timestamp"`
 }
@@ -20,6 +20,21 @@ type DecodedEvent struct {
 	data    map[string]interface{}
 }
 
+// AbiName returns the name of the ABI the event was decoded with
+func (de *DecodedEvent) AbiName() string {
+	return de.abiName
+}
+
+// Name returns the name of the decoded event
+func (de *DecodedEvent) Name() string {
+	return de.name
+}
+
+// Data returns the decoded event parameters keyed by parameter name
+func (de *DecodedEvent) Data() map[string]interface{} {
+	return de.data
+}
+
 // class Representing an ABI Function.  Includes a function name, the function signature, and the input
 // and output parameters.
 type AbiFunction struct {
@@ -106,6 +121,24 @@ type AbiEvent struct {
 	data       map[string]StarknetType
 }
 
+// Name returns the name of the event
+func (ae AbiEvent) Name() string {
+	return ae.name
+}
+
+// Signature returns the event selector, the starknet keccak of the event name
+func (ae AbiEvent) Signature() []byte {
+	if ae.signature == nil {
+		return StarknetKeccak([]byte(ae.name))
+	}
+	return ae.signature
+}
+
+// Parameters returns the event parameter names in ABI order
+func (ae AbiEvent) Parameters() []string {
+	return ae.parameters
+}
+
 // keys can be either map[string]StarknetType or nil and abiName can be either string or Nil
 func NewAbiEvent(name string, parameters []string, data map[string]StarknetType, keys interface{}, abiName interface{}) *AbiEvent {
 	return &AbiEvent{